@@ -0,0 +1,137 @@
+// block_io.c — eBPF program for per-process block I/O bytes and latency.
+//
+// Attaches to two BTF-powered raw tracepoints on the block layer:
+//
+//  1. block_rq_issue fires when a request is handed to the device driver. It
+//     runs in the context of the task issuing the I/O, so the TGID, comm,
+//     direction (read/write), size, and issue time are stamped in rq_start,
+//     keyed by the struct request pointer.
+//
+//  2. block_rq_complete fires when the device finishes the request. It
+//     usually runs in softirq or interrupt context on whatever CPU took the
+//     interrupt, where the current task is unrelated to the I/O. The request
+//     pointer is the only reliable link back to the issuer: the stamp is
+//     looked up, the bytes and issue-to-completion latency are charged to the
+//     stamped TGID in io_stats, and the stamp is removed.
+//
+// The issuer is whoever dispatched the request to the driver. Reads are
+// usually dispatched by the reading process itself; buffered writes are
+// written back later by kworker/flush threads, so they are charged to those
+// rather than to the process that dirtied the pages. O_DIRECT and fsync'd
+// writes are charged to the writer.
+//
+// Only reads and writes are counted. Flushes, discards, and zeroing requests
+// carry no data transfer (or a nominal size that would swamp the byte counts)
+// and are ignored.
+//
+// Maps are read and cleared by the Go collector (pkg/collector/blockio) each tick.
+//
+// Requires: kernel ≥5.11 with BTF support (CONFIG_DEBUG_INFO_BTF=y), where
+// block_rq_issue takes the request as its only argument.
+
+#include "vmlinux.h"
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include "cgroup_filter.h"
+
+// The low bits of request->cmd_flags hold the operation (enum req_op).
+#define REQ_OP_BITS 8
+#define REQ_OP_MASK ((1 << REQ_OP_BITS) - 1)
+
+// An issued request that has not completed yet.
+struct rq_info {
+	u64 ts;    // ktime_ns at issue
+	u32 tgid;  // process that issued it
+	u32 bytes; // request size at issue
+	u32 write; // 1 for REQ_OP_WRITE, 0 for REQ_OP_READ
+	u32 _pad;
+	char comm[16];
+};
+
+// In-flight requests keyed by their struct request pointer. LRU so requests
+// that never complete (a device removed mid-flight, a missed completion
+// while the program was loading) age out instead of filling the map.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 16384);
+	__type(key, u64);
+	__type(value, struct rq_info);
+} rq_start SEC(".maps");
+
+// Per-process (TGID) block I/O completed in the current window.
+// Layout must match the Go ioStat struct in collector_linux.go exactly.
+struct io_stat {
+	u64 read_bytes;
+	u64 write_bytes;
+	u64 requests;   // completed read and write requests
+	u64 latency_ns; // summed issue-to-completion time of those requests
+	char comm[16];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 4096);
+	__type(key, u32);
+	__type(value, struct io_stat);
+} io_stats SEC(".maps");
+
+SEC("tp_btf/block_rq_issue")
+int BPF_PROG(handle_block_rq_issue, struct request *rq) {
+	u32 op = BPF_CORE_READ(rq, cmd_flags) & REQ_OP_MASK;
+	if (op != REQ_OP_READ && op != REQ_OP_WRITE)
+		return 0;
+	// Completion runs in interrupt context, so the issuer is filtered here;
+	// a request without a stamp is never charged.
+	if (!in_target_cgroup())
+		return 0;
+
+	struct rq_info info = {};
+	info.ts = bpf_ktime_get_ns();
+	info.tgid = bpf_get_current_pid_tgid() >> 32;
+	info.bytes = BPF_CORE_READ(rq, __data_len);
+	info.write = op == REQ_OP_WRITE;
+	bpf_get_current_comm(&info.comm, sizeof(info.comm));
+
+	// A requeued request is issued again; the new stamp replaces the old
+	// one so only the final attempt's latency is counted.
+	u64 key = (u64)rq;
+	bpf_map_update_elem(&rq_start, &key, &info, BPF_ANY);
+	return 0;
+}
+
+SEC("tp_btf/block_rq_complete")
+int BPF_PROG(handle_block_rq_complete, struct request *rq, blk_status_t error,
+	     unsigned int nr_bytes) {
+	u64 key = (u64)rq;
+	struct rq_info *info = bpf_map_lookup_elem(&rq_start, &key);
+	if (!info)
+		return 0; // issued before the program was attached, or not a read/write
+
+	u64 now = bpf_ktime_get_ns();
+	u64 latency = now > info->ts ? now - info->ts : 0;
+	u32 tgid = info->tgid;
+
+	struct io_stat *st = bpf_map_lookup_elem(&io_stats, &tgid);
+	if (!st) {
+		struct io_stat zero = {};
+		__builtin_memcpy(zero.comm, info->comm, sizeof(zero.comm));
+		bpf_map_update_elem(&io_stats, &tgid, &zero, BPF_NOEXIST);
+		st = bpf_map_lookup_elem(&io_stats, &tgid);
+		if (!st)
+			goto out;
+	}
+	if (info->write)
+		__sync_fetch_and_add(&st->write_bytes, info->bytes);
+	else
+		__sync_fetch_and_add(&st->read_bytes, info->bytes);
+	__sync_fetch_and_add(&st->requests, 1);
+	__sync_fetch_and_add(&st->latency_ns, latency);
+
+out:
+	bpf_map_delete_elem(&rq_start, &key);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -0,0 +1,36 @@
+// cgroup_filter.h — optional in-kernel scoping to one cgroup v2 subtree.
+//
+// With -cgroup-path the Go side (pkg/collector/cgroupfilter) sets
+// filter_cgroup and stores the cgroup's directory in slot 0 of
+// cgroup_filter before the program is loaded. in_target_cgroup() then
+// reports whether the current task is in that cgroup or one of its
+// descendants, matching -watch-cgroup; each hook returns before touching
+// its maps when it is not.
+//
+// bpf_current_task_under_cgroup only tests the current task, so a hook may
+// only filter with it where the task it charges is the one running. Hooks
+// that run on behalf of another task (block I/O completion, a wakeup) rely
+// on a stamp left by a hook that could filter instead.
+//
+// filter_cgroup is a load-time constant: left false, the verifier prunes
+// the check and the programs behave as before.
+//
+// Requires: cgroup v2; bpf_current_task_under_cgroup (Linux ≥4.9).
+
+#ifndef __CGROUP_FILTER_H
+#define __CGROUP_FILTER_H
+
+volatile const bool filter_cgroup = false;
+
+struct {
+	__uint(type, BPF_MAP_TYPE_CGROUP_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u32);
+} cgroup_filter SEC(".maps");
+
+static __always_inline bool in_target_cgroup(void) {
+	return !filter_cgroup || bpf_current_task_under_cgroup(&cgroup_filter, 0) == 1;
+}
+
+#endif
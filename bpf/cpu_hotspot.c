@@ -15,7 +15,43 @@
 //  2. Accumulate nanosecond-accurate CPU time for the outgoing process (TGID).
 //     Multiple threads of the same process contribute to a single pid_stats entry.
 //
-//  3. Snapshot the process name (comm) and cgroup leaf name for display in the TUI.
+//  3. Count switch-outs of the process in favour of a different TGID. Each one
+//     lets other work evict the process's cache lines, so a high rate relative
+//     to CPU time means the process rarely runs with a warm cache.
+//
+//     Every switch-out, to any task, is also counted as voluntary (the thread
+//     blocked) or involuntary (it was preempted, even just after setting a
+//     sleep state), like the nvcsw/nivcsw counters in /proc/PID/status but
+//     per window: the kernel counts a switch as voluntary only when it is
+//     not a preemption and the thread left TASK_RUNNING.
+//
+//  4. Snapshot the process name (comm) and cgroup v2 id for display in the
+//     TUI. Userspace resolves the id to a path (pkg/cgroup), so no string is
+//     copied here.
+//
+//  5. Measure off-CPU time: a thread that leaves the CPU blocked (sleeping on
+//     I/O, a lock, or a futex, rather than preempted while runnable) is stamped
+//     by TID, and the time until it is next switched in is charged to its
+//     process. A process stuck in D-state thus shows blocked time instead of
+//     looking idle.
+//
+//  6. Measure run-queue latency: a thread that becomes runnable — woken
+//     (sched_wakeup, sched_wakeup_new, attached separately) or preempted while
+//     still runnable — is stamped by TID, and the time until it is switched in
+//     is the scheduling delay charged to its process. Unlike a preemption
+//     count, this tells a process starved of CPU from one that is idle.
+//
+//  7. Optionally, push a spike event to a ring buffer when a thread's run
+//     since it was switched in (one scheduling slice) reached
+//     spike_threshold_ns. Spikes shorter than the sampling interval are
+//     invisible in the per-window totals; the events reach userspace as they
+//     happen. When the buffer is full the event is counted in spike_drops
+//     rather than waited for.
+//
+// With key_by_tid set (-threads), steps 2-5 key pid_stats and off_cpu_ns by
+// thread (TID) instead, each entry recording its TGID, so userspace can show
+// which thread of a process is busy. Contention and run-queue latency stay
+// per process.
 //
 // Maps are read and cleared by the Go collector (pkg/collector/cpu) each tick.
 //
@@ -27,10 +63,13 @@
 #include <bpf/bpf_tracing.h>
 #include <stdbool.h>
 
+#include "cgroup_filter.h"
+
 // Per-CPU scratch space: tracks which process (TGID) was last running and when.
 // Using a PERCPU_ARRAY with a single key avoids lock contention between CPUs.
 struct cpu_state {
 	u32 tgid; // TGID of the task that was last running on this CPU
+	u32 tid;  // and its TID, checked only with key_by_tid
 	u64 ts;   // ktime_ns timestamp when that task was switched in
 };
 
@@ -38,13 +77,35 @@ struct cpu_state {
 // window. When multiple threads of the same process run on different cores,
 // their CPU time is aggregated into a single entry keyed by the shared TGID.
 //
+// pid_stats is a PERCPU_HASH: each CPU adds to its own copy of the entry, so
+// the read-modify-write of cpu_time_ns and switches never races with another
+// core switching out a thread of the same process. With a shared HASH value,
+// two cores updating the same busy multi-threaded process at once could each
+// read the old total and one delta was lost. The Go collector sums the copies.
+//
 // cpu_id is the last CPU core observed at switch-out (not necessarily stable
 // for migratory workloads — it's a snapshot, not a primary-core assignment).
+// Each copy only knows its own core, so last_ns stamps the switch-out and
+// userspace takes cpu_id from the most recent copy.
+//
+// switches occupies what used to be explicit padding; cpu_perf.c keeps the
+// same layout and leaves it zero, as it does vol_switches and invol_switches.
+//
+// cgroup_id is bpf_get_current_cgroup_id(), the inode number of the cgroup
+// v2 directory; the Go side resolves it to a path.
+//
+// tgid is the entry's process. It equals the key unless key_by_tid is set,
+// when the key is a thread of it.
 struct pid_stat {
 	u64 cpu_time_ns;
 	char comm[16];
-	char cgroup[64];
+	u64 cgroup_id;
 	u32 cpu_id;
+	u32 switches; // switch-outs to a different TGID in the window
+	u64 last_ns;  // ktime_ns of the switch-out that set cpu_id
+	u32 vol_switches;   // switch-outs of threads that blocked
+	u32 invol_switches; // switch-outs of threads preempted, whatever their state
+	u32 tgid;
 	u32 _pad;
 };
 
@@ -56,7 +117,7 @@ struct {
 } cpu_state SEC(".maps");
 
 struct {
-	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(type, BPF_MAP_TYPE_PERCPU_HASH);
 	__uint(max_entries, 10240);   // plenty for MVP
 	__type(key, u32);
 	__type(value, struct pid_stat);
@@ -66,6 +127,8 @@ struct {
 // Records how many times one process's threads preempted another process's
 // threads within the window. Keyed by TGID so intra-process thread switches
 // are filtered out.
+// Neither TGID is ever 0 (the idle task); the Go side (packContentionKey,
+// unpackContentionKey in helpers.go) checks both halves.
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
 	__uint(max_entries, 2048);
@@ -73,55 +136,174 @@ struct {
 	__type(value, u64);
 } cpu_contention SEC(".maps");
 
-#ifndef bpf_get_current_task_btf
-#define bpf_get_current_task_btf() bpf_get_current_task()
-#endif
+// Blocked threads keyed by TID, valued by the ktime_ns they were switched out.
+// LRU so threads that exit while blocked age out.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 16384);
+	__type(key, u32);
+	__type(value, u64);
+} off_cpu_start SEC(".maps");
+
+// Off-CPU (blocked) nanoseconds per process (TGID), or per thread with
+// key_by_tid, in the current window.
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 10240);
+	__type(key, u32);
+	__type(value, u64);
+} off_cpu_ns SEC(".maps");
+
+// ktime_ns at which the current window began, written by the Go collector
+// on each reset. Blocked time from before it belongs to an earlier window.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u64);
+} window_start SEC(".maps");
+
+// Runnable threads keyed by TID: when they became runnable and their TGID,
+// so userspace can charge threads still waiting at snapshot time.
+struct runq_wait {
+	u64 ts;
+	u32 tgid;
+	u32 _pad;
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 16384);
+	__type(key, u32);
+	__type(value, struct runq_wait);
+} runq_start SEC(".maps");
+
+// Run-queue delay per process (TGID) for waits that ended in this window.
+// Threads of one process are switched in on several cores at once, so this is
+// a PERCPU_HASH: each core adds to its own copy and no read-modify-write races
+// with another. The Go collector sums the copies and keeps the largest max.
+// Layout must match the Go runqStat struct in collector_linux.go.
+struct runq_stat {
+	u64 total_ns; // summed across threads
+	u64 max_ns;   // longest single wait
+	u64 count;    // waits that ended with the thread being switched in
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+	__uint(max_entries, 10240);
+	__type(key, u32);
+	__type(value, struct runq_stat);
+} runq_latency SEC(".maps");
+
+// Minimum single-slice run time that emits a spike event, set by the Go
+// collector before loading. 0 leaves the spike path dead: the verifier
+// prunes it, so spike_events is never written.
+volatile const u64 spike_threshold_ns = 0;
+
+// Key pid_stats and off_cpu_ns by TID rather than TGID, set by the Go
+// collector before loading for -threads.
+volatile const bool key_by_tid = false;
+
+// One run of a thread that reached spike_threshold_ns.
+// Layout must match the Go spikeEvent struct in collector_linux.go.
+struct spike_event {
+	u64 ts;       // ktime_ns of the switch-out that ended the run
+	u64 slice_ns; // length of the run
+	u32 tgid;
+	u32 tid;
+	u32 cpu;
+	u32 _pad;
+	char comm[16];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_RINGBUF);
+	__uint(max_entries, 256 * 1024);
+} spike_events SEC(".maps");
+
+// Spike events lost because spike_events was full, per CPU.
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u64);
+} spike_drops SEC(".maps");
+
+// task_struct->state was renamed to __state (and narrowed) in 5.14.
+struct task_struct___pre514 {
+	long state;
+} __attribute__((preserve_access_index));
+
+#define TASK_RUNNING 0
+
+// task_state returns the scheduler state of task on either side of the rename.
+static __always_inline long task_state(struct task_struct *task) {
+	if (bpf_core_field_exists(task->__state))
+		return BPF_CORE_READ(task, __state);
+	return BPF_CORE_READ((struct task_struct___pre514 *)task, state);
+}
 
-// write_placeholder fills dst with "n/a" when cgroup resolution fails.
-static __always_inline void write_placeholder(char *dst, size_t len) {
-	if (!dst || len == 0)
+// stamp_runnable records that task became runnable at ts.
+static __always_inline void stamp_runnable(struct task_struct *task, u64 ts) {
+	u32 tgid = BPF_CORE_READ(task, tgid);
+	if (tgid == 0)
 		return;
-	__builtin_memset(dst, 0, len);
-	if (len > 0)
-		dst[0] = 'n';
-	if (len > 1)
-		dst[1] = '/';
-	if (len > 2)
-		dst[2] = 'a';
+	u32 tid = BPF_CORE_READ(task, pid);
+	struct runq_wait w = {};
+	w.ts = ts;
+	w.tgid = tgid;
+	bpf_map_update_elem(&runq_start, &tid, &w, BPF_ANY);
 }
 
-// snapshot_cgroup reads the leaf cgroup name for the current task via
-// task->cgroups->dfl_cgrp->kn->name. Falls back to the parent kernfs node
-// if the leaf name is empty. This is best-effort and not a full path.
-static __always_inline bool snapshot_cgroup(char *dst, size_t len) {
-	if (!dst || len == 0)
-		return false;
-	__builtin_memset(dst, 0, len);
-
-	struct task_struct *task = (struct task_struct *)bpf_get_current_task_btf();
-	if (!task)
-		return false;
-
-	struct css_set *cset = BPF_CORE_READ(task, cgroups);
-	if (!cset)
-		return false;
-	struct cgroup *cgrp = BPF_CORE_READ(cset, dfl_cgrp);
-	if (!cgrp)
-		return false;
-	struct kernfs_node *kn = BPF_CORE_READ(cgrp, kn);
-	if (!kn)
-		return false;
-
-	const char *leaf = BPF_CORE_READ(kn, name);
-	if (leaf && bpf_core_read_str(dst, len, leaf) > 0)
-		return true;
-	struct kernfs_node *parent_kn = BPF_CORE_READ(kn, parent);
-	if (parent_kn) {
-		const char *parent = BPF_CORE_READ(parent_kn, name);
-		if (parent)
-			return bpf_core_read_str(dst, len, parent) > 0;
+// emit_spike reports a run of slice_ns by the outgoing thread prev. The
+// reservation never waits: if the reader has fallen behind and the buffer is
+// full, the event is dropped and counted instead.
+static __always_inline void emit_spike(struct task_struct *prev, u32 tgid, u32 cpu,
+				       u64 slice_ns, u64 ts) {
+	struct spike_event *e = bpf_ringbuf_reserve(&spike_events, sizeof(*e), 0);
+	if (!e) {
+		u32 key = 0;
+		u64 *drops = bpf_map_lookup_elem(&spike_drops, &key);
+		if (drops)
+			(*drops)++;
+		return;
 	}
-	return false;
+	e->ts = ts;
+	e->slice_ns = slice_ns;
+	e->tgid = tgid;
+	e->tid = BPF_CORE_READ(prev, pid);
+	e->cpu = cpu;
+	e->_pad = 0;
+	bpf_get_current_comm(e->comm, sizeof(e->comm));
+	bpf_ringbuf_submit(e, 0);
+}
+
+// A wakeup can also hit a thread that is still running (it was about to
+// sleep); it is not waiting for a CPU, so it is not stamped.
+SEC("tp_btf/sched_wakeup")
+int BPF_PROG(handle_sched_wakeup, struct task_struct *p) {
+	if (BPF_CORE_READ(p, on_cpu))
+		return 0;
+	// The waker is current, not p, so p's cgroup cannot be tested here.
+	// A thread that went to sleep in the target cgroup has an off-CPU
+	// stamp; without one, it was filtered out when it left the CPU.
+	if (filter_cgroup) {
+		u32 tid = BPF_CORE_READ(p, pid);
+		if (!bpf_map_lookup_elem(&off_cpu_start, &tid))
+			return 0;
+	}
+	stamp_runnable(p, bpf_ktime_get_ns());
+	return 0;
+}
+
+// A new task is woken by its parent, whose cgroup it starts in.
+SEC("tp_btf/sched_wakeup_new")
+int BPF_PROG(handle_sched_wakeup_new, struct task_struct *p) {
+	if (!in_target_cgroup())
+		return 0;
+	stamp_runnable(p, bpf_ktime_get_ns());
+	return 0;
 }
 
 // handle_sched_switch runs on every CPU context switch via tp_btf/sched_switch.
@@ -163,6 +345,12 @@ int BPF_PROG(handle_sched_switch, bool preempt,
 	if (prev_mm == NULL && !(prev_flags & PF_KTHREAD))
 		goto record_next;
 
+	// prev is still the current task, so -cgroup-path is tested on it.
+	// next is charged below only from stamps that passed this test (or
+	// the wakeup ones), so it needs no test of its own.
+	if (!in_target_cgroup())
+		goto record_next;
+
 	// Track contention: when a non-idle process is switched out in favour of
 	// a different non-idle process, record the pair. Intra-process switches
 	// (same TGID, different threads) are NOT contention and are skipped.
@@ -177,40 +365,132 @@ int BPF_PROG(handle_sched_switch, bool preempt,
 		}
 	}
 
+	// A thread leaving the CPU of its own accord in any state but
+	// TASK_RUNNING went to sleep; stamp it until it runs again, and make sure
+	// no stale run-queue stamp counts its sleep as waiting. One preempted,
+	// even just after setting a sleep state, is still on the run queue and
+	// goes straight back to waiting there.
+	bool slept = !preempt && task_state(prev) != TASK_RUNNING;
+	if (prev_tgid != 0 && slept) {
+		u32 tid = BPF_CORE_READ(prev, pid);
+		bpf_map_update_elem(&off_cpu_start, &tid, &ts, BPF_ANY);
+		bpf_map_delete_elem(&runq_start, &tid);
+	} else {
+		stamp_runnable(prev, ts);
+	}
+
 	// Accumulate CPU time for the process that was running on this core.
 	// st->tgid was stored when this task was previously switched IN.
 	// Validate that it matches the actual outgoing task (prev_tgid).
 	// After a map reset, cpu_state may hold a stale TGID for a process
 	// that has already exited; skipping the mismatch prevents ghost
 	// entries in pid_stats.
-	if (st->tgid != 0 && st->tgid == prev_tgid) {
+	//
+	// The lookup returns this CPU's copy of the entry, which no other CPU
+	// writes, so plain increments are safe. Copies other CPUs created start
+	// zeroed here and are filled in by the update path below.
+	u32 prev_tid = BPF_CORE_READ(prev, pid);
+	if (st->tgid != 0 && st->tgid == prev_tgid && (!key_by_tid || st->tid == prev_tid)) {
 		u64 delta = ts - st->ts;
 		u32 tgid = st->tgid;
+		u32 id = key_by_tid ? prev_tid : tgid;
 		u32 cpu = bpf_get_smp_processor_id();
+		u32 switched = prev_tgid != next_tgid ? 1 : 0;
 
-		struct pid_stat *ps = bpf_map_lookup_elem(&pid_stats, &tgid);
+		if (spike_threshold_ns && delta >= spike_threshold_ns)
+			emit_spike(prev, tgid, cpu, delta, ts);
+
+		struct pid_stat *ps = bpf_map_lookup_elem(&pid_stats, &id);
 		if (!ps) {
 			struct pid_stat new_ps = {};
+			new_ps.tgid = tgid;
 			new_ps.cpu_time_ns = delta;
 			new_ps.cpu_id = cpu;
+			new_ps.switches = switched;
+			new_ps.last_ns = ts;
+			if (slept)
+				new_ps.vol_switches = 1;
+			else
+				new_ps.invol_switches = 1;
 			bpf_get_current_comm(new_ps.comm, sizeof(new_ps.comm));
-			if (!snapshot_cgroup(new_ps.cgroup, sizeof(new_ps.cgroup)))
-				write_placeholder(new_ps.cgroup, sizeof(new_ps.cgroup));
-			bpf_map_update_elem(&pid_stats, &tgid, &new_ps, BPF_ANY);
+			new_ps.cgroup_id = bpf_get_current_cgroup_id();
+			bpf_map_update_elem(&pid_stats, &id, &new_ps, BPF_ANY);
 		} else {
 			ps->cpu_time_ns += delta;
 			ps->cpu_id = cpu;
-			if (ps->cgroup[0] == '\0' && !snapshot_cgroup(ps->cgroup, sizeof(ps->cgroup)))
-				write_placeholder(ps->cgroup, sizeof(ps->cgroup));
+			ps->switches += switched;
+			ps->last_ns = ts;
+			if (slept)
+				ps->vol_switches++;
+			else
+				ps->invol_switches++;
+			if (ps->cgroup_id == 0)
+				ps->cgroup_id = bpf_get_current_cgroup_id();
 			if (ps->comm[0] == '\0')
 				bpf_get_current_comm(ps->comm, sizeof(ps->comm));
 		}
 	}
 
 record_next:
+	// Charge the time the incoming thread spent blocked, counted from the
+	// start of this window at the earliest.
+	if (next_tgid != 0) {
+		u32 tid = BPF_CORE_READ(next, pid);
+		u64 *since = bpf_map_lookup_elem(&off_cpu_start, &tid);
+		if (since) {
+			u64 from = *since;
+			u64 *start = bpf_map_lookup_elem(&window_start, &key);
+			if (start && *start > from)
+				from = *start;
+			u64 blocked = ts > from ? ts - from : 0;
+			bpf_map_delete_elem(&off_cpu_start, &tid);
+
+			u32 id = key_by_tid ? tid : next_tgid;
+			u64 *total = bpf_map_lookup_elem(&off_cpu_ns, &id);
+			if (total)
+				__sync_fetch_and_add(total, blocked);
+			else if (blocked > 0)
+				bpf_map_update_elem(&off_cpu_ns, &id, &blocked, BPF_NOEXIST);
+		}
+	}
+
+	// Charge the incoming thread's wait on the run queue, again counted from
+	// the start of this window at the earliest.
+	if (next_tgid != 0) {
+		u32 tid = BPF_CORE_READ(next, pid);
+		struct runq_wait *w = bpf_map_lookup_elem(&runq_start, &tid);
+		if (w) {
+			u64 from = w->ts;
+			u64 *start = bpf_map_lookup_elem(&window_start, &key);
+			if (start && *start > from)
+				from = *start;
+			u64 wait = ts > from ? ts - from : 0;
+			bpf_map_delete_elem(&runq_start, &tid);
+
+			// The lookup returns this CPU's copy. Creating a missing entry
+			// sets only this CPU's copy too, and if another core created it
+			// first BPF_ANY fills in this CPU's still-zero copy, so no wait
+			// is dropped either way.
+			struct runq_stat *rs = bpf_map_lookup_elem(&runq_latency, &next_tgid);
+			if (rs) {
+				rs->total_ns += wait;
+				rs->count++;
+				if (wait > rs->max_ns)
+					rs->max_ns = wait;
+			} else {
+				struct runq_stat init = {};
+				init.total_ns = wait;
+				init.max_ns = wait;
+				init.count = 1;
+				bpf_map_update_elem(&runq_latency, &next_tgid, &init, BPF_ANY);
+			}
+		}
+	}
+
 	// Record the incoming process so we can attribute its CPU time on the
 	// next switch-out from this core.
 	st->tgid = next_tgid;
+	st->tid = BPF_CORE_READ(next, pid);
 	st->ts = ts;
 
 	return 0;
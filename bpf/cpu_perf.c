@@ -0,0 +1,90 @@
+// cpu_perf.c — eBPF program for sampling-based CPU usage tracking.
+//
+// Alternative to cpu_hotspot.c for context-switch-heavy workloads. Instead of
+// running on every sched_switch, this program is attached to a per-CPU
+// software perf event (PERF_COUNT_SW_CPU_CLOCK) that fires at a fixed period.
+// Each sample credits one period of CPU time to the process (TGID) that was
+// running when the timer fired, so overhead scales with the sampling
+// frequency rather than the context-switch rate.
+//
+// Trade-offs versus sched_switch accounting:
+//   - CPU time is statistical: resolution is one sample period per process.
+//   - There is no switch event, so scheduler contention is NOT recorded.
+//
+// With key_by_tid set (-threads) samples are credited to the running thread
+// (TID) instead, as in cpu_hotspot.c.
+//
+// Maps are read and cleared by the Go collector (pkg/collector/cpu) each tick.
+
+#include "vmlinux.h"
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include "cgroup_filter.h"
+
+// Same layout as struct pid_stat in cpu_hotspot.c so the Go collector can
+// read both maps with a single pidStat struct. Sampling sees no switches, so
+// the switch counters are always zero here. The map is a PERCPU_HASH for the
+// same reason: each CPU's timer only ever updates its own copy.
+struct pid_stat {
+	u64 cpu_time_ns;
+	char comm[16];
+	u64 cgroup_id;
+	u32 cpu_id;
+	u32 _pad;
+	u64 last_ns;
+	u32 vol_switches;
+	u32 invol_switches;
+	u32 tgid;
+	u32 _pad2;
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+	__uint(max_entries, 10240);
+	__type(key, u32);
+	__type(value, struct pid_stat);
+} pid_stats SEC(".maps");
+
+// Key pid_stats by TID rather than TGID, set by the Go collector before
+// loading for -threads.
+volatile const bool key_by_tid = false;
+
+// handle_cpu_sample runs in the perf timer interrupt of each CPU.
+// ctx->sample_period is the CPU-clock period in nanoseconds, i.e. how much
+// on-CPU time this single sample represents.
+SEC("perf_event")
+int handle_cpu_sample(struct bpf_perf_event_data *ctx) {
+	u64 pid_tgid = bpf_get_current_pid_tgid();
+	u32 tgid = pid_tgid >> 32;
+	if (tgid == 0) // idle task
+		return 0;
+	if (!in_target_cgroup())
+		return 0;
+
+	u64 delta = ctx->sample_period;
+	u32 cpu = bpf_get_smp_processor_id();
+	u64 now = bpf_ktime_get_ns();
+	u32 id = key_by_tid ? (u32)pid_tgid : tgid;
+
+	struct pid_stat *ps = bpf_map_lookup_elem(&pid_stats, &id);
+	if (!ps) {
+		struct pid_stat new_ps = {};
+		new_ps.tgid = tgid;
+		new_ps.cpu_time_ns = delta;
+		new_ps.cpu_id = cpu;
+		new_ps.last_ns = now;
+		bpf_get_current_comm(new_ps.comm, sizeof(new_ps.comm));
+		new_ps.cgroup_id = bpf_get_current_cgroup_id();
+		bpf_map_update_elem(&pid_stats, &id, &new_ps, BPF_ANY);
+	} else {
+		ps->cpu_time_ns += delta;
+		ps->cpu_id = cpu;
+		ps->last_ns = now;
+	}
+
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
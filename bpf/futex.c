@@ -0,0 +1,134 @@
+// futex.c — eBPF program for per-process time spent waiting on futexes.
+//
+// Attaches to the syscalls:sys_enter_futex and sys_exit_futex tracepoints,
+// which fire only for the futex system call rather than every call:
+//
+//  1. sys_enter_futex stamps the entry time in futex_start, keyed by TID,
+//     when the operation is one that can sleep (FUTEX_WAIT and its bitset,
+//     PI, and requeue variants). Wakes and other operations are skipped.
+//
+//  2. sys_exit_futex charges the elapsed time to the TGID in futex_stats and
+//     removes the stamp. A wait that returns -EAGAIN found the futex word
+//     already changed and never slept; it is not counted. Every other wait
+//     blocked on a lock (or a condition variable) held by another thread and
+//     counts as contended.
+//
+// A wait that began before the current window is only charged from
+// window_start on, like off-CPU time in cpu_hotspot.c.
+//
+// Futex traffic is among the highest-volume syscall traffic on a busy host,
+// so both maps are LRU and bounded: stamps of threads that exit mid-wait,
+// and processes beyond the map's capacity, age out rather than failing
+// updates.
+//
+// Maps are read and cleared by the Go collector (pkg/collector/futex) each tick.
+//
+// Requires: CONFIG_FTRACE_SYSCALLS, for the syscalls tracepoints.
+
+#include "vmlinux.h"
+#include <bpf/bpf_helpers.h>
+
+#include "cgroup_filter.h"
+
+#define FUTEX_WAIT 0
+#define FUTEX_LOCK_PI 6
+#define FUTEX_WAIT_BITSET 9
+#define FUTEX_WAIT_REQUEUE_PI 11
+#define FUTEX_LOCK_PI2 13
+// FUTEX_PRIVATE_FLAG and FUTEX_CLOCK_REALTIME are or'ed into the operation.
+#define FUTEX_CMD_MASK (~(128 | 256))
+
+#define EAGAIN 11
+
+// Wait entry time per thread (TID). LRU so stamps of threads that exit
+// while waiting age out.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 16384);
+	__type(key, u32);
+	__type(value, u64);
+} futex_start SEC(".maps");
+
+// Per-process (TGID) futex waits in the current window.
+// Layout must match the Go futexStat struct in collector_linux.go exactly.
+struct futex_stat {
+	u64 wait_ns;   // time blocked in futex waits, summed across threads
+	u64 contended; // waits that blocked
+	char comm[16];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 8192);
+	__type(key, u32);
+	__type(value, struct futex_stat);
+} futex_stats SEC(".maps");
+
+// ktime_ns at which the current window began, written by the Go collector
+// on each reset.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u64);
+} window_start SEC(".maps");
+
+static __always_inline int is_wait(unsigned long op) {
+	switch (op & FUTEX_CMD_MASK) {
+	case FUTEX_WAIT:
+	case FUTEX_LOCK_PI:
+	case FUTEX_WAIT_BITSET:
+	case FUTEX_WAIT_REQUEUE_PI:
+	case FUTEX_LOCK_PI2:
+		return 1;
+	}
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_enter_futex")
+int handle_futex_enter(struct trace_event_raw_sys_enter *ctx) {
+	if (!is_wait(ctx->args[1]) || !in_target_cgroup())
+		return 0;
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	u64 ts = bpf_ktime_get_ns();
+	bpf_map_update_elem(&futex_start, &tid, &ts, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_futex")
+int handle_futex_exit(struct trace_event_raw_sys_exit *ctx) {
+	u64 pid_tgid = bpf_get_current_pid_tgid();
+	u32 tid = (u32)pid_tgid;
+	u64 *tsp = bpf_map_lookup_elem(&futex_start, &tid);
+	if (!tsp)
+		return 0; // not a wait, or entered before the program was attached
+	u64 from = *tsp;
+	bpf_map_delete_elem(&futex_start, &tid);
+	if (ctx->ret == -EAGAIN)
+		return 0;
+
+	u32 tgid = pid_tgid >> 32;
+	if (tgid == 0)
+		return 0;
+	u32 key0 = 0;
+	u64 *start = bpf_map_lookup_elem(&window_start, &key0);
+	if (start && *start > from)
+		from = *start;
+	u64 now = bpf_ktime_get_ns();
+	u64 waited = now > from ? now - from : 0;
+
+	struct futex_stat *fw = bpf_map_lookup_elem(&futex_stats, &tgid);
+	if (!fw) {
+		struct futex_stat zero = {};
+		bpf_get_current_comm(&zero.comm, sizeof(zero.comm));
+		bpf_map_update_elem(&futex_stats, &tgid, &zero, BPF_NOEXIST);
+		fw = bpf_map_lookup_elem(&futex_stats, &tgid);
+		if (!fw)
+			return 0;
+	}
+	__sync_fetch_and_add(&fw->wait_ns, waited);
+	__sync_fetch_and_add(&fw->contended, 1);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -8,7 +8,50 @@
 //  2. Read the process's current RSS directly from task->mm->rss_stat[].count
 //     (the base counter of the percpu_counter). This is approximate — it omits
 //     per-CPU deltas — but accurate enough for trend-based OOM classification.
-//  3. Snapshot the cgroup leaf name (best-effort, same as cpu_hotspot.c).
+//     The lowest and highest values seen are kept too, so userspace sees how
+//     far RSS swung while the faults happened rather than only where it ended.
+//  3. Record the cgroup v2 id, resolved to a path in userspace as in
+//     cpu_hotspot.c.
+//  4. Hash the faulting page into a 256-bit per-PID bitmap. Userspace turns the
+//     bitmap into a distinct-page estimate (linear counting) to tell faults on
+//     a small hot set (thrashing) from faults spread over new pages (growth).
+//
+// An optional kretprobe on the same function times each fault: the entry
+// probe stamps the thread's start time, and the return probe charges the
+// elapsed time to the process when the fault was major (VM_FAULT_MAJOR),
+// i.e. had to wait for swap or a file read. The average separates a major
+// fault served by a fast NVMe from one stuck on a slow network filesystem.
+// A fault that returns VM_FAULT_RETRY (mmap lock dropped while waiting on
+// I/O) is timed again when retried, so only the final attempt is counted.
+//
+// A second, optional kprobe on the x86 remote TLB flush path
+// (native_flush_tlb_multi, or native_flush_tlb_others before 5.11) counts TLB
+// shootdowns per TGID. The probe runs on the CPU that initiates the flush, so
+// the count is charged to the process whose munmap/mprotect/migration forced
+// IPIs to other CPUs — not to the victims that had to handle them.
+//
+// A third, optional pair of kprobes counts swap traffic per TGID in
+// swap_events: swap_read_folio (swap_readpage before 6.10) runs when a
+// process faults a page back in from swap, and swap_writepage when reclaim
+// writes one out. Swap-ins are charged to the faulting process. Swap-outs
+// are charged to whichever task is reclaiming: kswapd in the background, or
+// the allocating process itself when it falls into direct reclaim, which is
+// the process pushing the system into swap.
+//
+// page_faults is a PERCPU_HASH: every CPU updates its own copy of a process's
+// entry, so a multi-threaded process faulting on several cores at once no
+// longer loses increments to racing read-modify-writes (the fault count of a
+// shared HASH entry undercounted under exactly the load it was meant to
+// catch). The Go collector merges the copies when it reads the map.
+//
+// The per-window maps are double-buffered: page_faults, tlb_shootdowns, and
+// swap_events each have a _b twin, and active_buffer selects which set the
+// probes write.
+// To end a window the Go collector flips active_buffer, reads the retired
+// pair, and clears it while the probes fill the other one. Clearing no longer
+// races with the probes, so no fault is lost between reading a window and
+// starting the next, and the flip costs one array write however many
+// processes are in the maps.
 //
 // Maps are read and cleared by the Go collector (pkg/collector/memory) each tick.
 
@@ -18,68 +61,145 @@
 #include <bpf/bpf_tracing.h>
 #include <stdbool.h>
 
+#include "cgroup_filter.h"
+
 // Per-PID fault statistics for the current sampling window.
 // Layout must match the Go faultStat struct in collector_linux.go exactly.
+#define FAULT_PAGE_BITMAP_WORDS 4 // 256 bits
+
 struct fault_stat {
     u64 faults;     // total page faults since last reset
     u64 rss_pages;  // RSS in pages, read from mm->rss_stat at fault time
-    char cgroup[64];
+    u64 cgroup_id;  // cgroup v2 id, resolved to a path in userspace
+    u64 page_bitmap[FAULT_PAGE_BITMAP_WORDS]; // hashed set of faulting pages
+    u64 major_faults;   // faults that returned VM_FAULT_MAJOR (kretprobe only)
+    u64 major_fault_ns; // total time spent in those faults
+    u64 rss_min_pages;  // lowest rss_pages seen at a fault in the window
+    u64 rss_max_pages;  // highest rss_pages seen at a fault in the window
+    u64 last_fault_ns;  // ktime_ns of the fault that set rss_pages
 };
 
 struct {
-    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
     __uint(max_entries, 4096);
     __type(key, u32);
     __type(value, struct fault_stat);
 } page_faults SEC(".maps");
 
-#ifndef bpf_get_current_task_btf
-#define bpf_get_current_task_btf() bpf_get_current_task()
-#endif
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, struct fault_stat);
+} page_faults_b SEC(".maps");
+
+// Which copy of the per-window maps the probes write: 0 for page_faults,
+// tlb_shootdowns, and swap_events, 1 for their _b twins. Written only by the
+// Go collector.
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, u32);
+} active_buffer SEC(".maps");
+
+// Fault entry timestamps keyed by pid_tgid (one per thread in a fault).
+// LRU so stamps orphaned when the return probe is not attached age out.
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 8192);
+    __type(key, u64);
+    __type(value, u64);
+} fault_start SEC(".maps");
+
+// Remote TLB shootdowns initiated per TGID in the current sampling window.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, u64);
+} tlb_shootdowns SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, u64);
+} tlb_shootdowns_b SEC(".maps");
+
+// Swap traffic per TGID in the current sampling window.
+// Layout must match the Go swapStat struct in collector_linux.go.
+struct swap_stat {
+    u64 ins;  // folios read back from swap
+    u64 outs; // folios written out to swap
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, struct swap_stat);
+} swap_events SEC(".maps");
 
-static __always_inline void write_placeholder(char *dst, size_t len) {
-    if (!dst || len == 0)
-        return;
-    __builtin_memset(dst, 0, len);
-    if (len > 0)
-        dst[0] = 'n';
-    if (len > 1)
-        dst[1] = '/';
-    if (len > 2)
-        dst[2] = 'a';
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, struct swap_stat);
+} swap_events_b SEC(".maps");
+
+// The verifier needs a constant map pointer at each helper call, so the
+// buffer is picked with a branch per access rather than a map pointer
+// variable.
+static __always_inline u32 active_index(void) {
+    u32 key = 0;
+    u32 *idx = bpf_map_lookup_elem(&active_buffer, &key);
+    return idx ? *idx : 0;
 }
 
-static __always_inline bool snapshot_cgroup(char *dst, size_t len) {
-    if (!dst || len == 0)
-        return false;
-    __builtin_memset(dst, 0, len);
+static __always_inline struct fault_stat *lookup_fault_stat(u32 buf, u32 *pid) {
+    if (buf)
+        return bpf_map_lookup_elem(&page_faults_b, pid);
+    return bpf_map_lookup_elem(&page_faults, pid);
+}
 
-    struct task_struct *task = (struct task_struct *)bpf_get_current_task_btf();
-    if (!task)
-        return false;
-
-    struct css_set *cset = BPF_CORE_READ(task, cgroups);
-    if (!cset)
-        return false;
-    struct cgroup *cgrp = BPF_CORE_READ(cset, dfl_cgrp);
-    if (!cgrp)
-        return false;
-    struct kernfs_node *kn = BPF_CORE_READ(cgrp, kn);
-    if (!kn)
-        return false;
-
-    const char *leaf = BPF_CORE_READ(kn, name);
-    if (leaf && bpf_core_read_str(dst, len, leaf) > 0)
-        return true;
-    struct kernfs_node *parent_kn = BPF_CORE_READ(kn, parent);
-    if (parent_kn) {
-        const char *parent = BPF_CORE_READ(parent_kn, name);
-        if (parent)
-            return bpf_core_read_str(dst, len, parent) > 0;
-    }
-    return false;
+static __always_inline void insert_fault_stat(u32 buf, u32 *pid, struct fault_stat *stat) {
+    if (buf)
+        bpf_map_update_elem(&page_faults_b, pid, stat, BPF_ANY);
+    else
+        bpf_map_update_elem(&page_faults, pid, stat, BPF_ANY);
+}
+
+static __always_inline u64 *lookup_tlb_count(u32 buf, u32 *pid) {
+    if (buf)
+        return bpf_map_lookup_elem(&tlb_shootdowns_b, pid);
+    return bpf_map_lookup_elem(&tlb_shootdowns, pid);
 }
 
+static __always_inline void insert_tlb_count(u32 buf, u32 *pid, u64 *count) {
+    if (buf)
+        bpf_map_update_elem(&tlb_shootdowns_b, pid, count, BPF_NOEXIST);
+    else
+        bpf_map_update_elem(&tlb_shootdowns, pid, count, BPF_NOEXIST);
+}
+
+static __always_inline struct swap_stat *lookup_swap_stat(u32 buf, u32 *pid) {
+    if (buf)
+        return bpf_map_lookup_elem(&swap_events_b, pid);
+    return bpf_map_lookup_elem(&swap_events, pid);
+}
+
+static __always_inline void insert_swap_stat(u32 buf, u32 *pid, struct swap_stat *stat) {
+    if (buf)
+        bpf_map_update_elem(&swap_events_b, pid, stat, BPF_NOEXIST);
+    else
+        bpf_map_update_elem(&swap_events, pid, stat, BPF_NOEXIST);
+}
+
+#ifndef bpf_get_current_task_btf
+#define bpf_get_current_task_btf() bpf_get_current_task()
+#endif
+
 // read_rss_pages reads the approximate RSS (in pages) from the task's mm_struct.
 // Sums file-backed + anonymous + shared-memory pages. The percpu_counter .count
 // field is the base counter; per-CPU deltas are not included (off by at most
@@ -95,35 +215,140 @@ static __always_inline u64 read_rss_pages(struct task_struct *task) {
     return total > 0 ? (u64)total : 0;
 }
 
-static __always_inline int record_fault(void) {
-    u32 pid = bpf_get_current_pid_tgid() >> 32;
-    if (pid == 0)
+// mark_fault_page sets the bitmap bit for the page containing address.
+// A multiplicative (Fibonacci) hash spreads neighbouring pages across buckets;
+// the top 8 bits select one of 256 bits.
+static __always_inline void mark_fault_page(u64 *bitmap, unsigned long address) {
+    u64 page = address >> 12;
+    u32 bit = (u32)((page * 0x9E3779B97F4A7C15ULL) >> 56) & 0xff;
+    bitmap[(bit >> 6) & (FAULT_PAGE_BITMAP_WORDS - 1)] |= 1ULL << (bit & 63);
+}
+
+static __always_inline int record_fault(unsigned long address) {
+    u64 id = bpf_get_current_pid_tgid();
+    u32 pid = id >> 32;
+    if (pid == 0 || !in_target_cgroup())
         return 0;
 
+    u64 now = bpf_ktime_get_ns();
+    bpf_map_update_elem(&fault_start, &id, &now, BPF_ANY);
+
     struct task_struct *task = (struct task_struct *)bpf_get_current_task_btf();
     u64 rss = read_rss_pages(task);
 
-    struct fault_stat *entry = bpf_map_lookup_elem(&page_faults, &pid);
+    // The lookup returns this CPU's copy. One another CPU created starts
+    // zeroed, so its RSS range begins at the first fault counted here.
+    u32 buf = active_index();
+    struct fault_stat *entry = lookup_fault_stat(buf, &pid);
     if (entry) {
+        if (entry->faults == 0 || rss < entry->rss_min_pages)
+            entry->rss_min_pages = rss;
+        if (rss > entry->rss_max_pages)
+            entry->rss_max_pages = rss;
         entry->faults++;
         entry->rss_pages = rss;
-        if (entry->cgroup[0] == '\0' && !snapshot_cgroup(entry->cgroup, sizeof(entry->cgroup)))
-            write_placeholder(entry->cgroup, sizeof(entry->cgroup));
+        entry->last_fault_ns = now;
+        mark_fault_page(entry->page_bitmap, address);
+        if (entry->cgroup_id == 0)
+            entry->cgroup_id = bpf_get_current_cgroup_id();
     } else {
         struct fault_stat init = {};
         init.faults = 1;
         init.rss_pages = rss;
-        if (!snapshot_cgroup(init.cgroup, sizeof(init.cgroup)))
-            write_placeholder(init.cgroup, sizeof(init.cgroup));
-        bpf_map_update_elem(&page_faults, &pid, &init, BPF_ANY);
+        init.rss_min_pages = rss;
+        init.rss_max_pages = rss;
+        init.last_fault_ns = now;
+        init.cgroup_id = bpf_get_current_cgroup_id();
+        mark_fault_page(init.page_bitmap, address);
+        insert_fault_stat(buf, &pid, &init);
     }
 
     return 0;
 }
 
 SEC("kprobe/handle_mm_fault")
-int BPF_KPROBE(handle_mm_fault_kprobe) {
-    return record_fault();
+int BPF_KPROBE(handle_mm_fault_kprobe, struct vm_area_struct *vma, unsigned long address) {
+    return record_fault(address);
+}
+
+SEC("kretprobe/handle_mm_fault")
+int BPF_KRETPROBE(handle_mm_fault_return, vm_fault_t ret) {
+    u64 id = bpf_get_current_pid_tgid();
+    u64 *start = bpf_map_lookup_elem(&fault_start, &id);
+    if (!start)
+        return 0;
+    u64 delta = bpf_ktime_get_ns() - *start;
+    bpf_map_delete_elem(&fault_start, &id);
+    if (!(ret & VM_FAULT_MAJOR))
+        return 0;
+
+    // The entry probe created the entry; it is only missing if userspace
+    // flipped the buffers while this fault was in flight. If the thread
+    // migrated mid-fault this is another CPU's copy, which is fine: copies
+    // are summed.
+    u32 pid = id >> 32;
+    struct fault_stat *entry = lookup_fault_stat(active_index(), &pid);
+    if (!entry)
+        return 0;
+    entry->major_faults++;
+    entry->major_fault_ns += delta;
+    return 0;
+}
+
+// handle_tlb_shootdown is attached by the Go collector to whichever remote
+// flush function the running kernel provides, so the section name only
+// documents the preferred target.
+SEC("kprobe/native_flush_tlb_multi")
+int handle_tlb_shootdown(struct pt_regs *ctx) {
+    u32 pid = bpf_get_current_pid_tgid() >> 32;
+    if (pid == 0 || !in_target_cgroup())
+        return 0;
+
+    u32 buf = active_index();
+    u64 *count = lookup_tlb_count(buf, &pid);
+    if (count) {
+        __sync_fetch_and_add(count, 1);
+    } else {
+        u64 init = 1;
+        insert_tlb_count(buf, &pid, &init);
+    }
+    return 0;
+}
+
+// count_swap charges one swapped folio to the current process. An insert
+// that loses the race with another CPU's insert is retried as an update.
+static __always_inline int count_swap(bool out) {
+    u32 pid = bpf_get_current_pid_tgid() >> 32;
+    if (pid == 0 || !in_target_cgroup())
+        return 0;
+
+    u32 buf = active_index();
+    struct swap_stat *st = lookup_swap_stat(buf, &pid);
+    if (!st) {
+        struct swap_stat init = {};
+        insert_swap_stat(buf, &pid, &init);
+        st = lookup_swap_stat(buf, &pid);
+        if (!st)
+            return 0;
+    }
+    if (out)
+        __sync_fetch_and_add(&st->outs, 1);
+    else
+        __sync_fetch_and_add(&st->ins, 1);
+    return 0;
+}
+
+// handle_swap_in and handle_swap_out are attached by the Go collector to
+// whichever swap I/O functions the running kernel provides, so the section
+// names only document the preferred targets.
+SEC("kprobe/swap_read_folio")
+int handle_swap_in(struct pt_regs *ctx) {
+    return count_swap(false);
+}
+
+SEC("kprobe/swap_writepage")
+int handle_swap_out(struct pt_regs *ctx) {
+    return count_swap(true);
 }
 
 char LICENSE[] SEC("license") = "Dual BSD/GPL";
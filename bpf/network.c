@@ -0,0 +1,83 @@
+// network.c — eBPF program for per-process TCP bytes sent and received.
+//
+// Attaches to two TCP functions that every stream socket goes through:
+//
+//  1. A kretprobe on tcp_sendmsg charges the bytes it returns, the payload
+//     actually queued on the socket, to the sending process. A send that
+//     fails or would block returns a negative error and is not counted.
+//
+//  2. A kprobe on tcp_cleanup_rbuf charges its copied argument, the bytes
+//     a recvmsg just handed to userspace, to the receiving process. The
+//     kernel also calls it with nothing copied; those calls are skipped.
+//
+// Both functions run in the context of the task that made the system call,
+// so bytes are charged to the process that called send or recv rather than
+// to the socket's owner. The two differ when a socket is passed over a unix
+// socket or inherited across fork, and the caller is the process doing the
+// work. Bytes the kernel sends on its own (retransmits, ACKs) are not
+// counted.
+//
+// Both hooks fire on every send and receive on the host, which is why the
+// collector is opt-in (-net). The map is LRU and bounded, so processes
+// beyond its capacity age out rather than failing updates.
+//
+// Maps are read and cleared by the Go collector (pkg/collector/network) each tick.
+//
+// Requires: kprobes on tcp_sendmsg and tcp_cleanup_rbuf (not inlined in any
+// mainstream kernel).
+
+#include "vmlinux.h"
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include "cgroup_filter.h"
+
+// Per-process (TGID) TCP traffic in the current window.
+// Layout must match the Go netStat struct in collector_linux.go exactly.
+struct net_stat {
+	u64 tx_bytes; // bytes queued by tcp_sendmsg
+	u64 rx_bytes; // bytes copied to userspace by recvmsg
+	char comm[16];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 8192);
+	__type(key, u32);
+	__type(value, struct net_stat);
+} net_stats SEC(".maps");
+
+static __always_inline struct net_stat *lookup_stat(void) {
+	u32 tgid = bpf_get_current_pid_tgid() >> 32;
+	if (tgid == 0 || !in_target_cgroup())
+		return NULL;
+	struct net_stat *st = bpf_map_lookup_elem(&net_stats, &tgid);
+	if (st)
+		return st;
+	struct net_stat zero = {};
+	bpf_get_current_comm(&zero.comm, sizeof(zero.comm));
+	bpf_map_update_elem(&net_stats, &tgid, &zero, BPF_NOEXIST);
+	return bpf_map_lookup_elem(&net_stats, &tgid);
+}
+
+SEC("kretprobe/tcp_sendmsg")
+int BPF_KRETPROBE(handle_tcp_sendmsg, int ret) {
+	if (ret <= 0)
+		return 0;
+	struct net_stat *st = lookup_stat();
+	if (st)
+		__sync_fetch_and_add(&st->tx_bytes, ret);
+	return 0;
+}
+
+SEC("kprobe/tcp_cleanup_rbuf")
+int BPF_KPROBE(handle_tcp_cleanup_rbuf, struct sock *sk, int copied) {
+	if (copied <= 0)
+		return 0;
+	struct net_stat *st = lookup_stat();
+	if (st)
+		__sync_fetch_and_add(&st->rx_bytes, copied);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -0,0 +1,82 @@
+// profile.c — eBPF program sampling the call stacks of one process.
+//
+// Attached, like cpu_perf.c, to a per-CPU software perf event
+// (PERF_COUNT_SW_CPU_CLOCK) firing at a fixed frequency. A sample taken
+// while a thread of the target process is running records its kernel and
+// user stacks in stacks and counts the pair in stack_counts; samples of
+// every other task return at once. The Go collector (pkg/collector/profile)
+// retargets it to the Focus process each interval by rewriting
+// target_tgid, so the perf events stay attached throughout.
+//
+// Maps are read and cleared by the Go collector each tick.
+
+#include "vmlinux.h"
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#define MAX_STACK_DEPTH 127
+
+// TGID whose threads are sampled; 0 samples nothing.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u32);
+} target_tgid SEC(".maps");
+
+// Stack id -> up to MAX_STACK_DEPTH instruction pointers, innermost first,
+// zero-terminated when shorter.
+struct {
+	__uint(type, BPF_MAP_TYPE_STACK_TRACE);
+	__uint(max_entries, 4096);
+	__uint(key_size, sizeof(u32));
+	__uint(value_size, MAX_STACK_DEPTH * sizeof(u64));
+} stacks SEC(".maps");
+
+// A sampled (kernel stack, user stack) pair. An id is negative when that
+// stack could not be collected: -EFAULT for the user stack of a kernel
+// thread, -EEXIST when another stack already holds its hash bucket.
+// Layout must match the Go stackKey struct in collector_linux.go.
+struct stack_key {
+	s32 kernel_stack_id;
+	s32 user_stack_id;
+};
+
+// Samples per stack pair in the current window.
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 4096);
+	__type(key, struct stack_key);
+	__type(value, u64);
+} stack_counts SEC(".maps");
+
+// handle_profile_sample runs in the perf timer interrupt of each CPU.
+SEC("perf_event")
+int handle_profile_sample(struct bpf_perf_event_data *ctx) {
+	u32 key = 0;
+	u32 *target = bpf_map_lookup_elem(&target_tgid, &key);
+	if (!target || *target == 0)
+		return 0;
+	if ((u32)(bpf_get_current_pid_tgid() >> 32) != *target)
+		return 0;
+
+	struct stack_key sk = {};
+	sk.kernel_stack_id = bpf_get_stackid(ctx, &stacks, 0);
+	sk.user_stack_id = bpf_get_stackid(ctx, &stacks, BPF_F_USER_STACK);
+
+	u64 *cnt = bpf_map_lookup_elem(&stack_counts, &sk);
+	if (cnt) {
+		__sync_fetch_and_add(cnt, 1);
+		return 0;
+	}
+	// Another CPU may insert the same pair first; then add to its entry.
+	u64 one = 1;
+	if (bpf_map_update_elem(&stack_counts, &sk, &one, BPF_NOEXIST) != 0) {
+		cnt = bpf_map_lookup_elem(&stack_counts, &sk);
+		if (cnt)
+			__sync_fetch_and_add(cnt, 1);
+	}
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -0,0 +1,121 @@
+// syscalls.c — eBPF program for per-process time spent in system calls.
+//
+// Attaches to the raw_syscalls BTF raw tracepoints, which fire on every
+// system call of every task, so both handlers are kept to a handful of
+// instructions:
+//
+//  1. sys_enter stamps the entry time and syscall number in task-local
+//     storage. The storage hangs off the task_struct itself, so there is no
+//     hash keyed by TID to look up, and nothing is left behind when a thread
+//     exits mid-call (exit and exit_group never return).
+//
+//  2. sys_exit charges the elapsed time to (TGID, syscall number) in
+//     syscall_time. The map is per-CPU, so the update never contends with
+//     another core. Userspace sums each process's entries for its totals and
+//     picks the costliest number as its top syscall.
+//
+// A call that began before the current window (a long epoll_wait, say) is
+// only charged from window_start on, like off-CPU time in cpu_hotspot.c.
+//
+// Time in a syscall includes time blocked inside it: a process parked in
+// futex or epoll_wait accumulates syscall time without using a CPU. The top
+// syscall tells the two apart.
+//
+// Maps are read and cleared by the Go collector (pkg/collector/syscalls) each tick.
+//
+// Requires: kernel ≥5.11 with BTF support (CONFIG_DEBUG_INFO_BTF=y), for
+// task storage in tracing programs.
+
+#include "vmlinux.h"
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include "cgroup_filter.h"
+
+// The system call a thread is currently in.
+struct sys_entry {
+	u64 ts; // ktime_ns at entry
+	s64 nr; // syscall number; -1 when not in a syscall
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_TASK_STORAGE);
+	__uint(map_flags, BPF_F_NO_PREALLOC);
+	__type(key, int);
+	__type(value, struct sys_entry);
+} sys_entries SEC(".maps");
+
+// Time per process (TGID) and syscall number in the current window, keyed
+// by (tgid << 32 | nr). Layout must match the Go syscallTime struct in
+// collector_linux.go.
+struct syscall_time {
+	u64 total_ns;
+	u64 count; // calls that returned in the window
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+	__uint(max_entries, 32768);
+	__type(key, u64);
+	__type(value, struct syscall_time);
+} syscall_time SEC(".maps");
+
+// ktime_ns at which the current window began, written by the Go collector
+// on each reset.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, u64);
+} window_start SEC(".maps");
+
+SEC("tp_btf/sys_enter")
+int BPF_PROG(handle_sys_enter, struct pt_regs *regs, long id) {
+	// Without an entry, sys_exit returns early, so only entry is filtered.
+	if (!in_target_cgroup())
+		return 0;
+	struct task_struct *task = bpf_get_current_task_btf();
+	struct sys_entry *e = bpf_task_storage_get(&sys_entries, task, 0, BPF_LOCAL_STORAGE_GET_F_CREATE);
+	if (!e)
+		return 0;
+	e->ts = bpf_ktime_get_ns();
+	e->nr = id;
+	return 0;
+}
+
+SEC("tp_btf/sys_exit")
+int BPF_PROG(handle_sys_exit, struct pt_regs *regs, long ret) {
+	struct task_struct *task = bpf_get_current_task_btf();
+	struct sys_entry *e = bpf_task_storage_get(&sys_entries, task, 0, 0);
+	if (!e || e->nr < 0)
+		return 0;
+	u64 ts = bpf_ktime_get_ns();
+	u64 from = e->ts;
+	u64 nr = e->nr;
+	e->nr = -1;
+
+	u32 tgid = bpf_get_current_pid_tgid() >> 32;
+	if (tgid == 0)
+		return 0;
+	u32 key0 = 0;
+	u64 *start = bpf_map_lookup_elem(&window_start, &key0);
+	if (start && *start > from)
+		from = *start;
+	u64 elapsed = ts > from ? ts - from : 0;
+
+	u64 key = ((u64)tgid << 32) | (u32)nr;
+	struct syscall_time *st = bpf_map_lookup_elem(&syscall_time, &key);
+	if (st) {
+		st->total_ns += elapsed;
+		st->count++;
+	} else {
+		struct syscall_time init = {};
+		init.total_ns = elapsed;
+		init.count = 1;
+		bpf_map_update_elem(&syscall_time, &key, &init, BPF_ANY);
+	}
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
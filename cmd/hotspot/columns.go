@@ -0,0 +1,381 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+	"github.com/srodi/hotspot-bpf/pkg/ui"
+)
+
+// column is one column of a process table: the id -columns selects it by,
+// its header, how readily it is dropped on a narrow terminal, and how a row
+// renders in it.
+type column struct {
+	id       string
+	header   string
+	priority int
+	value    func(row report.ProcMetrics, probes probeSet) string
+}
+
+// Column priorities. On a terminal narrower than a table, columns are
+// dropped lowest priority first, rightmost first among equals.
+const (
+	priorityLow    = iota + 1 // detail most views can do without
+	priorityMedium            // context for the table's metric
+	priorityHigh              // the metric the table is about
+	priorityKeep              // identifies the row; never dropped
+)
+
+// elidable lists the columns shortened with an ellipsis, in order, before
+// any column is dropped, and how narrow each may become.
+var elidable = []struct {
+	id       string
+	elide    func(s string, n int) string
+	minWidth int
+}{
+	{"cgroup", ui.ElideStart, 20},
+	{"comm", ui.Elide, 12},
+}
+
+// probeSet records which optional probes are attached. Columns fed by a
+// missing probe show "-" rather than a misleading zero.
+type probeSet struct {
+	offCPU       bool // blocked time and voluntary/involuntary switches are only measured with -cpu-method sched
+	majorLatency bool // major faults are only timed with the kretprobe
+	tlb          bool // shootdowns are only tracked on x86
+	swap         bool // swap I/O is only counted when its kprobes attach
+}
+
+// Columns shared by every process table.
+var (
+	pidColumn    = column{"pid", "PID", priorityKeep, func(r report.ProcMetrics, _ probeSet) string { return r.PIDLabel() }}
+	commColumn   = column{"comm", "COMM", priorityKeep, func(r report.ProcMetrics, _ probeSet) string { return r.CommLabel() }}
+	cgroupColumn = column{"cgroup", "CGROUP", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return r.CgroupLabel() }}
+	cpuMsColumn  = column{"cpu_ms", "CPU(ms)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.CPUMs) }}
+	diagColumn   = column{"diag", "Diag", priorityKeep, func(r report.ProcMetrics, _ probeSet) string { return diagCell(r.Diagnosis) }}
+	stateColumn  = column{"state", "State", priorityMedium, func(r report.ProcMetrics, _ probeSet) string {
+		switch {
+		case r.State == "":
+			return "-"
+		case r.IOBlocked():
+			return "D (I/O-blocked)"
+		default:
+			return r.State
+		}
+	}}
+)
+
+// diagCell renders a diagnosis colored by its severity.
+func diagCell(label string) string {
+	return ui.ColorizeDiagnosis(label, report.Severity(label))
+}
+
+// cpuColumns lays out the CPU Hotspots table.
+var cpuColumns = []column{
+	pidColumn, commColumn, cgroupColumn, cpuMsColumn,
+	{"offcpu_ms", "Off-CPU(ms)", priorityMedium, func(r report.ProcMetrics, p probeSet) string {
+		if !p.offCPU {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", r.OffCPUMs)
+	}},
+	{"runq_ms", "RunQ(ms)", priorityMedium, func(r report.ProcMetrics, _ probeSet) string {
+		if !r.RunqTracked { // run-queue latency needs the wakeup tracepoints
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", r.RunqWaitMs)
+	}},
+	{"cpu_pct", "CPU(%)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.CPUPercent) + r.CPUTrend }},
+	{"cpu_pct_max", "MaxCPU(%)", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.CPUPercentMax) }},
+	{"cpu_pct_avg", "AvgCPU(%)", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.CPUPercentAvg) }},
+	{"vol_switches", "VolCS", priorityLow, func(r report.ProcMetrics, p probeSet) string {
+		if !p.offCPU {
+			return "-"
+		}
+		return fmt.Sprint(r.VolCtxSwitches)
+	}},
+	{"invol_switches", "InvolCS", priorityMedium, func(r report.ProcMetrics, p probeSet) string {
+		if !p.offCPU {
+			return "-"
+		}
+		return fmt.Sprint(r.InvolCtxSwitches)
+	}},
+	{"core_pct", "Core%", priorityLow, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.CoreCPUPercent) }},
+	{"last_core", "LastCore", priorityLow, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprint(r.CPUCore) }},
+	stateColumn,
+	diagColumn,
+}
+
+// threadCPUColumns lays out the CPU Hotspots table with -threads: one row
+// per thread, identified by its TID next to its process's PID.
+var threadCPUColumns = append([]column{pidColumn,
+	{"tid", "TID", priorityKeep, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprint(r.TID) }},
+}, cpuColumns[1:]...)
+
+// memoryColumns lays out the Memory Pressure table.
+var memoryColumns = []column{
+	pidColumn, commColumn, cgroupColumn, cpuMsColumn,
+	{"rss_mb", "RSS(MB)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.RSSMB) }},
+	{"huge_mb", "Huge(MB)", priorityLow, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.HugepagesMB) }},
+	{"rss_slope", "Slope(MB/min)", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.RSSSlopeMBPerMin) }},
+	{"faults", "Faults", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprint(r.Faults) }},
+	{"faults_per_sec", "Faults/sec", priorityHigh, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.1f", r.FaultsPerSec) + r.FaultsTrend
+	}},
+	{"cpu_cost_per_fault", "Cost/Fault(ms)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.CPUCostPerFault) }},
+	{"mem_efficiency", "MemEff", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.0f", r.MemEfficiency) }},
+	{"major_per_sec", "Major/sec", priorityMedium, func(r report.ProcMetrics, p probeSet) string {
+		if !p.majorLatency {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", r.MajorFaultsPerSec)
+	}},
+	{"major_ms", "Major(ms)", priorityMedium, func(r report.ProcMetrics, p probeSet) string {
+		if !p.majorLatency {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", r.MajorFaultLatencyMs)
+	}},
+	{"tlb_per_sec", "TLB/sec", priorityLow, func(r report.ProcMetrics, p probeSet) string {
+		if !p.tlb {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", r.TLBShootdownsPerSec)
+	}},
+	{"swapin_per_sec", "SwapIn/s", priorityLow, func(r report.ProcMetrics, p probeSet) string {
+		if !p.swap {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", r.SwapInsPerSec)
+	}},
+	{"swapout_per_sec", "SwapOut/s", priorityLow, func(r report.ProcMetrics, p probeSet) string {
+		if !p.swap {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", r.SwapOutsPerSec)
+	}},
+	diagColumn,
+}
+
+// blockIOColumns lays out the Disk I/O table.
+var blockIOColumns = []column{
+	pidColumn, commColumn, cgroupColumn,
+	{"read_mb", "Read(MB)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.1f", float64(r.IOReadBytes)/(1024*1024))
+	}},
+	{"write_mb", "Write(MB)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.1f", float64(r.IOWriteBytes)/(1024*1024))
+	}},
+	{"io_requests", "Requests", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprint(r.IORequests) }},
+	{"io_lat_ms", "AvgLat(ms)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.2f", r.IOLatencyMs) }},
+	stateColumn,
+	diagColumn,
+}
+
+// syscallColumns lays out the Syscalls table.
+var syscallColumns = []column{
+	pidColumn, commColumn, cgroupColumn,
+	{"syscall_ms", "Syscall(ms)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.SyscallMs) }},
+	{"syscall_pct", "Window%", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.SyscallPercent) }},
+	{"syscalls_per_sec", "Calls/s", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.0f", r.SyscallsPerSec) }},
+	{"top_syscall", "Top syscall", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return r.TopSyscall }},
+	diagColumn,
+}
+
+// futexColumns lays out the Locks table.
+var futexColumns = []column{
+	pidColumn, commColumn, cgroupColumn,
+	{"futex_wait_ms", "Wait(ms)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.FutexWaitMs) }},
+	{"futex_wait_pct", "Window%", priorityMedium, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.1f", r.FutexWaitPercent) }},
+	{"contended_per_sec", "Contended/s", priorityHigh, func(r report.ProcMetrics, _ probeSet) string { return fmt.Sprintf("%.0f", r.FutexContendedPerSec) }},
+	diagColumn,
+}
+
+// netColumns lays out the Network table.
+var netColumns = []column{
+	pidColumn, commColumn, cgroupColumn,
+	{"tx_mb", "TX(MB)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.1f", float64(r.NetTxBytes)/(1024*1024))
+	}},
+	{"rx_mb", "RX(MB)", priorityHigh, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.1f", float64(r.NetRxBytes)/(1024*1024))
+	}},
+	{"tx_mb_per_sec", "TX MB/s", priorityMedium, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.2f", r.NetTxBytesPerSec/(1024*1024))
+	}},
+	{"rx_mb_per_sec", "RX MB/s", priorityMedium, func(r report.ProcMetrics, _ probeSet) string {
+		return fmt.Sprintf("%.2f", r.NetRxBytesPerSec/(1024*1024))
+	}},
+	diagColumn,
+}
+
+// optionalColumns are left out of the default layout and shown only when
+// -columns names them. The spread of CPU% across -sample-window sub-windows
+// says nothing over CPU% without sub-windows, where all three are equal.
+var optionalColumns = map[string]bool{
+	"cpu_pct_max": true, "cpu_pct_avg": true,
+}
+
+// rollupLabels are the columns that describe a single process rather than
+// add up, left blank in the "others" row of -show-others (comm carries its
+// label instead).
+var rollupLabels = map[string]bool{
+	"pid": true, "comm": true, "cgroup": true, "last_core": true, "state": true, "diag": true,
+	"cpu_pct_max":    true, // the busiest sub-windows of different processes need not coincide
+	"mem_efficiency": true, // a score, not a quantity
+}
+
+// columnSet is the set of column ids selected with -columns. A nil set
+// selects every column but the optional ones, today's full layout.
+type columnSet map[string]bool
+
+// parseColumns parses a -columns value: a comma-separated list of column
+// ids from any process table. An empty value selects every column.
+func parseColumns(s string) (columnSet, error) {
+	known := make(map[string]bool)
+	for _, table := range [][]column{cpuColumns, threadCPUColumns, memoryColumns, blockIOColumns, syscallColumns, futexColumns, netColumns} {
+		for _, c := range table {
+			known[c.id] = true
+		}
+	}
+	var set columnSet
+	for _, id := range strings.Split(s, ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		if !known[id] {
+			ids := make([]string, 0, len(known))
+			for k := range known {
+				ids = append(ids, k)
+			}
+			sort.Strings(ids)
+			return nil, fmt.Errorf("unknown column %q (want one of %s)", id, strings.Join(ids, ", "))
+		}
+		if set == nil {
+			set = make(columnSet)
+		}
+		set[id] = true
+	}
+	return set, nil
+}
+
+// pick returns the columns of table that s selects, in the table's order.
+func (s columnSet) pick(table []column) []column {
+	var cols []column
+	for _, c := range table {
+		if s == nil && !optionalColumns[c.id] || s[c.id] {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// writeColumns writes rows as a table of cols, fitted to width terminal
+// cells (see fitColumns); a width of 0 writes every column in full. A
+// non-empty others rollup is added as a last row. A table none of whose
+// columns were selected gets a note instead, so its section is not
+// mistaken for an empty one.
+func writeColumns(body *bytes.Buffer, cols []column, rows []report.ProcMetrics, others report.Rollup, probes probeSet, width int) {
+	if len(cols) == 0 {
+		fmt.Fprintln(body, ui.C(ui.Dim, "No columns of this table selected by -columns"))
+		return
+	}
+	cells := make([][]string, len(cols))
+	for i, c := range cols {
+		cells[i] = make([]string, 0, len(rows)+1)
+		cells[i] = append(cells[i], c.header)
+		for _, row := range rows {
+			cells[i] = append(cells[i], c.value(row, probes))
+		}
+		if others.Procs > 0 {
+			cells[i] = append(cells[i], rollupCell(c, others, probes))
+		}
+	}
+	if width > 0 {
+		cells = fitColumns(cols, cells, width)
+	}
+
+	tw := ui.NewTable(body, columnGap)
+	line := make([]string, len(cells))
+	for r := range cells[0] {
+		for i := range cells {
+			line[i] = cells[i][r]
+		}
+		fmt.Fprintln(tw, strings.Join(line, "\t"))
+	}
+	tw.Flush()
+}
+
+// rollupCell renders column c of the "others" row.
+func rollupCell(c column, others report.Rollup, probes probeSet) string {
+	switch {
+	case c.id == "comm":
+		return fmt.Sprintf("others (%d processes)", others.Procs)
+	case rollupLabels[c.id]:
+		return ""
+	default:
+		return c.value(others.Row, probes)
+	}
+}
+
+// columnGap is the padding ui.Table puts between columns.
+const columnGap = 2
+
+// fitColumns returns the cells (one slice per column, header first) of a
+// table narrowed to width terminal cells. It first elides the elidable
+// columns down to their minimum widths, then drops columns by priority, and
+// returns the table as narrow as it got if it still does not fit.
+func fitColumns(cols []column, cells [][]string, width int) [][]string {
+	widths := make([]int, len(cells))
+	total := columnGap * (len(cells) - 1)
+	for i, col := range cells {
+		for _, cell := range col {
+			widths[i] = max(widths[i], ui.Width(cell))
+		}
+		total += widths[i]
+	}
+
+	for _, e := range elidable {
+		if total <= width {
+			return cells
+		}
+		for i, c := range cols {
+			if c.id != e.id {
+				continue
+			}
+			target := max(widths[i]-(total-width), max(e.minWidth, ui.Width(c.header)))
+			if target >= widths[i] {
+				continue
+			}
+			for r := 1; r < len(cells[i]); r++ {
+				cells[i][r] = e.elide(cells[i][r], target)
+			}
+			total -= widths[i] - target
+			widths[i] = target
+		}
+	}
+
+	cols = append([]column(nil), cols...)
+	for total > width {
+		drop := -1
+		for i, c := range cols {
+			if c.priority < priorityKeep && (drop < 0 || c.priority <= cols[drop].priority) {
+				drop = i
+			}
+		}
+		if drop < 0 {
+			break
+		}
+		total -= widths[drop] + columnGap
+		cols = append(cols[:drop], cols[drop+1:]...)
+		cells = append(cells[:drop], cells[drop+1:]...)
+		widths = append(widths[:drop], widths[drop+1:]...)
+	}
+	return cells
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/srodi/hotspot-bpf/pkg/ui"
+)
+
+// Keys handled in the interactive view.
+const (
+	keyPause = ' '
+	keyQuit  = 'q'
+	keyMore  = '+'
+	keyLess  = '-'
+)
+
+// readKeys returns a channel of single keypresses read from stdin, or nil
+// when stdin or stdout is not a terminal (piped, redirected, or under a
+// supervisor), so the caller's select simply never fires. It relies on
+// enterCbreakMode having turned off line buffering; otherwise keys arrive
+// only after Enter. The reader goroutine stops at EOF, or when ctx is
+// cancelled while a key is pending.
+func readKeys(ctx context.Context) <-chan byte {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 0 {
+				continue
+			}
+			select {
+			case keys <- buf[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return keys
+}
+
+// adjust returns l with every section's limit changed by delta, never below 1.
+func (l topKLimits) adjust(delta int) topKLimits {
+	return topKLimits{
+		CPU:        max(l.CPU+delta, 1),
+		Contention: max(l.Contention+delta, 1),
+		Cost:       max(l.Cost+delta, 1),
+		IO:         max(l.IO+delta, 1),
+		Syscall:    max(l.Syscall+delta, 1),
+		Lock:       max(l.Lock+delta, 1),
+		Net:        max(l.Net+delta, 1),
+	}
+}
+
+// showPaused overwrites the first line of the frame on screen with a pause
+// marker. The frame itself stays as it was; the next one after resuming is
+// drawn in full.
+func showPaused() {
+	fmt.Print("\033[H\033[2K" + ui.C(ui.Bold+ui.Yellow, "PAUSED") + ui.C(ui.Dim, "  space to resume · q to quit"))
+}
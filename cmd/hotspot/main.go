@@ -10,7 +10,11 @@
 //   5. Reset:    clear all BPF maps for the next sampling window
 //
 // The reset-after-render pattern means all metrics are windowed — they reflect
-// only the activity since the previous tick, not cumulative totals.
+// only the activity since the previous tick, not cumulative totals. With
+// -cumulative step 5 is skipped and step 2 starts by subtracting the previous
+// tick's readings (report.Cumulative), so the metrics are still windowed.
+// With -sample-window steps 1 and 5 also run several times per tick, and
+// step 2 starts by combining those sub-windows (report.SubWindows).
 
 //go:build linux
 
@@ -21,51 +25,226 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
-	"text/tabwriter"
 	"time"
 
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/cgroup"
+	"github.com/srodi/hotspot-bpf/pkg/collector/blockio"
 	"github.com/srodi/hotspot-bpf/pkg/collector/cpu"
+	"github.com/srodi/hotspot-bpf/pkg/collector/futex"
 	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
+	"github.com/srodi/hotspot-bpf/pkg/collector/network"
+	"github.com/srodi/hotspot-bpf/pkg/collector/syscalls"
+	"github.com/srodi/hotspot-bpf/pkg/collector/system"
 	"github.com/srodi/hotspot-bpf/pkg/config"
+	"github.com/srodi/hotspot-bpf/pkg/container"
+	"github.com/srodi/hotspot-bpf/pkg/engine"
+	"github.com/srodi/hotspot-bpf/pkg/export"
+	"github.com/srodi/hotspot-bpf/pkg/logging"
+	"github.com/srodi/hotspot-bpf/pkg/prom"
+	"github.com/srodi/hotspot-bpf/pkg/recorder"
 	"github.com/srodi/hotspot-bpf/pkg/report"
+	"github.com/srodi/hotspot-bpf/pkg/stream"
 	"github.com/srodi/hotspot-bpf/pkg/types"
 	"github.com/srodi/hotspot-bpf/pkg/ui"
+	"github.com/srodi/hotspot-bpf/pkg/webhook"
 	"golang.org/x/sys/unix"
 	"golang.org/x/term"
 )
 
-// version is set at build time via -ldflags.
-var version = "dev"
-
 const defaultInterval = 5 * time.Second
 
+// Bounds for -interval-adaptive when -interval-floor/-interval-ceiling are unset.
+const (
+	defaultIntervalFloor   = 1 * time.Second
+	defaultIntervalCeiling = 30 * time.Second
+)
+
+// Below this terminal size the full tables wrap into an unreadable mess, so
+// the table format falls back to the compact view without the banner.
+const (
+	minFullWidth  = 80
+	minFullHeight = 24
+)
+
+// Output formats accepted by -format.
+const (
+	formatTable        = "table"         // full CPU, contention, and memory tables
+	formatTableCompact = "table-compact" // one row per non-OK process: PID, COMM, key metric, diagnosis
+	formatCSV          = "csv"           // one CSV row per process per interval, header once (export.StreamColumns)
+)
+
+// diagLog is where hotspot's own log records go: stderr, except that
+// enableSingleView holds them while the alternate screen is up.
+var diagLog = logging.NewHolder(os.Stderr)
+
 type runConfig struct {
-	interval     time.Duration
-	topK         int
-	hideKernel   bool
-	cgroupFilter string
-	exclude      []string
-	thresholds   config.Thresholds
+	interval       time.Duration
+	adaptive       bool
+	floor          time.Duration
+	ceiling        time.Duration
+	topK           topKLimits
+	sortKey        report.SortKey // orders the CPU Hotspots table
+	columns        columnSet      // process table columns to show; nil = all
+	hideKernel     bool
+	cgroupFilter   []string // -cgroup-filter: keep processes whose cgroup contains any of these
+	cgroupExclude  []string // -cgroup-exclude: hide processes whose cgroup contains any of these
+	cgroupRegex    *regexp.Regexp
+	commRegex      *regexp.Regexp
+	exeFilter      string
+	watchCgroup    string
+	cgroupPath     string // -cgroup-path: collect only from this cgroup v2 subtree, in the kernel
+	exclude        []string
+	pids           map[uint32]struct{} // -pid: show only these processes; nil = no list
+	ppid           uint32              // -ppid: show only children of this process; 0 = off
+	ppidTree       bool                // with ppid: every descendant, not just children
+	includeSelf    bool                // -include-self: show hotspot and its descendants
+	format         string
+	noAltScreen    bool
+	count          int           // stop after this many snapshots; 0 runs until interrupted (-once is -count=1)
+	snapshot       time.Duration // -snapshot: sample one window this long, print it as JSON, and exit; 0 = off
+	exportDir      string        // with count: also write each snapshot as txt, json, and csv here
+	socketPath     string        // stream each snapshot as JSON Lines to clients of this Unix socket
+	metricsAddr    string        // serve Prometheus metrics for the top rows on this address
+	webhookURL     string        // -webhook-url: POST an alert here when a process reaches webhookSeverity
+	webhookSev     int           // least severe diagnosis alerted on
+	webhookCool    time.Duration // repeat alerts for one process and diagnosis at most this often
+	containers     bool          // show pod and container names instead of raw cgroups
+	fullCmd        bool          // show command lines instead of 15-character comms
+	interactive    bool          // keypresses are read (alternate screen on a terminal)
+	paused         bool          // keep sampling but leave the frame on screen as it is
+	term           termSize      // stdout's size, re-read on SIGWINCH; zero when not a terminal
+	window         time.Duration // measured length of the window being sampled; 0 = assume interval
+	intervalNote   string        // how a signal last changed the interval, shown in the header for one frame
+	debugFilters   bool
+	focusPID       uint32 // pin the Focus section to this process; 0 = automatic
+	onlyContention bool
+	memProfile     string
+	flightSize     int
+	flightDir      string
+	flightTrigger  string               // diagnosis label; "" = dump on SIGQUIT only
+	stateFile      string               // persist the latest complete snapshot here
+	stateEvery     int                  // save the state file every N intervals
+	stateRestore   bool                 // show the saved state until the first interval completes
+	logFile        string               // append every complete snapshot here as JSON Lines
+	logMaxSize     int64                // rotate logFile past this many bytes; 0 = never
+	net            bool                 // -net: count TCP bytes per process and show the Network table
+	profileFocus   bool                 // -profile-focus: sample the Focus process's stacks and show a Profile pane
+	minSeverity    int                  // diagnoses below this severity are treated as OK in Focus and triggers
+	cumulative     bool                 // -cumulative: never reset the maps; each window is the change since the previous reading
+	sampleWindow   time.Duration        // -sample-window: collect and reset this often, combining the sub-windows of each interval; 0 = off
+	sampleAggr     report.AggregateMode // how sampleWindow's sub-windows are combined
+	smooth         float64              // -smooth: weight of the newest interval in the CPU and fault moving averages; 0 = off
+	showOthers     bool                 // -show-others: sum the processes beyond topK into a last row of the CPU and memory tables
+	trend          bool                 // -trend: mark CPU% and faults/sec with their direction against the previous interval
+	aggrDiag       bool
+	groupByComm    bool
+	cpuOptions     cpu.Options
+	identity       report.IdentityStrategy
+	thresholds     config.Thresholds
 }
 
 func parseConfig() runConfig {
 	interval := flag.Duration("interval", defaultInterval, "sampling interval (e.g. 3s, 1m)")
-	topK := flag.Int("topk", types.DefaultTopK, "number of processes to display per section")
+	adaptive := flag.Bool("interval-adaptive", false, "shorten the interval while non-OK diagnoses are present and lengthen it when all clear")
+	floor := flag.Duration("interval-floor", defaultIntervalFloor, "shortest interval used by -interval-adaptive and reached by SIGUSR1")
+	ceiling := flag.Duration("interval-ceiling", defaultIntervalCeiling, "longest interval used by -interval-adaptive and reached by SIGUSR2")
+	topK := flag.String("topk", strconv.Itoa(types.DefaultTopK), "rows per section: N for all, or per section as cpu=N,contention=N,cost=N,io=N,syscall=N,lock=N,net=N (a bare N sets the default for unnamed sections, e.g. 8,cost=3)")
+	columns := flag.String("columns", "", "comma-separated column ids to show in the process tables (e.g. pid,comm,cpu_pct,faults,diag); each table shows the selected columns it has, in its usual order (default every column)")
+	sortKey := flag.String("sort", string(report.SortCPU), "order the main process table by cpu, faults, rss, cost, or preempted")
 	hideKernel := flag.Bool("hide-kernel", true, "hide kernel threads such as kworker, ksoftirqd, etc")
-	cgroupFilter := flag.String("cgroup-filter", "", "only show processes whose cgroup path contains this substring (case-insensitive)")
+	includeSelf := flag.Bool("include-self", false, "show hotspot itself and its descendants in the tables and contention pairs, which are hidden by default; for debugging the tool's own overhead")
+	var cgroupFilter, cgroupExclude []string
+	flag.Func("cgroup-filter", "only show processes whose cgroup path contains this substring (case-insensitive); repeat the flag or separate substrings with commas to show processes matching any of them", listFlag(&cgroupFilter))
+	flag.Func("cgroup-exclude", "hide processes whose cgroup path contains this substring (case-insensitive), e.g. /system.slice; repeatable or comma-separated like -cgroup-filter, and applied after it", listFlag(&cgroupExclude))
+	exeFilter := flag.String("exe-filter", "", "only show processes whose executable path (/proc/PID/exe) contains this substring (case-sensitive, e.g. /usr/local/bin/myapp)")
+	watchCgroup := flag.String("watch-cgroup", "", "only show processes currently in this cgroup v2 path or its descendants (e.g. /system.slice/nginx.service); membership is re-read every interval")
+	cgroupPath := flag.String("cgroup-path", "", "only collect from tasks in this cgroup v2 path or its descendants, checked in the eBPF programs so other tasks cost no map updates; needs -cpu-method sched or perf")
 	exclude := flag.String("exclude", "", "comma-separated list of command names or PIDs to hide (e.g. wdavdaemon,2574)")
+	cgroupRegex := flag.String("cgroup-regex", "", "only show processes whose cgroup path matches this regular expression (case-sensitive; prefix (?i) to ignore case); applied together with -cgroup-filter")
+	commRegex := flag.String("comm-regex", "", "only show processes whose command name matches this regular expression (e.g. '^(nginx|envoy)$')")
+	pidList := flag.String("pid", "", "comma-separated list of PIDs to show, ignoring every other filter (e.g. 812,2574)")
+	ppid := flag.Uint("ppid", 0, "only show children of this parent PID (read from /proc/PID/stat)")
+	ppidTree := flag.Bool("ppid-tree", false, "with -ppid, show the parent's whole process tree instead of only its direct children")
+	threads := flag.Bool("threads", false, "account CPU time per thread and show the busiest threads, with their TID and thread name (from /proc/PID/task/TID/comm), in the CPU Hotspots table instead of processes, to find one runaway thread in an otherwise calm process; other tables and diagnoses stay per process. Needs -cpu-method sched or perf; not with -cumulative or -sample-window, and -show-others then sums only the Memory Pressure table")
+	showOthers := flag.Bool("show-others", false, "add an \"others (N processes)\" row to the CPU Hotspots and Memory Pressure tables summing every process below the top -topk, to show whether the top rows dominate or the load is spread thin")
+	trend := flag.Bool("trend", false, "compare each process's CPU% and faults/sec with the previous interval and mark them rising (↑), falling (↓), or steady (→) in the CPU Hotspots and Memory Pressure tables")
+	smooth := flag.Float64("smooth", 0, "keep moving averages of CPU% and faults/sec per process, giving the newest interval this weight (0-1, e.g. 0.3), and rank the Focus section by them so sustained offenders come before one-interval spikes (0 = off)")
+	cumulative := flag.Bool("cumulative", false, "never reset the eBPF maps between intervals; each window is computed as the change since the previous reading instead, so no event is lost to a reset (maps then hold every PID seen since startup, see README)")
+	sampleWindow := flag.Duration("sample-window", 0, "collect and reset the counters this often (e.g. 1s) and combine the sub-windows of each -interval as -sample-aggregate says, so the measurement window can be shorter than the screen refresh; must be shorter than -interval (0 = one window per interval)")
+	sampleAggr := flag.String("sample-aggregate", string(report.AggregateAvg), "how -sample-window sub-windows are combined: sum (totals over the interval), avg (the mean sub-window), or max (each metric's highest sub-window, so a short burst is not diluted)")
+	maxTracked := flag.Int("max-tracked-history", 0, "maximum processes with cross-interval RSS history; least recently seen are evicted (default from config, 4096)")
+	minFaults := flag.Int("faults-threshold-absolute", -1, "minimum page faults in a window before fault-rate diagnoses (OOM risk, Mem-thrashing) apply; -1 uses the config value (default 50)")
+	groupByComm := flag.Bool("group-threads-by-comm", false, "merge contention pairs of processes sharing a command name (e.g. worker pools) into one row")
+	aggrDiag := flag.Bool("aggressor-diag", false, "add the aggressor's own diagnosis as a column in the contention table")
+	cpuMethod := flag.String("cpu-method", string(cpu.MethodSched), "CPU accounting backend: sched (exact, per context switch), perf (sampled, lower overhead), or proc (/proc/PID/stat deltas, no eBPF); perf and proc record no contention")
+	perfFreq := flag.Int("perf-freq", cpu.DefaultPerfFrequency, "sampling frequency in Hz per CPU for -cpu-method perf")
+	bpfObjectDir := flag.String("bpf-object-dir", "", "load the eBPF programs from the <name>_bpfel.o files go generate writes (e.g. hotspot_bpf_bpfel.o) in this directory instead of the objects built into the binary, for kernels the built-in CO-RE objects do not relocate against")
+	netFlag := flag.Bool("net", false, "count TCP bytes sent and received per process and show a Network table; the kprobes on tcp_sendmsg and tcp_cleanup_rbuf fire on every send and receive, so this adds overhead on busy hosts")
+	spikeThreshold := flag.Duration("spike-threshold", 0, "stream every run of a thread at least this long without a context switch (e.g. 50ms) into a Recent Spikes pane, catching bursts shorter than the interval; needs -cpu-method sched and Linux 5.8 (0 = off)")
+	flightSize := flag.Int("flight-recorder", 0, "keep the last N complete snapshots in memory and write them to a JSON Lines file on SIGQUIT (0 = off)")
+	flightDir := flag.String("flight-recorder-dir", ".", "directory for -flight-recorder dumps")
+	flightTrigger := flag.String("flight-recorder-trigger", "", "also dump automatically when a diagnosis at least this severe first appears (e.g. mem-thrashing, oom)")
+	stateFile := flag.String("state-file", "", "save the latest complete snapshot to this file every -state-every intervals, replacing it atomically, so it survives a crash of hotspot itself")
+	stateEvery := flag.Int("state-every", 1, "intervals between -state-file saves")
+	stateRestore := flag.Bool("state-restore", false, "on startup, show the snapshot saved in -state-file until the first interval completes")
+	logFile := flag.String("log-file", "", "append every complete snapshot to this file as JSON Lines, alongside the live view, for long-running captures")
+	logMaxSize := flag.Int("log-max-size", 100, "rotate -log-file to FILE.1, FILE.2, ... once it would grow past this many MB, keeping 5 rotated files (0 = never rotate)")
+	minSeverity := flag.String("min-severity", "0", "ignore diagnoses less severe than this in the Focus section, table-compact, -interval-adaptive, and -flight-recorder-trigger: a diagnosis label (e.g. starved) or a level (1 = least severe, 0 = all)")
+	memProfile := flag.String("memprofile", "", "write a heap profile of hotspot itself to this file on clean shutdown")
+	focusPID := flag.Uint("focus-pid", 0, "pin the Focus section to this PID, showing its diagnosis and key metrics every interval instead of the automatically selected processes")
+	profileFocus := flag.Bool("profile-focus", false, "sample the call stacks of the Focus process (the -focus-pid process, or the first one listed) at 99 Hz and show the functions its CPU time went to in a Profile pane; kernel frames need root for /proc/kallsyms")
+	onlyContention := flag.Bool("only-contention", false, "render only the contention section, expanded with aggressor diagnoses and fairness scores")
+	debugFilters := flag.Bool("debug-filters", false, "show how many processes each filter (-pid, -hide-kernel, -cgroup-filter/-cgroup-exclude/-cgroup-regex, -comm-regex, -watch-cgroup, -exe-filter, -exclude, -ppid, and hiding hotspot itself) removed in the header")
+	noColor := flag.Bool("no-color", false, "print plain text without ANSI colors; also set by the NO_COLOR environment variable and when stdout is not a terminal")
+	logFormat := flag.String("log-format", logging.FormatText, "format of hotspot's own warnings and errors on stderr: text (key=value) or json (one object per line); while the alternate-screen view is up they are held and printed when it closes, unless stderr is redirected")
+	noAltScreen := flag.Bool("no-alt-screen", false, "print each snapshot to the normal terminal buffer so history stays in scrollback")
+	once := flag.Bool("once", false, "print a single snapshot after one interval and exit (same as -count=1)")
+	count := flag.Int("count", 0, "print this many snapshots, one per interval, and exit; 0 runs until interrupted")
+	snapshot := flag.Bool("snapshot", false, "sample a single -snapshot-window, print it to stdout as one JSON document with the kernel version and CPU count, and exit without touching the terminal; failures print a JSON error object and exit 1")
+	snapshotWindow := flag.Duration("snapshot-window", 2*time.Second, "sampling window for -snapshot")
+	exportDir := flag.String("export-dir", "", "with -once or -count, also write each snapshot to snapshot.txt, snapshot.json, and snapshot.csv in this directory (the last one remains)")
+	socketPath := flag.String("socket", "", "also stream each snapshot as JSON Lines to every client connected to a Unix domain socket at this path (created mode 0600, removed on exit)")
+	metricsAddr := flag.String("metrics-addr", "", "also serve Prometheus metrics at /metrics on this address (e.g. :9464), for the top -topk processes of each section labelled per -identity")
+	webhookURL := flag.String("webhook-url", "", "POST a JSON alert (pid, comm, cgroup, pod, diagnosis, summary) to this http(s) URL when a process reaches -webhook-min-severity, retrying failed deliveries with backoff")
+	webhookSeverity := flag.String("webhook-min-severity", "starved", "least severe diagnosis that triggers -webhook-url: a diagnosis label (e.g. mem-thrashing) or a level")
+	webhookCooldown := flag.Duration("webhook-cooldown", 10*time.Minute, "repeat the -webhook-url alert for a process that keeps a diagnosis once per this period; one that regains a diagnosis it lost is alerted at once")
+	fullCmd := flag.Bool("full-cmd", false, "show each process's command line (from /proc/PID/cmdline, secrets redacted, cut to 80 characters) instead of the 15-character comm")
+	resolveContainers := flag.Bool("resolve-containers", false, "show Kubernetes pod and container names instead of raw cgroup paths, read from /var/lib/kubelet/pods and the Docker socket (cached per container)")
+	format := flag.String("format", formatTable, "output format: table, table-compact (non-OK processes only, one line each), or csv (one row per process per interval, for spreadsheets and pandas)")
+	identity := flag.String("identity", string(report.IdentityPID), "process identity for exported metric series: pid or comm-cgroup (stable across restarts)")
 	configPath := flag.String("config", "", "path to YAML config file for classification thresholds (see -generate-config)")
 	generateConfig := flag.Bool("generate-config", false, "print the default config YAML to stdout and exit")
-	showVersion := flag.Bool("version", false, "print version and exit")
+	listDiagnoses := flag.Bool("list-diagnoses", false, "print every diagnosis label with its severity, meaning, and trigger conditions under the current thresholds (-config), and exit")
+	dryClassify := flag.String("dry-classify", "", "re-classify the processes in a -flight-recorder dump or -export-dir snapshot.json with the current thresholds (-config), print every label that changes, and exit")
+	selfTest := flag.Bool("selftest", false, "check that the eBPF programs load and attach here (kernel BTF, capabilities, every expected map and program, the probes attached and detached again), print what was found, and exit 0, or 1 naming each failed check; for CI and preflight")
+	showVersion := flag.Bool("version", false, "print the version, git commit, build date, kernel release, and CO-RE/BTF support, and exit")
 	flag.Parse()
 
+	logger, err := logging.New(diagLog, strings.ToLower(strings.TrimSpace(*logFormat)))
+	if err != nil {
+		logging.Fatalf("parsing -log-format: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	if *noColor {
+		ui.SetColorEnabled(false)
+	}
+
 	if *showVersion {
-		fmt.Printf("hotspot-bpf %s\n", version)
+		writeVersion(os.Stdout)
 		os.Exit(0)
 	}
 
@@ -76,20 +255,74 @@ func parseConfig() runConfig {
 
 	th := config.Default()
 	if *configPath != "" {
-		var err error
 		th, err = config.LoadFile(*configPath)
 		if err != nil {
-			log.Fatalf("loading config: %v", err)
+			logging.Fatalf("loading config: %v", err)
 		}
 	}
 
+	if *maxTracked > 0 {
+		th.RSSTracker.MaxTracked = *maxTracked
+	}
+	if *minFaults >= 0 {
+		th.Faults.MinCount = uint64(*minFaults)
+	}
+
+	if *listDiagnoses {
+		writeDiagnoses(os.Stdout, th)
+		os.Exit(0)
+	}
+
+	if *dryClassify != "" {
+		if err := runDryClassify(os.Stdout, *dryClassify, th); err != nil {
+			logging.Fatalf("dry-classify: %v", err)
+		}
+		os.Exit(0)
+	}
+
 	cfg := runConfig{
-		interval:     *interval,
-		topK:         *topK,
-		hideKernel:   *hideKernel,
-		cgroupFilter: strings.ToLower(strings.TrimSpace(*cgroupFilter)),
-		exclude:      th.Exclude,
-		thresholds:   th,
+		interval:       *interval,
+		adaptive:       *adaptive,
+		floor:          *floor,
+		ceiling:        *ceiling,
+		hideKernel:     *hideKernel,
+		includeSelf:    *includeSelf,
+		cgroupFilter:   cgroupFilter,
+		cgroupExclude:  cgroupExclude,
+		exeFilter:      strings.TrimSpace(*exeFilter),
+		watchCgroup:    strings.TrimSpace(*watchCgroup),
+		cgroupPath:     strings.TrimSpace(*cgroupPath),
+		exclude:        th.Exclude,
+		format:         strings.ToLower(strings.TrimSpace(*format)),
+		noAltScreen:    *noAltScreen || *once || *count > 0, // a bounded capture must stay on screen after exit
+		count:          *count,
+		exportDir:      strings.TrimSpace(*exportDir),
+		socketPath:     strings.TrimSpace(*socketPath),
+		metricsAddr:    strings.TrimSpace(*metricsAddr),
+		webhookURL:     strings.TrimSpace(*webhookURL),
+		webhookCool:    *webhookCooldown,
+		containers:     *resolveContainers,
+		fullCmd:        *fullCmd,
+		debugFilters:   *debugFilters,
+		focusPID:       uint32(*focusPID),
+		onlyContention: *onlyContention,
+		memProfile:     *memProfile,
+		flightSize:     *flightSize,
+		flightDir:      *flightDir,
+		stateFile:      strings.TrimSpace(*stateFile),
+		stateEvery:     *stateEvery,
+		stateRestore:   *stateRestore,
+		logFile:        strings.TrimSpace(*logFile),
+		logMaxSize:     int64(*logMaxSize) << 20,
+		net:            *netFlag,
+		profileFocus:   *profileFocus,
+		cumulative:     *cumulative,
+		sampleWindow:   *sampleWindow,
+		showOthers:     *showOthers,
+		trend:          *trend,
+		aggrDiag:       *aggrDiag,
+		groupByComm:    *groupByComm,
+		thresholds:     th,
 	}
 	if ex := strings.TrimSpace(*exclude); ex != "" {
 		for _, s := range strings.Split(ex, ",") {
@@ -98,132 +331,1122 @@ func parseConfig() runConfig {
 			}
 		}
 	}
+	// Patterns are compiled once here, so a typo fails at startup rather
+	// than silently hiding every process.
+	if *cgroupRegex != "" {
+		re, err := regexp.Compile(*cgroupRegex)
+		if err != nil {
+			logging.Fatalf("parsing -cgroup-regex: %v", err)
+		}
+		cfg.cgroupRegex = re
+	}
+	if *commRegex != "" {
+		re, err := regexp.Compile(*commRegex)
+		if err != nil {
+			logging.Fatalf("parsing -comm-regex: %v", err)
+		}
+		cfg.commRegex = re
+	}
+	if *pidList != "" {
+		cfg.pids = make(map[uint32]struct{})
+		for _, s := range strings.Split(*pidList, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			pid, err := strconv.ParseUint(s, 10, 32)
+			if err != nil || pid == 0 {
+				logging.Fatalf("parsing -pid: invalid PID %q", s)
+			}
+			cfg.pids[uint32(pid)] = struct{}{}
+		}
+	}
+	if uint(uint32(*ppid)) != *ppid {
+		logging.Fatalf("parsing -ppid: %d is not a valid PID", *ppid)
+	}
+	cfg.ppid, cfg.ppidTree = uint32(*ppid), *ppidTree
+	if cfg.ppidTree && cfg.ppid == 0 {
+		logging.Fatalf("-ppid-tree requires -ppid")
+	}
+
+	limits, err := parseTopK(*topK)
+	if err != nil {
+		logging.Fatalf("parsing -topk: %v", err)
+	}
+	cfg.topK = limits
+
+	if cfg.columns, err = parseColumns(*columns); err != nil {
+		logging.Fatalf("parsing -columns: %v", err)
+	}
+
+	method, err := cpu.ParseMethod(*cpuMethod)
+	if err != nil {
+		logging.Fatalf("parsing -cpu-method: %v", err)
+	}
+	if *spikeThreshold < 0 {
+		logging.Fatalf("-spike-threshold must not be negative, got %v", *spikeThreshold)
+	}
+	if *spikeThreshold > 0 && method != cpu.MethodSched {
+		logging.Fatalf("-spike-threshold requires -cpu-method sched")
+	}
+	if cfg.cgroupPath != "" && method == cpu.MethodProc {
+		logging.Fatalf("-cgroup-path requires -cpu-method sched or perf")
+	}
+	if *threads {
+		switch {
+		case method == cpu.MethodProc:
+			logging.Fatalf("-threads requires -cpu-method sched or perf")
+		case *cumulative:
+			logging.Fatalf("-threads conflicts with -cumulative")
+		case *sampleWindow > 0:
+			logging.Fatalf("-threads conflicts with -sample-window")
+		}
+	}
+	cfg.cpuOptions = cpu.Options{Method: method, PerfFrequency: *perfFreq, SpikeThreshold: *spikeThreshold, CgroupPath: cfg.cgroupPath, Threads: *threads}
+	if err := bpfenv.SetObjectDir(strings.TrimSpace(*bpfObjectDir)); err != nil {
+		logging.Fatalf("-bpf-object-dir: %v", err)
+	}
+	if *selfTest {
+		os.Exit(runSelfTest(os.Stdout, cfg))
+	}
+
+	if *flightTrigger != "" {
+		if cfg.flightSize <= 0 {
+			logging.Fatalf("-flight-recorder-trigger requires -flight-recorder N")
+		}
+		if cfg.flightTrigger, err = report.ParseDiagnosis(*flightTrigger); err != nil {
+			logging.Fatalf("parsing -flight-recorder-trigger: %v", err)
+		}
+	}
+
+	if cfg.stateFile == "" && cfg.stateRestore {
+		logging.Fatalf("-state-restore requires -state-file")
+	}
+	if cfg.stateEvery < 1 {
+		logging.Fatalf("-state-every must be at least 1, got %d", cfg.stateEvery)
+	}
+	if *logMaxSize < 0 {
+		logging.Fatalf("-log-max-size must not be negative, got %d", *logMaxSize)
+	}
+
+	if cfg.minSeverity, err = report.ParseSeverity(*minSeverity); err != nil {
+		logging.Fatalf("parsing -min-severity: %v", err)
+	}
+	if cfg.webhookURL != "" {
+		if u, err := url.Parse(cfg.webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			logging.Fatalf("-webhook-url must be an http or https URL, got %q", cfg.webhookURL)
+		}
+		if cfg.webhookSev, err = report.ParseSeverity(*webhookSeverity); err != nil {
+			logging.Fatalf("parsing -webhook-min-severity: %v", err)
+		}
+		if cfg.webhookCool <= 0 {
+			logging.Fatalf("-webhook-cooldown must be positive, got %v", cfg.webhookCool)
+		}
+	}
+	if *smooth < 0 || *smooth > 1 {
+		logging.Fatalf("-smooth must be between 0 and 1, got %g", *smooth)
+	}
+	cfg.smooth = *smooth
+
+	identityStrategy, err := report.ParseIdentityStrategy(*identity)
+	if err != nil {
+		logging.Fatalf("parsing -identity: %v", err)
+	}
+	cfg.identity = identityStrategy
+
+	if cfg.sortKey, err = report.ParseSortKey(*sortKey); err != nil {
+		logging.Fatalf("parsing -sort: %v", err)
+	}
+	if cfg.sampleAggr, err = report.ParseAggregateMode(*sampleAggr); err != nil {
+		logging.Fatalf("parsing -sample-aggregate: %v", err)
+	}
+
+	if cfg.count < 0 {
+		logging.Fatalf("-count must be >= 0, got %d", cfg.count)
+	}
+	if *snapshot {
+		if *snapshotWindow <= 0 {
+			logging.Fatalf("-snapshot-window must be positive, got %v", *snapshotWindow)
+		}
+		cfg.snapshot = *snapshotWindow
+	}
+	if *once {
+		if cfg.count > 1 {
+			logging.Fatalf("-once conflicts with -count=%d", cfg.count)
+		}
+		cfg.count = 1
+	}
+
+	switch cfg.format {
+	case formatTable, formatTableCompact:
+	case formatCSV:
+		cfg.noAltScreen = true // rows go to stdout, usually redirected to a file
+	default:
+		logging.Fatalf("unknown -format %q (want %s, %s, or %s)", *format, formatTable, formatTableCompact, formatCSV)
+	}
+	if cfg.exportDir != "" {
+		if cfg.count == 0 {
+			logging.Fatalf("-export-dir requires -once or -count")
+		}
+		if info, err := os.Stat(cfg.exportDir); err != nil || !info.IsDir() {
+			logging.Fatalf("-export-dir %s is not a directory", cfg.exportDir)
+		}
+	}
+	if cfg.watchCgroup != "" {
+		if _, err := cgroup.Members(cfg.watchCgroup); err != nil {
+			logging.Fatalf("reading -watch-cgroup %s: %v", cfg.watchCgroup, err)
+		}
+	}
+	if cfg.cgroupPath != "" {
+		if info, err := os.Stat(cgroup.Resolve(cfg.cgroupPath)); err != nil || !info.IsDir() {
+			logging.Fatalf("-cgroup-path %s is not a cgroup directory", cfg.cgroupPath)
+		}
+	}
 	if cfg.interval <= 0 {
 		cfg.interval = defaultInterval
 	}
-	if cfg.topK <= 0 {
-		cfg.topK = 1
+	if cfg.adaptive {
+		if cfg.floor <= 0 || cfg.ceiling <= 0 || cfg.floor > cfg.ceiling {
+			logging.Fatalf("invalid adaptive bounds: -interval-floor=%v must be positive and <= -interval-ceiling=%v", cfg.floor, cfg.ceiling)
+		}
+		cfg.interval = clampInterval(cfg.interval, cfg.floor, cfg.ceiling)
+	}
+	if cfg.sampleWindow < 0 {
+		logging.Fatalf("-sample-window must be >= 0, got %v", cfg.sampleWindow)
+	}
+	if shortest := cfg.interval; cfg.sampleWindow > 0 {
+		if cfg.adaptive {
+			shortest = cfg.floor
+		}
+		if cfg.sampleWindow >= shortest {
+			logging.Fatalf("-sample-window=%v must be shorter than the interval (%v)", cfg.sampleWindow, shortest)
+		}
 	}
 	return cfg
 }
 
+// subWindowsPerInterval returns how many -sample-window sub-windows make up
+// one display interval, at least one. The remainder of an interval that is
+// not a multiple of the sub-window is dropped.
+func (cfg runConfig) subWindowsPerInterval() int {
+	return max(int(cfg.interval/cfg.sampleWindow), 1)
+}
+
 func main() {
+	// Parsed first so modes that load no eBPF (-version, -generate-config,
+	// -list-diagnoses, -dry-classify) also run unprivileged.
+	cfg := parseConfig()
+	if cfg.snapshot > 0 {
+		os.Exit(runSnapshot(os.Stdout, cfg))
+	}
+
 	// Raise rlimit for locked memory to allow eBPF programs to load.
 	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{
 		Cur: unix.RLIM_INFINITY,
 		Max: unix.RLIM_INFINITY,
 	}); err != nil {
-		log.Fatalf("failed to raise rlimit memlock: %v", err)
+		logging.Fatalf("failed to raise rlimit memlock: %v", err)
 	}
 
-	cfg := parseConfig()
+	exitCode := 0
+	defer func() {
+		// Registered first so it runs after every other deferred cleanup.
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+	if cfg.memProfile != "" {
+		// Deferred first so it runs last, after the terminal is restored.
+		defer writeHeapProfile(cfg.memProfile)
+	}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	cpuCollector, err := cpu.NewCollector()
+	cpuCollector, err := cpu.NewCollectorWithOptions(cfg.cpuOptions)
 	if err != nil {
-		log.Fatalf("initializing CPU collector: %v", err)
+		logging.Fatalf("initializing CPU collector: %v", err)
 	}
 	defer cpuCollector.Close()
+	var spikes *spikeLog
+	if cfg.cpuOptions.SpikeThreshold > 0 {
+		spikes = &spikeLog{}
+		if cpuCollector.SpikeTracking() {
+			go spikes.follow(cpuCollector) // returns when the collector is closed
+		}
+	}
 
-	memCollector, err := memory.NewCollector()
+	memCollector, err := memory.NewCollectorForCgroup(cfg.cgroupPath)
 	if err != nil {
-		log.Fatalf("initializing memory collector: %v", err)
+		logging.Fatalf("initializing memory collector: %v", err)
 	}
 	defer memCollector.Close()
+	if cfg.cumulative {
+		memCollector.SetCumulative()
+	}
+
+	// Block I/O is best-effort: without the block tracepoints (kernels
+	// before 5.11, or no BTF for them) the I/O table explains why it is
+	// empty and every other section works as usual.
+	blockCollector, blockErr := blockio.NewCollectorForCgroup(cfg.cgroupPath)
+	if blockErr == nil {
+		defer blockCollector.Close()
+	} else {
+		slog.Warn("collector unavailable", "collector", "blockio", "err", blockErr)
+	}
+	readBlockIO := func(limit int) ([]types.BlockIOStat, error) {
+		if blockErr != nil {
+			return nil, blockErr
+		}
+		return blockCollector.Snapshot(limit)
+	}
+
+	// Syscall time is best-effort too: it needs BTF tracepoints and task
+	// storage (Linux 5.11).
+	syscallCollector, syscallErr := syscalls.NewCollectorForCgroup(cfg.cgroupPath)
+	if syscallErr == nil {
+		defer syscallCollector.Close()
+	} else {
+		slog.Warn("collector unavailable", "collector", "syscalls", "err", syscallErr)
+	}
+	readSyscalls := func(limit int) ([]types.SyscallStat, error) {
+		if syscallErr != nil {
+			return nil, syscallErr
+		}
+		return syscallCollector.Snapshot(limit)
+	}
+
+	// Futex waits are best-effort as well: the syscalls tracepoints need
+	// CONFIG_FTRACE_SYSCALLS.
+	futexCollector, futexErr := futex.NewCollectorForCgroup(cfg.cgroupPath)
+	if futexErr == nil {
+		defer futexCollector.Close()
+	} else {
+		slog.Warn("collector unavailable", "collector", "futex", "err", futexErr)
+	}
+	readFutex := func(limit int) ([]types.FutexStat, error) {
+		if futexErr != nil {
+			return nil, futexErr
+		}
+		return futexCollector.Snapshot(limit)
+	}
 
-	cleanupTerminal := enableSingleView()
+	// Network accounting is opt-in: its probes fire on every TCP send and
+	// receive. Without -net readNet stays nil and the section is not drawn.
+	var readNet func(limit int) ([]types.NetStat, error)
+	var netCollector *network.Collector
+	if cfg.net {
+		var netErr error
+		netCollector, netErr = network.NewCollectorForCgroup(cfg.cgroupPath)
+		if netErr == nil {
+			defer netCollector.Close()
+		} else {
+			slog.Warn("collector unavailable", "collector", "network", "err", netErr)
+		}
+		readNet = func(limit int) ([]types.NetStat, error) {
+			if netErr != nil {
+				return nil, netErr
+			}
+			return netCollector.Snapshot(limit)
+		}
+	}
+	// The profiler follows the Focus section: it samples the process the
+	// previous frame focused on, starting at once when -focus-pid pins it.
+	var profiler *focusProfiler
+	if cfg.profileFocus {
+		profiler = newFocusProfiler()
+		defer profiler.Close()
+		profiler.take(cfg.focusPID)
+	}
+	// Outputs that can fail are opened before the alternate screen is up:
+	// logging.Fatalf skips the deferred cleanup, so its message would be
+	// held and the terminal left in the alternate screen.
+	var sock *stream.Server
+	if cfg.socketPath != "" {
+		if sock, err = stream.Listen(cfg.socketPath); err != nil {
+			logging.Fatalf("listening on -socket %s: %v", cfg.socketPath, err)
+		}
+		defer sock.Close()
+	}
+
+	var exporter *prom.Exporter
+	if cfg.metricsAddr != "" {
+		exporter = prom.NewExporter(cfg.identity)
+		srv, err := prom.Listen(ctx, cfg.metricsAddr, exporter)
+		if err != nil {
+			logging.Fatalf("listening on -metrics-addr %s: %v", cfg.metricsAddr, err)
+		}
+		defer srv.Close()
+	}
+
+	var snapLog *recorder.Log
+	if cfg.logFile != "" {
+		if snapLog, err = recorder.OpenLog(cfg.logFile, cfg.logMaxSize); err != nil {
+			logging.Fatalf("opening -log-file: %v", err)
+		}
+		defer snapLog.Close()
+	}
+
+	// Rates are computed over the measured window rather than the nominal
+	// interval, which a slow frame or a GC pause stretches.
+	windowStart := time.Now()
+
+	// The size is re-read on SIGWINCH rather than every frame; tables are
+	// fitted to the width from the next frame on.
+	cfg.term = currentTermSize()
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	defer signal.Stop(resized)
+
+	cleanupTerminal := func() {}
+	var keys <-chan byte
+	if !cfg.noAltScreen {
+		cleanupTerminal = enableSingleView()
+		keys = readKeys(ctx)
+		cfg.interactive = keys != nil
+	}
 	defer cleanupTerminal()
 
-	rssTracker := report.NewRSSTracker(cfg.thresholds.RSSTracker.WindowTicks)
+	rssTracker := report.NewRSSTracker(cfg.thresholds.RSSTracker)
+	if cfg.containers {
+		rssTracker.ResolveContainers(container.NewResolver())
+	}
+	if cfg.fullCmd {
+		rssTracker.ShowCmdline()
+	}
+	if cfg.smooth > 0 {
+		rssTracker.SmoothWith(report.NewHistory(cfg.smooth, cfg.thresholds.RSSTracker))
+	}
+	if cfg.trend {
+		rssTracker.TrendWith(report.NewTrend(cfg.thresholds.RSSTracker))
+	}
+	sysSampler := system.NewSampler()
+	var cumulative *report.Cumulative
+	if cfg.cumulative {
+		cumulative = report.NewCumulative()
+	}
+
+	// Alerts go through engine.Observers so the webhook sees each process
+	// when it takes on a diagnosis and then once per cooldown.
+	var observers *engine.Observers
+	if cfg.webhookURL != "" {
+		notifier := webhook.New(cfg.webhookURL)
+		defer notifier.Close()
+		observers = engine.NewObservers(cfg.webhookSev, cfg.webhookCool)
+		observers.Add(notifier)
+	}
+
+	var csvOut *export.CSVStream
+	if cfg.format == formatCSV {
+		csvOut = export.NewCSVStream(os.Stdout)
+	}
+
+	var flight *recorder.Ring
+	dumpRequests := make(chan os.Signal, 1)
+	if cfg.flightSize > 0 {
+		flight = recorder.NewRing(cfg.flightSize)
+		signal.Notify(dumpRequests, syscall.SIGQUIT)
+		defer signal.Stop(dumpRequests)
+	}
+	// SIGUSR1 halves and SIGUSR2 doubles the interval.
+	intervalRequests := make(chan os.Signal, 1)
+	signal.Notify(intervalRequests, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(intervalRequests)
+	var nextRequested time.Duration // interval asked for by a signal, from the next window; 0 = none
+	var nextNote string
+	record := recordWindow(flight, snapLog, cfg.stateFile, cfg.stateEvery)
+	if cfg.stateRestore {
+		if saved, err := recorder.Load(cfg.stateFile); err != nil {
+			slog.Warn("restoring state failed", "file", cfg.stateFile, "err", err)
+		} else {
+			writeRestoredFrame(cfg, saved)
+		}
+	}
+	triggerSeverity := report.Severity(cfg.flightTrigger)
+	prevSeverity := 0
+	taken := 0 // snapshots printed, for -count
 
-	ticker := time.NewTicker(cfg.interval)
+	// With -sample-window the ticker ends each sub-window, and every
+	// subWindowsPerInterval-th tick also draws a frame.
+	var subWindows *report.SubWindows
+	tick := cfg.interval
+	if cfg.sampleWindow > 0 {
+		subWindows = report.NewSubWindows(cfg.sampleAggr)
+		tick = cfg.sampleWindow
+	}
+	readers := collectors{
+		cpu:      cpuCollector,
+		mem:      memCollector,
+		blockIO:  readBlockIO,
+		syscalls: readSyscalls,
+		futex:    readFutex,
+		net:      readNet,
+	}
+	frame := frameOptions{
+		rssTracker: rssTracker,
+		cumulative: cumulative,
+		subWindows: subWindows,
+		spikes:     spikes,
+		profiler:   profiler,
+		record:     record,
+		sock:       sock,
+		exporter:   exporter,
+		observers:  observers,
+		csvOut:     csvOut,
+	}
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
+	// With -cumulative the counts keep growing and the next snapshot
+	// subtracts this one's readings instead.
+	resetCollectors := func() {
+		if cfg.cumulative {
+			return
+		}
+		if err := cpuCollector.Reset(); err != nil {
+			slog.Error("reset failed", "collector", "cpu", "err", err)
+		}
+		if err := memCollector.Reset(); err != nil {
+			slog.Error("reset failed", "collector", "memory", "err", err)
+		}
+		if blockErr == nil {
+			if err := blockCollector.Reset(); err != nil {
+				slog.Error("reset failed", "collector", "blockio", "err", err)
+			}
+		}
+		if syscallErr == nil {
+			if err := syscallCollector.Reset(); err != nil {
+				slog.Error("reset failed", "collector", "syscalls", "err", err)
+			}
+		}
+		if futexErr == nil {
+			if err := futexCollector.Reset(); err != nil {
+				slog.Error("reset failed", "collector", "futex", "err", err)
+			}
+		}
+		if netCollector != nil {
+			if err := netCollector.Reset(); err != nil {
+				slog.Error("reset failed", "collector", "network", "err", err)
+			}
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-dumpRequests:
+			dumpFlightRecorder(flight, cfg.flightDir, "SIGQUIT")
+		case sig := <-intervalRequests:
+			// The window in progress keeps its length; the new interval
+			// starts with the next one, after the collectors are reset.
+			cur := cfg.interval
+			if nextRequested > 0 {
+				cur = nextRequested
+			}
+			faster := sig == syscall.SIGUSR1
+			next := stepInterval(cur, signalFloor(cfg), cfg.ceiling, faster)
+			if next == cur {
+				slog.Info("interval already at its bound", "signal", sig, "interval", cur)
+				break
+			}
+			nextRequested, nextNote = next, "doubled by SIGUSR2"
+			if faster {
+				nextNote = "halved by SIGUSR1"
+			}
+			slog.Info("interval change requested", "signal", sig, "interval", next)
+		case <-resized:
+			cfg.term = currentTermSize()
+		case key := <-keys:
+			switch key {
+			case keyQuit:
+				return
+			case keyPause:
+				if cfg.paused = !cfg.paused; cfg.paused {
+					showPaused()
+				}
+			case keyMore:
+				cfg.topK = cfg.topK.adjust(1)
+			case keyLess:
+				cfg.topK = cfg.topK.adjust(-1)
+			}
 		case <-ticker.C:
-			if err := snapshotAndPrint(cpuCollector, memCollector, cfg, rssTracker); err != nil {
-				log.Printf("snapshot failed: %v", err)
+			cfg.window = time.Since(windowStart)
+			if cfg.window > 2*tick {
+				slog.Warn("window over twice the interval; rates use the measured window", "window", cfg.window.Round(time.Millisecond), "interval", tick)
+			}
+			if subWindows != nil && subWindows.Len()+1 < cfg.subWindowsPerInterval() {
+				sampleSubWindow(readers, cfg, cumulative, subWindows)
+				windowStart = time.Now()
+				resetCollectors()
+				continue
+			}
+			severity, snapErr := snapshotAndPrint(readers, sysSampler, cfg, frame)
+			if snapErr != nil {
+				slog.Error("snapshot failed", "err", snapErr)
+				if cfg.count > 0 {
+					exitCode = 1 // a bounded capture with a failed window is incomplete
+				}
+			}
+			// Dump on the transition into the trigger severity, not on every
+			// tick it persists, so one incident produces one file.
+			if triggerSeverity > 0 && severity >= triggerSeverity && prevSeverity < triggerSeverity {
+				dumpFlightRecorder(flight, cfg.flightDir, cfg.flightTrigger)
+			}
+			if snapErr == nil {
+				prevSeverity = severity
+			}
+			// The final window needs no reset: nothing samples after it.
+			if taken++; cfg.count > 0 && taken >= cfg.count {
+				return
+			}
+			hasIssues := severity > 0
+			windowStart = time.Now()
+			resetCollectors()
+			// The Profile pane covers one window either way.
+			if profiler != nil {
+				profiler.reset()
 			}
-			if err := cpuCollector.Reset(); err != nil {
-				log.Printf("reset failed: %v", err)
+			// Maps were just reset, so the new interval also sets the
+			// length of the next sampling window, or with -sample-window
+			// the number of sub-windows in it. A signal's request takes
+			// the place of this window's adaptive step.
+			next := cfg.interval
+			cfg.intervalNote = ""
+			if nextRequested > 0 {
+				if nextRequested != cfg.interval { // not undone by the opposite signal
+					next, cfg.intervalNote = nextRequested, nextNote
+				}
+				nextRequested = 0
+			} else if cfg.adaptive && snapErr == nil {
+				next = nextInterval(cfg.interval, cfg.floor, cfg.ceiling, hasIssues)
 			}
-			if err := memCollector.Reset(); err != nil {
-				log.Printf("memory reset failed: %v", err)
+			if next != cfg.interval {
+				cfg.interval = next
+				if subWindows == nil {
+					tick = next
+					ticker.Reset(next)
+				}
 			}
 		}
 	}
 }
 
-func snapshotAndPrint(cpuCollector *cpu.Collector, memCollector *memory.Collector, cfg runConfig, rssTracker *report.RSSTracker) error {
-	cpuLimit := max(cfg.topK*3, cfg.topK)
-	stats, err := cpuCollector.Snapshot(cpuLimit)
+// recordWindow returns the function each complete, unfiltered window is
+// passed to: it feeds the flight recorder, appends to the log file, and
+// saves the state file every stateEvery windows. It returns nil when none
+// is enabled.
+func recordWindow(flight *recorder.Ring, snapLog *recorder.Log, stateFile string, stateEvery int) func(recorder.Snapshot) {
+	if flight == nil && snapLog == nil && stateFile == "" {
+		return nil
+	}
+	windows := 0
+	return func(s recorder.Snapshot) {
+		if flight != nil {
+			flight.Add(s)
+		}
+		if snapLog != nil {
+			if err := snapLog.Append(s); err != nil {
+				slog.Error("writing log file failed", "err", err)
+			}
+		}
+		if windows++; stateFile == "" || windows%stateEvery != 0 {
+			return
+		}
+		if err := recorder.Save(stateFile, s); err != nil {
+			slog.Error("saving state failed", "file", stateFile, "err", err)
+		}
+	}
+}
+
+// writeRestoredFrame shows the processes needing attention in a snapshot
+// saved by a previous run, as a placeholder until live data arrives. CSV
+// output only ever carries live windows, so nothing is written there.
+func writeRestoredFrame(cfg runConfig, saved recorder.Snapshot) {
+	if cfg.format == formatCSV {
+		return
+	}
+	var header, body bytes.Buffer
+	fmt.Fprintf(&header, "%s  %s │ %s  %s\n",
+		ui.C(ui.Bold+ui.White, "hotspot-bpf"), ui.C(ui.Dim, "(Ctrl+C to exit)"),
+		ui.C(ui.Gray, "Restored:"), ui.C(ui.Dim, saved.Time.Format(time.RFC3339)))
+	fmt.Fprintln(&header, ui.C(ui.Yellow, fmt.Sprintf("Showing the state saved in %s; live data follows in %v", cfg.stateFile, cfg.interval)))
+
+	rows := report.FilterMetrics(saved.Processes, cfg.filterConfig(nil))
+	writeCompactTable(&body, report.FocusGroupsAtLeast(report.SelectFocusGroups(rows), cfg.minSeverity), len(rows))
+	writeFrame(cfg, header.String(), body.String())
+}
+
+// dumpFlightRecorder writes the flight recorder ring to a new file in dir.
+func dumpFlightRecorder(flight *recorder.Ring, dir, reason string) {
+	path, err := flight.Dump(dir, time.Now())
+	if err != nil {
+		slog.Error("flight recorder dump failed", "reason", reason, "err", err)
+		return
+	}
+	slog.Info("flight recorder dump written", "snapshots", flight.Len(), "file", path, "reason", reason)
+}
+
+// writeDiagnoses prints each diagnosis in evaluation order with the
+// conditions that trigger it under th.
+func writeDiagnoses(w io.Writer, th config.Thresholds) {
+	fmt.Fprintln(w, "Diagnoses in evaluation order; a process gets the first label whose conditions all hold.")
+	fmt.Fprintln(w, "Severity ranks labels in the Focus section and for -min-severity (higher is more urgent).")
+	for _, d := range report.Diagnoses(th) {
+		fmt.Fprintf(w, "\n%d. %s (severity %d)\n   %s\n", d.Priority, d.Label, d.Severity, d.Description)
+		for _, c := range d.Conditions {
+			fmt.Fprintf(w, "   - %s\n", c)
+		}
+	}
+}
+
+// runDryClassify re-runs classification over every snapshot in the capture at
+// path with th and writes each changed label, then a count per transition.
+func runDryClassify(w io.Writer, path string, th config.Thresholds) error {
+	snaps, err := recorder.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	contentionLimit := max(cfg.topK*4, cfg.topK)
-	contentionStats, contentionErr := cpuCollector.Contention(contentionLimit)
-	if contentionErr != nil {
-		contentionStats = nil
+	tw := ui.NewTable(w, 2)
+	fmt.Fprintln(tw, "TIME\tPID\tCOMM\tRECORDED\tNOW")
+	samples, changed := 0, 0
+	transitions := make(map[[2]string]int)
+	var order [][2]string
+	for _, snap := range snaps {
+		samples += len(snap.Processes)
+		for _, c := range report.Reclassify(snap.Processes, th) {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", snap.Time.Local().Format("15:04:05"), c.PID, c.Comm, c.From, c.To)
+			key := [2]string{c.From, c.To}
+			if transitions[key] == 0 {
+				order = append(order, key)
+			}
+			transitions[key]++
+			changed++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
 	}
 
-	pageFaultLimit := max(cfg.topK*3, cfg.topK*2)
-	pageFaults, pfErr := memCollector.Snapshot(pageFaultLimit, cfg.interval)
-	if pfErr != nil {
-		pageFaults = nil
+	fmt.Fprintf(w, "\n%d of %d process samples in %d snapshots change label\n", changed, samples, len(snaps))
+	sort.SliceStable(order, func(i, j int) bool { return transitions[order[i]] > transitions[order[j]] })
+	for _, key := range order {
+		fmt.Fprintf(w, "  %-26s → %-26s %d\n", key[0], key[1], transitions[key])
+	}
+	return nil
+}
+
+// writeHeapProfile writes hotspot's own heap profile for offline analysis
+// with `go tool pprof`, e.g. to confirm cross-interval state stays bounded.
+func writeHeapProfile(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		slog.Error("creating memory profile failed", "file", path, "err", err)
+		return
+	}
+	defer f.Close()
+	runtime.GC() // materialize up-to-date allocation statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		slog.Error("writing memory profile failed", "file", path, "err", err)
+	}
+}
+
+// nextInterval implements -interval-adaptive: it halves the interval while
+// any process has a non-OK diagnosis and doubles it once all clear, staying
+// within [floor, ceiling]. Tightening fast and relaxing fast keeps overhead
+// low in steady state without missing the start of an incident.
+func nextInterval(cur, floor, ceiling time.Duration, hasIssues bool) time.Duration {
+	if hasIssues {
+		return clampInterval(cur/2, floor, ceiling)
 	}
+	return clampInterval(cur*2, floor, ceiling)
+}
 
-	procRows, procIndex := report.BuildProcMetrics(stats, pageFaults, contentionStats, cfg.interval, rssTracker, cfg.thresholds)
-	filterCfg := report.FilterConfig{HideKernel: &cfg.hideKernel, CgroupFilter: cfg.cgroupFilter, Exclude: cfg.exclude}
-	filteredRows := report.FilterMetrics(procRows, filterCfg)
-	focusGroups := report.SelectFocusGroups(filteredRows)
+// stepInterval implements SIGUSR1 (faster) and SIGUSR2: it halves or
+// doubles the interval, stopping at floor or ceiling. An interval already
+// past a bound, as -interval may set it, is left where it is rather than
+// moved the other way.
+func stepInterval(cur, floor, ceiling time.Duration, faster bool) time.Duration {
+	if faster {
+		if cur <= floor {
+			return cur
+		}
+		return clampInterval(cur/2, floor, cur)
+	}
+	if cur >= ceiling {
+		return cur
+	}
+	return clampInterval(cur*2, cur, ceiling)
+}
+
+// signalFloor is the shortest interval SIGUSR1 halves to: -interval-floor,
+// and with -sample-window at least two sub-windows, which -sample-window
+// needs to be shorter than the interval.
+func signalFloor(cfg runConfig) time.Duration {
+	if floor := 2 * cfg.sampleWindow; floor > cfg.floor {
+		return floor
+	}
+	return cfg.floor
+}
+
+// topSeverity returns the highest severity among focus groups, which are
+// sorted most severe first.
+func topSeverity(groups []report.FocusGroup) int {
+	if len(groups) == 0 {
+		return 0
+	}
+	return groups[0].Severity
+}
+
+// metricsRows selects the processes exported to Prometheus. Rows are merged
+// by identity first, so each series is unique, and only the union of each
+// table section's top rows is kept, which bounds series cardinality on busy
+// hosts.
+func metricsRows(rows []report.ProcMetrics, limits topKLimits, strategy report.IdentityStrategy) []report.ProcMetrics {
+	rows = report.MergeByIdentity(rows, strategy)
+	seen := make(map[string]bool, len(rows))
+	var selected []report.ProcMetrics
+	for _, section := range [][]report.ProcMetrics{
+		report.CPUUsageRows(rows, limits.CPU),
+		report.PreemptedRows(rows, limits.Contention),
+		report.CPUCostRows(rows, limits.Cost),
+	} {
+		for _, row := range section {
+			if id := strategy.Identity(row); !seen[id] {
+				seen[id] = true
+				selected = append(selected, row)
+			}
+		}
+	}
+	return selected
+}
+
+// topKLimits holds the per-section row limits parsed from -topk.
+type topKLimits struct {
+	CPU        int // CPU Hotspots table
+	Contention int // Scheduler Contention table
+	Cost       int // Memory Pressure table
+	IO         int // Disk I/O table
+	Syscall    int // Syscalls table
+	Lock       int // Locks table
+	Net        int // Network table (-net)
+}
+
+// widest returns the largest limit, used to size BPF snapshots so every
+// section (and the contention proc lookups) has enough rows to draw from.
+func (l topKLimits) widest() int {
+	return max(max(max(l.CPU, l.Contention), max(l.Cost, l.IO)), max(max(l.Syscall, l.Lock), l.Net))
+}
+
+func (l topKLimits) String() string {
+	if l.CPU == l.Contention && l.CPU == l.Cost && l.CPU == l.IO && l.CPU == l.Syscall && l.CPU == l.Lock && l.CPU == l.Net {
+		return strconv.Itoa(l.CPU)
+	}
+	return fmt.Sprintf("cpu=%d,contention=%d,cost=%d,io=%d,syscall=%d,lock=%d,net=%d", l.CPU, l.Contention, l.Cost, l.IO, l.Syscall, l.Lock, l.Net)
+}
+
+// parseTopK parses a -topk value. A bare integer applies to every section;
+// section=N items override it for one section. Sections left unset use the
+// bare integer if given, otherwise types.DefaultTopK.
+func parseTopK(s string) (topKLimits, error) {
+	def := types.DefaultTopK
+	perSection := make(map[string]int)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, named := strings.Cut(item, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !named {
+			n, err = strconv.Atoi(key)
+		}
+		if err != nil || n < 1 {
+			return topKLimits{}, fmt.Errorf("invalid limit in %q: want a positive integer", item)
+		}
+		if !named {
+			def = n
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		switch key {
+		case "cpu", "contention", "cost", "io", "syscall", "lock", "net":
+		default:
+			return topKLimits{}, fmt.Errorf("unknown section %q (want cpu, contention, cost, io, syscall, lock, or net)", key)
+		}
+		if _, dup := perSection[key]; dup {
+			return topKLimits{}, fmt.Errorf("section %q given more than once", key)
+		}
+		perSection[key] = n
+	}
+	limit := func(key string) int {
+		if n, ok := perSection[key]; ok {
+			return n
+		}
+		return def
+	}
+	return topKLimits{CPU: limit("cpu"), Contention: limit("contention"), Cost: limit("cost"), IO: limit("io"), Syscall: limit("syscall"), Lock: limit("lock"), Net: limit("net")}, nil
+}
+
+func clampInterval(d, floor, ceiling time.Duration) time.Duration {
+	if d < floor {
+		return floor
+	}
+	if d > ceiling {
+		return ceiling
+	}
+	return d
+}
+
+// frameOptions are the state and outputs snapshotAndPrint carries from one
+// frame to the next. Each but rssTracker is nil when its flag is off.
+type frameOptions struct {
+	rssTracker *report.RSSTracker
+	cumulative *report.Cumulative      // -cumulative
+	subWindows *report.SubWindows      // -sample-window
+	spikes     *spikeLog               // -spike-threshold
+	profiler   *focusProfiler          // -profile-focus
+	record     func(recorder.Snapshot) // see recordWindow
+	sock       *stream.Server          // -socket
+	exporter   *prom.Exporter          // -metrics-addr
+	observers  *engine.Observers       // -webhook-url
+	csvOut     *export.CSVStream       // -format csv
+}
+
+// snapshotAndPrint renders one frame and returns the highest diagnosis
+// severity shown (0 when every process is OK), which drives
+// -interval-adaptive and the flight recorder trigger. When opts.record is
+// non-nil the unfiltered window is also passed to it (see recordWindow). With
+// cfg.exportDir set the same frame and filtered rows are written there too,
+// when opts.sock is non-nil the filtered rows are streamed to its clients,
+// and when opts.exporter is non-nil its gauges are updated from them (see
+// metricsRows). With -format csv the rows are appended to opts.csvOut instead
+// of drawing a frame.
+func snapshotAndPrint(readers collectors, sysSampler *system.Sampler, cfg runConfig, opts frameOptions) (int, error) {
+	var members map[uint32]struct{}
+	if cfg.watchCgroup != "" {
+		var err error
+		if members, err = cgroup.Members(cfg.watchCgroup); err != nil {
+			return 0, fmt.Errorf("reading -watch-cgroup members: %w", err)
+		}
+	}
+
+	limits := readLimits{
+		cpu:        cfg.topK.widest() * 3,
+		contention: cfg.topK.Contention * 4,
+		pageFaults: cfg.topK.Cost * 3,
+		blockIO:    cfg.topK.IO * 3,
+		syscalls:   cfg.topK.Syscall * 3,
+		futex:      cfg.topK.Lock * 3,
+		net:        cfg.topK.Net * 3,
+	}
+	if cfg.onlyContention {
+		limits.contention = 0 // every pair, so reverse directions are available for fairness scores
+	}
+	if members != nil || cfg.pids != nil || cfg.ppid != 0 || opts.cumulative != nil || opts.subWindows != nil {
+		// Host-wide top-N could crowd out the watched processes, so read
+		// everything and let the filters pick the rows. Cumulative readings
+		// are ranked by lifetime totals, and a process cut from one reading
+		// would have its whole total counted when it came back. Sub-windows
+		// must be complete for their sums to be.
+		limits = readLimits{}
+	}
+	if cfg.cpuOptions.Threads {
+		limits.cpu = 0 // a process's total needs every one of its threads
+	}
+
+	window := cfg.window
+	if window <= 0 {
+		window = cfg.interval
+	}
+	snap := collectSnapshot(readers, window, limits)
+	if opts.cumulative != nil {
+		snap = opts.cumulative.Window(snap, window)
+	}
+	// The last sub-window ends with the interval; the rest were read by
+	// sampleSubWindow.
+	if opts.subWindows != nil {
+		opts.subWindows.Add(snap, window)
+		snap, window = opts.subWindows.Result()
+	}
+	if err := snap.Err(); err != nil {
+		return 0, err
+	}
+	contentionStats, contentionErr, pfErr := snap.Contention, snap.ContentionErr, snap.PageFaultsErr
+	// With -threads the CPU stats are per thread: everything but the CPU
+	// Hotspots table works on their per-process totals.
+	var threadStats []types.CPUStat
+	if cfg.cpuOptions.Threads {
+		threadStats, snap.CPU = snap.CPU, report.ProcessTotals(snap.CPU)
+	}
+
+	procRows, procIndex := report.BuildProcMetrics(snap, report.BuildOptions{Interval: window, Tracker: opts.rssTracker, Thresholds: cfg.thresholds})
+	filterCfg := cfg.filterConfig(members)
+	if opts.record != nil {
+		opts.record(recorder.Snapshot{Time: time.Now(), Interval: window, Processes: procRows, Contention: contentionStats, Errors: snap.Errors()})
+	}
+	filteredRows, filterStats := report.FilterMetricsWithStats(procRows, filterCfg)
+	focusGroups := report.FocusGroupsAtLeast(report.SelectFocusGroups(filteredRows), cfg.minSeverity)
+	var profiled profileWindow
+	if opts.profiler != nil {
+		profiled = opts.profiler.take(focusTarget(cfg.focusPID, focusGroups))
+	}
+	captured := time.Now()
+	if opts.observers != nil {
+		opts.observers.Notify(filteredRows, captured)
+	}
+
+	small := cfg.term.tooSmall()
+	if small && cfg.format == formatTable {
+		cfg.format = formatTableCompact
+	}
 
 	// --- Build the fixed header (banner + status) ---
 	var header bytes.Buffer
-	header.WriteString(ui.Banner())
+	if cfg.format != formatTableCompact && !small {
+		header.WriteString(ui.Banner(ui.ColorEnabled())) // the compact pane has no room for the logo
+	}
 
-	timestamp := ui.C(ui.Dim, time.Now().Format(time.RFC3339))
+	timestamp := ui.C(ui.Dim, captured.Format(time.RFC3339))
 	interval := ui.C(ui.Dim, cfg.interval.String())
+	hint := "(Ctrl+C to exit)"
+	if cfg.interactive {
+		hint = "(space pause · +/- rows · q quit)"
+	}
 	fmt.Fprintf(&header, "%s  %s │ %s  %s\n",
-		ui.C(ui.Bold+ui.White, "hotspot-bpf"), ui.C(ui.Dim, "(Ctrl+C to exit)"),
+		ui.C(ui.Bold+ui.White, "hotspot-bpf"), ui.C(ui.Dim, hint),
 		ui.C(ui.Gray, "Updated:"), timestamp)
+	if cfg.adaptive {
+		interval += ui.C(ui.Dim, fmt.Sprintf(" (adaptive %v–%v)", cfg.floor, cfg.ceiling))
+	}
+	if cfg.sampleWindow > 0 {
+		interval += ui.C(ui.Dim, fmt.Sprintf(" (%s of %v sub-windows)", cfg.sampleAggr, cfg.sampleWindow))
+	}
+	if cfg.intervalNote != "" {
+		interval += " " + ui.C(ui.Yellow, cfg.intervalNote)
+	}
 	fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "Interval:"), interval)
+	sysStat, sysErr := sysSampler.Sample()
+	fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "System:"), systemSummary(sysStat, sysErr))
+	if sysErr == nil {
+		fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "CPU time:"), cpuBreakdown(sysStat.CPU))
+	}
+	if warning := system.StealWarning(sysStat, cfg.thresholds.Steal.MinPercent); warning != "" {
+		fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "Warning:"), ui.C(ui.Bold+ui.Red, warning))
+	}
+	if note := system.IRQNote(sysStat, cfg.thresholds.IRQLoad.MinPercent); note != "" {
+		fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "Note:"), ui.C(ui.Yellow, note))
+	}
+	if blocked := report.CountIOBlocked(filteredRows); blocked > 0 {
+		fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "I/O-blocked:"),
+			ui.C(ui.Yellow, fmt.Sprintf("%d processes in D state (uninterruptible sleep) at the end of the window", blocked)))
+	}
+	if cfg.debugFilters {
+		fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "Filters:"), filterSummary(filterStats))
+	}
+	if snap.CPUErr != nil || snap.PageFaultsErr != nil {
+		// Contention alone failing is routine (perf/proc modes) and is
+		// already explained in its own section.
+		fmt.Fprintf(&header, "%s  %s\n", ui.C(ui.Gray, "Data:"), ui.C(ui.Yellow, snap.Status()))
+	}
+	if small {
+		fmt.Fprintln(&header, ui.C(ui.Dim, fmt.Sprintf("Terminal %dx%d is below %dx%d; showing compact view",
+			cfg.term.width, cfg.term.height, minFullWidth, minFullHeight)))
+	}
 
 	// --- Build the scrollable body (tables + focus) ---
 	var body bytes.Buffer
 
-	// Focus section — all non-OK processes grouped by diagnosis
-	if len(focusGroups) > 0 {
+	emit := func() (int, error) {
+		if opts.csvOut != nil {
+			if err := opts.csvOut.Write(captured, filteredRows); err != nil {
+				return topSeverity(focusGroups), fmt.Errorf("writing CSV: %w", err)
+			}
+		} else if !cfg.paused {
+			writeFrame(cfg, header.String(), body.String())
+		}
+		exported := recorder.Snapshot{Time: captured, Interval: window, Processes: filteredRows, Contention: contentionStats, Errors: snap.Errors()}
+		if opts.sock != nil {
+			if err := opts.sock.Publish(exported); err != nil {
+				return topSeverity(focusGroups), fmt.Errorf("streaming snapshot: %w", err)
+			}
+		}
+		if opts.exporter != nil {
+			opts.exporter.Update(metricsRows(filteredRows, cfg.topK, cfg.identity))
+		}
+		if cfg.exportDir != "" {
+			if err := export.WriteDir(cfg.exportDir, ui.StripANSI(header.String()+body.String()), exported); err != nil {
+				return topSeverity(focusGroups), fmt.Errorf("exporting snapshot: %w", err)
+			}
+		}
+		return topSeverity(focusGroups), nil
+	}
+
+	if cfg.onlyContention {
+		writeContentionFocus(&body, cfg, contentionStats, contentionErr, filterCfg, procIndex)
+		return emit()
+	}
+
+	if cfg.format == formatTableCompact {
+		if cfg.focusPID != 0 {
+			writePinnedFocus(&body, cfg.focusPID, filteredRows)
+		} else {
+			writeCompactTable(&body, focusGroups, len(filteredRows))
+		}
+		return emit()
+	}
+
+	// Focus section — the pinned process, or all non-OK processes grouped by diagnosis
+	if cfg.focusPID != 0 {
+		writePinnedFocus(&body, cfg.focusPID, filteredRows)
+	} else if len(focusGroups) > 0 {
 		body.WriteString(ui.SectionHeader("Focus · Processes requiring attention"))
 		for _, group := range focusGroups {
-			body.WriteString(ui.FocusGroupHeader(group.Diagnosis, len(group.Procs)))
+			body.WriteString(ui.FocusGroupHeader(group.Diagnosis, group.Severity, len(group.Procs)))
 			for _, proc := range group.Procs {
-				body.WriteString(ui.FocusEntry(proc.Comm, proc.PID, report.FocusSummary(proc), proc.Diagnosis))
+				body.WriteString(ui.FocusEntry(proc.CommLabel(), proc.PID, report.FocusSummary(proc), group.Severity))
 			}
 		}
 	} else if len(filteredRows) == 0 {
-		fmt.Fprintf(&body, "\n%s No processes matched current filters (topk=%d, hide-kernel=%t)\n",
+		fmt.Fprintf(&body, "\n%s No processes matched current filters (topk=%s, hide-kernel=%t)\n",
 			ui.C(ui.Dim, "[–]"), cfg.topK, cfg.hideKernel)
 	}
+	if opts.profiler != nil {
+		writeProfile(&body, opts.profiler, profiled, filteredRows)
+	}
+
+	probes := probeSet{
+		offCPU:       readers.cpu.OffCPUTracking(),
+		majorLatency: readers.mem.LatencyTracking(),
+		tlb:          readers.mem.TLBTracking(),
+		swap:         readers.mem.SwapTracking(),
+	}
 
 	// CPU Usage table
-	body.WriteString(ui.SectionHeader(fmt.Sprintf("CPU Hotspots · Top %d processes by CPU time (window %v)", cfg.topK, cfg.interval)))
-	cpuRows := report.CPUUsageRows(filteredRows, cfg.topK)
-	if len(cpuRows) == 0 {
-		fmt.Fprintln(&body, ui.C(ui.Dim, "No CPU samples for this window"))
-	} else {
-		tw := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(tw, "PID\tCOMM\tCGROUP\tCPU(ms)\tCPU(%)\tCore%\tLastCore\tDiag")
-		for _, row := range cpuRows {
-			diag := ui.DiagLabel(row.Diagnosis)
-			fmt.Fprintf(tw, "%d\t%s\t%s\t%.2f\t%.2f\t%.1f\t%d\t%s\n",
-				row.PID, row.Comm, row.Cgroup, row.CPUMs, row.CPUPercent,
-				row.CoreCPUPercent, row.CPUCore, diag)
+	cpuTable, cpuUnit := cpuColumns, "processes"
+	cpuRows := report.TopRows(filteredRows, cfg.sortKey, cfg.topK.CPU)
+	var cpuOthers report.Rollup
+	if cfg.cpuOptions.Threads {
+		// Thread rows repeat their process's memory and I/O, which an
+		// others row would add up once per thread, so there is none.
+		cpuTable, cpuUnit = threadCPUColumns, "threads"
+		cpuRows = report.TopRows(report.ThreadRows(threadStats, filteredRows, window), cfg.sortKey, cfg.topK.CPU)
+	} else if cfg.showOthers {
+		cpuRows, cpuOthers = report.SplitTop(report.TopRows(filteredRows, cfg.sortKey, 0), cfg.topK.CPU)
+	}
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("CPU Hotspots · Top %d %s by %s (window %v)", cfg.topK.CPU, cpuUnit, cfg.sortKey.Label(), cfg.interval)))
+	if snap.CPUErr != nil {
+		fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("CPU collector unavailable: %v", snap.CPUErr)))
+	} else if len(cpuRows) == 0 {
+		msg := "No CPU samples for this window"
+		if cfg.sortKey != report.SortCPU {
+			msg = fmt.Sprintf("No processes with %s in this window", cfg.sortKey.Label())
 		}
-		tw.Flush()
+		fmt.Fprintln(&body, ui.C(ui.Dim, msg))
+	} else {
+		writeColumns(&body, cfg.columns.pick(cpuTable), cpuRows, cpuOthers, probes, cfg.term.width)
+	}
+
+	if opts.spikes != nil {
+		writeSpikes(&body, readers.cpu, opts.spikes, cfg.cpuOptions.SpikeThreshold)
 	}
 
 	// CPU Contention table
@@ -231,45 +1454,359 @@ func snapshotAndPrint(cpuCollector *cpu.Collector, memCollector *memory.Collecto
 		body.WriteString(ui.SectionHeader("Scheduler Contention"))
 		fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("unavailable: %v", contentionErr)))
 	} else {
-		body.WriteString(ui.SectionHeader(fmt.Sprintf("Scheduler Contention · Which processes preempt others (window %v)", cfg.interval)))
-		rows := report.FilterContentionRows(contentionStats, filterCfg, procIndex, cfg.topK)
+		title := fmt.Sprintf("Scheduler Contention · Which processes preempt others (window %v)", cfg.interval)
+		if cfg.groupByComm {
+			title += " · grouped by comm, lowest PID shown"
+		}
+		body.WriteString(ui.SectionHeader(title))
+		rows := report.FilterContentionRows(contentionStats, filterCfg, procIndex, cfg.topK.Contention)
 		if len(rows) == 0 {
 			fmt.Fprintln(&body, ui.C(ui.Dim, "No preemptions recorded in this window"))
 		} else {
-			tw := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(tw, "VICTIM PID\tVICTIM\tAGGRESSOR PID\tAGGRESSOR\tCOUNT")
+			tw := ui.NewTable(&body, 2)
+			if cfg.aggrDiag {
+				// Explains why the aggressor holds the CPU (e.g. it is itself CPU-bound).
+				fmt.Fprintln(tw, "VICTIM PID\tVICTIM\tAGGRESSOR PID\tAGGRESSOR\tCOUNT\tAGGRESSOR DIAG")
+			} else {
+				fmt.Fprintln(tw, "VICTIM PID\tVICTIM\tAGGRESSOR PID\tAGGRESSOR\tCOUNT")
+			}
 			for _, pair := range rows {
-				fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%d\n",
+				fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%d",
 					pair.VictimPID, pair.VictimComm, pair.AggressorPID, pair.AggressorComm, pair.Count)
+				if cfg.aggrDiag {
+					fmt.Fprintf(tw, "\t%s", diagCell(procIndex[pair.AggressorPID].Diagnosis))
+				}
+				fmt.Fprintln(tw)
 			}
 			tw.Flush()
 		}
 	}
 
 	// Memory Pressure table
-	body.WriteString(ui.SectionHeader(fmt.Sprintf("Memory Pressure · Top %d processes by page fault rate", cfg.topK)))
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Memory Pressure · Top %d processes by page fault rate", cfg.topK.Cost)))
 	if pfErr != nil {
 		fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("Page fault tracker unavailable: %v", pfErr)))
 	} else {
-		costRows := report.CPUCostRows(filteredRows, cfg.topK)
+		costRows := report.CPUCostRows(filteredRows, cfg.topK.Cost)
+		var costOthers report.Rollup
+		if cfg.showOthers {
+			costRows, costOthers = report.SplitTop(report.CPUCostRows(filteredRows, 0), cfg.topK.Cost)
+		}
 		if len(costRows) == 0 {
 			fmt.Fprintln(&body, ui.C(ui.Dim, "No page faults recorded in this window"))
 		} else {
-			tw := tabwriter.NewWriter(&body, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(tw, "PID\tCOMM\tCGROUP\tCPU(ms)\tRSS(MB)\tFaults\tFaults/sec\tCost/Fault(ms)\tDiag")
-			for _, row := range costRows {
-				diag := ui.DiagLabel(row.Diagnosis)
-				fmt.Fprintf(tw, "%d\t%s\t%s\t%.2f\t%.1f\t%d\t%.1f\t%.2f\t%s\n",
-					row.PID, row.Comm, row.Cgroup, row.CPUMs, row.RSSMB,
-					row.Faults, row.FaultsPerSec, row.CPUCostPerFault, diag)
+			writeColumns(&body, cfg.columns.pick(memoryColumns), costRows, costOthers, probes, cfg.term.width)
+		}
+	}
+
+	// Disk I/O table
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Disk I/O · Top %d processes by bytes read and written (window %v)", cfg.topK.IO, cfg.interval)))
+	if snap.BlockIOErr != nil {
+		fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("Block I/O tracker unavailable: %v", snap.BlockIOErr)))
+	} else {
+		ioRows := report.BlockIORows(filteredRows, cfg.topK.IO)
+		if len(ioRows) == 0 {
+			fmt.Fprintln(&body, ui.C(ui.Dim, "No block I/O completed in this window"))
+		} else {
+			writeColumns(&body, cfg.columns.pick(blockIOColumns), ioRows, report.Rollup{}, probes, cfg.term.width)
+		}
+	}
+
+	// Syscalls table
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Syscalls · Top %d processes by time in system calls (window %v)", cfg.topK.Syscall, cfg.interval)))
+	if snap.SyscallsErr != nil {
+		fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("Syscall tracker unavailable: %v", snap.SyscallsErr)))
+	} else {
+		syscallRows := report.SyscallRows(filteredRows, cfg.topK.Syscall)
+		if len(syscallRows) == 0 {
+			fmt.Fprintln(&body, ui.C(ui.Dim, "No system calls returned in this window"))
+		} else {
+			writeColumns(&body, cfg.columns.pick(syscallColumns), syscallRows, report.Rollup{}, probes, cfg.term.width)
+		}
+	}
+
+	// Locks table
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Locks · Top %d processes by time blocked in futex waits (window %v)", cfg.topK.Lock, cfg.interval)))
+	if snap.FutexErr != nil {
+		fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("Futex tracker unavailable: %v", snap.FutexErr)))
+	} else {
+		futexRows := report.FutexRows(filteredRows, cfg.topK.Lock)
+		if len(futexRows) == 0 {
+			fmt.Fprintln(&body, ui.C(ui.Dim, "No futex waits blocked in this window"))
+		} else {
+			writeColumns(&body, cfg.columns.pick(futexColumns), futexRows, report.Rollup{}, probes, cfg.term.width)
+		}
+	}
+
+	// Network table, only with -net
+	if snap.NetTracked {
+		body.WriteString(ui.SectionHeader(fmt.Sprintf("Network · Top %d processes by TCP bytes sent and received (window %v)", cfg.topK.Net, cfg.interval)))
+		if snap.NetErr != nil {
+			fmt.Fprintf(&body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("Network tracker unavailable: %v", snap.NetErr)))
+		} else {
+			netRows := report.NetRows(filteredRows, cfg.topK.Net)
+			if len(netRows) == 0 {
+				fmt.Fprintln(&body, ui.C(ui.Dim, "No TCP traffic in this window"))
+			} else {
+				writeColumns(&body, cfg.columns.pick(netColumns), netRows, report.Rollup{}, probes, cfg.term.width)
 			}
-			tw.Flush()
 		}
 	}
 
 	// --- Compose final output: fixed header + truncated body ---
-	renderFrame(header.String(), body.String())
-	return nil
+	return emit()
+}
+
+// listFlag returns the flag.Func callback of a repeatable list flag: each
+// use appends its comma-separated values to list, trimmed and lowercased.
+func listFlag(list *[]string) func(string) error {
+	return func(v string) error {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+				*list = append(*list, item)
+			}
+		}
+		return nil
+	}
+}
+
+// filterConfig returns the process filters selected by flags. members is
+// the current -watch-cgroup membership, or nil.
+func (cfg runConfig) filterConfig(members map[uint32]struct{}) report.FilterConfig {
+	return report.FilterConfig{
+		HideKernel:    &cfg.hideKernel,
+		CgroupFilter:  cfg.cgroupFilter,
+		CgroupExclude: cfg.cgroupExclude,
+		CgroupRegex:   cfg.cgroupRegex,
+		CommRegex:     cfg.commRegex,
+		ExeFilter:     cfg.exeFilter,
+		Exclude:       cfg.exclude,
+		GroupByComm:   cfg.groupByComm,
+		Members:       members,
+		PIDs:          cfg.pids,
+		PPID:          cfg.ppid,
+		PPIDTree:      cfg.ppidTree,
+		IncludeSelf:   cfg.includeSelf,
+	}
+}
+
+// sampleSubWindow reads every collector for one -sample-window sub-window
+// that does not end an interval, and adds the readings to subWindows. Like
+// the final sub-window it reads every process, not the top-N.
+func sampleSubWindow(readers collectors, cfg runConfig, cumulative *report.Cumulative, subWindows *report.SubWindows) {
+	snap := collectSnapshot(readers, cfg.window, readLimits{})
+	if cumulative != nil {
+		snap = cumulative.Window(snap, cfg.window)
+	}
+	subWindows.Add(snap, cfg.window)
+}
+
+// collectors are the readers behind one window. The optional collectors are
+// read through funcs that return their startup error when they failed to
+// load; net is nil without -net.
+type collectors struct {
+	cpu      *cpu.Collector
+	mem      *memory.Collector
+	blockIO  func(limit int) ([]types.BlockIOStat, error)
+	syscalls func(limit int) ([]types.SyscallStat, error)
+	futex    func(limit int) ([]types.FutexStat, error)
+	net      func(limit int) ([]types.NetStat, error)
+}
+
+// readLimits caps the rows collectSnapshot reads from each collector. The
+// zero value reads every row.
+type readLimits struct {
+	cpu        int // CPU snapshot and run-queue latency
+	contention int
+	pageFaults int
+	blockIO    int
+	syscalls   int
+	futex      int
+	net        int
+}
+
+// collectSnapshot reads every collector for one window, concurrently. A
+// failing collector only invalidates its own part of the result. The CPU
+// snapshot, contention, and run-queue reads touch separate BPF maps (or /proc
+// for MethodProc), so they are safe to run side by side.
+func collectSnapshot(readers collectors, interval time.Duration, limits readLimits) report.SnapshotResult {
+	src := report.SnapshotSources{
+		CPU:         func() ([]types.CPUStat, error) { return readers.cpu.Snapshot(limits.cpu) },
+		Contention:  func() ([]types.ContentionStat, error) { return readers.cpu.Contention(limits.contention) },
+		PageFaults:  func() ([]types.PageFaultStat, error) { return readers.mem.Snapshot(limits.pageFaults, interval) },
+		RunqLatency: func() ([]types.RunqLatencyStat, error) { return readers.cpu.RunqueueLatency(limits.cpu) },
+		BlockIO:     func() ([]types.BlockIOStat, error) { return readers.blockIO(limits.blockIO) },
+		Syscalls:    func() ([]types.SyscallStat, error) { return readers.syscalls(limits.syscalls) },
+		Futex:       func() ([]types.FutexStat, error) { return readers.futex(limits.futex) },
+	}
+	if readers.net != nil {
+		src.Net = func() ([]types.NetStat, error) { return readers.net(limits.net) }
+	}
+	return report.CollectSnapshot(src)
+}
+
+// systemSummary formats whole-machine CPU busy% and memory usage for the header
+// so per-process numbers can be read against overall load.
+func systemSummary(stat types.SystemStat, err error) string {
+	if err != nil && stat.MemTotalBytes == 0 {
+		return ui.C(ui.Dim, fmt.Sprintf("unavailable: %v", err))
+	}
+	const gib = 1 << 30
+	used := stat.MemTotalBytes - min(stat.MemAvailableBytes, stat.MemTotalBytes)
+	cpuText := fmt.Sprintf("CPU %.1f%% busy", stat.CPUBusyPercent)
+	if err != nil {
+		cpuText = "CPU n/a"
+	}
+	return fmt.Sprintf("%s │ Mem %.1f/%.1f GiB used (%.1f GiB available)",
+		cpuText, float64(used)/gib, float64(stat.MemTotalBytes)/gib, float64(stat.MemAvailableBytes)/gib)
+}
+
+// cpuBreakdown formats the window's machine-wide CPU time by kind, e.g.
+// "user 41.2% │ system 8.0% │ iowait 0.4% │ steal 12.5% │ idle 36.1%".
+// Steal is highlighted once it reaches 1%: on bare metal it is always 0.
+func cpuBreakdown(c types.SystemCPU) string {
+	steal := fmt.Sprintf("steal %.1f%%", c.StealPercent)
+	if c.StealPercent >= 1 {
+		steal = ui.C(ui.Yellow, steal)
+	}
+	return fmt.Sprintf("user %.1f%% │ system %.1f%% │ iowait %.1f%% │ %s │ idle %.1f%%",
+		c.UserPercent, c.SystemPercent, c.IOWaitPercent, steal, c.IdlePercent)
+}
+
+// filterSummary formats -debug-filters output, e.g.
+// "212 processes → 9 shown (kernel −180, cgroup −23)".
+func filterSummary(stats report.FilterStats) string {
+	var removed []string
+	for _, stage := range []struct {
+		name  string
+		count int
+	}{
+		{"pid", stats.PID},
+		{"kernel", stats.Kernel},
+		{"cgroup", stats.Cgroup},
+		{"comm", stats.Comm},
+		{"watch-cgroup", stats.WatchCgroup},
+		{"exe", stats.Exe},
+		{"exclude", stats.Exclude},
+		{"ppid", stats.PPID},
+		{"self", stats.Self},
+	} {
+		if stage.count > 0 {
+			removed = append(removed, fmt.Sprintf("%s −%d", stage.name, stage.count))
+		}
+	}
+	summary := fmt.Sprintf("%d processes → %d shown", stats.Total, stats.Kept)
+	if len(removed) > 0 {
+		summary += " (" + strings.Join(removed, ", ") + ")"
+	}
+	return summary
+}
+
+// writeContentionFocus renders the -only-contention view: a contention table
+// with three times the usual rows, each aggressor's own diagnosis, and a
+// fairness score (1.00 = the pair preempt each other equally, 0.00 = one-sided).
+func writeContentionFocus(body *bytes.Buffer, cfg runConfig, entries []types.ContentionStat, contentionErr error, filterCfg report.FilterConfig, procIndex map[uint32]report.ProcMetrics) {
+	if contentionErr != nil {
+		body.WriteString(ui.SectionHeader("Scheduler Contention"))
+		fmt.Fprintf(body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("unavailable: %v", contentionErr)))
+		return
+	}
+	topK := cfg.topK.Contention * 3
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Scheduler Contention · Top %d preemption pairs (window %v)", topK, cfg.interval)))
+	rows := report.FilterContentionRows(entries, filterCfg, procIndex, topK)
+	if len(rows) == 0 {
+		fmt.Fprintln(body, ui.C(ui.Dim, "No preemptions recorded in this window"))
+		return
+	}
+	fairness := report.ContentionFairness(entries)
+	tw := ui.NewTable(body, 2)
+	fmt.Fprintln(tw, "VICTIM PID\tVICTIM\tAGGRESSOR PID\tAGGRESSOR\tCOUNT\tFAIRNESS\tAGGRESSOR DIAG")
+	for _, pair := range rows {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%d\t%.2f\t%s\n",
+			pair.VictimPID, pair.VictimComm, pair.AggressorPID, pair.AggressorComm, pair.Count,
+			fairness(pair.VictimPID, pair.AggressorPID), diagCell(procIndex[pair.AggressorPID].Diagnosis))
+	}
+	tw.Flush()
+}
+
+// writePinnedFocus renders the Focus section for -focus-pid: the pinned
+// process with its real diagnosis and summary, even when it is OK.
+func writePinnedFocus(body *bytes.Buffer, pid uint32, rows []report.ProcMetrics) {
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Focus · Pinned to PID %d", pid)))
+	proc, ok := report.SelectFocusPID(rows, pid)
+	if !ok {
+		fmt.Fprintf(body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("focus pid %d not found this window", pid)))
+		return
+	}
+	severity := report.Severity(proc.Diagnosis)
+	body.WriteString(ui.FocusGroupHeader(proc.Diagnosis, severity, 1))
+	body.WriteString(ui.FocusEntry(proc.CommLabel(), proc.PID, report.FocusSummary(proc), severity))
+}
+
+// writeCompactTable renders the table-compact format: one row per non-OK
+// process with only its key metric, ordered by diagnosis severity.
+func writeCompactTable(body *bytes.Buffer, focusGroups []report.FocusGroup, totalRows int) {
+	body.WriteString(ui.SectionHeader("Focus · Processes requiring attention"))
+	if len(focusGroups) == 0 {
+		fmt.Fprintf(body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("All %d processes OK in this window", totalRows)))
+		return
+	}
+	tw := ui.NewTable(body, 2)
+	fmt.Fprintln(tw, "PID\tCOMM\tKEY METRIC\tDiag")
+	for _, group := range focusGroups {
+		for _, proc := range group.Procs {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n",
+				proc.PID, proc.Comm, report.KeyMetric(proc), diagCell(proc.Diagnosis))
+		}
+	}
+	tw.Flush()
+}
+
+// termSize is the size of the terminal on stdout, in cells. It is zero when
+// stdout is not a terminal, so piped or redirected output gets full-width
+// tables in the requested format.
+type termSize struct {
+	width, height int
+}
+
+// currentTermSize queries the size of the terminal on stdout.
+func currentTermSize() termSize {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return termSize{}
+	}
+	return termSize{width: width, height: height}
+}
+
+// tooSmall reports whether the terminal is below minFullWidth x
+// minFullHeight.
+func (t termSize) tooSmall() bool {
+	return t.width > 0 && (t.width < minFullWidth || t.height < minFullHeight)
+}
+
+// writeFrame outputs one snapshot: redrawn in place on the alternate screen by
+// default, or appended to the normal buffer with -no-alt-screen.
+func writeFrame(cfg runConfig, header, body string) {
+	if cfg.noAltScreen {
+		appendFrame(header, body)
+		return
+	}
+	renderFrame(header, body, cfg.term.height)
+}
+
+// appendFrame prints a complete, untruncated snapshot below the previous one.
+// No cursor movement or clearing is used, so every snapshot stays in the
+// terminal's scrollback (and in files when stdout is redirected).
+func appendFrame(header, body string) {
+	var frame bytes.Buffer
+	frame.WriteString(header)
+	frame.WriteString(body)
+	if !strings.HasSuffix(body, "\n") {
+		frame.WriteString("\n")
+	}
+	frame.WriteString(ui.C(ui.Dim, strings.Repeat("═", 60)))
+	frame.WriteString("\n")
+	fmt.Print(frame.String())
 }
 
 // renderFrame writes a flicker-free frame to the terminal.
@@ -282,9 +1819,8 @@ func snapshotAndPrint(cpuCollector *cpu.Collector, memCollector *memory.Collecto
 //  1. Moving cursor to home (\033[H]) instead of clearing the screen
 //  2. Overwriting existing content in-place
 //  3. Clearing only leftover lines after the new content (\033[J)
-func renderFrame(header, body string) {
-	_, termHeight, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil || termHeight <= 0 {
+func renderFrame(header, body string, termHeight int) {
+	if termHeight <= 0 {
 		termHeight = 50 // safe fallback for non-TTY / pipe
 	}
 
@@ -338,33 +1874,44 @@ func enableSingleView() func() {
 	fmt.Print("\033[?1049h") // switch to alternate buffer
 	fmt.Print("\033[?25l")   // hide cursor
 
+	// Log records written to the terminal would land in the middle of the
+	// tables; hold them until the main buffer is back. Redirected stderr
+	// (2>hotspot.log) gets them as they happen.
 	var restore []func()
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		diagLog.Hold()
+		restore = append(restore, func() { diagLog.Release() })
+	}
 	if term.IsTerminal(stdinFD) {
-		if undoEcho, err := disableInputEcho(stdinFD); err != nil {
-			log.Printf("unable to suppress stdin echo: %v", err)
-		} else if undoEcho != nil {
-			restore = append(restore, undoEcho)
+		if undo, err := enterCbreakMode(stdinFD); err != nil {
+			slog.Warn("unable to suppress stdin echo", "err", err)
+		} else if undo != nil {
+			restore = append(restore, undo)
 		}
 	}
 
 	return func() {
+		fmt.Print("\033[?25h")   // show cursor
+		fmt.Print("\033[?1049l") // restore main buffer
 		for i := len(restore) - 1; i >= 0; i-- {
 			restore[i]()
 		}
-		fmt.Print("\033[?25h")   // show cursor
-		fmt.Print("\033[?1049l") // restore main buffer
 	}
 }
 
-// disableInputEcho turns off stdin echo so the alternate-screen view stays clean.
-func disableInputEcho(fd int) (func(), error) {
+// enterCbreakMode turns off stdin echo, so the alternate-screen view stays
+// clean, and line buffering, so readKeys sees each keypress as it is typed.
+// Signal keys such as Ctrl+C keep working.
+func enterCbreakMode(fd int) (func(), error) {
 	termState, err := unix.IoctlGetTermios(fd, unix.TCGETS)
 	if err != nil {
 		return nil, err
 	}
 
 	updated := *termState
-	updated.Lflag &^= unix.ECHO
+	updated.Lflag &^= unix.ECHO | unix.ICANON
+	updated.Cc[unix.VMIN] = 1
+	updated.Cc[unix.VTIME] = 0
 
 	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &updated); err != nil {
 		return nil, err
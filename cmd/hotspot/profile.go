@@ -0,0 +1,126 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"github.com/srodi/hotspot-bpf/pkg/collector/profile"
+	"github.com/srodi/hotspot-bpf/pkg/report"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+	"github.com/srodi/hotspot-bpf/pkg/ui"
+)
+
+// profileFrames is how many functions the Profile pane shows.
+const profileFrames = 10
+
+// focusProfiler samples the stacks of the Focus process (-profile-focus).
+// It is best-effort: when the sampler cannot load, the pane says why and
+// every other section works as usual.
+type focusProfiler struct {
+	c   *profile.Collector // nil when err is set
+	err error
+}
+
+// profileWindow is what the profiler sampled in one window.
+type profileWindow struct {
+	pid    uint32 // 0 when nothing was sampled
+	stacks []types.StackCount
+	err    error
+}
+
+func newFocusProfiler() *focusProfiler {
+	c, err := profile.NewCollector(profile.DefaultFrequency)
+	return &focusProfiler{c: c, err: err}
+}
+
+// take returns the stacks of the window that just ended and switches the
+// sampler to next, the process the Focus section shows this window, so
+// the following frame explains it.
+func (p *focusProfiler) take(next uint32) profileWindow {
+	if p.err != nil {
+		return profileWindow{err: p.err}
+	}
+	w := profileWindow{pid: p.c.Target()}
+	if w.pid != 0 {
+		w.stacks, w.err = p.c.Snapshot()
+	}
+	if next != w.pid {
+		if err := p.c.SetTarget(next); err != nil {
+			slog.Warn("profile target not set", "pid", next, "err", err)
+		}
+	}
+	return w
+}
+
+// reset clears the counts for the next window.
+func (p *focusProfiler) reset() {
+	if p.err != nil {
+		return
+	}
+	if err := p.c.Reset(); err != nil {
+		slog.Error("reset failed", "collector", "profile", "err", err)
+	}
+}
+
+func (p *focusProfiler) Close() error {
+	if p.err != nil {
+		return nil
+	}
+	return p.c.Close()
+}
+
+// focusTarget returns the process the Focus section is about: the pinned
+// PID, or else the first process of the most severe group, or 0 for none.
+func focusTarget(pinned uint32, focusGroups []report.FocusGroup) uint32 {
+	if pinned != 0 {
+		return pinned
+	}
+	if len(focusGroups) > 0 && len(focusGroups[0].Procs) > 0 {
+		return focusGroups[0].Procs[0].PID
+	}
+	return 0
+}
+
+// writeProfile renders the Profile pane: the functions the sampled process
+// was running in (Self) and had on its stack (Total), as shares of its
+// samples. Kernel functions are marked [k].
+func writeProfile(body *bytes.Buffer, p *focusProfiler, w profileWindow, rows []report.ProcMetrics) {
+	if w.err != nil {
+		body.WriteString(ui.SectionHeader("Profile"))
+		fmt.Fprintf(body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("unavailable: %v", w.err)))
+		return
+	}
+	if w.pid == 0 {
+		body.WriteString(ui.SectionHeader("Profile"))
+		fmt.Fprintln(body, ui.C(ui.Dim, "Sampling starts once a process is in the Focus section"))
+		return
+	}
+	title := fmt.Sprintf("Profile · Where PID %d spent its CPU", w.pid)
+	if proc, ok := report.SelectFocusPID(rows, w.pid); ok {
+		title = fmt.Sprintf("Profile · Where PID %d (%s) spent its CPU", w.pid, proc.CommLabel())
+	}
+	body.WriteString(ui.SectionHeader(title))
+	total := profile.TotalSamples(w.stacks)
+	if total == 0 {
+		fmt.Fprintln(body, ui.C(ui.Dim, "No samples in this window: the process did not run"))
+		return
+	}
+	tw := ui.NewTable(body, 2)
+	fmt.Fprintln(tw, "Self%\tTotal%\tFUNCTION")
+	for _, f := range profile.TopFrames(w.stacks, profileFrames) {
+		name := f.Frame.Symbol
+		if f.Frame.Kernel {
+			name = "[k] " + name
+		}
+		fmt.Fprintf(tw, "%.1f\t%.1f\t%s\n",
+			100*float64(f.Self)/float64(total), 100*float64(f.Total)/float64(total), name)
+	}
+	tw.Flush()
+	fmt.Fprintln(body, ui.C(ui.Dim, fmt.Sprintf("%d samples at %d Hz", total, profile.DefaultFrequency)))
+	if err := p.c.KernelSymbolsErr(); err != nil {
+		fmt.Fprintln(body, ui.C(ui.Dim, fmt.Sprintf("kernel frames shown as addresses: %v", err)))
+	}
+}
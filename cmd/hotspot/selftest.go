@@ -0,0 +1,83 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cpu"
+	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
+)
+
+// selfCheck is one step of -selftest: run returns what it found, or why it
+// failed.
+type selfCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+// runSelfTest runs -selftest, a preflight for environments about to run
+// hotspot: it reports the kernel, its BTF, and the capabilities held, loads
+// the CPU and memory eBPF objects, checks they have every program and map
+// this version expects, and attaches and detaches their probes with the
+// collector options cfg was given. It never starts the collection loop or
+// touches the terminal. Every step runs even after one fails, so a single
+// run names every problem. It writes the report to w and returns the exit
+// status: 0 when every step passed, 1 otherwise.
+func runSelfTest(w io.Writer, cfg runConfig) int {
+	checks := []selfCheck{
+		{"kernel BTF", func() (string, error) {
+			if err := bpfenv.CheckBTF(); err != nil {
+				return "", err
+			}
+			return bpfenv.KernelBTF()
+		}},
+		{"capabilities", func() (string, error) {
+			held, err := bpfenv.Capabilities()
+			if err != nil {
+				return "", fmt.Errorf("reading capabilities: %w", err)
+			}
+			if err := bpfenv.CheckCapabilities(); err != nil {
+				return "", err
+			}
+			return strings.Join(held, ", "), nil
+		}},
+		{"cpu eBPF", func() (string, error) {
+			if err := cpu.SelfTest(cfg.cpuOptions); err != nil {
+				return "", err
+			}
+			if cfg.cpuOptions.Method == cpu.MethodProc {
+				return "objects complete; -cpu-method proc reads /proc and loads neither", nil
+			}
+			return fmt.Sprintf("objects complete, -cpu-method %s loaded, attached, and detached", cfg.cpuOptions.Method), nil
+		}},
+		{"memory eBPF", func() (string, error) {
+			if err := memory.SelfTest(cfg.cgroupPath); err != nil {
+				return "", err
+			}
+			return "object complete, loaded, kprobe/handle_mm_fault attached and detached", nil
+		}},
+	}
+
+	fmt.Fprintf(w, "hotspot-bpf %s selftest\n", readBuildInfo().Version)
+	fmt.Fprintf(w, "  %-13s %s\n", "kernel:", kernelRelease())
+	var failed []string
+	for _, c := range checks {
+		detail, err := c.run()
+		if err != nil {
+			failed = append(failed, c.name)
+			fmt.Fprintf(w, "  %-13s FAIL: %v\n", c.name+":", err)
+			continue
+		}
+		fmt.Fprintf(w, "  %-13s ok (%s)\n", c.name+":", detail)
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(w, "selftest failed: %s\n", strings.Join(failed, ", "))
+		return 1
+	}
+	fmt.Fprintln(w, "selftest passed")
+	return 0
+}
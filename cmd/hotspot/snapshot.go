@@ -0,0 +1,96 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/cgroup"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cpu"
+	"github.com/srodi/hotspot-bpf/pkg/engine"
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+// snapshotHost identifies where a -snapshot document was taken, so a bot
+// that collects them from many machines can tell them apart.
+type snapshotHost struct {
+	Version  string `json:"version"`
+	Hostname string `json:"hostname,omitempty"`
+	Kernel   string `json:"kernel"`
+	CPUs     int    `json:"cpus"`
+}
+
+// snapshotDocument is what -snapshot prints on success. Processes are
+// filtered and sorted as the CPU Hotspots table would be.
+type snapshotDocument struct {
+	snapshotHost
+	Time      time.Time            `json:"time"`
+	Window    time.Duration        `json:"window_ns"`
+	Processes []report.ProcMetrics `json:"processes"`
+}
+
+// snapshotError is what -snapshot prints instead when sampling fails.
+type snapshotError struct {
+	snapshotHost
+	Error string `json:"error"`
+}
+
+// runSnapshot samples one cfg.snapshot window with the library engine,
+// writes a single JSON document to w, and returns the exit code.
+func runSnapshot(w io.Writer, cfg runConfig) int {
+	host := snapshotHost{Version: readBuildInfo().Version, Kernel: kernelRelease(), CPUs: runtime.NumCPU()}
+	host.Hostname, _ = os.Hostname()
+	fail := func(err error) int {
+		writeJSON(w, snapshotError{snapshotHost: host, Error: err.Error()})
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Spike events would need a reader; a single window has no pane for them.
+	cpuOpts := cpu.Options{Method: cfg.cpuOptions.Method, PerfFrequency: cfg.cpuOptions.PerfFrequency}
+	eng, err := engine.New(engine.Options{CPU: cpuOpts, Thresholds: &cfg.thresholds, Net: cfg.net, CgroupPath: cfg.cgroupPath})
+	if err != nil {
+		return fail(err)
+	}
+	defer eng.Close()
+
+	rows, err := eng.Collect(ctx, cfg.snapshot)
+	if err != nil {
+		return fail(err)
+	}
+	var members map[uint32]struct{}
+	if cfg.watchCgroup != "" {
+		if members, err = cgroup.Members(cfg.watchCgroup); err != nil {
+			return fail(fmt.Errorf("reading -watch-cgroup members: %w", err))
+		}
+	}
+	rows = report.FilterMetrics(rows, cfg.filterConfig(members))
+	report.SortRows(rows, cfg.sortKey)
+	if rows == nil {
+		rows = []report.ProcMetrics{} // "processes": [] rather than null
+	}
+
+	if err := writeJSON(w, snapshotDocument{snapshotHost: host, Time: time.Now(), Window: cfg.snapshot, Processes: rows}); err != nil {
+		slog.Error("writing snapshot failed", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// writeJSON writes v to w as one indented JSON document.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/collector/cpu"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+	"github.com/srodi/hotspot-bpf/pkg/ui"
+)
+
+// recentSpikes is how many spike events the Recent Spikes pane shows.
+const recentSpikes = 8
+
+// spikeLog keeps the latest spike events streamed by the CPU collector. The
+// stream runs independently of the sampling interval, so the pane shows
+// spikes from earlier windows too until newer ones push them out.
+type spikeLog struct {
+	mu     sync.Mutex
+	events []types.SpikeEvent // oldest first, at most recentSpikes
+	total  uint64             // events received since start
+	err    error              // why the stream stopped, if not Close
+}
+
+// follow reads spike events from c until c is closed.
+func (l *spikeLog) follow(c *cpu.Collector) {
+	for {
+		ev, err := c.ReadSpike()
+		l.mu.Lock()
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				l.err = err
+			}
+			l.mu.Unlock()
+			return
+		}
+		l.events = append(l.events, ev)
+		if len(l.events) > recentSpikes {
+			l.events = l.events[len(l.events)-recentSpikes:]
+		}
+		l.total++
+		l.mu.Unlock()
+	}
+}
+
+// recent returns the kept events, newest first, and the total received.
+func (l *spikeLog) recent() ([]types.SpikeEvent, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]types.SpikeEvent, len(l.events))
+	for i, ev := range l.events {
+		events[len(events)-1-i] = ev
+	}
+	return events, l.total, l.err
+}
+
+// writeSpikes renders the Recent Spikes pane. Spikes are not filtered: they
+// are rare by construction, and one from a hidden process still explains a
+// stall elsewhere.
+func writeSpikes(body *bytes.Buffer, cpuCollector *cpu.Collector, spikes *spikeLog, threshold time.Duration) {
+	body.WriteString(ui.SectionHeader(fmt.Sprintf("Recent Spikes · Threads that ran %v or longer without a context switch", threshold)))
+	if !cpuCollector.SpikeTracking() {
+		fmt.Fprintln(body, ui.C(ui.Dim, "unavailable: spike events need a ring buffer (Linux 5.8)"))
+		return
+	}
+	events, total, err := spikes.recent()
+	if err != nil {
+		fmt.Fprintf(body, "%s\n", ui.C(ui.Dim, fmt.Sprintf("spike stream stopped: %v", err)))
+	}
+	if drops, err := cpuCollector.SpikeDrops(); err == nil && drops > 0 {
+		fmt.Fprintln(body, ui.C(ui.Yellow, fmt.Sprintf("%d spike events dropped: the ring buffer was full", drops)))
+	}
+	if len(events) == 0 {
+		fmt.Fprintln(body, ui.C(ui.Dim, "No spikes since start"))
+		return
+	}
+	tw := ui.NewTable(body, 2)
+	fmt.Fprintln(tw, "TIME\tPID\tTID\tCOMM\tCPU\tRun(ms)")
+	for _, ev := range events {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t%.1f\n",
+			ev.At.Format("15:04:05.000"), ev.PID, ev.TID, ev.Comm, ev.CPU, float64(ev.SliceNs)/1e6)
+	}
+	tw.Flush()
+	if total > uint64(len(events)) {
+		fmt.Fprintln(body, ui.C(ui.Dim, fmt.Sprintf("%d spikes since start", total)))
+	}
+}
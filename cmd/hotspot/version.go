@@ -0,0 +1,106 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cpu"
+	"golang.org/x/sys/unix"
+)
+
+// Build information, set at build time via
+//
+//	-ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unset values fall back to what the Go toolchain stamped into the binary.
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo identifies the running binary.
+type buildInfo struct {
+	Version string
+	Commit  string // "" when neither -ldflags nor the toolchain recorded one
+	Date    string // commit time from the toolchain unless set with -ldflags
+	Dirty   bool   // built from a tree with uncommitted changes
+	Go      string
+}
+
+// readBuildInfo returns the -ldflags values, filling in the module version
+// and VCS revision the toolchain records (go install, or go build in a git
+// checkout) for those that were not set.
+func readBuildInfo() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, Date: date, Go: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	if b.Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		b.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = s.Value
+			}
+		case "vcs.time":
+			if b.Date == "" {
+				b.Date = s.Value
+			}
+		case "vcs.modified":
+			b.Dirty = s.Value == "true"
+		}
+	}
+	return b
+}
+
+// writeVersion prints -version: the build, the running kernel, and whether
+// the eBPF objects can be relocated to it.
+func writeVersion(w io.Writer) {
+	b := readBuildInfo()
+	commit := orUnknown(b.Commit)
+	if b.Dirty {
+		commit += " (modified)"
+	}
+	core := "no (eBPF objects carry no BTF; regenerate them with bpf2go)"
+	if cpu.ObjectBTF() {
+		core = "yes"
+	}
+	kernelBTF := "no (build the kernel with CONFIG_DEBUG_INFO_BTF=y)"
+	if path, err := bpfenv.KernelBTF(); err == nil {
+		kernelBTF = "yes (" + path + ")"
+	}
+
+	fmt.Fprintf(w, "hotspot-bpf %s\n", b.Version)
+	fmt.Fprintf(w, "  commit:     %s\n", commit)
+	fmt.Fprintf(w, "  built:      %s\n", orUnknown(b.Date))
+	fmt.Fprintf(w, "  go:         %s %s/%s\n", b.Go, runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "  kernel:     %s\n", kernelRelease())
+	fmt.Fprintf(w, "  CO-RE:      %s\n", core)
+	fmt.Fprintf(w, "  kernel BTF: %s\n", kernelBTF)
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// kernelRelease returns the running kernel's release (uname -r), or
+// "unknown".
+func kernelRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return "unknown"
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
@@ -5,9 +5,20 @@ go 1.26.0
 toolchain go1.26.2
 
 require (
-	golang.org/x/sys v0.43.0
 	github.com/cilium/ebpf v0.21.0
+	github.com/prometheus/client_golang v1.22.0
+	golang.org/x/sys v0.43.0
 	golang.org/x/term v0.37.0
 )
 
 require gopkg.in/yaml.v3 v3.0.1
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+)
@@ -0,0 +1,170 @@
+// Package bpfenv checks that the running kernel can take the collectors'
+// eBPF programs before they are loaded, so a missing prerequisite is
+// reported as such instead of as a verifier or CO-RE relocation error.
+package bpfenv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNoKernelBTF reports a kernel without BTF type information.
+var ErrNoKernelBTF = errors.New("kernel BTF not found")
+
+// ErrMissingCapabilities reports a process that may not load and attach the
+// collectors' programs.
+var ErrMissingCapabilities = errors.New("missing capabilities")
+
+// kernelBTFPath is where kernels built with CONFIG_DEBUG_INFO_BTF expose
+// their BTF.
+const kernelBTFPath = "/sys/kernel/btf/vmlinux"
+
+// vmlinuxPaths are where distributions install a vmlinux image with BTF for
+// kernels that do not expose it in sysfs, formatted with the kernel release.
+// The loader searches the same places.
+var vmlinuxPaths = []string{
+	"/boot/vmlinux-%s",
+	"/lib/modules/%[1]s/vmlinux-%[1]s",
+	"/lib/modules/%s/build/vmlinux",
+	"/usr/lib/modules/%s/kernel/vmlinux",
+	"/usr/lib/debug/boot/vmlinux-%s",
+	"/usr/lib/debug/boot/vmlinux-%s.debug",
+	"/usr/lib/debug/lib/modules/%s/vmlinux",
+}
+
+// root prefixes every path searched; tests point it at a fixture directory.
+var root = "/"
+
+// KernelBTF returns the file the running kernel's BTF is read from:
+// /sys/kernel/btf/vmlinux, or failing that a vmlinux image installed for the
+// kernel release. It returns ErrNoKernelBTF when there is neither.
+func KernelBTF() (string, error) {
+	if fileExists(filepath.Join(root, kernelBTFPath)) {
+		return kernelBTFPath, nil
+	}
+	release, err := kernelRelease()
+	if err != nil {
+		return "", fmt.Errorf("%w: reading kernel release: %v", ErrNoKernelBTF, err)
+	}
+	for _, p := range vmlinuxPaths {
+		path := fmt.Sprintf(p, release)
+		if fileExists(filepath.Join(root, path)) {
+			return path, nil
+		}
+	}
+	return "", ErrNoKernelBTF
+}
+
+// CheckBTF returns nil when the kernel's BTF can be found. Otherwise the
+// error explains that the collectors' CO-RE programs need it and how to get
+// a kernel that has it; it wraps ErrNoKernelBTF.
+func CheckBTF() error {
+	if _, err := KernelBTF(); err != nil {
+		return fmt.Errorf("%w: %s is missing and no vmlinux image was found under /boot or /lib/modules. "+
+			"The eBPF programs are compiled once (CO-RE) and relocated against the running kernel's types, "+
+			"so they need a kernel built with CONFIG_DEBUG_INFO_BTF=y (the default on most distributions since 2020) "+
+			"or a vmlinux with BTF installed for this kernel", err, kernelBTFPath)
+	}
+	return nil
+}
+
+// Capability bits, as numbered in linux/capability.h.
+const (
+	capSysAdmin = 21
+	capPerfmon  = 38
+	capBPF      = 39
+)
+
+// capNames are the capabilities Capabilities reports, in order.
+var capNames = []struct {
+	bit  uint
+	name string
+}{
+	{capBPF, "CAP_BPF"},
+	{capPerfmon, "CAP_PERFMON"},
+	{capSysAdmin, "CAP_SYS_ADMIN"},
+}
+
+// Capabilities returns the names of the capabilities the collectors use that
+// the process holds in its effective set, read from /proc/self/status:
+// CAP_BPF, CAP_PERFMON, and CAP_SYS_ADMIN.
+func Capabilities() ([]string, error) {
+	eff, err := effectiveCaps()
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	for _, c := range capNames {
+		if eff&(1<<c.bit) != 0 {
+			held = append(held, c.name)
+		}
+	}
+	return held, nil
+}
+
+// CheckCapabilities returns nil when the process may load the collectors'
+// programs and attach their tracepoints, kprobes, and perf events: with
+// CAP_SYS_ADMIN, or CAP_BPF and CAP_PERFMON on Linux 5.8 and later.
+// Otherwise the error names what is missing; it wraps
+// ErrMissingCapabilities.
+func CheckCapabilities() error {
+	eff, err := effectiveCaps()
+	if err != nil {
+		return err
+	}
+	if eff&(1<<capSysAdmin) != 0 {
+		return nil
+	}
+	var missing []string
+	for _, c := range capNames[:2] {
+		if eff&(1<<c.bit) == 0 {
+			missing = append(missing, c.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s not held. Loading and attaching the eBPF programs needs CAP_SYS_ADMIN, "+
+			"or CAP_BPF and CAP_PERFMON (Linux 5.8+); run as root or grant them, e.g. setcap cap_bpf,cap_perfmon+ep",
+			ErrMissingCapabilities, strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// effectiveCaps returns the CapEff mask of /proc/self/status.
+func effectiveCaps() (uint64, error) {
+	f, err := os.Open(filepath.Join(root, "proc/self/status"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("no CapEff line in /proc/self/status")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// kernelRelease returns the running kernel's release (uname -r).
+var kernelRelease = func() (string, error) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return "", err
+	}
+	return unix.ByteSliceToString(uts.Release[:]), nil
+}
@@ -0,0 +1,93 @@
+package bpfenv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeRoot(t *testing.T, release string, files ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldRoot, oldRelease := root, kernelRelease
+	t.Cleanup(func() { root, kernelRelease = oldRoot, oldRelease })
+	root = dir
+	kernelRelease = func() (string, error) { return release, nil }
+}
+
+func TestKernelBTFPrefersSysfs(t *testing.T) {
+	fakeRoot(t, "6.8.0", "sys/kernel/btf/vmlinux", "boot/vmlinux-6.8.0")
+	path, err := KernelBTF()
+	if err != nil || path != "/sys/kernel/btf/vmlinux" {
+		t.Fatalf("KernelBTF() = %q, %v; want the sysfs file", path, err)
+	}
+}
+
+func TestKernelBTFFallsBackToVmlinux(t *testing.T) {
+	fakeRoot(t, "5.4.0-42", "lib/modules/5.4.0-42/vmlinux-5.4.0-42", "boot/vmlinux-5.15.0")
+	path, err := KernelBTF()
+	if err != nil || path != "/lib/modules/5.4.0-42/vmlinux-5.4.0-42" {
+		t.Fatalf("KernelBTF() = %q, %v; want the image for the running release", path, err)
+	}
+}
+
+func TestCheckBTFExplainsMissingBTF(t *testing.T) {
+	// An image for another release does not count.
+	fakeRoot(t, "5.4.0", "boot/vmlinux-5.15.0")
+	err := CheckBTF()
+	if !errors.Is(err, ErrNoKernelBTF) {
+		t.Fatalf("CheckBTF() = %v; want ErrNoKernelBTF", err)
+	}
+	if !strings.Contains(err.Error(), "CONFIG_DEBUG_INFO_BTF") {
+		t.Fatalf("error does not say how to fix it: %v", err)
+	}
+
+	fakeRoot(t, "5.4.0", "sys/kernel/btf/vmlinux")
+	if err := CheckBTF(); err != nil {
+		t.Fatalf("CheckBTF() with BTF = %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		name, capEff string
+		held         []string
+		ok           bool
+	}{
+		{"root", "000001ffffffffff", []string{"CAP_BPF", "CAP_PERFMON", "CAP_SYS_ADMIN"}, true},
+		{"bpfAndPerfmon", "000000c000000000", []string{"CAP_BPF", "CAP_PERFMON"}, true},
+		{"sysAdminOnly", "0000000000200000", []string{"CAP_SYS_ADMIN"}, true},
+		{"bpfOnly", "0000008000000000", []string{"CAP_BPF"}, false}, // reported as missing CAP_PERFMON
+		{"none", "0000000000000000", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeRoot(t, "6.8.0", "proc/self/status")
+			status := "Name:\thotspot\nCapInh:\t0000000000000000\nCapEff:\t" + tc.capEff + "\n"
+			if err := os.WriteFile(filepath.Join(root, "proc/self/status"), []byte(status), 0644); err != nil {
+				t.Fatal(err)
+			}
+			held, err := Capabilities()
+			if err != nil || strings.Join(held, ",") != strings.Join(tc.held, ",") {
+				t.Fatalf("Capabilities() = %v, %v; want %v", held, err, tc.held)
+			}
+			err = CheckCapabilities()
+			if tc.ok != (err == nil) {
+				t.Fatalf("CheckCapabilities() = %v; want ok %v", err, tc.ok)
+			}
+			if !tc.ok && (!errors.Is(err, ErrMissingCapabilities) || !strings.Contains(err.Error(), "CAP_PERFMON not held")) {
+				t.Fatalf("CheckCapabilities() = %v; want ErrMissingCapabilities naming CAP_PERFMON", err)
+			}
+		})
+	}
+}
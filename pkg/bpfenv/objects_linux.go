@@ -0,0 +1,101 @@
+//go:build linux
+
+package bpfenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// objectDir is where LoadSpec reads eBPF objects from; "" uses the objects
+// embedded at build time.
+var objectDir string
+
+// SetObjectDir has LoadSpec read eBPF objects from dir (-bpf-object-dir)
+// instead of the objects embedded at build time, for kernels the embedded
+// CO-RE objects do not relocate cleanly against. An empty dir restores the
+// embedded objects. It applies to every collector created afterwards.
+func SetObjectDir(dir string) error {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	objectDir = dir
+	return nil
+}
+
+// LoadSpec returns the collection spec of the object bpf2go generated as
+// name (e.g. "hotspot_bpf"). Normally that is embedded, returned by
+// embedded; after SetObjectDir it is read from name+"_bpfel.o" in that
+// directory, the file go generate writes, and checked against objs, the
+// generated objects struct it will be assigned to, so an object built from
+// other sources fails with the maps and programs it lacks rather than
+// partway through loading.
+func LoadSpec(name string, embedded func() (*ebpf.CollectionSpec, error), objs interface{}) (*ebpf.CollectionSpec, error) {
+	if objectDir == "" {
+		return embedded()
+	}
+	path := filepath.Join(objectDir, name+"_bpfel.o")
+	spec, err := ebpf.LoadCollectionSpec(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading eBPF object: %w", err)
+	}
+	if err := CheckSpec(spec, objs); err != nil {
+		return nil, fmt.Errorf("eBPF object %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// CheckSpec reports the programs, maps, and variables objs, a generated
+// objects struct, expects that spec does not have. LoadSpec runs it on
+// objects read with SetObjectDir; -selftest runs it on every object.
+func CheckSpec(spec *ebpf.CollectionSpec, objs interface{}) error {
+	var missing []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			name := f.Tag.Get("ebpf")
+			if name == "" {
+				continue
+			}
+			var ok bool
+			var kind string
+			switch f.Type {
+			case reflect.TypeOf((*ebpf.Program)(nil)):
+				_, ok = spec.Programs[name]
+				kind = "program"
+			case reflect.TypeOf((*ebpf.Map)(nil)):
+				_, ok = spec.Maps[name]
+				kind = "map"
+			case reflect.TypeOf((*ebpf.Variable)(nil)):
+				_, ok = spec.Variables[name]
+				kind = "variable"
+			default:
+				continue
+			}
+			if !ok {
+				missing = append(missing, kind+" "+name)
+			}
+		}
+	}
+	walk(reflect.TypeOf(objs).Elem())
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s; was it built from this version's bpf/ sources?", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package bpfenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+type testPrograms struct {
+	HandleSwitch *ebpf.Program `ebpf:"handle_switch"`
+}
+
+type testMaps struct {
+	PidStats *ebpf.Map `ebpf:"pid_stats"`
+	Window   *ebpf.Map `ebpf:"window_start"`
+}
+
+type testObjects struct {
+	testPrograms
+	testMaps
+}
+
+func TestCheckSpecNamesWhatIsMissing(t *testing.T) {
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{"handle_switch": {}},
+		Maps:     map[string]*ebpf.MapSpec{"pid_stats": {}},
+	}
+	err := CheckSpec(spec, &testObjects{})
+	if err == nil || !strings.Contains(err.Error(), "map window_start") {
+		t.Fatalf("CheckSpec = %v; want window_start reported missing", err)
+	}
+	if strings.Contains(err.Error(), "pid_stats") || strings.Contains(err.Error(), "handle_switch") {
+		t.Fatalf("CheckSpec reported objects that are present: %v", err)
+	}
+
+	spec.Maps["window_start"] = &ebpf.MapSpec{}
+	if err := CheckSpec(spec, &testObjects{}); err != nil {
+		t.Fatalf("CheckSpec with everything present = %v", err)
+	}
+}
+
+func TestLoadSpecFromObjectDir(t *testing.T) {
+	t.Cleanup(func() { objectDir = "" })
+	embedded := func() (*ebpf.CollectionSpec, error) { return &ebpf.CollectionSpec{}, nil }
+
+	if spec, err := LoadSpec("hotspot_bpf", embedded, &testObjects{}); err != nil || spec == nil {
+		t.Fatalf("LoadSpec without an object dir = %v, %v; want the embedded spec", spec, err)
+	}
+
+	dir := t.TempDir()
+	if err := SetObjectDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSpec("hotspot_bpf", embedded, &testObjects{}); err == nil {
+		t.Fatal("LoadSpec fell back to the embedded object when the file is missing")
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetObjectDir(file); err == nil {
+		t.Fatal("SetObjectDir accepted a regular file")
+	}
+}
@@ -0,0 +1,172 @@
+// Package cgroup reads process membership from the cgroup v2 unified
+// hierarchy. It backs -watch-cgroup, which scopes output to the exact set of
+// processes in a cgroup instead of matching cgroup names by substring, and
+// reports a cgroup's effective memory limit and the headroom left under it.
+// Memory limits are also read from the cgroup v1 memory controller. It also
+// turns the cgroup ids the BPF collectors record back into paths.
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// root is the cgroup v2 mount point; tests point it at a fixture directory.
+var root = "/sys/fs/cgroup"
+
+// Resolve maps a user-supplied cgroup path to its directory in the unified
+// hierarchy. Both "/system.slice/nginx.service" (as shown in
+// /proc/PID/cgroup) and the full "/sys/fs/cgroup/system.slice/nginx.service"
+// form are accepted.
+func Resolve(path string) string {
+	path = filepath.Clean("/" + strings.TrimSpace(path))
+	if path == root || strings.HasPrefix(path, root+"/") {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// Members returns the TGIDs currently in the cgroup at path and all of its
+// descendants. cgroup v2 only places processes in leaf cgroups, so a pod or
+// slice has no direct members of its own; walking the subtree matches what
+// the kernel charges to the cgroup. Child cgroups that disappear during the
+// walk are skipped, but a missing top-level cgroup is an error.
+func Members(path string) (map[uint32]struct{}, error) {
+	dir := Resolve(path)
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.procs")); err != nil {
+		return nil, err
+	}
+	members := make(map[uint32]struct{})
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p != dir {
+				return nil // removed while walking
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(filepath.Join(p, "cgroup.procs"))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p != dir {
+				return nil
+			}
+			return err
+		}
+		parseProcs(data, members)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// parseProcs adds each PID listed in a cgroup.procs file to members.
+func parseProcs(data []byte, members map[uint32]struct{}) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		pid, err := strconv.ParseUint(strings.TrimSpace(sc.Text()), 10, 32)
+		if err != nil || pid == 0 {
+			continue
+		}
+		members[uint32(pid)] = struct{}{}
+	}
+}
+
+// MemoryHeadroom returns how many more bytes the cgroup at path can be
+// charged before it reaches a memory limit: the smallest memory.max minus
+// memory.current over the cgroup and its ancestors, since a limit set on a
+// parent slice or pod applies to every cgroup below it. limited is false when
+// no cgroup on the path sets memory.max.
+func MemoryHeadroom(path string) (headroom uint64, limited bool, err error) {
+	dir := Resolve(path)
+	for {
+		limit, err := readMemoryValue(filepath.Join(dir, "memory.max"))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// The root cgroup has no memory.max; nor do cgroups without
+			// the memory controller enabled.
+		case err != nil:
+			return 0, false, err
+		case limit > 0:
+			current, err := readMemoryValue(filepath.Join(dir, "memory.current"))
+			if err != nil {
+				return 0, false, err
+			}
+			room := uint64(0)
+			if current < limit {
+				room = limit - current
+			}
+			if !limited || room < headroom {
+				headroom = room
+			}
+			limited = true
+		}
+		if dir == root || !strings.HasPrefix(dir, root+"/") {
+			return headroom, limited, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// v1Unlimited is the smallest memory.limit_in_bytes treated as "no limit".
+// cgroup v1 reports an unset limit as PAGE_COUNTER_MAX pages, just under
+// 2^63 bytes, rounded down to the page size.
+const v1Unlimited = 1 << 62
+
+// MemoryLimit returns the effective memory limit of the cgroup at path: the
+// smallest limit set on it or any ancestor. The v2 memory.max is read from
+// the unified hierarchy; on a cgroup v1 host, where path is the process's
+// memory controller path, memory.limit_in_bytes is read from the memory
+// hierarchy instead. limited is false when no cgroup on the path sets a limit.
+func MemoryLimit(path string) (limit uint64, limited bool, err error) {
+	dir, file := Resolve(path), "memory.max"
+	top := root
+	if v1 := filepath.Join(root, "memory"); isDir(v1) {
+		dir, file, top = filepath.Join(v1, filepath.Clean("/"+path)), "memory.limit_in_bytes", v1
+	}
+	for {
+		value, err := readMemoryValue(filepath.Join(dir, file))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return 0, false, err
+		case value > 0 && value < v1Unlimited:
+			if !limited || value < limit {
+				limit = value
+			}
+			limited = true
+		}
+		if dir == top || !strings.HasPrefix(dir, top+"/") {
+			return limit, limited, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// readMemoryValue parses a cgroup memory file holding a byte count or "max",
+// which is returned as 0.
+func readMemoryValue(file string) (uint64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
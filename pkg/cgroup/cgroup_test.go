@@ -0,0 +1,140 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProcs(t *testing.T, dir, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cases := map[string]string{
+		"/system.slice/nginx.service":                "/sys/fs/cgroup/system.slice/nginx.service",
+		"system.slice/nginx.service":                 "/sys/fs/cgroup/system.slice/nginx.service",
+		"/sys/fs/cgroup/system.slice/nginx.service/": "/sys/fs/cgroup/system.slice/nginx.service",
+		"/": "/sys/fs/cgroup",
+	}
+	for in, want := range cases {
+		if got := Resolve(in); got != want {
+			t.Fatalf("Resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMembersExactAndRecursive(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { root = "/sys/fs/cgroup" })
+	root = dir
+
+	// "app" is a prefix of "app-canary"; substring matching would conflate them.
+	writeProcs(t, filepath.Join(dir, "pods", "app"), "")
+	writeProcs(t, filepath.Join(dir, "pods", "app", "c1"), "10\n11\n")
+	writeProcs(t, filepath.Join(dir, "pods", "app", "c2"), "12\n")
+	writeProcs(t, filepath.Join(dir, "pods", "app-canary"), "20\n")
+
+	members, err := Members("/pods/app")
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("expected 3 members, got %v", members)
+	}
+	for _, pid := range []uint32{10, 11, 12} {
+		if _, ok := members[pid]; !ok {
+			t.Fatalf("missing pid %d in %v", pid, members)
+		}
+	}
+	if _, ok := members[20]; ok {
+		t.Fatal("sibling cgroup with a shared prefix leaked into membership")
+	}
+
+	if _, err := Members("/pods/missing"); err == nil {
+		t.Fatal("expected error for missing cgroup")
+	}
+}
+
+func TestParseProcsSkipsJunk(t *testing.T) {
+	members := make(map[uint32]struct{})
+	parseProcs([]byte("1\n\nnot-a-pid\n0\n42\n"), members)
+	if len(members) != 2 {
+		t.Fatalf("expected pids 1 and 42, got %v", members)
+	}
+}
+
+func TestMemoryHeadroomUsesTightestAncestor(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { root = "/sys/fs/cgroup" })
+	root = dir
+
+	write := func(rel, file, value string) {
+		t.Helper()
+		p := filepath.Join(dir, rel)
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(p, file), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The pod has 1 GiB left; the container's own limit leaves 1.5 GiB.
+	write("pods/app", "memory.max", "4294967296")
+	write("pods/app", "memory.current", "3221225472")
+	write("pods/app/c1", "memory.max", "2147483648")
+	write("pods/app/c1", "memory.current", "536870912")
+	write("system.slice/cron.service", "memory.max", "max")
+	write("system.slice/cron.service", "memory.current", "1048576")
+
+	headroom, limited, err := MemoryHeadroom("/pods/app/c1")
+	if err != nil || !limited || headroom != 1<<30 {
+		t.Fatalf("MemoryHeadroom = %d, %v, %v; want 1 GiB from the pod limit", headroom, limited, err)
+	}
+	if _, limited, err := MemoryHeadroom("/system.slice/cron.service"); err != nil || limited {
+		t.Fatalf("memory.max of \"max\" is no limit, got limited=%v err=%v", limited, err)
+	}
+
+	limit, limited, err := MemoryLimit("/pods/app/c1")
+	if err != nil || !limited || limit != 2<<30 {
+		t.Fatalf("MemoryLimit = %d, %v, %v; want the container's 2 GiB", limit, limited, err)
+	}
+	if _, limited, err := MemoryLimit("/system.slice/cron.service"); err != nil || limited {
+		t.Fatalf("memory.max of \"max\" is no limit, got limited=%v err=%v", limited, err)
+	}
+}
+
+func TestMemoryLimitCgroupV1(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { root = "/sys/fs/cgroup" })
+	root = dir
+
+	write := func(rel, value string) {
+		t.Helper()
+		p := filepath.Join(dir, "memory", rel)
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(p, "memory.limit_in_bytes"), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("", "9223372036854771712")
+	write("kubepods/pod1", "1073741824")
+	write("kubepods/pod1/c1", "9223372036854771712") // no limit of its own
+	write("docker/abc", "9223372036854771712")
+
+	limit, limited, err := MemoryLimit("/kubepods/pod1/c1")
+	if err != nil || !limited || limit != 1<<30 {
+		t.Fatalf("MemoryLimit = %d, %v, %v; want the pod's 1 GiB", limit, limited, err)
+	}
+	if _, limited, err := MemoryLimit("/docker/abc"); err != nil || limited {
+		t.Fatalf("PAGE_COUNTER_MAX is no limit, got limited=%v err=%v", limited, err)
+	}
+}
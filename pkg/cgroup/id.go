@@ -0,0 +1,81 @@
+package cgroup
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// rewalkInterval bounds how often an unknown id triggers a fresh walk of the
+// hierarchy. Ids of cgroups removed since they were recorded never resolve,
+// and each would otherwise cost a walk.
+const rewalkInterval = time.Second
+
+// idCache maps cgroup ids to paths. It is filled by walking the whole
+// hierarchy once, and walked again when asked for an id it has not seen.
+type idCache struct {
+	mu     sync.Mutex
+	paths  map[uint64]string
+	walked time.Time // when paths was last built; zero before the first walk
+}
+
+var ids idCache
+
+// PathForID returns the path, relative to the hierarchy root, of the cgroup
+// v2 cgroup with the given id, e.g. "/system.slice/nginx.service". This is
+// the id bpf_get_current_cgroup_id reports: the inode number of the
+// cgroup's directory. It returns "" for an id that is not in the hierarchy,
+// such as a cgroup removed since the id was recorded.
+func PathForID(id uint64) string {
+	return ids.path(id, time.Now())
+}
+
+func (c *idCache) path(id uint64, now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.paths[id]; ok {
+		return p
+	}
+	if !c.walked.IsZero() && now.Sub(c.walked) < rewalkInterval {
+		return ""
+	}
+	// A failed walk keeps the previous map: the cgroups it knew are still
+	// more likely to be right than nothing.
+	if paths, err := walkIDs(); err == nil {
+		c.paths = paths
+	}
+	c.walked = now
+	return c.paths[id]
+}
+
+// walkIDs maps the id of every cgroup under root to its path. Cgroups
+// removed during the walk are skipped.
+func walkIDs() (map[uint64]string, error) {
+	paths := make(map[uint64]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p != root {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed while walking
+		}
+		if id, ok := inode(info); ok {
+			rel, _ := filepath.Rel(root, p)
+			paths[id] = filepath.Clean("/" + rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,53 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func mkdirID(t *testing.T, dir string) uint64 {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	return st.Ino
+}
+
+func TestPathForIDRewalksOnUnseenID(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { root = "/sys/fs/cgroup" })
+	root = dir
+
+	pod := filepath.Join(dir, "kubepods.slice", "kubepods-burstable-pod3f1c2a9e_8d4b.slice")
+	api := mkdirID(t, filepath.Join(pod, "cri-containerd-9b2e.scope"))
+
+	var c idCache
+	start := time.Unix(1000, 0)
+	if got, want := c.path(api, start), "/kubepods.slice/kubepods-burstable-pod3f1c2a9e_8d4b.slice/cri-containerd-9b2e.scope"; got != want {
+		t.Fatalf("path = %q, want %q", got, want)
+	}
+	if got := c.path(mkdirID(t, dir), start); got != "/" {
+		t.Fatalf("root path = %q, want /", got)
+	}
+
+	// A container started after the walk resolves once the next walk is due.
+	sidecar := mkdirID(t, filepath.Join(pod, "cri-containerd-41ad.scope"))
+	if got := c.path(sidecar, start.Add(rewalkInterval/2)); got != "" {
+		t.Fatalf("walked again within rewalkInterval: %q", got)
+	}
+	if got := c.path(sidecar, start.Add(rewalkInterval)); filepath.Base(got) != "cri-containerd-41ad.scope" {
+		t.Fatalf("new cgroup not found after rewalk: %q", got)
+	}
+	if got := c.path(1<<62, start.Add(2*rewalkInterval)); got != "" {
+		t.Fatalf("unknown id resolved to %q", got)
+	}
+}
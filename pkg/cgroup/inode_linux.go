@@ -0,0 +1,18 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode returns the inode number of the file behind info, which for a cgroup
+// v2 directory is its cgroup id.
+func inode(info fs.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return st.Ino, true
+}
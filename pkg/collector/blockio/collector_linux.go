@@ -0,0 +1,148 @@
+//go:build linux
+// +build linux
+
+// Package blockio tracks per-process block device I/O: bytes read and
+// written and the average latency of each request, from issue to the driver
+// until completion.
+package blockio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cgroupfilter"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+const resetSweepRetries = 3
+
+// Collector owns the eBPF programs on the block_rq_issue and
+// block_rq_complete tracepoints.
+type Collector struct {
+	objs     block_io_bpfObjects
+	issue    link.Link
+	complete link.Link
+}
+
+// NewCollector loads the block I/O tracker and attaches both tracepoints.
+// Either one alone is useless, so failing to attach one is an error.
+func NewCollector() (*Collector, error) {
+	return NewCollectorForCgroup("")
+}
+
+// NewCollectorForCgroup is NewCollector counting only tasks in the cgroup v2
+// subtree at cgroupPath, checked in the kernel. An empty path counts every
+// task.
+func NewCollectorForCgroup(cgroupPath string) (*Collector, error) {
+	var objs block_io_bpfObjects
+	spec, err := bpfenv.LoadSpec("block_io_bpf", loadBlock_io_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading block io bpf spec: %w", err)
+	}
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
+		return nil, fmt.Errorf("loading block io bpf objects: %w", err)
+	}
+
+	issue, err := link.AttachTracing(link.TracingOptions{Program: objs.HandleBlockRqIssue})
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching block_rq_issue tracepoint failed: %w", err)
+	}
+	complete, err := link.AttachTracing(link.TracingOptions{Program: objs.HandleBlockRqComplete})
+	if err != nil {
+		issue.Close()
+		objs.Close()
+		return nil, fmt.Errorf("attaching block_rq_complete tracepoint failed: %w", err)
+	}
+	return &Collector{objs: objs, issue: issue, complete: complete}, nil
+}
+
+// Close releases the BPF resources.
+func (c *Collector) Close() error {
+	return errors.Join(c.issue.Close(), c.complete.Close(), c.objs.Close())
+}
+
+// Snapshot returns the processes that moved the most bytes since the last
+// reset, counting requests that completed in the window.
+func (c *Collector) Snapshot(limit int) ([]types.BlockIOStat, error) {
+	stats := make([]types.BlockIOStat, 0, limit)
+	iter := c.objs.IoStats.Iterate()
+	var pid uint32
+	var stat ioStat
+	for iter.Next(&pid, &stat) {
+		if stat.Requests == 0 {
+			continue
+		}
+		stats = append(stats, types.BlockIOStat{
+			PID:          pid,
+			Comm:         cStr(stat.Comm[:]),
+			ReadBytes:    stat.ReadBytes,
+			WriteBytes:   stat.WriteBytes,
+			Requests:     stat.Requests,
+			AvgLatencyNs: stat.LatencyNs / stat.Requests,
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating block io map: %w", err)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		ti := stats[i].ReadBytes + stats[i].WriteBytes
+		tj := stats[j].ReadBytes + stats[j].WriteBytes
+		if ti != tj {
+			return ti > tj
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// Reset clears the per-process totals for the next interval. In-flight
+// requests are kept so a request issued in one window and completed in the
+// next is still charged, in the window it completes.
+func (c *Collector) Reset() error {
+	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
+		iter := c.objs.IoStats.Iterate()
+		var pid uint32
+		var stat ioStat
+		for iter.Next(&pid, &stat) {
+			if err := c.objs.IoStats.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("clearing block io for pid %d: %w", pid, err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
+				continue
+			}
+			return fmt.Errorf("iterating block io map: %w", err)
+		}
+		return nil
+	}
+	return nil
+}
+
+func cStr(b []byte) string {
+	if n := bytes.IndexByte(b, 0); n != -1 {
+		return string(b[:n])
+	}
+	return string(b)
+}
+
+// ioStat mirrors the BPF struct io_stat in block_io.c.
+// Field order and sizes MUST match exactly for correct map iteration.
+type ioStat struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	Requests   uint64   // completed read and write requests
+	LatencyNs  uint64   // summed issue-to-completion time
+	Comm       [16]byte // comm of the issuing task
+}
@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package cgroupfilter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/srodi/hotspot-bpf/pkg/cgroup"
+)
+
+// LoadAndAssign loads spec into to like ebpf.CollectionSpec.LoadAndAssign.
+// With a non-empty path, the programs count only tasks in that cgroup or its
+// descendants; path takes the same forms as -watch-cgroup. An empty path
+// loads the programs unchanged.
+func LoadAndAssign(spec *ebpf.CollectionSpec, to interface{}, path string) error {
+	if path == "" {
+		return spec.LoadAndAssign(to, nil)
+	}
+	v, ok := spec.Variables["filter_cgroup"]
+	if !ok {
+		return errors.New("filter_cgroup is missing; regenerate eBPF objects")
+	}
+	if err := v.Set(true); err != nil {
+		return fmt.Errorf("enabling cgroup filter: %w", err)
+	}
+	m, err := targetMap(path)
+	if err != nil {
+		return err
+	}
+	// The collection gets its own reference to the map.
+	defer m.Close()
+	return spec.LoadAndAssign(to, &ebpf.CollectionOptions{
+		MapReplacements: map[string]*ebpf.Map{"cgroup_filter": m},
+	})
+}
+
+// targetMap returns a cgroup array holding the cgroup at path in slot 0.
+func targetMap(path string) (*ebpf.Map, error) {
+	dir, err := os.Open(cgroup.Resolve(path))
+	if err != nil {
+		return nil, fmt.Errorf("opening cgroup: %w", err)
+	}
+	defer dir.Close()
+
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "cgroup_filter",
+		Type:       ebpf.CGroupArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating cgroup filter map: %w", err)
+	}
+	// The kernel rejects a directory that is not a cgroup v2 cgroup here.
+	if err := m.Put(uint32(0), uint32(dir.Fd())); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("storing cgroup %s: %w", path, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,7 @@
+// Package cgroupfilter scopes the eBPF collectors to one cgroup v2 subtree
+// in the kernel (-cgroup-path), instead of collecting system-wide and
+// filtering rows in userspace. Every program includes bpf/cgroup_filter.h,
+// whose check this package switches on before loading.
+//
+// It requires Linux; elsewhere the package is empty.
+package cgroupfilter
@@ -4,32 +4,135 @@
 package cpu
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"runtime"
 	"sort"
+	"time"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/features"
 	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cgroupfilter"
+	"github.com/srodi/hotspot-bpf/pkg/collector/perfevent"
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
 	"github.com/srodi/hotspot-bpf/pkg/types"
+	"golang.org/x/sys/unix"
 )
 
 // Collector owns the eBPF programs and maps that record CPU hotspots.
-// The BPF program uses tp_btf/sched_switch (BTF-powered raw tracepoint) to get
-// direct access to both prev and next task_struct pointers, enabling TGID-based
-// keying that matches the memory collector's process-level granularity.
+//
+// With MethodSched (the default) the BPF program uses tp_btf/sched_switch
+// (BTF-powered raw tracepoint) to get direct access to both prev and next
+// task_struct pointers, enabling TGID-based keying that matches the memory
+// collector's process-level granularity.
+//
+// With MethodPerf a per-CPU CPU-clock perf event samples the running process
+// at a fixed frequency instead. Both programs fill a pid_stats map with the
+// same layout; only the sched program records contention, off-CPU time, and
+// (with its wakeup tracepoints attached) run-queue latency.
+//
+// With MethodProc no eBPF is loaded; CPU time comes from /proc/PID/stat.
+//
+// With MethodSched and Options.SpikeThreshold set, the sched program also
+// streams single runs longer than the threshold through a ring buffer; see
+// ReadSpike.
+//
+// With Options.Threads set, pid_stats (and the sched program's off-CPU map)
+// are keyed by thread rather than process, and Snapshot returns one stat per
+// thread.
 type Collector struct {
-	objs hotspot_bpfObjects
-	tp   link.Link
+	method      Method
+	pidStats    *ebpf.Map       // nil with MethodProc
+	contention  *ebpf.Map       // nil with MethodPerf and MethodProc
+	cpuState    *ebpf.Map       // nil with MethodPerf and MethodProc
+	offCPU      *ebpf.Map       // off-CPU ns per TGID (per TID with threads); nil with MethodPerf and MethodProc
+	windowStart *ebpf.Map       // window start in ktime_ns; nil with MethodPerf and MethodProc
+	runqStart   *ebpf.Map       // runnable threads; nil unless the wakeup tracepoints are attached
+	runqLatency *ebpf.Map       // run-queue delay per TGID; nil unless runqStart is set
+	windowNs    uint64          // value last written to windowStart
+	spikes      *ringbuf.Reader // nil unless spike events are streamed
+	spikeDrops  *ebpf.Map       // per-CPU count of spikes lost to a full ring buffer; nil unless spikes is set
+	objs        io.Closer       // nil with MethodProc
+	links       []io.Closer
+	proc        *procSampler // non-nil only with MethodProc
+	threads     bool         // maps are keyed by TID; see Options.Threads
 }
 
 const resetSweepRetries = 3
 
+// threadMapEntries sizes the maps keyed by TID with Options.Threads: a host
+// runs many more threads than processes.
+const threadMapEntries = 65536
+
 // NewCollector loads the compiled eBPF program and attaches it via tp_btf/sched_switch.
 // This requires a kernel with BTF support (≥5.5, CONFIG_DEBUG_INFO_BTF=y).
 func NewCollector() (*Collector, error) {
+	return NewCollectorWithOptions(Options{Method: MethodSched})
+}
+
+// NewCollectorWithOptions loads and attaches the CPU program selected by opts.Method.
+func NewCollectorWithOptions(opts Options) (*Collector, error) {
+	switch opts.Method {
+	case MethodSched, "":
+		return newSchedCollector(opts.SpikeThreshold, opts.CgroupPath, opts.Threads)
+	case MethodPerf:
+		return newPerfCollector(opts.PerfFrequency, opts.CgroupPath, opts.Threads)
+	case MethodProc:
+		if opts.CgroupPath != "" {
+			return nil, fmt.Errorf("cgroup scoping needs eBPF; use cpu method %s or %s", MethodSched, MethodPerf)
+		}
+		if opts.Threads {
+			return nil, fmt.Errorf("per-thread accounting needs eBPF; use cpu method %s or %s", MethodSched, MethodPerf)
+		}
+		sampler, err := newProcSampler()
+		if err != nil {
+			return nil, fmt.Errorf("reading /proc: %w", err)
+		}
+		return &Collector{method: MethodProc, proc: sampler}, nil
+	default:
+		return nil, fmt.Errorf("unknown cpu method %q", opts.Method)
+	}
+}
+
+func newSchedCollector(spikeThreshold time.Duration, cgroupPath string, threads bool) (*Collector, error) {
+	if err := bpfenv.CheckBTF(); err != nil {
+		return nil, err
+	}
 	var objs hotspot_bpfObjects
-	if err := loadHotspot_bpfObjects(&objs, nil); err != nil {
+	spec, err := bpfenv.LoadSpec("hotspot_bpf", loadHotspot_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading bpf spec: %w", err)
+	}
+	// Spike events are best-effort: ring buffers need Linux 5.8. Without
+	// them spike_threshold_ns stays 0, the verifier prunes the spike path,
+	// and a one-slot array stands in for spike_events so the program still
+	// loads on older kernels.
+	spikes := spikeThreshold > 0 && features.HaveMapType(ebpf.RingBuf) == nil
+	if spikes {
+		v, ok := spec.Variables["spike_threshold_ns"]
+		if !ok {
+			return nil, errors.New("spike_threshold_ns is missing; regenerate eBPF objects")
+		}
+		if err := v.Set(uint64(spikeThreshold)); err != nil {
+			return nil, fmt.Errorf("setting spike threshold: %w", err)
+		}
+	} else {
+		spec.Maps["spike_events"] = &ebpf.MapSpec{Name: "spike_events", Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: 1}
+	}
+	if threads {
+		if err := keyByTID(spec, "pid_stats", "off_cpu_ns"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
 		return nil, fmt.Errorf("loading bpf objects: %w", err)
 	}
 
@@ -41,14 +144,231 @@ func NewCollector() (*Collector, error) {
 		return nil, fmt.Errorf("attaching tp_btf/sched_switch: %w", err)
 	}
 
-	return &Collector{objs: objs, tp: tp}, nil
+	c := &Collector{
+		method:      MethodSched,
+		pidStats:    objs.PidStats,
+		contention:  objs.CpuContention,
+		cpuState:    objs.CpuState,
+		offCPU:      objs.OffCpuNs,
+		windowStart: objs.WindowStart,
+		objs:        &objs,
+		links:       []io.Closer{tp},
+		threads:     threads,
+	}
+
+	// Run-queue latency needs both wakeup tracepoints; without them only
+	// preempted threads would be stamped, which understates every wait.
+	// Best-effort: CPU time and contention do not depend on it.
+	var wakeups []io.Closer
+	for _, prog := range []*ebpf.Program{objs.HandleSchedWakeup, objs.HandleSchedWakeupNew} {
+		l, err := link.AttachTracing(link.TracingOptions{Program: prog})
+		if err != nil {
+			break
+		}
+		wakeups = append(wakeups, l)
+	}
+	if len(wakeups) == 2 {
+		c.runqStart = objs.RunqStart
+		c.runqLatency = objs.RunqLatency
+	} else {
+		for _, l := range wakeups {
+			l.Close()
+		}
+		wakeups = nil
+		slog.Info("probes not attached, run-queue latency not measured", "probe", "tp_btf/sched_wakeup")
+	}
+	c.links = append(c.links, wakeups...)
+
+	if spikes {
+		rd, err := ringbuf.NewReader(objs.SpikeEvents)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("opening spike ring buffer: %w", err)
+		}
+		c.spikes = rd
+		c.spikeDrops = objs.SpikeDrops
+	}
+	return c, nil
+}
+
+func newPerfCollector(freq int, cgroupPath string, threads bool) (*Collector, error) {
+	if err := bpfenv.CheckBTF(); err != nil {
+		return nil, err
+	}
+	if freq <= 0 {
+		freq = DefaultPerfFrequency
+	}
+	var objs cpu_perf_bpfObjects
+	spec, err := bpfenv.LoadSpec("cpu_perf_bpf", loadCpu_perf_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading perf bpf spec: %w", err)
+	}
+	if threads {
+		if err := keyByTID(spec, "pid_stats"); err != nil {
+			return nil, err
+		}
+	}
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
+		return nil, fmt.Errorf("loading perf bpf objects: %w", err)
+	}
+
+	events, err := perfevent.AttachSampler(objs.HandleCpuSample, freq)
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching cpu-clock perf events: %w", err)
+	}
+
+	return &Collector{
+		method:   MethodPerf,
+		pidStats: objs.PidStats,
+		objs:     &objs,
+		links:    events,
+		threads:  threads,
+	}, nil
+}
+
+// keyByTID sets the program's key_by_tid constant and grows maps, which it
+// then keys by thread, to threadMapEntries.
+func keyByTID(spec *ebpf.CollectionSpec, maps ...string) error {
+	v, ok := spec.Variables["key_by_tid"]
+	if !ok {
+		return errors.New("key_by_tid is missing; regenerate eBPF objects")
+	}
+	if err := v.Set(true); err != nil {
+		return fmt.Errorf("setting per-thread keys: %w", err)
+	}
+	for _, name := range maps {
+		if m, ok := spec.Maps[name]; ok {
+			m.MaxEntries = max(m.MaxEntries, threadMapEntries)
+		}
+	}
+	return nil
+}
+
+// SelfTest checks that the objects of both eBPF CPU methods have every
+// program, map, and variable this version expects, then creates a collector
+// with opts, which loads and attaches its programs, and closes it again,
+// for -selftest. It leaves nothing attached.
+func SelfTest(opts Options) error {
+	for _, o := range []struct {
+		name string
+		load func() (*ebpf.CollectionSpec, error)
+		objs interface{}
+	}{
+		{"hotspot_bpf", loadHotspot_bpf, &hotspot_bpfObjects{}},
+		{"cpu_perf_bpf", loadCpu_perf_bpf, &cpu_perf_bpfObjects{}},
+	} {
+		spec, err := bpfenv.LoadSpec(o.name, o.load, o.objs)
+		if err != nil {
+			return fmt.Errorf("loading %s spec: %w", o.name, err)
+		}
+		if err := bpfenv.CheckSpec(spec, o.objs); err != nil {
+			return fmt.Errorf("eBPF object %s: %w", o.name, err)
+		}
+	}
+	c, err := NewCollectorWithOptions(opts)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// ObjectBTF reports whether the embedded sched_switch object carries BTF, which
+// CO-RE needs to relocate its task_struct reads to the running kernel.
+func ObjectBTF() bool {
+	spec, err := loadHotspot_bpf()
+	return err == nil && spec.Types != nil
+}
+
+// OffCPUTracking reports whether blocked time is being measured. When false,
+// CPUStat.OffCPUNs is always zero, and so are VolSwitches and InvolSwitches,
+// which come from the same sched_switch program.
+func (c *Collector) OffCPUTracking() bool {
+	return c.offCPU != nil
+}
+
+// RunqueueTracking reports whether run-queue latency is being measured. When
+// false, RunqueueLatency returns an error.
+func (c *Collector) RunqueueTracking() bool {
+	return c.runqLatency != nil
 }
 
-// Close releases the BPF resources and detaches the tracepoint.
+// SpikeTracking reports whether spike events are streamed. When false,
+// ReadSpike fails right away.
+func (c *Collector) SpikeTracking() bool {
+	return c.spikes != nil
+}
+
+// ReadSpike blocks until the next spike event and returns it. Events are
+// delivered in the order the runs ended. After Close it returns an error
+// wrapping os.ErrClosed, so a reader goroutine can stop on it.
+func (c *Collector) ReadSpike() (types.SpikeEvent, error) {
+	if c.spikes == nil {
+		return types.SpikeEvent{}, errors.New("spike events are not streamed (they need -cpu-method sched, a spike threshold, and Linux 5.8)")
+	}
+	rec, err := c.spikes.Read()
+	if err != nil {
+		return types.SpikeEvent{}, err
+	}
+	now, err := monotonicNs()
+	if err != nil {
+		return types.SpikeEvent{}, err
+	}
+	return decodeSpike(rec.RawSample, now, time.Now())
+}
+
+// SpikeDrops returns how many spike events were lost because the ring
+// buffer was full, since the collector was created.
+func (c *Collector) SpikeDrops() (uint64, error) {
+	if c.spikeDrops == nil {
+		return 0, nil
+	}
+	var perCPU []uint64
+	key := uint32(0)
+	if err := c.spikeDrops.Lookup(&key, &perCPU); err != nil {
+		return 0, fmt.Errorf("reading spike drops: %w", err)
+	}
+	var total uint64
+	for _, n := range perCPU {
+		total += n
+	}
+	return total, nil
+}
+
+// decodeSpike converts a raw spike_event record. nowNs and now are the same
+// instant on the monotonic clock and the wall clock, so the event's
+// bpf_ktime_get_ns stamp can be placed in wall time.
+func decodeSpike(raw []byte, nowNs uint64, now time.Time) (types.SpikeEvent, error) {
+	var ev spikeEvent
+	if err := binary.Read(bytes.NewReader(raw), binary.NativeEndian, &ev); err != nil {
+		return types.SpikeEvent{}, fmt.Errorf("decoding spike event: %w", err)
+	}
+	at := now
+	if nowNs > ev.Ts {
+		at = now.Add(-time.Duration(nowNs - ev.Ts))
+	}
+	return types.SpikeEvent{
+		PID:     ev.Tgid,
+		TID:     ev.Tid,
+		Comm:    cStr(ev.Comm[:]),
+		CPU:     ev.CPU,
+		SliceNs: ev.SliceNs,
+		At:      at,
+	}, nil
+}
+
+// Close releases the BPF resources and detaches the tracepoint or perf events.
+// A ReadSpike blocked in another goroutine returns.
 func (c *Collector) Close() error {
 	var err error
-	if c.tp != nil {
-		err = errors.Join(err, c.tp.Close())
+	if c.spikes != nil {
+		err = c.spikes.Close()
+	}
+	for _, l := range c.links {
+		err = errors.Join(err, l.Close())
+	}
+	if c.objs == nil {
+		return err
 	}
 	return errors.Join(err, c.objs.Close())
 }
@@ -56,72 +376,146 @@ func (c *Collector) Close() error {
 // Snapshot returns per-process (TGID) CPU stats gathered since the previous reset.
 // The BPF program aggregates CPU time across all threads of the same process,
 // so each entry represents total process CPU time, not individual thread time.
+//
+// With Options.Threads each entry is one thread instead, with TID set and
+// named from /proc/PID/task/TID/comm, which follows a rename since the
+// thread was first seen; report.ProcessTotals folds them back into
+// processes.
 func (c *Collector) Snapshot(limit int) ([]types.CPUStat, error) {
+	if c.proc != nil {
+		return c.proc.snapshot(limit)
+	}
 	stats := make([]types.CPUStat, 0, limit)
 
-	iter := c.objs.PidStats.Iterate()
-	var pid uint32
-	var stat pidStat
-	for iter.Next(&pid, &stat) {
+	iter := c.pidStats.Iterate()
+	var id uint32
+	var perCPU []pidStat
+	for iter.Next(&id, &perCPU) {
+		stat := mergePIDStats(perCPU)
 		if stat.CPUTimeNS == 0 {
 			continue
 		}
 
+		pid, tid := id, uint32(0)
+		if c.threads {
+			pid, tid = stat.TGID, id
+		}
 		stats = append(stats, types.CPUStat{
-			PID:     pid,
-			Comm:    cStr(stat.Comm[:]),
-			Cgroup:  cStr(stat.Cgroup[:]),
-			Ns:      stat.CPUTimeNS,
-			CPUCore: stat.CPUId,
+			PID:           pid,
+			TID:           tid,
+			Comm:          cStr(stat.Comm[:]),
+			CgroupID:      stat.CgroupID,
+			Ns:            stat.CPUTimeNS,
+			CPUCore:       stat.CPUId,
+			Switches:      uint64(stat.Switches),
+			VolSwitches:   uint64(stat.VolSwitches),
+			InvolSwitches: uint64(stat.InvolSwitches),
 		})
 	}
 	if err := iter.Err(); err != nil {
 		return nil, fmt.Errorf("iterating cpu stats: %w", err)
 	}
+	if c.offCPU != nil {
+		if err := c.addOffCPU(stats); err != nil {
+			return nil, err
+		}
+	}
 
-	sort.Slice(stats, func(i, j int) bool { return stats[i].Ns > stats[j].Ns })
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Ns != stats[j].Ns {
+			return stats[i].Ns > stats[j].Ns
+		}
+		if stats[i].PID != stats[j].PID {
+			return stats[i].PID < stats[j].PID
+		}
+		return stats[i].TID < stats[j].TID
+	})
 	if limit > 0 && len(stats) > limit {
 		stats = stats[:limit]
 	}
+	if c.threads {
+		for i := range stats {
+			if name, err := procfs.ThreadComm(int(stats[i].PID), int(stats[i].TID)); err == nil {
+				stats[i].Comm = name
+			}
+		}
+	}
 
 	return stats, nil
 }
 
+// addOffCPU fills OffCPUNs for every process (or thread) in stats.
+// Processes that were only blocked, and never ran, have no pid_stats entry
+// and are left out.
+func (c *Collector) addOffCPU(stats []types.CPUStat) error {
+	byID := make(map[uint32]int, len(stats))
+	for i := range stats {
+		if c.threads {
+			byID[stats[i].TID] = i
+		} else {
+			byID[stats[i].PID] = i
+		}
+	}
+	iter := c.offCPU.Iterate()
+	var id uint32
+	var ns uint64
+	for iter.Next(&id, &ns) {
+		if i, ok := byID[id]; ok {
+			stats[i].OffCPUNs = ns
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("iterating off-cpu stats: %w", err)
+	}
+	return nil
+}
+
 // Reset clears the BPF maps so the next window can accumulate fresh values.
 // It first zeroes the per-CPU state array to invalidate stale TGIDs that would
 // otherwise resurrect ghost entries for processes that have already exited.
 // The cpu_state reset is best-effort; a failure does not prevent the main
 // pid_stats and cpu_contention maps from being cleared.
 func (c *Collector) Reset() error {
+	if c.proc != nil {
+		c.proc.reset()
+		return nil
+	}
 	// Best-effort: zero cpu_state to prevent ghost entries from dead PIDs.
 	// Not fatal because the main map clearing below is more important.
-	_ = c.resetCPUState()
+	if c.cpuState != nil {
+		_ = c.resetCPUState()
+	}
 
-	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
-		iter := c.objs.PidStats.Iterate()
-		var pid uint32
-		var stat pidStat
-		for iter.Next(&pid, &stat) {
-			if err := c.objs.PidStats.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
-				return fmt.Errorf("clearing pid %d: %w", pid, err)
-			}
+	// pid_stats is per-CPU: deleting a key drops every CPU's copy at once.
+	if err := clearMap(c.pidStats, new([]pidStat)); err != nil {
+		return fmt.Errorf("pid stats map: %w", err)
+	}
+
+	if c.offCPU != nil {
+		// Mark the window start first, so a thread that wakes between the
+		// two steps is not charged for time before the new window.
+		if err := c.markWindowStart(); err != nil {
+			return fmt.Errorf("marking window start: %w", err)
 		}
-		if err := iter.Err(); err != nil {
-			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
-				continue
-			}
-			return err
+		if err := clearMap(c.offCPU, new(uint64)); err != nil {
+			return fmt.Errorf("off-cpu map: %w", err)
+		}
+	}
+	// runq_start is left alone: threads waiting now are still waiting in
+	// the new window, and their waits are clamped to its start.
+	if c.runqLatency != nil {
+		if err := clearMap(c.runqLatency, new([]runqStat)); err != nil {
+			return fmt.Errorf("runqueue latency map: %w", err)
 		}
-		break
 	}
 
-	if c.objs.CpuContention != nil {
+	if c.contention != nil {
 		for attempt := 1; attempt <= resetSweepRetries; attempt++ {
-			cIter := c.objs.CpuContention.Iterate()
+			cIter := c.contention.Iterate()
 			var key uint64
 			var count uint64
 			for cIter.Next(&key, &count) {
-				if err := c.objs.CpuContention.Delete(&key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				if err := c.contention.Delete(&key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
 					return fmt.Errorf("clearing contention entry: %w", err)
 				}
 			}
@@ -146,18 +540,71 @@ func (c *Collector) resetCPUState() error {
 	numCPUs := runtime.NumCPU()
 	zeroes := make([]hotspot_bpfCpuState, numCPUs)
 	key := uint32(0)
-	return c.objs.CpuState.Put(&key, zeroes)
+	return c.cpuState.Put(&key, zeroes)
+}
+
+// markWindowStart stores the current CLOCK_MONOTONIC time, the clock behind
+// bpf_ktime_get_ns, as the start of the new window.
+func (c *Collector) markWindowStart() error {
+	now, err := monotonicNs()
+	if err != nil {
+		return err
+	}
+	key := uint32(0)
+	if err := c.windowStart.Put(&key, now); err != nil {
+		return err
+	}
+	c.windowNs = now
+	return nil
+}
+
+// monotonicNs returns CLOCK_MONOTONIC in nanoseconds, comparable with
+// bpf_ktime_get_ns.
+func monotonicNs() (uint64, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, err
+	}
+	return uint64(ts.Nano()), nil
+}
+
+// clearMap deletes every entry of a TGID-keyed map. value is scratch space
+// of the map's value type, or of a slice of it for a per-CPU map. Concurrent
+// inserts from BPF can abort an iteration, so the sweep is retried a bounded
+// number of times.
+func clearMap(m *ebpf.Map, value interface{}) error {
+	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
+		iter := m.Iterate()
+		var pid uint32
+		for iter.Next(&pid, value) {
+			if err := m.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("clearing pid %d: %w", pid, err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
+				continue
+			}
+			return err
+		}
+		return nil
+	}
+	return nil
 }
 
 // Contention returns the busiest victim/aggressor pairs observed since the last reset.
 // Pairs are keyed by TGID (process-level), so intra-process thread switches are
-// already filtered out at the BPF level.
+// already filtered out at the BPF level. Keys that do not decode to two real
+// processes (see unpackContentionKey) are skipped.
 func (c *Collector) Contention(limit int) ([]types.ContentionStat, error) {
-	if c.objs.CpuContention == nil {
+	if c.method == MethodPerf || c.method == MethodProc {
+		return nil, fmt.Errorf("contention is not tracked with -cpu-method %s", c.method)
+	}
+	if c.contention == nil {
 		return nil, fmt.Errorf("contention map is unavailable; regenerate eBPF objects")
 	}
 
-	iter := c.objs.CpuContention.Iterate()
+	iter := c.contention.Iterate()
 	var key uint64
 	var count uint64
 	cache := make(map[uint32]string)
@@ -166,8 +613,10 @@ func (c *Collector) Contention(limit int) ([]types.ContentionStat, error) {
 		if count == 0 {
 			continue
 		}
-		victim := uint32(key >> 32)
-		aggressor := uint32(key & 0xffffffff)
+		victim, aggressor, ok := unpackContentionKey(key)
+		if !ok {
+			continue
+		}
 		stats = append(stats, types.ContentionStat{
 			VictimPID:     victim,
 			VictimComm:    commForPID(victim, cache),
@@ -181,7 +630,13 @@ func (c *Collector) Contention(limit int) ([]types.ContentionStat, error) {
 	}
 
 	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].Count > stats[j].Count
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		if stats[i].VictimPID != stats[j].VictimPID {
+			return stats[i].VictimPID < stats[j].VictimPID
+		}
+		return stats[i].AggressorPID < stats[j].AggressorPID
 	})
 	if limit > 0 && len(stats) > limit {
 		stats = stats[:limit]
@@ -190,13 +645,160 @@ func (c *Collector) Contention(limit int) ([]types.ContentionStat, error) {
 	return stats, nil
 }
 
+// RunqueueLatency returns the processes that waited longest on the run queue
+// since the last reset. Threads still runnable but not yet running at the
+// time of the call are included, their wait so far counted up to now, so a
+// process that never gets a CPU in the window is not missed.
+func (c *Collector) RunqueueLatency(limit int) ([]types.RunqLatencyStat, error) {
+	if c.runqLatency == nil {
+		return nil, fmt.Errorf("runqueue latency is not tracked with -cpu-method %s", c.method)
+	}
+
+	byPID := make(map[uint32]*types.RunqLatencyStat)
+	get := func(pid uint32) *types.RunqLatencyStat {
+		s, ok := byPID[pid]
+		if !ok {
+			s = &types.RunqLatencyStat{PID: pid}
+			byPID[pid] = s
+		}
+		return s
+	}
+
+	iter := c.runqLatency.Iterate()
+	var pid uint32
+	var perCPU []runqStat
+	for iter.Next(&pid, &perCPU) {
+		stat := mergeRunqStats(perCPU)
+		s := get(pid)
+		s.Waits = stat.Count
+		s.TotalNs = stat.TotalNs
+		s.MaxNs = stat.MaxNs
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating runqueue latency: %w", err)
+	}
+
+	now, err := monotonicNs()
+	if err != nil {
+		return nil, err
+	}
+	waitIter := c.runqStart.Iterate()
+	var tid uint32
+	var w runqWait
+	for waitIter.Next(&tid, &w) {
+		from := max(w.Ts, c.windowNs)
+		if w.Tgid == 0 || from >= now {
+			continue
+		}
+		wait := now - from
+		s := get(w.Tgid)
+		s.Waiting++
+		s.TotalNs += wait
+		s.MaxNs = max(s.MaxNs, wait)
+	}
+	if err := waitIter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating runnable threads: %w", err)
+	}
+
+	cache := make(map[uint32]string)
+	stats := make([]types.RunqLatencyStat, 0, len(byPID))
+	for _, s := range byPID {
+		if s.TotalNs == 0 {
+			continue
+		}
+		s.Comm = commForPID(s.PID, cache)
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalNs != stats[j].TotalNs {
+			return stats[i].TotalNs > stats[j].TotalNs
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// runqWait mirrors the BPF struct runq_wait in cpu_hotspot.c.
+type runqWait struct {
+	Ts   uint64 // ktime_ns the thread became runnable
+	Tgid uint32
+	_    uint32
+}
+
+// spikeEvent mirrors the BPF struct spike_event in cpu_hotspot.c.
+type spikeEvent struct {
+	Ts      uint64 // ktime_ns the run ended
+	SliceNs uint64
+	Tgid    uint32
+	Tid     uint32
+	CPU     uint32
+	_       uint32
+	Comm    [16]byte
+}
+
+// runqStat mirrors the BPF struct runq_stat in cpu_hotspot.c. The map is
+// per-CPU; see mergeRunqStats.
+type runqStat struct {
+	TotalNs uint64
+	MaxNs   uint64
+	Count   uint64
+}
+
+// mergeRunqStats folds the per-CPU copies of one runq_latency entry into one:
+// wait counts and totals are summed, and the longest wait is kept.
+func mergeRunqStats(perCPU []runqStat) runqStat {
+	var out runqStat
+	for _, s := range perCPU {
+		out.TotalNs += s.TotalNs
+		out.Count += s.Count
+		out.MaxNs = max(out.MaxNs, s.MaxNs)
+	}
+	return out
+}
+
 // pidStat mirrors the BPF struct pid_stat in cpu_hotspot.c.
 // Field order and sizes MUST match exactly for correct map iteration.
-// Keyed by TGID (process ID), so multi-threaded processes have one entry.
+// Keyed by TGID (process ID), so multi-threaded processes have one entry,
+// unless the collector runs per thread. The map is per-CPU; see mergePIDStats.
 type pidStat struct {
-	CPUTimeNS uint64
-	Comm      [16]byte
-	Cgroup    [64]byte
-	CPUId     uint32
-	Pad       uint32
+	CPUTimeNS     uint64
+	Comm          [16]byte
+	CgroupID      uint64 // cgroup v2 id, resolved to a path by report.BuildProcMetrics
+	CPUId         uint32
+	Switches      uint32 // switch-outs to another process; always 0 with MethodPerf
+	LastNs        uint64 // ktime_ns of the update that set CPUId
+	VolSwitches   uint32 // switch-outs of blocked threads; 0 with MethodPerf
+	InvolSwitches uint32 // switch-outs of preempted threads; 0 with MethodPerf
+	TGID          uint32 // the entry's process; differs from the key with Options.Threads
+	_             uint32
+}
+
+// mergePIDStats folds the per-CPU copies of one pid_stats entry into one.
+// CPU time and switch counts are summed, CPUId comes from the most recently
+// updated copy, and comm and cgroup from the first copy that has them:
+// copies created on another CPU than the first start zeroed.
+func mergePIDStats(perCPU []pidStat) pidStat {
+	var out pidStat
+	for _, s := range perCPU {
+		out.CPUTimeNS += s.CPUTimeNS
+		out.Switches += s.Switches
+		out.VolSwitches += s.VolSwitches
+		out.InvolSwitches += s.InvolSwitches
+		if s.CPUTimeNS > 0 && s.LastNs >= out.LastNs {
+			out.CPUId, out.LastNs = s.CPUId, s.LastNs
+		}
+		if out.Comm[0] == 0 {
+			out.Comm = s.Comm
+		}
+		if out.CgroupID == 0 {
+			out.CgroupID = s.CgroupID
+		}
+		if out.TGID == 0 {
+			out.TGID = s.TGID
+		}
+	}
+	return out
 }
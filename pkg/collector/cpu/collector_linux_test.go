@@ -0,0 +1,92 @@
+//go:build linux
+
+package cpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestMergeRunqStats(t *testing.T) {
+	perCPU := []runqStat{
+		{TotalNs: 300, MaxNs: 200, Count: 2},
+		{}, // no thread of the process was switched in on this CPU
+		{TotalNs: 500, MaxNs: 500, Count: 1},
+	}
+
+	got := mergeRunqStats(perCPU)
+	if got.TotalNs != 800 || got.Count != 3 {
+		t.Fatalf("TotalNs=%d Count=%d; want 800 and 3", got.TotalNs, got.Count)
+	}
+	if got.MaxNs != 500 {
+		t.Fatalf("MaxNs = %d; want 500, the longest wait on any CPU", got.MaxNs)
+	}
+}
+
+func TestMergePIDStats(t *testing.T) {
+	var comm [16]byte
+	copy(comm[:], "worker")
+	perCPU := []pidStat{
+		{CPUTimeNS: 300, Comm: comm, CgroupID: 7429, CPUId: 0, Switches: 2, LastNs: 1000, VolSwitches: 5, InvolSwitches: 1, TGID: 880},
+		{}, // the process never ran on this CPU
+		{CPUTimeNS: 200, CPUId: 2, Switches: 1, LastNs: 5000, VolSwitches: 2, InvolSwitches: 3}, // created zeroed, never got a comm
+		{CPUTimeNS: 100, CPUId: 3, LastNs: 4000},
+	}
+
+	got := mergePIDStats(perCPU)
+	if got.CPUTimeNS != 600 || got.Switches != 3 {
+		t.Fatalf("CPUTimeNS=%d Switches=%d; want 600 and 3", got.CPUTimeNS, got.Switches)
+	}
+	if got.VolSwitches != 7 || got.InvolSwitches != 4 {
+		t.Fatalf("VolSwitches=%d InvolSwitches=%d; want 7 and 4", got.VolSwitches, got.InvolSwitches)
+	}
+	if got.CPUId != 2 {
+		t.Fatalf("CPUId = %d; want 2, the most recently updated copy", got.CPUId)
+	}
+	if cStr(got.Comm[:]) != "worker" {
+		t.Fatalf("Comm = %q; want worker", cStr(got.Comm[:]))
+	}
+	if got.CgroupID != 7429 {
+		t.Fatalf("CgroupID = %d; want 7429", got.CgroupID)
+	}
+	if got.TGID != 880 {
+		t.Fatalf("TGID = %d; want 880", got.TGID)
+	}
+}
+
+func TestPIDStatLayout(t *testing.T) {
+	// struct pid_stat: 8 + 16 + 8 + 4 + 4 + 8 + 4 + 4 + 4 + 4 bytes.
+	if got := binary.Size(pidStat{}); got != 64 {
+		t.Fatalf("pidStat is %d bytes; struct pid_stat is 64", got)
+	}
+}
+
+func TestDecodeSpike(t *testing.T) {
+	ev := spikeEvent{Ts: 7_000_000_000, SliceNs: 250_000_000, Tgid: 42, Tid: 43, CPU: 3}
+	copy(ev.Comm[:], "busy-loop")
+	var raw bytes.Buffer
+	if err := binary.Write(&raw, binary.NativeEndian, ev); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Len() != 48 {
+		t.Fatalf("spikeEvent is %d bytes; struct spike_event is 48", raw.Len())
+	}
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	got, err := decodeSpike(raw.Bytes(), 9_000_000_000, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PID != 42 || got.TID != 43 || got.CPU != 3 || got.Comm != "busy-loop" || got.SliceNs != 250_000_000 {
+		t.Fatalf("decoded %+v", got)
+	}
+	if want := now.Add(-2 * time.Second); !got.At.Equal(want) {
+		t.Fatalf("At = %v; want %v, two seconds before now", got.At, want)
+	}
+
+	if _, err := decodeSpike(raw.Bytes()[:20], 0, now); err == nil {
+		t.Fatal("expected an error for a truncated record")
+	}
+}
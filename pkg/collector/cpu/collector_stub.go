@@ -19,6 +19,21 @@ func NewCollector() (*Collector, error) {
 	return nil, errUnsupported
 }
 
+// NewCollectorWithOptions returns an error because eBPF is only supported on Linux.
+func NewCollectorWithOptions(opts Options) (*Collector, error) {
+	return nil, errUnsupported
+}
+
+// SelfTest returns an error because eBPF is only supported on Linux.
+func SelfTest(opts Options) error {
+	return errUnsupported
+}
+
+// ObjectBTF reports false: no eBPF objects are embedded on non-Linux platforms.
+func ObjectBTF() bool {
+	return false
+}
+
 // Snapshot always fails on unsupported platforms.
 func (c *Collector) Snapshot(limit int) ([]types.CPUStat, error) {
 	return nil, errUnsupported
@@ -29,6 +44,36 @@ func (c *Collector) Contention(limit int) ([]types.ContentionStat, error) {
 	return nil, errUnsupported
 }
 
+// OffCPUTracking reports false: nothing is tracked on unsupported platforms.
+func (c *Collector) OffCPUTracking() bool {
+	return false
+}
+
+// RunqueueTracking reports false: nothing is tracked on unsupported platforms.
+func (c *Collector) RunqueueTracking() bool {
+	return false
+}
+
+// RunqueueLatency always fails on unsupported platforms.
+func (c *Collector) RunqueueLatency(limit int) ([]types.RunqLatencyStat, error) {
+	return nil, errUnsupported
+}
+
+// SpikeTracking reports false: nothing is tracked on unsupported platforms.
+func (c *Collector) SpikeTracking() bool {
+	return false
+}
+
+// ReadSpike always fails on unsupported platforms.
+func (c *Collector) ReadSpike() (types.SpikeEvent, error) {
+	return types.SpikeEvent{}, errUnsupported
+}
+
+// SpikeDrops always reports zero on unsupported platforms.
+func (c *Collector) SpikeDrops() (uint64, error) {
+	return 0, nil
+}
+
 // Reset does nothing on unsupported platforms.
 func (c *Collector) Reset() error {
 	return nil
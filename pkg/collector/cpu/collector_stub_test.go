@@ -11,6 +11,12 @@ func TestStubCollectorBehavior(t *testing.T) {
 	if _, err := NewCollector(); !errors.Is(err, errUnsupported) {
 		t.Fatalf("expected errUnsupported, got %v", err)
 	}
+	if _, err := NewCollectorWithOptions(Options{Method: MethodPerf}); !errors.Is(err, errUnsupported) {
+		t.Fatalf("expected errUnsupported with options, got %v", err)
+	}
+	if err := SelfTest(Options{}); !errors.Is(err, errUnsupported) {
+		t.Fatalf("expected errUnsupported from SelfTest, got %v", err)
+	}
 
 	var c Collector
 	if stats, err := c.Snapshot(5); err != errUnsupported || stats != nil {
@@ -20,6 +20,37 @@ func cStr(b []byte) string {
 	return string(b[:n])
 }
 
+// pidMaxLimit is the kernel's PID_MAX_LIMIT on 64-bit systems: pid_max can
+// be raised up to it, never past, so every PID is below it.
+const pidMaxLimit = 1 << 22
+
+// The cpu_contention map (cpu_hotspot.c) keys each victim→aggressor pair by
+// one uint64: the victim's TGID in the high 32 bits and the aggressor's in
+// the low 32. Both are real processes: the BPF program skips switches to or
+// from the idle task (PID 0, the per-CPU swapper), which only mean a CPU
+// went idle or woke. The helpers below check both halves, so a wider PID
+// space or an idle-task encoding would be caught rather than misread.
+
+// packContentionKey returns the cpu_contention key of a pair, or an error
+// when either PID is not a valid process ID.
+func packContentionKey(victim, aggressor int) (uint64, error) {
+	for _, pid := range []int{victim, aggressor} {
+		if pid <= 0 || pid >= pidMaxLimit {
+			return 0, fmt.Errorf("pid %d out of range (1-%d)", pid, pidMaxLimit-1)
+		}
+	}
+	return uint64(victim)<<32 | uint64(aggressor), nil
+}
+
+// unpackContentionKey splits a cpu_contention key into its victim and
+// aggressor. ok is false when either half is the idle task or not a valid
+// PID, a pair to be dropped rather than shown.
+func unpackContentionKey(key uint64) (victim, aggressor uint32, ok bool) {
+	victim, aggressor = uint32(key>>32), uint32(key)
+	ok = victim != 0 && victim < pidMaxLimit && aggressor != 0 && aggressor < pidMaxLimit
+	return victim, aggressor, ok
+}
+
 func commForPID(pid uint32, cache map[uint32]string) string {
 	if pid == 0 {
 		return "idle"
@@ -61,3 +61,50 @@ func TestCommForPIDReadsOnceAndCaches(t *testing.T) {
 		t.Fatalf("expected idle for pid 0, got %q", idle)
 	}
 }
+
+func TestContentionKeyRoundTrip(t *testing.T) {
+	key, err := packContentionKey(4211, 812)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != 4211<<32|812 {
+		t.Fatalf("key = %#x; want victim in the high half, aggressor in the low", key)
+	}
+	victim, aggressor, ok := unpackContentionKey(key)
+	if !ok || victim != 4211 || aggressor != 812 {
+		t.Fatalf("unpack = %d, %d, %t", victim, aggressor, ok)
+	}
+
+	// The largest PID pid_max allows still fits.
+	if key, err = packContentionKey(pidMaxLimit-1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if victim, _, ok = unpackContentionKey(key); !ok || victim != pidMaxLimit-1 {
+		t.Fatalf("unpack max pid = %d, %t", victim, ok)
+	}
+}
+
+func TestContentionKeyBounds(t *testing.T) {
+	for _, pair := range [][2]int{{0, 812}, {812, 0}, {-1, 812}, {812, pidMaxLimit}, {1 << 32, 1}} {
+		if _, err := packContentionKey(pair[0], pair[1]); err == nil {
+			t.Fatalf("packContentionKey(%d, %d) accepted an invalid pid", pair[0], pair[1])
+		}
+	}
+}
+
+func TestUnpackContentionKeyDropsIdle(t *testing.T) {
+	cases := []struct {
+		name string
+		key  uint64
+	}{
+		{"idleAggressor", 4211 << 32},                // "preempted by idle": the CPU went idle
+		{"idleVictim", 812},                          // idle switched out for real work
+		{"swapperAsMinusOne", 4211<<32 | 0xffffffff}, // a -1 encoding of the idle task
+		{"highHalfTooWide", uint64(pidMaxLimit)<<32 | 812},
+	}
+	for _, tc := range cases {
+		if victim, aggressor, ok := unpackContentionKey(tc.key); ok {
+			t.Fatalf("%s: unpacked to %d→%d; want dropped", tc.name, victim, aggressor)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Method selects how CPU time is attributed to processes.
+type Method string
+
+const (
+	// MethodSched accounts exact on-CPU time on every context switch
+	// (tp_btf/sched_switch) and records scheduler contention.
+	MethodSched Method = "sched"
+	// MethodPerf samples the running process with a per-CPU CPU-clock perf
+	// event. Overhead is proportional to the sampling frequency instead of
+	// the context-switch rate, but contention is not recorded.
+	MethodPerf Method = "perf"
+	// MethodProc computes CPU time from /proc/PID/stat utime+stime deltas
+	// without eBPF. Resolution is 10ms (USER_HZ) and contention is not
+	// recorded; useful to cross-check the BPF numbers or where attaching
+	// sched_switch fails.
+	MethodProc Method = "proc"
+)
+
+// DefaultPerfFrequency is the MethodPerf sampling rate in Hz. 99 rather than
+// 100 avoids lockstep with timer-driven workloads.
+const DefaultPerfFrequency = 99
+
+// Options configures NewCollectorWithOptions.
+type Options struct {
+	Method        Method
+	PerfFrequency int // samples per second per CPU for MethodPerf (0 = DefaultPerfFrequency)
+	// SpikeThreshold streams a types.SpikeEvent for every run of a thread at
+	// least this long (0 = off). Only MethodSched sees individual runs.
+	SpikeThreshold time.Duration
+	// CgroupPath counts only tasks in this cgroup v2 subtree, checked in the
+	// kernel ("" = every task). MethodProc has no eBPF to check it with and
+	// fails.
+	CgroupPath string
+	// Threads accounts CPU time per thread (TID) rather than per process,
+	// so Snapshot returns one types.CPUStat per thread with TID set.
+	// MethodProc fails.
+	Threads bool
+}
+
+// ParseMethod validates a -cpu-method flag value.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(strings.ToLower(strings.TrimSpace(s))); m {
+	case MethodSched, MethodPerf, MethodProc:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cpu method %q (want %s, %s, or %s)", s, MethodSched, MethodPerf, MethodProc)
+	}
+}
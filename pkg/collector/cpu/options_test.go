@@ -0,0 +1,16 @@
+package cpu
+
+import "testing"
+
+func TestParseMethod(t *testing.T) {
+	cases := map[string]Method{"sched": MethodSched, "PERF": MethodPerf, " perf ": MethodPerf, "proc": MethodProc}
+	for in, want := range cases {
+		got, err := ParseMethod(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseMethod(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMethod("ebpf"); err == nil {
+		t.Fatal("expected error for unknown method")
+	}
+}
@@ -0,0 +1,125 @@
+package cpu
+
+import (
+	"sort"
+
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// userHZ is the unit of utime/stime in /proc/PID/stat. The kernel exports
+// these in USER_HZ, which is 100 on every Linux architecture regardless of
+// CONFIG_HZ.
+const userHZ = 100
+
+// Stubbed by tests.
+var (
+	listPIDs       = procfs.PIDs
+	readProcStat   = procfs.ReadStat
+	readCgroupPath = procfs.CgroupPath
+)
+
+// procInstance identifies one process across samples; the start time keeps a
+// recycled PID from inheriting the previous process's CPU counters.
+type procInstance struct {
+	pid       int
+	startTime uint64
+}
+
+type procTimes struct {
+	ticks     uint64 // utime + stime
+	comm      string
+	processor int
+}
+
+// procSampler implements MethodProc: per-process CPU time from
+// /proc/PID/stat deltas instead of eBPF. It mirrors the BPF collectors'
+// snapshot/reset contract: snapshot reports CPU time since the last reset.
+type procSampler struct {
+	baseline map[procInstance]uint64 // ticks at the last reset
+	last     map[procInstance]procTimes
+}
+
+func newProcSampler() (*procSampler, error) {
+	s := &procSampler{}
+	current, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.last = current
+	s.reset()
+	return s, nil
+}
+
+// read walks /proc once. Processes that exit mid-walk are skipped.
+func (s *procSampler) read() (map[procInstance]procTimes, error) {
+	pids, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[procInstance]procTimes, len(pids))
+	for _, pid := range pids {
+		st, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		current[procInstance{pid: pid, startTime: st.StartTime}] = procTimes{
+			ticks:     st.UTime + st.STime,
+			comm:      st.Comm,
+			processor: st.Processor,
+		}
+	}
+	return current, nil
+}
+
+// snapshot returns CPU time accrued since the last reset, busiest first.
+// Processes started after the reset are charged their whole lifetime, which
+// all falls inside the window.
+func (s *procSampler) snapshot(limit int) ([]types.CPUStat, error) {
+	current, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.last = current
+
+	stats := make([]types.CPUStat, 0, len(current))
+	for inst, t := range current {
+		delta := t.ticks - min(s.baseline[inst], t.ticks)
+		if delta == 0 {
+			continue
+		}
+		stats = append(stats, types.CPUStat{
+			PID:     uint32(inst.pid),
+			Comm:    t.comm,
+			Ns:      delta * (1e9 / userHZ),
+			CPUCore: uint32(t.processor),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Ns != stats[j].Ns {
+			return stats[i].Ns > stats[j].Ns
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	// Only the rows that survive the limit pay for a cgroup lookup.
+	for i := range stats {
+		stats[i].Cgroup = "n/a"
+		if p, err := readCgroupPath(int(stats[i].PID)); err == nil && p != "/" {
+			stats[i].Cgroup = p
+		}
+	}
+	return stats, nil
+}
+
+// reset starts a new window at the most recent snapshot, so time spent
+// rendering between snapshot and reset is counted in the next window rather
+// than lost. Exited processes drop out of the baseline.
+func (s *procSampler) reset() {
+	s.baseline = make(map[procInstance]uint64, len(s.last))
+	for inst, t := range s.last {
+		s.baseline[inst] = t.ticks
+	}
+}
@@ -0,0 +1,101 @@
+package cpu
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+)
+
+// fakeProc is a mutable /proc used to drive procSampler.
+type fakeProc map[int]procfs.Stat
+
+func (f fakeProc) install(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		listPIDs = procfs.PIDs
+		readProcStat = procfs.ReadStat
+		readCgroupPath = procfs.CgroupPath
+	})
+	listPIDs = func() ([]int, error) {
+		pids := make([]int, 0, len(f))
+		for pid := range f {
+			pids = append(pids, pid)
+		}
+		return pids, nil
+	}
+	readProcStat = func(pid int) (procfs.Stat, error) {
+		st, ok := f[pid]
+		if !ok {
+			return procfs.Stat{}, errors.New("exited")
+		}
+		return st, nil
+	}
+	readCgroupPath = func(pid int) (string, error) { return "/system.slice/app.service", nil }
+}
+
+func TestProcSamplerDeltasBetweenResets(t *testing.T) {
+	proc := fakeProc{
+		10: {PID: 10, Comm: "busy", UTime: 100, STime: 50, StartTime: 1000, Processor: 3},
+		11: {PID: 11, Comm: "idle", UTime: 5, StartTime: 1000},
+	}
+	proc.install(t)
+
+	s, err := newProcSampler()
+	if err != nil {
+		t.Fatalf("newProcSampler: %v", err)
+	}
+
+	// busy accrues 0.5s; idle accrues nothing; a new process appears with 0.1s.
+	proc[10] = procfs.Stat{PID: 10, Comm: "busy", UTime: 140, STime: 60, StartTime: 1000, Processor: 2}
+	proc[12] = procfs.Stat{PID: 12, Comm: "new", UTime: 10, StartTime: 5000}
+
+	stats, err := s.snapshot(0)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 processes with CPU time, got %+v", stats)
+	}
+	if stats[0].PID != 10 || stats[0].Ns != uint64(500*time.Millisecond) || stats[0].CPUCore != 2 {
+		t.Fatalf("unexpected busy row: %+v", stats[0])
+	}
+	if stats[0].Cgroup != "/system.slice/app.service" {
+		t.Fatalf("expected cgroup path, got %q", stats[0].Cgroup)
+	}
+	if stats[1].PID != 12 || stats[1].Ns != uint64(100*time.Millisecond) {
+		t.Fatalf("new process should be charged its lifetime: %+v", stats[1])
+	}
+
+	// After reset only new CPU time counts.
+	s.reset()
+	proc[10] = procfs.Stat{PID: 10, Comm: "busy", UTime: 141, STime: 60, StartTime: 1000}
+	stats, err = s.snapshot(1)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Ns != uint64(10*time.Millisecond) {
+		t.Fatalf("expected 10ms after reset, got %+v", stats)
+	}
+}
+
+func TestProcSamplerPIDReuse(t *testing.T) {
+	proc := fakeProc{20: {PID: 20, Comm: "old", UTime: 900, StartTime: 1000}}
+	proc.install(t)
+
+	s, err := newProcSampler()
+	if err != nil {
+		t.Fatalf("newProcSampler: %v", err)
+	}
+	// PID 20 exits and is reused by a process with fewer ticks than the
+	// old baseline; it must not be compared against the old counters.
+	proc[20] = procfs.Stat{PID: 20, Comm: "reused", UTime: 30, StartTime: 7000}
+	stats, err := s.snapshot(0)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Comm != "reused" || stats[0].Ns != uint64(300*time.Millisecond) {
+		t.Fatalf("expected fresh counters for reused PID, got %+v", stats)
+	}
+}
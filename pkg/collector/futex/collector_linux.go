@@ -0,0 +1,166 @@
+//go:build linux
+// +build linux
+
+// Package futex tracks per-process time spent blocked in futex waits, the
+// kernel side of userspace locks and condition variables, to tell a process
+// stalled on lock contention from one starved of CPU.
+package futex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cgroupfilter"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+const resetSweepRetries = 3
+
+// Collector owns the eBPF programs on the sys_enter_futex and
+// sys_exit_futex tracepoints.
+type Collector struct {
+	objs  futex_bpfObjects
+	enter link.Link
+	exit  link.Link
+}
+
+// NewCollector loads the futex tracker and attaches both tracepoints.
+// Either one alone is useless, so failing to attach one is an error.
+func NewCollector() (*Collector, error) {
+	return NewCollectorForCgroup("")
+}
+
+// NewCollectorForCgroup is NewCollector counting only tasks in the cgroup v2
+// subtree at cgroupPath, checked in the kernel. An empty path counts every
+// task.
+func NewCollectorForCgroup(cgroupPath string) (*Collector, error) {
+	var objs futex_bpfObjects
+	spec, err := bpfenv.LoadSpec("futex_bpf", loadFutex_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading futex bpf spec: %w", err)
+	}
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
+		return nil, fmt.Errorf("loading futex bpf objects: %w", err)
+	}
+
+	c := &Collector{objs: objs}
+	// Mark the window before attaching, so waits already in progress are
+	// not charged from boot.
+	if err := c.markWindowStart(); err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("marking window start: %w", err)
+	}
+	enter, err := link.Tracepoint("syscalls", "sys_enter_futex", objs.HandleFutexEnter, nil)
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching sys_enter_futex tracepoint failed: %w", err)
+	}
+	exit, err := link.Tracepoint("syscalls", "sys_exit_futex", objs.HandleFutexExit, nil)
+	if err != nil {
+		enter.Close()
+		objs.Close()
+		return nil, fmt.Errorf("attaching sys_exit_futex tracepoint failed: %w", err)
+	}
+	c.enter, c.exit = enter, exit
+	return c, nil
+}
+
+// Close releases the BPF resources.
+func (c *Collector) Close() error {
+	return errors.Join(c.enter.Close(), c.exit.Close(), c.objs.Close())
+}
+
+// Snapshot returns the processes that spent the most time blocked in futex
+// waits since the last reset.
+func (c *Collector) Snapshot(limit int) ([]types.FutexStat, error) {
+	stats := make([]types.FutexStat, 0, limit)
+	iter := c.objs.FutexStats.Iterate()
+	var pid uint32
+	var stat futexStat
+	for iter.Next(&pid, &stat) {
+		if stat.Contended == 0 {
+			continue
+		}
+		stats = append(stats, types.FutexStat{
+			PID:       pid,
+			Comm:      cStr(stat.Comm[:]),
+			WaitNs:    stat.WaitNs,
+			Contended: stat.Contended,
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating futex map: %w", err)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].WaitNs != stats[j].WaitNs {
+			return stats[i].WaitNs > stats[j].WaitNs
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// Reset clears the per-process totals for the next interval. Waits in
+// progress are charged from the new window's start when they return.
+func (c *Collector) Reset() error {
+	// Mark the window start first, so a wait that returns between the two
+	// steps is not charged for time before the new window.
+	if err := c.markWindowStart(); err != nil {
+		return fmt.Errorf("marking window start: %w", err)
+	}
+	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
+		iter := c.objs.FutexStats.Iterate()
+		var pid uint32
+		var stat futexStat
+		for iter.Next(&pid, &stat) {
+			if err := c.objs.FutexStats.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("clearing futex waits for pid %d: %w", pid, err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
+				continue
+			}
+			return fmt.Errorf("iterating futex map: %w", err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// markWindowStart stores the current CLOCK_MONOTONIC time, the clock behind
+// bpf_ktime_get_ns, as the start of the new window.
+func (c *Collector) markWindowStart() error {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return err
+	}
+	key := uint32(0)
+	return c.objs.WindowStart.Put(&key, uint64(ts.Nano()))
+}
+
+func cStr(b []byte) string {
+	if n := bytes.IndexByte(b, 0); n != -1 {
+		return string(b[:n])
+	}
+	return string(b)
+}
+
+// futexStat mirrors the BPF struct futex_stat in futex.c.
+// Field order and sizes MUST match exactly for correct map iteration.
+type futexStat struct {
+	WaitNs    uint64   // time blocked in futex waits, summed across threads
+	Contended uint64   // waits that blocked
+	Comm      [16]byte // comm of the first thread that waited
+}
@@ -6,6 +6,7 @@ package memory
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"sort"
 	"time"
@@ -13,15 +14,51 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cgroupfilter"
 	"github.com/srodi/hotspot-bpf/pkg/types"
 )
 
-// Collector owns the eBPF program tracking per-PID page faults.
+// Collector owns the eBPF programs tracking per-PID page faults, major-fault
+// latency, TLB shootdowns, and swap traffic.
+//
+// The per-window maps are double-buffered (see memory_faults.c): a window
+// ends when the collector flips the active_buffer index, after which the
+// retired maps can be read and cleared without racing the probes.
 type Collector struct {
-	objs memory_bpfObjects
-	hook link.Link
+	objs        memory_bpfObjects
+	hook        link.Link
+	latencyHook link.Link // nil when the handle_mm_fault kretprobe could not be attached
+	tlbHook     link.Link // nil when no remote TLB flush function could be probed
+	swapIn      link.Link // nil (along with swapOut) when swap I/O could not be probed
+	swapOut     link.Link
+	buffers     [2]windowMaps
+	active      uint32 // index of the buffer the probes write
+	retired     bool   // Snapshot flipped the buffers; the window it read is in buffers[1-active]
+	cumulative  bool   // Snapshot reads the live buffer and never flips (SetCumulative)
 }
 
+// windowMaps is one copy of the double-buffered per-window maps.
+type windowMaps struct {
+	faults *ebpf.Map // page_faults or page_faults_b
+	tlb    *ebpf.Map // tlb_shootdowns or tlb_shootdowns_b
+	swap   *ebpf.Map // swap_events or swap_events_b
+}
+
+// tlbFlushSymbols are the x86 functions that send TLB shootdown IPIs, newest
+// first. native_flush_tlb_others was renamed in Linux 5.11. Other
+// architectures (e.g. arm64 broadcast TLBI) have no equivalent IPI path.
+var tlbFlushSymbols = []string{"native_flush_tlb_multi", "native_flush_tlb_others"}
+
+// swapInSymbols and swapOutSymbols are the functions that read a page back
+// from swap and write one out, newest first. swap_readpage became
+// swap_read_folio in Linux 6.10, and later kernels renamed swap_writepage to
+// swap_writeout.
+var (
+	swapInSymbols  = []string{"swap_read_folio", "swap_readpage"}
+	swapOutSymbols = []string{"swap_writeout", "swap_writepage"}
+)
+
 const resetSweepRetries = 3
 
 var pageSize = uint64(os.Getpagesize())
@@ -29,8 +66,22 @@ var pageSize = uint64(os.Getpagesize())
 // NewCollector loads the page fault tracker and attaches it to the always-available
 // handle_mm_fault kprobe so we always capture fault activity.
 func NewCollector() (*Collector, error) {
+	return NewCollectorForCgroup("")
+}
+
+// NewCollectorForCgroup is NewCollector counting only tasks in the cgroup v2
+// subtree at cgroupPath, checked in the kernel. An empty path counts every
+// task.
+func NewCollectorForCgroup(cgroupPath string) (*Collector, error) {
+	if err := bpfenv.CheckBTF(); err != nil {
+		return nil, err
+	}
 	var objs memory_bpfObjects
-	if err := loadMemory_bpfObjects(&objs, nil); err != nil {
+	spec, err := bpfenv.LoadSpec("memory_bpf", loadMemory_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading memory bpf spec: %w", err)
+	}
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
 		return nil, fmt.Errorf("loading memory bpf objects: %w", err)
 	}
 
@@ -40,7 +91,96 @@ func NewCollector() (*Collector, error) {
 		return nil, fmt.Errorf("attaching handle_mm_fault kprobe failed: %w", kerr)
 	}
 
-	return &Collector{objs: objs, hook: kp}, nil
+	c := &Collector{
+		objs: objs,
+		hook: kp,
+		buffers: [2]windowMaps{
+			{faults: objs.PageFaults, tlb: objs.TlbShootdowns, swap: objs.SwapEvents},
+			{faults: objs.PageFaultsB, tlb: objs.TlbShootdownsB, swap: objs.SwapEventsB},
+		},
+	}
+	// Major-fault latency is best-effort too: without the return probe the
+	// fault counts are unchanged and MajorFaults stays zero.
+	if l, err := link.Kretprobe("handle_mm_fault", objs.HandleMmFaultReturn, nil); err == nil {
+		c.latencyHook = l
+	} else {
+		slog.Info("probe not attached, major faults not timed", "probe", "kretprobe/handle_mm_fault", "err", err)
+	}
+	// TLB shootdown tracking is best-effort: the fault tracker is still
+	// useful on kernels or architectures without a probeable flush path.
+	for _, sym := range tlbFlushSymbols {
+		if l, err := link.Kprobe(sym, objs.HandleTlbShootdown, nil); err == nil {
+			c.tlbHook = l
+			break
+		}
+	}
+	if c.tlbHook == nil {
+		slog.Info("probe not attached, TLB shootdowns not counted", "symbols", tlbFlushSymbols)
+	}
+	// Swap tracking is best-effort as well, and all or nothing: swap-outs
+	// without swap-ins (or the reverse) would read as a one-way leak.
+	c.swapIn = attachFirst(swapInSymbols, objs.HandleSwapIn)
+	c.swapOut = attachFirst(swapOutSymbols, objs.HandleSwapOut)
+	if c.swapIn == nil || c.swapOut == nil {
+		if c.swapIn != nil {
+			c.swapIn.Close()
+		}
+		if c.swapOut != nil {
+			c.swapOut.Close()
+		}
+		c.swapIn, c.swapOut = nil, nil
+		slog.Info("probes not attached, swap not counted", "swap_in", swapInSymbols, "swap_out", swapOutSymbols)
+	}
+	return c, nil
+}
+
+// SelfTest checks that the memory eBPF object has every program, map, and
+// variable this version expects, then creates a collector for cgroupPath,
+// which loads the programs and attaches the handle_mm_fault kprobe, and
+// closes it again, for -selftest. It leaves nothing attached.
+func SelfTest(cgroupPath string) error {
+	var objs memory_bpfObjects
+	spec, err := bpfenv.LoadSpec("memory_bpf", loadMemory_bpf, &objs)
+	if err != nil {
+		return fmt.Errorf("loading memory bpf spec: %w", err)
+	}
+	if err := bpfenv.CheckSpec(spec, &objs); err != nil {
+		return fmt.Errorf("eBPF object memory_bpf: %w", err)
+	}
+	c, err := NewCollectorForCgroup(cgroupPath)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// attachFirst attaches prog as a kprobe on the first of symbols the kernel
+// has, or returns nil if none can be probed.
+func attachFirst(symbols []string, prog *ebpf.Program) link.Link {
+	for _, sym := range symbols {
+		if l, err := link.Kprobe(sym, prog, nil); err == nil {
+			return l
+		}
+	}
+	return nil
+}
+
+// TLBTracking reports whether TLB shootdowns are being counted. When false,
+// PageFaultStat.TLBShootdowns is always zero.
+func (c *Collector) TLBTracking() bool {
+	return c.tlbHook != nil
+}
+
+// SwapTracking reports whether swap-ins and swap-outs are being counted.
+// When false, PageFaultStat.SwapIns and SwapOuts are always zero.
+func (c *Collector) SwapTracking() bool {
+	return c.swapIn != nil
+}
+
+// LatencyTracking reports whether major faults are being timed. When false,
+// PageFaultStat.MajorFaults and MajorFaultNs are always zero.
+func (c *Collector) LatencyTracking() bool {
+	return c.latencyHook != nil
 }
 
 // Close releases the BPF resources.
@@ -49,15 +189,52 @@ func (c *Collector) Close() error {
 	if c.hook != nil {
 		err = errors.Join(err, c.hook.Close())
 	}
+	if c.latencyHook != nil {
+		err = errors.Join(err, c.latencyHook.Close())
+	}
+	if c.tlbHook != nil {
+		err = errors.Join(err, c.tlbHook.Close())
+	}
+	if c.swapIn != nil {
+		err = errors.Join(err, c.swapIn.Close(), c.swapOut.Close())
+	}
 	return errors.Join(err, c.objs.Close())
 }
 
+// SetCumulative makes Snapshot read the buffer the probes are writing
+// instead of ending the window, for callers that never Reset and compute
+// each window's change themselves. The counts then grow from the load of
+// the collector, and the maps stop taking new PIDs once full.
+func (c *Collector) SetCumulative() {
+	c.cumulative = true
+}
+
 // Snapshot returns the busiest PIDs by page faults for the current window.
+// PIDs that only initiated TLB shootdowns or swapped are included with zero
+// faults and sort after every faulting PID.
+//
+// The first Snapshot after a Reset ends the window: the probes switch to the
+// other buffer, so faults from then on count towards the next window. Calling
+// Snapshot again before Reset reads the same window.
 func (c *Collector) Snapshot(limit int, window time.Duration) ([]types.PageFaultStat, error) {
+	if !c.retired && !c.cumulative {
+		if err := c.flip(); err != nil {
+			return nil, fmt.Errorf("switching page fault buffers: %w", err)
+		}
+		c.retired = true
+	}
+	// A probe that read the old index just before the flip may still be
+	// finishing its update. It is a few instructions from done, so in
+	// practice it lands before the iteration below reaches its entry.
+	maps := c.buffers[1-c.active]
+	if c.cumulative {
+		maps = c.buffers[c.active]
+	}
+
 	stats := make([]types.PageFaultStat, 0, limit)
-	iter := c.objs.PageFaults.Iterate()
+	iter := maps.faults.Iterate()
 	var pid uint32
-	var stat faultStat
+	var perCPU []faultStat
 
 	cache := make(map[uint32]string)
 	windowSeconds := window.Seconds()
@@ -65,24 +242,91 @@ func (c *Collector) Snapshot(limit int, window time.Duration) ([]types.PageFault
 		windowSeconds = 1
 	}
 
-	for iter.Next(&pid, &stat) {
+	for iter.Next(&pid, &perCPU) {
+		stat := mergeFaultStats(perCPU)
 		if stat.Faults == 0 {
 			continue
 		}
 		stats = append(stats, types.PageFaultStat{
 			PID:          pid,
 			Comm:         commForPID(pid, cache),
-			Cgroup:       cStr(stat.Cgroup[:]),
+			CgroupID:     stat.CgroupID,
 			Faults:       stat.Faults,
 			FaultsPerSec: float64(stat.Faults) / windowSeconds,
 			RSSBytes:     stat.RSSPages * pageSize,
+			RSSMinBytes:  stat.RSSMinPages * pageSize,
+			RSSMaxBytes:  stat.RSSMaxPages * pageSize,
+			FaultPages:   estimateDistinctPages(stat.PageBitmap),
+			MajorFaults:  stat.MajorFaults,
+			MajorFaultNs: stat.MajorFaultNs,
 		})
 	}
 	if err := iter.Err(); err != nil {
 		return nil, fmt.Errorf("iterating page fault map: %w", err)
 	}
 
-	sort.Slice(stats, func(i, j int) bool { return stats[i].Faults > stats[j].Faults })
+	byPID := make(map[uint32]int, len(stats))
+	for i := range stats {
+		byPID[stats[i].PID] = i
+	}
+	// row returns the index of pid's stat, adding an empty one if it had no
+	// faults in the window.
+	row := func(pid uint32) int {
+		i, ok := byPID[pid]
+		if !ok {
+			i = len(stats)
+			byPID[pid] = i
+			stats = append(stats, types.PageFaultStat{PID: pid, Comm: commForPID(pid, cache)})
+		}
+		return i
+	}
+
+	if c.tlbHook != nil {
+		var count uint64
+		tlbIter := maps.tlb.Iterate()
+		for tlbIter.Next(&pid, &count) {
+			if count == 0 {
+				continue
+			}
+			i := row(pid)
+			stats[i].TLBShootdowns = count
+			stats[i].TLBShootdownsPerSec = float64(count) / windowSeconds
+		}
+		if err := tlbIter.Err(); err != nil {
+			return nil, fmt.Errorf("iterating tlb shootdown map: %w", err)
+		}
+	}
+
+	if c.swapIn != nil {
+		var swap swapStat
+		swapIter := maps.swap.Iterate()
+		for swapIter.Next(&pid, &swap) {
+			if swap.Ins == 0 && swap.Outs == 0 {
+				continue
+			}
+			i := row(pid)
+			stats[i].SwapIns = swap.Ins
+			stats[i].SwapOuts = swap.Outs
+			stats[i].SwapInsPerSec = float64(swap.Ins) / windowSeconds
+			stats[i].SwapOutsPerSec = float64(swap.Outs) / windowSeconds
+		}
+		if err := swapIter.Err(); err != nil {
+			return nil, fmt.Errorf("iterating swap map: %w", err)
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Faults != stats[j].Faults {
+			return stats[i].Faults > stats[j].Faults
+		}
+		if stats[i].TLBShootdowns != stats[j].TLBShootdowns {
+			return stats[i].TLBShootdowns > stats[j].TLBShootdowns
+		}
+		if si, sj := stats[i].SwapIns+stats[i].SwapOuts, stats[j].SwapIns+stats[j].SwapOuts; si != sj {
+			return si > sj
+		}
+		return stats[i].PID < stats[j].PID
+	})
 	if limit > 0 && len(stats) > limit {
 		stats = stats[:limit]
 	}
@@ -90,14 +334,57 @@ func (c *Collector) Snapshot(limit int, window time.Duration) ([]types.PageFault
 	return stats, nil
 }
 
-// Reset clears the page fault map for the next interval.
+// Reset starts the next window. After a Snapshot the probes already write
+// the other buffer, so Reset only clears the one Snapshot read and no fault
+// between the two calls is lost. Without a Snapshot since the last Reset,
+// the window in progress is discarded.
+//
+// The retired buffer is cleared with the probes no longer writing it, so the
+// sweep cannot race them; if it fails, the leftovers show up in the window
+// after next, as they did when the live map was swept.
 func (c *Collector) Reset() error {
+	if !c.retired {
+		if err := c.flip(); err != nil {
+			return fmt.Errorf("switching page fault buffers: %w", err)
+		}
+	}
+	c.retired = false
+	maps := c.buffers[1-c.active]
+	// page_faults is per-CPU: deleting a key drops every CPU's copy at once.
+	if err := clearMap(maps.faults, new([]faultStat)); err != nil {
+		return fmt.Errorf("page fault map: %w", err)
+	}
+	var count uint64
+	if err := clearMap(maps.tlb, &count); err != nil {
+		return fmt.Errorf("tlb shootdown map: %w", err)
+	}
+	if err := clearMap(maps.swap, new(swapStat)); err != nil {
+		return fmt.Errorf("swap map: %w", err)
+	}
+	return nil
+}
+
+// flip points the probes at the other buffer.
+func (c *Collector) flip() error {
+	next := 1 - c.active
+	key := uint32(0)
+	if err := c.objs.ActiveBuffer.Put(&key, next); err != nil {
+		return err
+	}
+	c.active = next
+	return nil
+}
+
+// clearMap deletes every PID-keyed entry of m. value is scratch space of the
+// map's value type, or of a slice of it for a per-CPU map. Concurrent inserts
+// from BPF can abort an iteration, so the sweep is retried a bounded number of
+// times.
+func clearMap(m *ebpf.Map, value interface{}) error {
 	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
-		iter := c.objs.PageFaults.Iterate()
+		iter := m.Iterate()
 		var pid uint32
-		var stat faultStat
-		for iter.Next(&pid, &stat) {
-			if err := c.objs.PageFaults.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		for iter.Next(&pid, value) {
+			if err := m.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
 				return fmt.Errorf("clearing pid %d: %w", pid, err)
 			}
 		}
@@ -105,7 +392,7 @@ func (c *Collector) Reset() error {
 			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
 				continue
 			}
-			return fmt.Errorf("iterating page fault map: %w", err)
+			return fmt.Errorf("iterating: %w", err)
 		}
 		return nil
 	}
@@ -115,7 +402,50 @@ func (c *Collector) Reset() error {
 // faultStat mirrors the BPF struct fault_stat in memory_faults.c.
 // Field order and sizes MUST match exactly for correct map iteration.
 type faultStat struct {
-	Faults   uint64   // page fault count in the current window
-	RSSPages uint64   // approximate RSS in pages (from BPF mm->rss_stat)
-	Cgroup   [64]byte // best-effort cgroup leaf name
+	Faults       uint64                      // page fault count in the current window
+	RSSPages     uint64                      // approximate RSS in pages (from BPF mm->rss_stat)
+	CgroupID     uint64                      // cgroup v2 id, resolved to a path by report.BuildProcMetrics
+	PageBitmap   [pageBitmapBits / 64]uint64 // hashed set of faulting pages
+	MajorFaults  uint64                      // faults that returned VM_FAULT_MAJOR (0 without the kretprobe)
+	MajorFaultNs uint64                      // total time spent in those faults
+	RSSMinPages  uint64                      // lowest RSS seen at a fault in the window
+	RSSMaxPages  uint64                      // highest RSS seen at a fault in the window
+	LastFaultNs  uint64                      // ktime_ns of the fault that set RSSPages
+}
+
+// swapStat mirrors the BPF struct swap_stat in memory_faults.c.
+type swapStat struct {
+	Ins  uint64 // folios read back from swap
+	Outs uint64 // folios written out to swap
+}
+
+// mergeFaultStats folds the per-CPU copies of one page_faults entry into
+// one. Counters are summed and bitmaps OR'd; the RSS range spans every copy
+// that saw a fault and RSSPages comes from the most recent fault. A copy
+// with major-fault time but no faults was only touched by the return probe
+// of a thread that migrated mid-fault, so its zero RSS is ignored.
+func mergeFaultStats(perCPU []faultStat) faultStat {
+	var out faultStat
+	for _, s := range perCPU {
+		out.MajorFaults += s.MajorFaults
+		out.MajorFaultNs += s.MajorFaultNs
+		if s.Faults == 0 {
+			continue
+		}
+		if out.Faults == 0 || s.RSSMinPages < out.RSSMinPages {
+			out.RSSMinPages = s.RSSMinPages
+		}
+		out.RSSMaxPages = max(out.RSSMaxPages, s.RSSMaxPages)
+		if s.LastFaultNs >= out.LastFaultNs {
+			out.RSSPages, out.LastFaultNs = s.RSSPages, s.LastFaultNs
+		}
+		out.Faults += s.Faults
+		for i := range out.PageBitmap {
+			out.PageBitmap[i] |= s.PageBitmap[i]
+		}
+		if out.CgroupID == 0 {
+			out.CgroupID = s.CgroupID
+		}
+	}
+	return out
 }
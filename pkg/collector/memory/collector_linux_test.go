@@ -0,0 +1,172 @@
+//go:build linux
+
+package memory
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestMergeFaultStats(t *testing.T) {
+	perCPU := []faultStat{
+		{Faults: 10, RSSPages: 500, RSSMinPages: 400, RSSMaxPages: 500, LastFaultNs: 2000, CgroupID: 7429, PageBitmap: [4]uint64{1}},
+		{MajorFaults: 1, MajorFaultNs: 300}, // return probe only: the thread migrated mid-fault
+		{Faults: 5, RSSPages: 450, RSSMinPages: 420, RSSMaxPages: 700, LastFaultNs: 3000, PageBitmap: [4]uint64{2}, MajorFaults: 2, MajorFaultNs: 600},
+	}
+
+	got := mergeFaultStats(perCPU)
+	if got.Faults != 15 || got.MajorFaults != 3 || got.MajorFaultNs != 900 {
+		t.Fatalf("Faults=%d MajorFaults=%d MajorFaultNs=%d; want 15, 3, 900", got.Faults, got.MajorFaults, got.MajorFaultNs)
+	}
+	if got.RSSPages != 450 || got.RSSMinPages != 400 || got.RSSMaxPages != 700 {
+		t.Fatalf("RSS %d (min %d, max %d); want 450 (min 400, max 700)", got.RSSPages, got.RSSMinPages, got.RSSMaxPages)
+	}
+	if got.PageBitmap[0] != 3 {
+		t.Fatalf("PageBitmap[0] = %b; want the union 11", got.PageBitmap[0])
+	}
+	if got.CgroupID != 7429 {
+		t.Fatalf("CgroupID = %d; want 7429", got.CgroupID)
+	}
+}
+
+func TestFaultStatLayout(t *testing.T) {
+	// struct fault_stat: 8 + 8 + 8 + 32 + 5 * 8 bytes.
+	if got := binary.Size(faultStat{}); got != 96 {
+		t.Fatalf("faultStat is %d bytes; struct fault_stat is 96", got)
+	}
+}
+
+// benchEntries is the number of processes in the maps for the reset
+// benchmarks, well past what a busy host shows in one window.
+const benchEntries = 20000
+
+// newTestCollector builds a Collector on freshly created maps, with no
+// programs attached, so the buffer handling can be driven from userspace.
+func newTestCollector(tb testing.TB) *Collector {
+	tb.Helper()
+	newMap := func(spec *ebpf.MapSpec) *ebpf.Map {
+		m, err := ebpf.NewMap(spec)
+		if err != nil {
+			tb.Skipf("creating %s map (needs CAP_BPF): %v", spec.Type, err)
+		}
+		tb.Cleanup(func() { m.Close() })
+		return m
+	}
+	faults := func() *ebpf.Map {
+		return newMap(&ebpf.MapSpec{Type: ebpf.PerCPUHash, KeySize: 4, ValueSize: uint32(binary.Size(faultStat{})), MaxEntries: 2 * benchEntries})
+	}
+	tlb := func() *ebpf.Map {
+		return newMap(&ebpf.MapSpec{Type: ebpf.Hash, KeySize: 4, ValueSize: 8, MaxEntries: 4096})
+	}
+	swap := func() *ebpf.Map {
+		return newMap(&ebpf.MapSpec{Type: ebpf.Hash, KeySize: 4, ValueSize: uint32(binary.Size(swapStat{})), MaxEntries: 4096})
+	}
+	c := &Collector{buffers: [2]windowMaps{
+		{faults: faults(), tlb: tlb(), swap: swap()},
+		{faults: faults(), tlb: tlb(), swap: swap()},
+	}}
+	c.objs.ActiveBuffer = newMap(&ebpf.MapSpec{Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: 1})
+	return c
+}
+
+// recordFaults stands in for the kprobe: it adds n faults for each PID to
+// the buffer the probes currently write.
+func recordFaults(tb testing.TB, c *Collector, n uint64, pids ...uint32) {
+	tb.Helper()
+	ncpu, err := ebpf.PossibleCPU()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m := c.buffers[c.active].faults
+	for _, pid := range pids {
+		perCPU := make([]faultStat, ncpu)
+		if err := m.Lookup(&pid, &perCPU); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			tb.Fatal(err)
+		}
+		perCPU[0].Faults += n
+		if err := m.Put(&pid, perCPU); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshotResetKeepsFaultsInBetween(t *testing.T) {
+	c := newTestCollector(t)
+	recordFaults(t, c, 5, 100)
+
+	stats, err := c.Snapshot(0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Faults != 5 {
+		t.Fatalf("first window = %+v; want pid 100 with 5 faults", stats)
+	}
+	// Faults between Snapshot and Reset belong to the next window.
+	recordFaults(t, c, 3, 100, 200)
+	if again, err := c.Snapshot(0, time.Second); err != nil || len(again) != 1 || again[0].Faults != 5 {
+		t.Fatalf("repeated Snapshot = %+v, %v; want the same window", again, err)
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err = c.Snapshot(0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Faults != 3 || stats[1].Faults != 3 {
+		t.Fatalf("second window = %+v; want pids 100 and 200 with 3 faults each", stats)
+	}
+}
+
+func TestResetWithoutSnapshotDiscardsWindow(t *testing.T) {
+	c := newTestCollector(t)
+	recordFaults(t, c, 5, 100)
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := c.Snapshot(0, time.Second); err != nil || len(stats) != 0 {
+		t.Fatalf("Snapshot after Reset = %+v, %v; want an empty window", stats, err)
+	}
+}
+
+// BenchmarkResetSweep measures the old reset: deleting every key of the map
+// the probes are writing. Faults landing on a key between its read and its
+// delete are lost, for as long as the sweep takes.
+func BenchmarkResetSweep(b *testing.B) {
+	c := newTestCollector(b)
+	pids := benchPIDs()
+	for b.Loop() {
+		b.StopTimer()
+		recordFaults(b, c, 1, pids...)
+		b.StartTimer()
+		if err := clearMap(c.buffers[c.active].faults, new([]faultStat)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkResetSwap measures the window boundary of the double buffer: the
+// flip Snapshot does before reading. Clearing the retired buffer costs the
+// same as the sweep above, but nothing writes to it by then.
+func BenchmarkResetSwap(b *testing.B) {
+	c := newTestCollector(b)
+	recordFaults(b, c, 1, benchPIDs()...)
+	for b.Loop() {
+		if err := c.flip(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchPIDs() []uint32 {
+	pids := make([]uint32, benchEntries)
+	for i := range pids {
+		pids[i] = uint32(i + 1)
+	}
+	return pids
+}
@@ -20,11 +20,39 @@ func NewCollector() (*Collector, error) {
 	return nil, errUnsupported
 }
 
+// NewCollectorForCgroup returns an error because eBPF is only supported on Linux.
+func NewCollectorForCgroup(cgroupPath string) (*Collector, error) {
+	return nil, errUnsupported
+}
+
+// SelfTest returns an error because eBPF is only supported on Linux.
+func SelfTest(cgroupPath string) error {
+	return errUnsupported
+}
+
 // Snapshot always fails on unsupported platforms.
 func (c *Collector) Snapshot(limit int, window time.Duration) ([]types.PageFaultStat, error) {
 	return nil, errUnsupported
 }
 
+// SetCumulative does nothing on unsupported platforms.
+func (c *Collector) SetCumulative() {}
+
+// TLBTracking always reports false on unsupported platforms.
+func (c *Collector) TLBTracking() bool {
+	return false
+}
+
+// SwapTracking always reports false on unsupported platforms.
+func (c *Collector) SwapTracking() bool {
+	return false
+}
+
+// LatencyTracking always reports false on unsupported platforms.
+func (c *Collector) LatencyTracking() bool {
+	return false
+}
+
 // Reset does nothing on unsupported platforms.
 func (c *Collector) Reset() error {
 	return nil
@@ -12,6 +12,9 @@ func TestMemoryStubCollectorBehavior(t *testing.T) {
 	if _, err := NewCollector(); !errors.Is(err, errUnsupported) {
 		t.Fatalf("expected errUnsupported, got %v", err)
 	}
+	if err := SelfTest(""); !errors.Is(err, errUnsupported) {
+		t.Fatalf("expected errUnsupported from SelfTest, got %v", err)
+	}
 
 	var c Collector
 	if stats, err := c.Snapshot(5, time.Second); err != errUnsupported || stats != nil {
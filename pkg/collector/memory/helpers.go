@@ -3,6 +3,8 @@ package memory
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"math/bits"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -41,3 +43,23 @@ func cStr(b []byte) string {
 	}
 	return string(b[:n])
 }
+
+// pageBitmapBits is the size of the fault_stat.page_bitmap in memory_faults.c.
+const pageBitmapBits = 256
+
+// estimateDistinctPages turns the hashed page bitmap into an estimate of how
+// many distinct pages faulted, using linear counting: n ≈ -m·ln(zeroBits/m).
+// A saturated bitmap means "more pages than the bitmap can count", so the
+// estimate is capped at m·ln(m) (≈1400 pages).
+func estimateDistinctPages(bitmap [pageBitmapBits / 64]uint64) float64 {
+	set := 0
+	for _, word := range bitmap {
+		set += bits.OnesCount64(word)
+	}
+	m := float64(pageBitmapBits)
+	zero := m - float64(set)
+	if zero == 0 {
+		return m * math.Log(m)
+	}
+	return -m * math.Log(zero/m)
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"errors"
+	"math"
 	"os"
 	"strings"
 	"testing"
@@ -62,3 +63,26 @@ func TestCommForPIDHandlesErrorsAndWhitespace(t *testing.T) {
 		t.Fatalf("missing file should fallback, got %q", name)
 	}
 }
+
+func TestEstimateDistinctPages(t *testing.T) {
+	var empty [pageBitmapBits / 64]uint64
+	if got := estimateDistinctPages(empty); got != 0 {
+		t.Fatalf("empty bitmap should estimate 0 pages, got %f", got)
+	}
+
+	few := empty
+	few[0] = 0b111 // 3 bits set: estimate is close to 3
+	if got := estimateDistinctPages(few); math.Abs(got-3) > 0.1 {
+		t.Fatalf("expected ~3 pages, got %f", got)
+	}
+
+	half := [pageBitmapBits / 64]uint64{^uint64(0), ^uint64(0), 0, 0}
+	if got := estimateDistinctPages(half); math.Abs(got-256*math.Ln2) > 1e-6 {
+		t.Fatalf("expected 256·ln2 for half-full bitmap, got %f", got)
+	}
+
+	full := [pageBitmapBits / 64]uint64{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+	if got := estimateDistinctPages(full); math.IsInf(got, 0) || got < estimateDistinctPages(half) {
+		t.Fatalf("saturated bitmap should give a finite upper estimate, got %f", got)
+	}
+}
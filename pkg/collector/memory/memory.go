@@ -9,11 +9,12 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -38,13 +39,44 @@ func rssBytes(pid int) (uint64, error) {
 	return rssPages * uint64(os.Getpagesize()), nil
 }
 
+// rssWorkers bounds the concurrent /proc reads of RSSBytesForPIDs.
+var rssWorkers = 16
+
+// rssReadTimeout bounds a single statm read. Reading /proc/PID/statm takes
+// the process's mmap lock, so it blocks while a task of that process holds
+// the lock in D state; such a PID is skipped for the interval rather than
+// stalling the snapshot.
+const rssReadTimeout = 250 * time.Millisecond
+
+// errRSSTimeout reports a statm read that did not finish within
+// rssReadTimeout.
+var errRSSTimeout = errors.New("timed out")
+
+// readRSS is rssBytes; tests replace it to simulate slow or hung reads.
+var readRSS = rssBytes
+
+// checkComm is commMatches; tests replace it like readRSS.
+var checkComm = commMatches
+
+// errCommMismatch reports a PID whose process no longer carries the comm
+// the BPF maps recorded, so it was recycled. It wraps os.ErrNotExist: the
+// process that was sampled has exited.
+var errCommMismatch = fmt.Errorf("comm changed: %w", os.ErrNotExist)
+
 // RSSBytesForPIDs returns a PID->RSS map for the provided set.
+// The reads run on up to rssWorkers goroutines, so a host with thousands of
+// active PIDs does not serialize into a stall every interval, and a read
+// that hangs is abandoned after rssReadTimeout.
 // It retries once on transient /proc read failures to handle race conditions
 // where a process is briefly unavailable between BPF data collection and /proc reads.
 func RSSBytesForPIDs(pids []int) map[int]uint64 {
-	result := make(map[int]uint64, len(pids))
+	return rssForPIDs(pids, readRSS)
+}
+
+// rssForPIDs is RSSBytesForPIDs with read in place of readRSS.
+func rssForPIDs(pids []int, read func(pid int) (uint64, error)) map[int]uint64 {
+	unique := make([]int, 0, len(pids))
 	seen := make(map[int]struct{}, len(pids))
-	var retryPIDs []int
 	for _, pid := range pids {
 		if pid <= 0 {
 			continue
@@ -53,29 +85,145 @@ func RSSBytesForPIDs(pids []int) map[int]uint64 {
 			continue
 		}
 		seen[pid] = struct{}{}
-		rss, err := rssBytes(pid)
-		if err == nil {
-			result[pid] = rss
-		} else if !errors.Is(err, os.ErrNotExist) {
-			retryPIDs = append(retryPIDs, pid)
+		unique = append(unique, pid)
+	}
+
+	result := make(map[int]uint64, len(unique))
+	var retryPIDs []int
+	for pid, err := range readAllRSS(unique, read, result) {
+		if errors.Is(err, errRSSTimeout) {
+			// Retrying would only block again.
+			slog.Warn("rss read failed", "pid", pid, "err", err)
+			continue
 		}
+		retryPIDs = append(retryPIDs, pid)
 	}
 	if len(retryPIDs) > 0 {
 		time.Sleep(5 * time.Millisecond)
-		for _, pid := range retryPIDs {
-			rss, err := rssBytes(pid)
-			if err == nil {
-				result[pid] = rss
-			} else {
-				log.Printf("rss read failed for pid %d after retry: %v", pid, err)
-			}
+		for pid, err := range readAllRSS(retryPIDs, read, result) {
+			slog.Warn("rss read failed after retry", "pid", pid, "err", err)
 		}
 	}
 	return result
 }
 
-// TotalMemoryBytes returns the total system memory in bytes.
-// TODO: future scenario, consider container memory limits
+// readAllRSS reads the RSS of each PID in pids with read, with up to
+// rssWorkers reads in flight, into result. It returns the error of each
+// read that failed other than because the process has exited.
+func readAllRSS(pids []int, read func(pid int) (uint64, error), result map[int]uint64) map[int]error {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = make(map[int]error)
+		jobs   = make(chan int)
+	)
+	for range min(rssWorkers, len(pids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pid := range jobs {
+				rss, err := readWithTimeout(read, pid)
+				mu.Lock()
+				if err == nil {
+					result[pid] = rss
+				} else if !errors.Is(err, os.ErrNotExist) {
+					failed[pid] = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, pid := range pids {
+		jobs <- pid
+	}
+	close(jobs)
+	wg.Wait()
+	return failed
+}
+
+// readWithTimeout is read(pid), giving up after rssReadTimeout. A read
+// cannot be interrupted, so the goroutine of an abandoned read finishes
+// whenever the kernel lets it.
+func readWithTimeout(read func(pid int) (uint64, error), pid int) (uint64, error) {
+	type result struct {
+		rss uint64
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rss, err := read(pid)
+		done <- result{rss, err}
+	}()
+	timer := time.NewTimer(rssReadTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.rss, r.err
+	case <-timer.C:
+		return 0, fmt.Errorf("%w after %v", errRSSTimeout, rssReadTimeout)
+	}
+}
+
+// RSSBytesForProcs is RSSBytesForPIDs with a PID reuse check. procs maps
+// each PID to the comm the BPF maps recorded for it ("" skips the check).
+// /proc is read after the maps were iterated, so a PID that exited and was
+// recycled in between would report an unrelated process's RSS; an entry is
+// dropped when no thread of the process still carries the expected comm.
+// The check is part of each PID's read, so it shares the worker pool and
+// rssReadTimeout.
+func RSSBytesForProcs(procs map[int]string) map[int]uint64 {
+	pids := make([]int, 0, len(procs))
+	for pid := range procs {
+		pids = append(pids, pid)
+	}
+	return rssForPIDs(pids, func(pid int) (uint64, error) {
+		rss, err := readRSS(pid)
+		if err != nil {
+			return 0, err
+		}
+		if !checkComm(pid, procs[pid]) {
+			return 0, errCommMismatch
+		}
+		return rss, nil
+	})
+}
+
+// commMatches reports whether pid, or one of its threads, is named comm.
+// The BPF programs record the comm of whichever thread they caught, which
+// for processes that name their threads differs from /proc/PID/comm, so
+// the threads are only scanned when the main thread's name differs.
+func commMatches(pid int, comm string) bool {
+	if comm == "" {
+		return true
+	}
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	if readComm(filepath.Join(dir, "comm")) == comm {
+		return true
+	}
+	tasks, err := os.ReadDir(filepath.Join(dir, "task"))
+	if err != nil {
+		return false
+	}
+	for _, task := range tasks {
+		if readComm(filepath.Join(dir, "task", task.Name(), "comm")) == comm {
+			return true
+		}
+	}
+	return false
+}
+
+// readComm returns the contents of a comm file without the trailing
+// newline, or "" if it cannot be read.
+func readComm(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// TotalMemoryBytes returns the total system memory in bytes. It ignores
+// cgroup limits; see cgroup.MemoryLimit for a containerized process.
 func TotalMemoryBytes() (uint64, error) {
 	f, err := os.Open("/proc/meminfo")
 	if err != nil {
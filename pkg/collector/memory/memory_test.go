@@ -1,8 +1,12 @@
 package memory
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRSSBytesForSelf(t *testing.T) {
@@ -43,6 +47,104 @@ func TestRSSBytesForPIDsDeduplicates(t *testing.T) {
 	}
 }
 
+// fakeRSS replaces readRSS for the duration of the test.
+func fakeRSS(tb testing.TB, read func(pid int) (uint64, error)) {
+	tb.Helper()
+	old := readRSS
+	tb.Cleanup(func() { readRSS = old })
+	readRSS = read
+}
+
+func TestRSSBytesForPIDsReadsEachPIDOnce(t *testing.T) {
+	var mu sync.Mutex
+	reads := make(map[int]int)
+	fakeRSS(t, func(pid int) (uint64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reads[pid]++
+		return uint64(pid) * 4096, nil
+	})
+
+	var pids []int
+	for pid := 1; pid <= 100; pid++ {
+		pids = append(pids, pid, pid)
+	}
+	rssMap := RSSBytesForPIDs(pids)
+	if len(rssMap) != 100 {
+		t.Fatalf("expected 100 entries, got %d", len(rssMap))
+	}
+	for pid := 1; pid <= 100; pid++ {
+		if rssMap[pid] != uint64(pid)*4096 {
+			t.Fatalf("pid %d: RSS %d, want %d", pid, rssMap[pid], pid*4096)
+		}
+		if reads[pid] != 1 {
+			t.Fatalf("pid %d read %d times, want once", pid, reads[pid])
+		}
+	}
+}
+
+func TestRSSBytesForPIDsSkipsHungRead(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	fakeRSS(t, func(pid int) (uint64, error) {
+		if pid == 2 {
+			<-release // a task stuck in D state holding the mmap lock
+		}
+		return 4096, nil
+	})
+
+	start := time.Now()
+	rssMap := RSSBytesForPIDs([]int{1, 2, 3})
+	if elapsed := time.Since(start); elapsed > 2*rssReadTimeout {
+		t.Fatalf("hung read stalled RSSBytesForPIDs for %v", elapsed)
+	}
+	if _, ok := rssMap[2]; ok || len(rssMap) != 2 {
+		t.Fatalf("expected RSS for pids 1 and 3 only, got %v", rssMap)
+	}
+}
+
+func TestRSSBytesForProcsChecksComm(t *testing.T) {
+	pid := os.Getpid()
+	comm, err := os.ReadFile("/proc/self/comm")
+	if err != nil {
+		t.Skipf("reading own comm: %v", err)
+	}
+
+	if rss := RSSBytesForProcs(map[int]string{pid: strings.TrimSpace(string(comm))}); rss[pid] == 0 {
+		t.Fatalf("expected RSS for own pid with matching comm, got %v", rss)
+	}
+	if rss := RSSBytesForProcs(map[int]string{pid: ""}); rss[pid] == 0 {
+		t.Fatalf("expected RSS for own pid without an expected comm, got %v", rss)
+	}
+	// A different comm is what a recycled PID looks like.
+	if rss := RSSBytesForProcs(map[int]string{pid: "not-this-proc"}); len(rss) != 0 {
+		t.Fatalf("expected RSS to be dropped on comm mismatch, got %v", rss)
+	}
+}
+
+func TestRSSBytesForProcsSkipsHungCommCheck(t *testing.T) {
+	fakeRSS(t, func(pid int) (uint64, error) { return 4096, nil })
+	release := make(chan struct{})
+	defer close(release)
+	old := checkComm
+	t.Cleanup(func() { checkComm = old })
+	checkComm = func(pid int, comm string) bool {
+		if pid == 2 {
+			<-release // a /proc/PID/task scan stuck behind a D-state task
+		}
+		return comm != "recycled"
+	}
+
+	start := time.Now()
+	rssMap := RSSBytesForProcs(map[int]string{1: "a", 2: "b", 3: "c", 4: "recycled"})
+	if elapsed := time.Since(start); elapsed > 2*rssReadTimeout {
+		t.Fatalf("hung comm check stalled RSSBytesForProcs for %v", elapsed)
+	}
+	if len(rssMap) != 2 || rssMap[1] == 0 || rssMap[3] == 0 {
+		t.Fatalf("expected RSS for pids 1 and 3 only, got %v", rssMap)
+	}
+}
+
 func TestTotalMemoryBytes(t *testing.T) {
 	total, err := TotalMemoryBytes()
 	if err != nil {
@@ -52,3 +154,26 @@ func TestTotalMemoryBytes(t *testing.T) {
 		t.Fatalf("total memory %d seems too small", total)
 	}
 }
+
+// BenchmarkRSSBytesForPIDs reads 2000 synthetic PIDs whose reads take 50µs
+// each, as on a busy host, serially and with the worker pool.
+func BenchmarkRSSBytesForPIDs(b *testing.B) {
+	fakeRSS(b, func(pid int) (uint64, error) {
+		time.Sleep(50 * time.Microsecond)
+		return 4096, nil
+	})
+	pids := make([]int, 2000)
+	for i := range pids {
+		pids[i] = i + 1
+	}
+	for _, workers := range []int{1, rssWorkers} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			old := rssWorkers
+			defer func() { rssWorkers = old }()
+			rssWorkers = workers
+			for b.Loop() {
+				RSSBytesForPIDs(pids)
+			}
+		})
+	}
+}
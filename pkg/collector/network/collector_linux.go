@@ -0,0 +1,141 @@
+//go:build linux
+// +build linux
+
+// Package network counts per-process TCP bytes sent and received, to show
+// which processes drive network traffic. Its probes fire on every TCP send
+// and receive, so it is opt-in.
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cgroupfilter"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+const resetSweepRetries = 3
+
+// Collector owns the eBPF programs on tcp_sendmsg and tcp_cleanup_rbuf.
+type Collector struct {
+	objs network_bpfObjects
+	send link.Link
+	recv link.Link
+}
+
+// NewCollector loads the network tracker and attaches both probes. Counting
+// only one direction would misread as one-way traffic, so failing to attach
+// either is an error.
+func NewCollector() (*Collector, error) {
+	return NewCollectorForCgroup("")
+}
+
+// NewCollectorForCgroup is NewCollector counting only tasks in the cgroup v2
+// subtree at cgroupPath, checked in the kernel. An empty path counts every
+// task.
+func NewCollectorForCgroup(cgroupPath string) (*Collector, error) {
+	var objs network_bpfObjects
+	spec, err := bpfenv.LoadSpec("network_bpf", loadNetwork_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading network bpf spec: %w", err)
+	}
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
+		return nil, fmt.Errorf("loading network bpf objects: %w", err)
+	}
+
+	send, err := link.Kretprobe("tcp_sendmsg", objs.HandleTcpSendmsg, nil)
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching tcp_sendmsg kretprobe failed: %w", err)
+	}
+	recv, err := link.Kprobe("tcp_cleanup_rbuf", objs.HandleTcpCleanupRbuf, nil)
+	if err != nil {
+		send.Close()
+		objs.Close()
+		return nil, fmt.Errorf("attaching tcp_cleanup_rbuf kprobe failed: %w", err)
+	}
+	return &Collector{objs: objs, send: send, recv: recv}, nil
+}
+
+// Close releases the BPF resources.
+func (c *Collector) Close() error {
+	return errors.Join(c.send.Close(), c.recv.Close(), c.objs.Close())
+}
+
+// Snapshot returns the processes that moved the most TCP bytes, sent plus
+// received, since the last reset.
+func (c *Collector) Snapshot(limit int) ([]types.NetStat, error) {
+	stats := make([]types.NetStat, 0, limit)
+	iter := c.objs.NetStats.Iterate()
+	var pid uint32
+	var stat netStat
+	for iter.Next(&pid, &stat) {
+		if stat.TxBytes == 0 && stat.RxBytes == 0 {
+			continue
+		}
+		stats = append(stats, types.NetStat{
+			PID:     pid,
+			Comm:    cStr(stat.Comm[:]),
+			TxBytes: stat.TxBytes,
+			RxBytes: stat.RxBytes,
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating network map: %w", err)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		ti, tj := stats[i].TxBytes+stats[i].RxBytes, stats[j].TxBytes+stats[j].RxBytes
+		if ti != tj {
+			return ti > tj
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// Reset clears the per-process totals for the next interval.
+func (c *Collector) Reset() error {
+	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
+		iter := c.objs.NetStats.Iterate()
+		var pid uint32
+		var stat netStat
+		for iter.Next(&pid, &stat) {
+			if err := c.objs.NetStats.Delete(&pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("clearing network bytes for pid %d: %w", pid, err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
+				continue
+			}
+			return fmt.Errorf("iterating network map: %w", err)
+		}
+		return nil
+	}
+	return nil
+}
+
+func cStr(b []byte) string {
+	if n := bytes.IndexByte(b, 0); n != -1 {
+		return string(b[:n])
+	}
+	return string(b)
+}
+
+// netStat mirrors the BPF struct net_stat in network.c.
+// Field order and sizes MUST match exactly for correct map iteration.
+type netStat struct {
+	TxBytes uint64   // bytes queued by tcp_sendmsg
+	RxBytes uint64   // bytes copied to userspace by recvmsg
+	Comm    [16]byte // comm of the first thread that sent or received
+}
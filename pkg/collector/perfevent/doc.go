@@ -0,0 +1,6 @@
+// Package perfevent attaches eBPF programs to per-CPU software perf events,
+// for the collectors that sample on a timer (-cpu-method perf and
+// -profile-focus) rather than hook a kernel event.
+//
+// It requires Linux; elsewhere the package is empty.
+package perfevent
@@ -0,0 +1,71 @@
+//go:build linux
+// +build linux
+
+package perfevent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// perfEvent is an open perf event file descriptor with a BPF program attached.
+type perfEvent struct {
+	fd int
+}
+
+func (p *perfEvent) Close() error {
+	return unix.Close(p.fd)
+}
+
+// AttachSampler opens one CPU-clock software perf event per possible CPU,
+// firing every 1/freq seconds of on-CPU time, and attaches prog to each.
+// Offline CPUs are skipped. The returned closers detach the program.
+func AttachSampler(prog *ebpf.Program, freq int) ([]io.Closer, error) {
+	ncpu, err := ebpf.PossibleCPU()
+	if err != nil {
+		return nil, fmt.Errorf("counting possible CPUs: %w", err)
+	}
+	periodNs := uint64(1e9 / freq)
+
+	var events []io.Closer
+	closeAll := func() {
+		for _, ev := range events {
+			ev.Close()
+		}
+	}
+	for cpu := 0; cpu < ncpu; cpu++ {
+		attr := unix.PerfEventAttr{
+			Type:   unix.PERF_TYPE_SOFTWARE,
+			Config: unix.PERF_COUNT_SW_CPU_CLOCK,
+			Size:   uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+			Sample: periodNs,
+		}
+		fd, err := unix.PerfEventOpen(&attr, -1, cpu, -1, unix.PERF_FLAG_FD_CLOEXEC)
+		if errors.Is(err, unix.ENODEV) {
+			continue // CPU is offline
+		}
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("perf_event_open on cpu %d: %w", cpu, err)
+		}
+		ev := &perfEvent{fd: fd}
+		events = append(events, ev)
+		if err := unix.IoctlSetInt(fd, unix.PERF_EVENT_IOC_SET_BPF, prog.FD()); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("attaching program on cpu %d: %w", cpu, err)
+		}
+		if err := unix.IoctlSetInt(fd, unix.PERF_EVENT_IOC_ENABLE, 0); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("enabling perf event on cpu %d: %w", cpu, err)
+		}
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no online CPUs accepted a perf event")
+	}
+	return events, nil
+}
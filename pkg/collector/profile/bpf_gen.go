@@ -0,0 +1,6 @@
+//go:build linux
+// +build linux
+
+package profile
+
+//go:generate bpf2go -cc clang -cflags "-O2 -g -D__TARGET_ARCH_x86" profile_bpf ../../../bpf/profile.c
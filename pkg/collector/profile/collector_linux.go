@@ -0,0 +1,170 @@
+//go:build linux
+// +build linux
+
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/perfevent"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// maxStackDepth is MAX_STACK_DEPTH in profile.c.
+const maxStackDepth = 127
+
+// Collector owns the stack sampling program and its per-CPU perf events.
+type Collector struct {
+	objs      profile_bpfObjects
+	events    []io.Closer
+	target    uint32
+	kernel    *KernelSymbols // nil when kallsyms is unreadable; see KernelSymbolsErr
+	kernelErr error
+	user      *userSymbols
+}
+
+// NewCollector loads the stack sampler and attaches it to a CPU-clock perf
+// event on every CPU, firing freq times a second (DefaultFrequency when
+// freq <= 0). Nothing is sampled until SetTarget names a process.
+func NewCollector(freq int) (*Collector, error) {
+	if freq <= 0 {
+		freq = DefaultFrequency
+	}
+	var objs profile_bpfObjects
+	spec, err := bpfenv.LoadSpec("profile_bpf", loadProfile_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading profile bpf spec: %w", err)
+	}
+	if err := spec.LoadAndAssign(&objs, nil); err != nil {
+		return nil, fmt.Errorf("loading profile bpf objects: %w", err)
+	}
+	events, err := perfevent.AttachSampler(objs.HandleProfileSample, freq)
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching cpu-clock perf events: %w", err)
+	}
+	c := &Collector{objs: objs, events: events, user: newUserSymbols()}
+	// Kernel frames fall back to addresses rather than failing the profile.
+	c.kernel, c.kernelErr = LoadKernelSymbols()
+	return c, nil
+}
+
+// KernelSymbolsErr returns why kernel frames are shown as addresses, or nil
+// when they are resolved.
+func (c *Collector) KernelSymbolsErr() error {
+	return c.kernelErr
+}
+
+// Target returns the PID being sampled, 0 for none.
+func (c *Collector) Target() uint32 {
+	return c.target
+}
+
+// SetTarget samples pid's threads from now on, 0 to sample nothing. Samples
+// of the previous target already counted stay until Reset.
+func (c *Collector) SetTarget(pid uint32) error {
+	key := uint32(0)
+	if err := c.objs.TargetTgid.Put(&key, &pid); err != nil {
+		return fmt.Errorf("setting profile target: %w", err)
+	}
+	c.target = pid
+	return nil
+}
+
+// Snapshot returns the target's sampled stacks since the last reset,
+// symbolized, most samples first. A stack the kernel could not collect,
+// such as the user stack of a thread sampled in the kernel without a frame
+// pointer chain, contributes no frames for that half.
+func (c *Collector) Snapshot() ([]types.StackCount, error) {
+	resolveUser := c.user.resolver(c.target)
+	var stacks []types.StackCount
+	iter := c.objs.StackCounts.Iterate()
+	var key stackKey
+	var count uint64
+	for iter.Next(&key, &count) {
+		var frames []types.StackFrame
+		for _, ip := range c.stack(key.KernelStackID) {
+			name, ok := c.kernel.Lookup(ip)
+			if !ok {
+				name = fmt.Sprintf("0x%x", ip)
+			}
+			frames = append(frames, types.StackFrame{Symbol: name, Kernel: true})
+		}
+		for _, ip := range c.stack(key.UserStackID) {
+			frames = append(frames, types.StackFrame{Symbol: resolveUser(ip)})
+		}
+		stacks = append(stacks, types.StackCount{Frames: frames, Samples: count})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating stack counts: %w", err)
+	}
+	sort.SliceStable(stacks, func(i, j int) bool { return stacks[i].Samples > stacks[j].Samples })
+	return stacks, nil
+}
+
+// stack returns the instruction pointers stored under id, innermost first,
+// or nil for a negative (failed) or evicted id.
+func (c *Collector) stack(id int32) []uint64 {
+	if id < 0 {
+		return nil
+	}
+	var ips [maxStackDepth]uint64
+	if err := c.objs.Stacks.Lookup(uint32(id), &ips); err != nil {
+		return nil
+	}
+	n := 0
+	for n < len(ips) && ips[n] != 0 {
+		n++
+	}
+	return ips[:n]
+}
+
+// Reset clears the counts and the stacks they reference, so the stack map
+// does not fill with stacks of earlier windows and targets.
+func (c *Collector) Reset() error {
+	var keys []stackKey
+	iter := c.objs.StackCounts.Iterate()
+	var key stackKey
+	var count uint64
+	for iter.Next(&key, &count) {
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("iterating stack counts: %w", err)
+	}
+	for _, k := range keys {
+		if err := c.objs.StackCounts.Delete(&k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("clearing stack count: %w", err)
+		}
+		for _, id := range []int32{k.KernelStackID, k.UserStackID} {
+			if id < 0 {
+				continue
+			}
+			if err := c.objs.Stacks.Delete(uint32(id)); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("clearing stack %d: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close detaches the sampler and releases the BPF resources.
+func (c *Collector) Close() error {
+	var err error
+	for _, ev := range c.events {
+		err = errors.Join(err, ev.Close())
+	}
+	return errors.Join(err, c.objs.Close())
+}
+
+// stackKey mirrors the BPF struct stack_key in profile.c.
+type stackKey struct {
+	KernelStackID int32
+	UserStackID   int32
+}
@@ -0,0 +1,50 @@
+//go:build !linux
+// +build !linux
+
+package profile
+
+import (
+	"errors"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+var errUnsupported = errors.New("profile collector requires linux")
+
+// Collector is a placeholder on non-Linux platforms.
+type Collector struct{}
+
+// NewCollector returns an error because eBPF is only supported on Linux.
+func NewCollector(freq int) (*Collector, error) {
+	return nil, errUnsupported
+}
+
+// KernelSymbolsErr reports errUnsupported.
+func (c *Collector) KernelSymbolsErr() error {
+	return errUnsupported
+}
+
+// Target returns 0: nothing is sampled on unsupported platforms.
+func (c *Collector) Target() uint32 {
+	return 0
+}
+
+// SetTarget always fails on unsupported platforms.
+func (c *Collector) SetTarget(pid uint32) error {
+	return errUnsupported
+}
+
+// Snapshot always fails on unsupported platforms.
+func (c *Collector) Snapshot() ([]types.StackCount, error) {
+	return nil, errUnsupported
+}
+
+// Reset does nothing on unsupported platforms.
+func (c *Collector) Reset() error {
+	return nil
+}
+
+// Close is a no-op stub.
+func (c *Collector) Close() error {
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package profile
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProfileStubCollectorBehavior(t *testing.T) {
+	if _, err := NewCollector(0); !errors.Is(err, errUnsupported) {
+		t.Fatalf("expected errUnsupported, got %v", err)
+	}
+
+	var c Collector
+	if err := c.SetTarget(1); err != errUnsupported {
+		t.Fatalf("SetTarget should fail with errUnsupported, got %v", err)
+	}
+	if stacks, err := c.Snapshot(); err != errUnsupported || stacks != nil {
+		t.Fatalf("snapshot should fail with errUnsupported, got stacks=%v err=%v", stacks, err)
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatalf("reset should no-op, got %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close should no-op, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package profile samples the call stacks of one process (-profile-focus),
+// turning the Focus section's "who" into "where": the functions its CPU
+// time went to. A perf_event program records kernel and user stacks of the
+// target's threads at DefaultFrequency; the collector symbolizes them with
+// /proc/kallsyms and the ELF symbol tables of the process's mappings.
+package profile
+
+import (
+	"sort"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// DefaultFrequency is the sampling frequency in Hz per CPU. Slightly off a
+// round number, so sampling does not run in lockstep with periodic work.
+const DefaultFrequency = 99
+
+// FrameCount is how many samples one function accounted for.
+type FrameCount struct {
+	Frame types.StackFrame
+	Self  uint64 // samples with the CPU in this function
+	Total uint64 // samples with this function anywhere on the stack
+}
+
+// TopFrames returns the n functions with the most self samples in stacks,
+// most first (n <= 0 returns every function). A function that recurses is
+// counted once per stack in Total.
+func TopFrames(stacks []types.StackCount, n int) []FrameCount {
+	byFrame := make(map[types.StackFrame]*FrameCount)
+	get := func(f types.StackFrame) *FrameCount {
+		fc, ok := byFrame[f]
+		if !ok {
+			fc = &FrameCount{Frame: f}
+			byFrame[f] = fc
+		}
+		return fc
+	}
+	for _, st := range stacks {
+		if len(st.Frames) == 0 {
+			continue
+		}
+		get(st.Frames[0]).Self += st.Samples
+		seen := make(map[types.StackFrame]bool, len(st.Frames))
+		for _, f := range st.Frames {
+			if !seen[f] {
+				seen[f] = true
+				get(f).Total += st.Samples
+			}
+		}
+	}
+
+	frames := make([]FrameCount, 0, len(byFrame))
+	for _, fc := range byFrame {
+		if fc.Self > 0 {
+			frames = append(frames, *fc)
+		}
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		if frames[i].Self != frames[j].Self {
+			return frames[i].Self > frames[j].Self
+		}
+		if frames[i].Total != frames[j].Total {
+			return frames[i].Total > frames[j].Total
+		}
+		return frames[i].Frame.Symbol < frames[j].Frame.Symbol
+	})
+	if n > 0 && len(frames) > n {
+		frames = frames[:n]
+	}
+	return frames
+}
+
+// TotalSamples sums the samples of stacks.
+func TotalSamples(stacks []types.StackCount) uint64 {
+	var total uint64
+	for _, st := range stacks {
+		total += st.Samples
+	}
+	return total
+}
@@ -0,0 +1,42 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestTopFrames(t *testing.T) {
+	memcpy := types.StackFrame{Symbol: "copy_user_enhanced_fast_string", Kernel: true}
+	read := types.StackFrame{Symbol: "ksys_read", Kernel: true}
+	parse := types.StackFrame{Symbol: "main.parse"}
+	walk := types.StackFrame{Symbol: "main.walk"}
+	main := types.StackFrame{Symbol: "main.main"}
+	stacks := []types.StackCount{
+		{Frames: []types.StackFrame{parse, walk, main}, Samples: 40},
+		{Frames: []types.StackFrame{memcpy, read, walk, main}, Samples: 25},
+		{Frames: []types.StackFrame{walk, walk, main}, Samples: 10}, // recursion
+		{Frames: nil, Samples: 5},                                   // both stacks lost
+	}
+
+	if got := TotalSamples(stacks); got != 80 {
+		t.Fatalf("TotalSamples = %d; want 80", got)
+	}
+	top := TopFrames(stacks, 0)
+	want := []FrameCount{
+		{Frame: parse, Self: 40, Total: 40},
+		{Frame: memcpy, Self: 25, Total: 25},
+		{Frame: walk, Self: 10, Total: 75},
+	}
+	if len(top) != len(want) {
+		t.Fatalf("TopFrames = %+v; want %+v", top, want)
+	}
+	for i := range want {
+		if top[i] != want[i] {
+			t.Fatalf("TopFrames[%d] = %+v; want %+v", i, top[i], want[i])
+		}
+	}
+	if top := TopFrames(stacks, 1); len(top) != 1 || top[0].Frame != parse {
+		t.Fatalf("TopFrames(1) = %+v; want only main.parse", top)
+	}
+}
@@ -0,0 +1,283 @@
+package profile
+
+import (
+	"bufio"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kallsymsPath lists the kernel's symbols; tests read a fixture instead.
+var kallsymsPath = "/proc/kallsyms"
+
+// KernelSymbols resolves kernel addresses to function names.
+type KernelSymbols struct {
+	addrs []uint64 // ascending
+	names []string // names[i] starts at addrs[i]; module functions carry " [module]"
+}
+
+// LoadKernelSymbols reads /proc/kallsyms. Without CAP_SYSLOG, or with
+// kernel.kptr_restrict=2, the kernel lists every address as zero and
+// LoadKernelSymbols reports that instead of resolving every frame to the
+// same symbol.
+func LoadKernelSymbols() (*KernelSymbols, error) {
+	f, err := os.Open(kallsymsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseKallsyms(f)
+}
+
+func parseKallsyms(r io.Reader) (*KernelSymbols, error) {
+	type sym struct {
+		addr uint64
+		name string
+	}
+	var syms []sym
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		// ffffffff81000000 T _stext
+		// ffffffffc0a01000 t nf_hook_slow	[nf_tables]
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[1] {
+		case "t", "T", "w", "W": // text: functions, including weak ones
+		default:
+			continue
+		}
+		addr, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil || addr == 0 {
+			continue
+		}
+		name := fields[2]
+		if len(fields) > 3 {
+			name += " " + fields[3]
+		}
+		syms = append(syms, sym{addr, name})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(syms) == 0 {
+		return nil, errors.New("no kernel symbol addresses are visible (kernel.kptr_restrict, or not running as root)")
+	}
+	sort.SliceStable(syms, func(i, j int) bool { return syms[i].addr < syms[j].addr })
+	k := &KernelSymbols{addrs: make([]uint64, len(syms)), names: make([]string, len(syms))}
+	for i, s := range syms {
+		k.addrs[i], k.names[i] = s.addr, s.name
+	}
+	return k, nil
+}
+
+// Lookup returns the function containing addr. kallsyms has no sizes, so
+// an address past the last function of a region resolves to that function.
+func (k *KernelSymbols) Lookup(addr uint64) (string, bool) {
+	if k == nil {
+		return "", false
+	}
+	i := sort.Search(len(k.addrs), func(i int) bool { return k.addrs[i] > addr })
+	if i == 0 {
+		return "", false
+	}
+	return k.names[i-1], true
+}
+
+// mapping is one executable region of a process's address space.
+type mapping struct {
+	start, end uint64
+	offset     uint64 // file offset of start
+	path       string // as listed in /proc/PID/maps, e.g. /usr/lib/libc.so.6 or [vdso]
+}
+
+// elfSymbols are the function symbols of one ELF file and the segments
+// that map file offsets to their addresses.
+type elfSymbols struct {
+	loads []elf.ProgHeader
+	funcs []elf.Symbol // ascending by Value
+	gotab *gosym.Table // a Go binary's pclntab, which stripping (-s) keeps
+}
+
+// maxCachedFiles bounds userSymbols' cache of parsed ELF files.
+const maxCachedFiles = 64
+
+// userSymbols resolves user-space addresses of a process through its
+// /proc/PID/maps and the ELF symbol tables of the mapped files. Parsed
+// files are cached across windows; the maps are re-read per process.
+type userSymbols struct {
+	files map[string]*elfSymbols // by path under /proc/PID/root; nil when unreadable
+}
+
+func newUserSymbols() *userSymbols {
+	return &userSymbols{files: make(map[string]*elfSymbols)}
+}
+
+// resolver returns a function resolving addresses of pid. Addresses
+// outside every file mapping, and those of stripped files, resolve to
+// module+offset or a bare address.
+func (u *userSymbols) resolver(pid uint32) func(addr uint64) string {
+	maps, err := readMaps(pid)
+	if err != nil {
+		return func(addr uint64) string { return fmt.Sprintf("0x%x", addr) }
+	}
+	return func(addr uint64) string {
+		i := sort.Search(len(maps), func(i int) bool { return maps[i].end > addr })
+		if i == len(maps) || addr < maps[i].start {
+			return fmt.Sprintf("0x%x", addr)
+		}
+		m := maps[i]
+		if !strings.HasPrefix(m.path, "/") {
+			return m.path // [vdso], [anon:...], JIT regions
+		}
+		fileOff := addr - m.start + m.offset
+		// The file as the process sees it, which may be in another mount
+		// namespace (a container).
+		if name, ok := u.file(filepath.Join("/proc", strconv.Itoa(int(pid)), "root", m.path)).lookup(fileOff); ok {
+			return name
+		}
+		return fmt.Sprintf("%s+0x%x", filepath.Base(m.path), fileOff)
+	}
+}
+
+func (u *userSymbols) file(path string) *elfSymbols {
+	if syms, ok := u.files[path]; ok {
+		return syms
+	}
+	if len(u.files) >= maxCachedFiles {
+		clear(u.files)
+	}
+	syms, err := readELFSymbols(path)
+	if err != nil {
+		syms = nil // remembered, so a stripped or vanished file is not reopened
+	}
+	u.files[path] = syms
+	return syms
+}
+
+func readELFSymbols(path string) (*elfSymbols, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e := &elfSymbols{}
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD {
+			e.loads = append(e.loads, p.ProgHeader)
+		}
+	}
+	syms, err := f.Symbols()
+	if err != nil || len(syms) == 0 {
+		syms, _ = f.DynamicSymbols()
+	}
+	if len(syms) == 0 {
+		e.gotab = goSymbols(f)
+		if e.gotab == nil {
+			return nil, errors.New("no symbols")
+		}
+	}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value != 0 {
+			e.funcs = append(e.funcs, s)
+		}
+	}
+	sort.Slice(e.funcs, func(i, j int) bool { return e.funcs[i].Value < e.funcs[j].Value })
+	return e, nil
+}
+
+// goSymbols returns the function table of a Go binary, or nil for any other.
+func goSymbols(f *elf.File) *gosym.Table {
+	pclntab, text := f.Section(".gopclntab"), f.Section(".text")
+	if pclntab == nil || text == nil {
+		return nil
+	}
+	data, err := pclntab.Data()
+	if err != nil {
+		return nil
+	}
+	tab, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return nil
+	}
+	return tab
+}
+
+// lookup returns the function at fileOff: the offset is turned into the
+// address the file's symbols use through the loadable segment holding it.
+func (e *elfSymbols) lookup(fileOff uint64) (string, bool) {
+	if e == nil {
+		return "", false
+	}
+	var vaddr uint64
+	found := false
+	for _, p := range e.loads {
+		if fileOff >= p.Off && fileOff < p.Off+p.Filesz {
+			vaddr, found = fileOff-p.Off+p.Vaddr, true
+			break
+		}
+	}
+	if !found {
+		return "", false
+	}
+	if e.gotab != nil {
+		if fn := e.gotab.PCToFunc(vaddr); fn != nil {
+			return fn.Name, true
+		}
+		return "", false
+	}
+	i := sort.Search(len(e.funcs), func(i int) bool { return e.funcs[i].Value > vaddr })
+	if i == 0 {
+		return "", false
+	}
+	s := e.funcs[i-1]
+	if s.Size > 0 && vaddr >= s.Value+s.Size {
+		return "", false
+	}
+	return s.Name, true
+}
+
+// readMaps returns the executable mappings of pid, ascending.
+func readMaps(pid uint32) ([]mapping, error) {
+	f, err := os.Open(filepath.Join("/proc", strconv.Itoa(int(pid)), "maps"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var maps []mapping
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// 55d4a8e00000-55d4a8e28000 r-xp 00028000 08:01 1835 /usr/bin/bash
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			continue
+		}
+		var m mapping
+		var err1, err2, err3 error
+		m.start, err1 = strconv.ParseUint(start, 16, 64)
+		m.end, err2 = strconv.ParseUint(end, 16, 64)
+		m.offset, err3 = strconv.ParseUint(fields[2], 16, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		m.path = strings.Join(fields[5:], " ")
+		maps = append(maps, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(maps, func(i, j int) bool { return maps[i].start < maps[j].start })
+	return maps, nil
+}
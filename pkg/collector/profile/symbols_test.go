@@ -0,0 +1,78 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKallsyms(t *testing.T) {
+	k, err := parseKallsyms(strings.NewReader(`ffffffff81000000 T _stext
+ffffffff81001000 t do_one_initcall
+ffffffff81002000 D some_data
+ffffffff81003000 W weak_fn
+ffffffffc0a01000 t nf_hook_slow	[nf_tables]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		addr uint64
+		want string
+		ok   bool
+	}{
+		{0xffffffff80ffffff, "", false},
+		{0xffffffff81000000, "_stext", true},
+		{0xffffffff81002010, "do_one_initcall", true}, // data symbols are skipped
+		{0xffffffff81003004, "weak_fn", true},
+		{0xffffffffc0a01100, "nf_hook_slow [nf_tables]", true},
+	}
+	for _, tc := range cases {
+		if got, ok := k.Lookup(tc.addr); got != tc.want || ok != tc.ok {
+			t.Errorf("Lookup(%#x) = %q, %t; want %q, %t", tc.addr, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseKallsymsHiddenAddresses(t *testing.T) {
+	// What an unprivileged reader sees with kernel.kptr_restrict=1.
+	_, err := parseKallsyms(strings.NewReader("0000000000000000 T _stext\n0000000000000000 t do_one_initcall\n"))
+	if err == nil || !strings.Contains(err.Error(), "kptr_restrict") {
+		t.Fatalf("parseKallsyms = %v; want an error naming kptr_restrict", err)
+	}
+}
+
+func TestLoadKernelSymbolsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kallsyms")
+	if err := os.WriteFile(path, []byte("ffffffff81000000 T _stext\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := kallsymsPath
+	t.Cleanup(func() { kallsymsPath = old })
+	kallsymsPath = path
+	k, err := LoadKernelSymbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := k.Lookup(0xffffffff81000010); !ok || name != "_stext" {
+		t.Fatalf("Lookup = %q, %t; want _stext", name, ok)
+	}
+}
+
+func symbolizeMe() {}
+
+func TestUserSymbolsResolveOwnFunction(t *testing.T) {
+	if _, err := os.Stat("/proc/self/maps"); err != nil {
+		t.Skipf("no /proc: %v", err)
+	}
+	resolve := newUserSymbols().resolver(uint32(os.Getpid()))
+	pc := uint64(reflect.ValueOf(symbolizeMe).Pointer())
+	if got := resolve(pc); !strings.HasSuffix(got, "symbolizeMe") {
+		t.Fatalf("resolve(%#x) = %q; want this package's symbolizeMe", pc, got)
+	}
+	if got := resolve(1); got != "0x1" {
+		t.Fatalf("resolve(unmapped) = %q; want the bare address", got)
+	}
+}
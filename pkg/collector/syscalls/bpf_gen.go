@@ -0,0 +1,6 @@
+//go:build linux
+// +build linux
+
+package syscalls
+
+//go:generate bpf2go -cc clang -cflags "-O2 -g -D__TARGET_ARCH_x86" syscalls_bpf ../../../bpf/syscalls.c
@@ -0,0 +1,202 @@
+//go:build linux
+// +build linux
+
+// Package syscalls tracks per-process time spent in system calls, with the
+// call that took the most of it, to tell a process spinning in userspace
+// from one hammering (or blocked in) the kernel.
+package syscalls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+
+	"github.com/srodi/hotspot-bpf/pkg/bpfenv"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cgroupfilter"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+const resetSweepRetries = 3
+
+// Collector owns the eBPF programs on the raw_syscalls sys_enter and
+// sys_exit tracepoints.
+type Collector struct {
+	objs  syscalls_bpfObjects
+	enter link.Link
+	exit  link.Link
+}
+
+// NewCollector loads the syscall tracker and attaches both tracepoints.
+// Either one alone is useless, so failing to attach one is an error.
+func NewCollector() (*Collector, error) {
+	return NewCollectorForCgroup("")
+}
+
+// NewCollectorForCgroup is NewCollector counting only tasks in the cgroup v2
+// subtree at cgroupPath, checked in the kernel. An empty path counts every
+// task.
+func NewCollectorForCgroup(cgroupPath string) (*Collector, error) {
+	var objs syscalls_bpfObjects
+	spec, err := bpfenv.LoadSpec("syscalls_bpf", loadSyscalls_bpf, &objs)
+	if err != nil {
+		return nil, fmt.Errorf("loading syscall bpf spec: %w", err)
+	}
+	if err := cgroupfilter.LoadAndAssign(spec, &objs, cgroupPath); err != nil {
+		return nil, fmt.Errorf("loading syscall bpf objects: %w", err)
+	}
+
+	c := &Collector{objs: objs}
+	// Mark the window before attaching, so calls already in progress are
+	// not charged from boot.
+	if err := c.markWindowStart(); err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("marking window start: %w", err)
+	}
+	enter, err := link.AttachTracing(link.TracingOptions{Program: objs.HandleSysEnter})
+	if err != nil {
+		objs.Close()
+		return nil, fmt.Errorf("attaching sys_enter tracepoint failed: %w", err)
+	}
+	exit, err := link.AttachTracing(link.TracingOptions{Program: objs.HandleSysExit})
+	if err != nil {
+		enter.Close()
+		objs.Close()
+		return nil, fmt.Errorf("attaching sys_exit tracepoint failed: %w", err)
+	}
+	c.enter, c.exit = enter, exit
+	return c, nil
+}
+
+// Close releases the BPF resources.
+func (c *Collector) Close() error {
+	return errors.Join(c.enter.Close(), c.exit.Close(), c.objs.Close())
+}
+
+// Snapshot returns the processes that spent the most time in system calls
+// since the last reset.
+func (c *Collector) Snapshot(limit int) ([]types.SyscallStat, error) {
+	type nrTime struct {
+		nr int64
+		ns uint64
+	}
+	byPID := make(map[uint32]*types.SyscallStat)
+	top := make(map[uint32]nrTime)
+
+	iter := c.objs.SyscallTime.Iterate()
+	var key uint64
+	var perCPU []syscallTime
+	for iter.Next(&key, &perCPU) {
+		var total syscallTime
+		for _, t := range perCPU {
+			total.TotalNs += t.TotalNs
+			total.Count += t.Count
+		}
+		if total.Count == 0 {
+			continue
+		}
+		pid, nr := uint32(key>>32), int64(uint32(key))
+		s, ok := byPID[pid]
+		if !ok {
+			s = &types.SyscallStat{PID: pid}
+			byPID[pid] = s
+		}
+		s.TotalNs += total.TotalNs
+		s.Count += total.Count
+		if t, ok := top[pid]; !ok || total.TotalNs > t.ns || total.TotalNs == t.ns && nr < t.nr {
+			top[pid] = nrTime{nr: nr, ns: total.TotalNs}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating syscall map: %w", err)
+	}
+
+	stats := make([]types.SyscallStat, 0, len(byPID))
+	for pid, s := range byPID {
+		s.Comm = commForPID(pid)
+		s.TopSyscall = syscallName(top[pid].nr)
+		s.TopSyscallNs = top[pid].ns
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalNs != stats[j].TotalNs {
+			return stats[i].TotalNs > stats[j].TotalNs
+		}
+		return stats[i].PID < stats[j].PID
+	})
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// Reset clears the per-process totals for the next interval. Calls in
+// progress are charged from the new window's start when they return.
+func (c *Collector) Reset() error {
+	// Mark the window start first, so a call that returns between the two
+	// steps is not charged for time before the new window.
+	if err := c.markWindowStart(); err != nil {
+		return fmt.Errorf("marking window start: %w", err)
+	}
+	for attempt := 1; attempt <= resetSweepRetries; attempt++ {
+		iter := c.objs.SyscallTime.Iterate()
+		var key uint64
+		var perCPU []syscallTime
+		for iter.Next(&key, &perCPU) {
+			if err := c.objs.SyscallTime.Delete(&key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("clearing syscalls for pid %d: %w", key>>32, err)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			if errors.Is(err, ebpf.ErrIterationAborted) && attempt < resetSweepRetries {
+				continue
+			}
+			return fmt.Errorf("iterating syscall map: %w", err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// markWindowStart stores the current CLOCK_MONOTONIC time, the clock behind
+// bpf_ktime_get_ns, as the start of the new window.
+func (c *Collector) markWindowStart() error {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return err
+	}
+	key := uint32(0)
+	return c.objs.WindowStart.Put(&key, uint64(ts.Nano()))
+}
+
+// syscallName returns the name of system call nr, or "syscall N" for
+// numbers missing from the table.
+func syscallName(nr int64) string {
+	if name, ok := syscallNames[nr]; ok {
+		return name
+	}
+	return "syscall " + strconv.FormatInt(nr, 10)
+}
+
+// commForPID reads /proc/PID/comm, falling back to "pid-N" for processes
+// that exited since the window.
+func commForPID(pid uint32) string {
+	data, err := os.ReadFile("/proc/" + strconv.FormatUint(uint64(pid), 10) + "/comm")
+	if comm := strings.TrimSpace(string(data)); err == nil && comm != "" {
+		return comm
+	}
+	return fmt.Sprintf("pid-%d", pid)
+}
+
+// syscallTime mirrors the BPF struct syscall_time in syscalls.c. The map is
+// per-CPU, so each key reads as one copy per possible CPU.
+type syscallTime struct {
+	TotalNs uint64
+	Count   uint64
+}
@@ -0,0 +1,7 @@
+//go:build linux && !amd64
+
+package syscalls
+
+// syscallNames is empty where the BPF program is not built for the
+// architecture; syscalls are reported by number.
+var syscallNames = map[int64]string{}
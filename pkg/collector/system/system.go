@@ -0,0 +1,181 @@
+// Package system samples machine-wide CPU (including hypervisor steal),
+// interrupt, and memory utilization from /proc so per-process numbers can be
+// read against the state of the whole host.
+//
+// Unlike the eBPF collectors this package only reads procfs, so it needs no
+// privileges. CPU busy% is a delta between consecutive samples, which makes
+// the Sampler stateful.
+package system
+
+import (
+	"fmt"
+
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// Readers are package variables so tests can stub /proc access.
+var (
+	readCPUTimes    = procfs.ReadCPUTimes
+	readPerCPUTimes = procfs.ReadPerCPUTimes
+	readSoftIRQs    = procfs.ReadSoftIRQs
+	readMemInfo     = procfs.ReadMemInfo
+)
+
+// Sampler computes system CPU busy% and interrupt load between successive
+// calls to Sample.
+type Sampler struct {
+	prev        procfs.CPUTimes
+	hasPrev     bool
+	prevPerCPU  map[int]procfs.CPUTimes
+	prevSoftIRQ map[string]uint64
+}
+
+// NewSampler takes a baseline CPU reading so the first Sample covers the
+// interval since construction rather than since boot.
+func NewSampler() *Sampler {
+	s := &Sampler{}
+	if ct, err := readCPUTimes(); err == nil {
+		s.prev, s.hasPrev = ct, true
+	}
+	s.prevPerCPU, _ = readPerCPUTimes()
+	s.prevSoftIRQ, _ = readSoftIRQs()
+	return s
+}
+
+// Sample returns CPU busy% since the previous sample and current memory usage.
+// If /proc/stat cannot be read, CPUBusyPercent is 0 and the error is returned
+// alongside whatever memory data was available.
+func (s *Sampler) Sample() (types.SystemStat, error) {
+	var stat types.SystemStat
+
+	mem, memErr := readMemInfo()
+	if memErr == nil {
+		stat.MemTotalBytes = mem.Total
+		stat.MemAvailableBytes = mem.Available
+	}
+
+	ct, err := readCPUTimes()
+	if err != nil {
+		return stat, err
+	}
+	if s.hasPrev {
+		stat.CPUBusyPercent = busyPercent(s.prev, ct)
+		stat.CPU = cpuBreakdown(s.prev, ct)
+		stat.IRQPercent = sharePercent(s.prev, ct, func(c procfs.CPUTimes) uint64 { return c.IRQ })
+		stat.SoftIRQPercent = sharePercent(s.prev, ct, func(c procfs.CPUTimes) uint64 { return c.SoftIRQ })
+	}
+	s.prev, s.hasPrev = ct, true
+
+	// Interrupt load is often pinned to a few CPUs (e.g. one NIC queue), where
+	// the machine-wide share dilutes it, so also find the worst single CPU.
+	// Both readers are best-effort: the summary line does not depend on them.
+	if perCPU, err := readPerCPUTimes(); err == nil {
+		stat.PeakIRQCPU, stat.PeakIRQPercent = peakIRQ(s.prevPerCPU, perCPU)
+		s.prevPerCPU = perCPU
+	}
+	if softirqs, err := readSoftIRQs(); err == nil {
+		stat.TopSoftIRQ = topSoftIRQ(s.prevSoftIRQ, softirqs)
+		s.prevSoftIRQ = softirqs
+	}
+	return stat, memErr
+}
+
+// IRQNote explains a high interrupt load, or returns "" when neither the
+// machine-wide nor the busiest CPU's irq+softirq share reaches minPercent
+// (0 disables the note). Time spent in interrupts is charged to no process,
+// so without this note the victims in the contention table appear to be
+// preempted by nobody.
+func IRQNote(stat types.SystemStat, minPercent float64) string {
+	total := stat.IRQPercent + stat.SoftIRQPercent
+	if minPercent <= 0 || (total < minPercent && stat.PeakIRQPercent < minPercent) {
+		return ""
+	}
+	note := fmt.Sprintf("High IRQ load: %.1f%% of CPU time in interrupts (irq %.1f%%, softirq %.1f%%; CPU %d at %.1f%%",
+		total, stat.IRQPercent, stat.SoftIRQPercent, stat.PeakIRQCPU, stat.PeakIRQPercent)
+	if stat.TopSoftIRQ != "" {
+		note += ", mostly " + stat.TopSoftIRQ
+	}
+	return note + "); processes may be preempted by interrupt work, not by another process"
+}
+
+// StealWarning explains high steal time, or returns "" below minPercent (0
+// disables the warning). Steal is time a VM's CPUs were runnable but the
+// hypervisor ran another guest: the processes here wanted CPU and did not
+// get it, which no per-process number shows.
+func StealWarning(stat types.SystemStat, minPercent float64) string {
+	if minPercent <= 0 || stat.CPU.StealPercent < minPercent {
+		return ""
+	}
+	return fmt.Sprintf("High steal time: the hypervisor withheld %.1f%% of CPU time from this VM; "+
+		"processes were runnable but not running, so CPU%%, run-queue, and Starved diagnoses are unreliable. "+
+		"Check for a noisy neighbour, CPU credits, or an overcommitted host", stat.CPU.StealPercent)
+}
+
+// cpuBreakdown returns each kind of CPU time's share between two readings.
+func cpuBreakdown(prev, cur procfs.CPUTimes) types.SystemCPU {
+	share := func(field func(procfs.CPUTimes) uint64) float64 { return sharePercent(prev, cur, field) }
+	return types.SystemCPU{
+		UserPercent:   share(func(c procfs.CPUTimes) uint64 { return c.User + c.Nice }),
+		SystemPercent: share(func(c procfs.CPUTimes) uint64 { return c.System }),
+		IdlePercent:   share(func(c procfs.CPUTimes) uint64 { return c.Idle }),
+		IOWaitPercent: share(func(c procfs.CPUTimes) uint64 { return c.IOWait }),
+		StealPercent:  share(func(c procfs.CPUTimes) uint64 { return c.Steal }),
+	}
+}
+
+// sharePercent returns field's share of all CPU ticks between two readings.
+func sharePercent(prev, cur procfs.CPUTimes, field func(procfs.CPUTimes) uint64) float64 {
+	if cur.Total() <= prev.Total() || field(cur) < field(prev) {
+		return 0
+	}
+	return 100 * float64(field(cur)-field(prev)) / float64(cur.Total()-prev.Total())
+}
+
+// peakIRQ returns the CPU with the highest irq+softirq share between two
+// per-CPU readings. CPUs missing from either reading are skipped.
+func peakIRQ(prev, cur map[int]procfs.CPUTimes) (int, float64) {
+	irq := func(c procfs.CPUTimes) uint64 { return c.IRQ + c.SoftIRQ }
+	peakCPU, peak := 0, 0.0
+	for cpu, ct := range cur {
+		p, ok := prev[cpu]
+		if !ok {
+			continue
+		}
+		share := sharePercent(p, ct, irq)
+		if share > peak || (share == peak && cpu < peakCPU) {
+			peakCPU, peak = cpu, share
+		}
+	}
+	return peakCPU, peak
+}
+
+// topSoftIRQ returns the softirq type with the largest event delta, or ""
+// when nothing fired.
+func topSoftIRQ(prev, cur map[string]uint64) string {
+	top, topDelta := "", uint64(0)
+	for name, count := range cur {
+		before := prev[name]
+		if count < before {
+			continue
+		}
+		if delta := count - before; delta > topDelta || (delta == topDelta && delta > 0 && name < top) {
+			top, topDelta = name, delta
+		}
+	}
+	return top
+}
+
+// busyPercent returns the non-idle share of CPU ticks between two readings.
+// Counter resets (e.g. CPU hotplug) that make a delta negative yield 0.
+func busyPercent(prev, cur procfs.CPUTimes) float64 {
+	if cur.Total() <= prev.Total() || cur.IdleTotal() < prev.IdleTotal() {
+		return 0
+	}
+	total := float64(cur.Total() - prev.Total())
+	idle := float64(cur.IdleTotal() - prev.IdleTotal())
+	if idle > total {
+		return 0
+	}
+	return 100 * (total - idle) / total
+}
@@ -0,0 +1,183 @@
+package system
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+)
+
+func TestBusyPercent(t *testing.T) {
+	prev := procfs.CPUTimes{User: 100, System: 50, Idle: 850}
+	cur := procfs.CPUTimes{User: 160, System: 90, Idle: 950} // +100 busy, +100 idle
+	if got := busyPercent(prev, cur); math.Abs(got-50) > 1e-9 {
+		t.Fatalf("expected 50%% busy, got %f", got)
+	}
+	if got := busyPercent(cur, prev); got != 0 {
+		t.Fatalf("counter reset should yield 0, got %f", got)
+	}
+	if got := busyPercent(cur, cur); got != 0 {
+		t.Fatalf("no elapsed ticks should yield 0, got %f", got)
+	}
+}
+
+func TestSamplerUsesDeltas(t *testing.T) {
+	t.Cleanup(func() {
+		readCPUTimes = procfs.ReadCPUTimes
+		readMemInfo = procfs.ReadMemInfo
+	})
+	readings := []procfs.CPUTimes{
+		{User: 1000, Idle: 9000},
+		{User: 1075, Idle: 9025}, // 75% busy
+		{User: 1085, Idle: 9115}, // 10% busy
+	}
+	readCPUTimes = func() (procfs.CPUTimes, error) {
+		ct := readings[0]
+		readings = readings[1:]
+		return ct, nil
+	}
+	readMemInfo = func() (procfs.MemInfo, error) {
+		return procfs.MemInfo{Total: 8 << 30, Available: 2 << 30}, nil
+	}
+
+	s := NewSampler()
+	for _, want := range []float64{75, 10} {
+		stat, err := s.Sample()
+		if err != nil {
+			t.Fatalf("Sample: %v", err)
+		}
+		if math.Abs(stat.CPUBusyPercent-want) > 1e-9 {
+			t.Fatalf("expected %.0f%% busy, got %f", want, stat.CPUBusyPercent)
+		}
+		if stat.MemTotalBytes != 8<<30 || stat.MemAvailableBytes != 2<<30 {
+			t.Fatalf("unexpected memory: %+v", stat)
+		}
+	}
+}
+
+func TestSamplerReportsReadErrors(t *testing.T) {
+	t.Cleanup(func() {
+		readCPUTimes = procfs.ReadCPUTimes
+		readMemInfo = procfs.ReadMemInfo
+	})
+	boom := errors.New("boom")
+	readCPUTimes = func() (procfs.CPUTimes, error) { return procfs.CPUTimes{}, boom }
+	readMemInfo = func() (procfs.MemInfo, error) { return procfs.MemInfo{Total: 1 << 30}, nil }
+
+	stat, err := NewSampler().Sample()
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected cpu read error, got %v", err)
+	}
+	if stat.MemTotalBytes != 1<<30 {
+		t.Fatalf("memory should still be reported, got %+v", stat)
+	}
+}
+
+func TestSamplerIRQLoad(t *testing.T) {
+	t.Cleanup(func() {
+		readCPUTimes = procfs.ReadCPUTimes
+		readPerCPUTimes = procfs.ReadPerCPUTimes
+		readSoftIRQs = procfs.ReadSoftIRQs
+		readMemInfo = procfs.ReadMemInfo
+	})
+	total := []procfs.CPUTimes{
+		{User: 1000, Idle: 9000},
+		{User: 1100, Idle: 9800, IRQ: 20, SoftIRQ: 80}, // 10% in interrupts
+	}
+	perCPU := []map[int]procfs.CPUTimes{
+		{0: {Idle: 4500}, 1: {Idle: 4500}},
+		{0: {Idle: 4990, SoftIRQ: 10}, 1: {Idle: 4800, IRQ: 20, SoftIRQ: 180}}, // CPU 1 at 40%
+	}
+	softirqs := []map[string]uint64{
+		{"TIMER": 100, "NET_RX": 50},
+		{"TIMER": 150, "NET_RX": 5050},
+	}
+	readCPUTimes = func() (procfs.CPUTimes, error) {
+		ct := total[0]
+		total = total[1:]
+		return ct, nil
+	}
+	readPerCPUTimes = func() (map[int]procfs.CPUTimes, error) {
+		m := perCPU[0]
+		perCPU = perCPU[1:]
+		return m, nil
+	}
+	readSoftIRQs = func() (map[string]uint64, error) {
+		m := softirqs[0]
+		softirqs = softirqs[1:]
+		return m, nil
+	}
+	readMemInfo = func() (procfs.MemInfo, error) { return procfs.MemInfo{Total: 1 << 30}, nil }
+
+	stat, err := NewSampler().Sample()
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	if math.Abs(stat.IRQPercent-2) > 1e-9 || math.Abs(stat.SoftIRQPercent-8) > 1e-9 {
+		t.Fatalf("unexpected machine-wide shares: irq=%f softirq=%f", stat.IRQPercent, stat.SoftIRQPercent)
+	}
+	if stat.PeakIRQCPU != 1 || math.Abs(stat.PeakIRQPercent-40) > 1e-9 {
+		t.Fatalf("expected CPU 1 at 40%%, got CPU %d at %f", stat.PeakIRQCPU, stat.PeakIRQPercent)
+	}
+	if stat.TopSoftIRQ != "NET_RX" {
+		t.Fatalf("expected NET_RX to dominate, got %q", stat.TopSoftIRQ)
+	}
+
+	// The busiest CPU crosses the threshold even though the machine-wide share does not.
+	if note := IRQNote(stat, 25); !strings.Contains(note, "CPU 1 at 40.0%") || !strings.Contains(note, "NET_RX") {
+		t.Fatalf("unexpected note: %q", note)
+	}
+	if note := IRQNote(stat, 50); note != "" {
+		t.Fatalf("expected no note below threshold, got %q", note)
+	}
+	if note := IRQNote(stat, 0); note != "" {
+		t.Fatalf("a zero threshold disables the note, got %q", note)
+	}
+}
+
+func TestSamplerStealTime(t *testing.T) {
+	t.Cleanup(func() {
+		readCPUTimes = procfs.ReadCPUTimes
+		readMemInfo = procfs.ReadMemInfo
+	})
+	readings := []procfs.CPUTimes{
+		{User: 1000, System: 200, Idle: 8000, Steal: 50},
+		// +1000 ticks: 400 user+nice, 100 system, 50 iowait, 250 steal, 200 idle
+		{User: 1300, Nice: 100, System: 300, Idle: 8200, IOWait: 50, Steal: 300},
+	}
+	readCPUTimes = func() (procfs.CPUTimes, error) {
+		ct := readings[0]
+		readings = readings[1:]
+		return ct, nil
+	}
+	readMemInfo = func() (procfs.MemInfo, error) { return procfs.MemInfo{}, nil }
+
+	stat, err := NewSampler().Sample()
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	want := map[string][2]float64{
+		"user":   {stat.CPU.UserPercent, 40},
+		"system": {stat.CPU.SystemPercent, 10},
+		"iowait": {stat.CPU.IOWaitPercent, 5},
+		"steal":  {stat.CPU.StealPercent, 25},
+		"idle":   {stat.CPU.IdlePercent, 20},
+	}
+	for name, v := range want {
+		if math.Abs(v[0]-v[1]) > 1e-9 {
+			t.Fatalf("%s = %f%%, want %.0f%%", name, v[0], v[1])
+		}
+	}
+
+	if w := StealWarning(stat, 10); !strings.Contains(w, "25.0%") {
+		t.Fatalf("unexpected warning: %q", w)
+	}
+	if w := StealWarning(stat, 30); w != "" {
+		t.Fatalf("expected no warning below threshold, got %q", w)
+	}
+	if w := StealWarning(stat, 0); w != "" {
+		t.Fatalf("a zero threshold disables the warning, got %q", w)
+	}
+}
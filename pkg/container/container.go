@@ -0,0 +1,242 @@
+// Package container turns a process's cgroup path into the container and
+// Kubernetes pod it belongs to, so tables can show "checkout/api" instead of
+// "/kubepods.slice/kubepods-burstable.slice/...-pod3f1c....slice/cri-containerd-9b2e....scope".
+//
+// The container ID and pod UID are parsed from the path itself (cgroup v1
+// and v2, cgroupfs and systemd drivers). Names need a lookup: the pod name
+// comes from the kubelet's per-pod directory under /var/lib/kubelet/pods,
+// and the container name from the Docker Engine API on its Unix socket.
+// containerd and CRI-O only expose gRPC APIs, so their containers resolve to
+// a pod name but not a container name.
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ref is what a cgroup path alone reveals about a container.
+type Ref struct {
+	ID      string // full 64-hex-digit container ID
+	Runtime string // "docker", "containerd", "crio", "libpod", or "" when the path does not say
+	PodUID  string // Kubernetes pod UID with dashes; "" outside Kubernetes
+}
+
+var (
+	// scopeRE matches a systemd-driver leaf such as "cri-containerd-<id>.scope".
+	scopeRE = regexp.MustCompile(`^(docker|cri-containerd|crio|libpod)-([0-9a-f]{64})\.scope$`)
+	// idRE matches a cgroupfs-driver leaf, which is the bare ID.
+	idRE = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// podRE matches a pod cgroup in either driver: "pod<uid>" or
+	// "kubepods-burstable-pod<uid_with_underscores>.slice".
+	podRE = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+)
+
+// Parse extracts the container ID, runtime, and pod UID from a cgroup path.
+// It reports false when the path does not end in a container.
+func Parse(path string) (Ref, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	leaf := parts[len(parts)-1]
+	var ref Ref
+	switch {
+	case scopeRE.MatchString(leaf):
+		m := scopeRE.FindStringSubmatch(leaf)
+		ref.Runtime, ref.ID = strings.TrimPrefix(m[1], "cri-"), m[2]
+	case idRE.MatchString(leaf):
+		ref.ID = leaf
+		if len(parts) > 1 && parts[len(parts)-2] == "docker" {
+			ref.Runtime = "docker"
+		}
+	default:
+		return Ref{}, false
+	}
+	for _, p := range parts[:len(parts)-1] {
+		if m := podRE.FindStringSubmatch(p); m != nil {
+			ref.PodUID = strings.ReplaceAll(m[1], "_", "-")
+		}
+	}
+	return ref, true
+}
+
+// Names is the readable identity of a container. Either field may be empty
+// when it could not be resolved.
+type Names struct {
+	Container string
+	Pod       string // "namespace/name" when known, otherwise the pod's hostname
+}
+
+// negativeTTL is how long a failed lookup is remembered before it is retried,
+// so a stopped Docker daemon is not asked again every tick.
+const negativeTTL = time.Minute
+
+// socketTimeout bounds one Docker API request.
+const socketTimeout = time.Second
+
+// Resolver maps cgroup paths to container and pod names, caching every
+// answer per container ID. It is safe for concurrent use.
+type Resolver struct {
+	podsDir      string // kubelet per-pod state, one directory per pod UID
+	dockerSocket string
+	client       *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	names   Names
+	expires time.Time // zero for a complete answer, which never expires
+}
+
+// NewResolver creates a resolver using the default kubelet directory and
+// Docker socket.
+func NewResolver() *Resolver {
+	return newResolver("/var/lib/kubelet/pods", "/var/run/docker.sock")
+}
+
+func newResolver(podsDir, dockerSocket string) *Resolver {
+	return &Resolver{
+		podsDir:      podsDir,
+		dockerSocket: dockerSocket,
+		client: &http.Client{
+			Timeout: socketTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", dockerSocket)
+				},
+			},
+		},
+		cache: make(map[string]cached),
+	}
+}
+
+// Lookup returns the names for the first of paths (a process's cgroup paths,
+// one per hierarchy) that is a container. It reports false when none is or
+// nothing could be resolved, in which case callers should show the raw cgroup.
+func (r *Resolver) Lookup(paths []string) (Names, bool) {
+	for _, path := range paths {
+		ref, ok := Parse(path)
+		if !ok {
+			continue
+		}
+		names := r.resolve(ref)
+		return names, names != (Names{})
+	}
+	return Names{}, false
+}
+
+// resolve returns the cached names for ref, looking them up on a miss or
+// once a partial answer has expired.
+func (r *Resolver) resolve(ref Ref) Names {
+	now := time.Now()
+	r.mu.Lock()
+	c, ok := r.cache[ref.ID]
+	r.mu.Unlock()
+	if ok && (c.expires.IsZero() || now.Before(c.expires)) {
+		return c.names
+	}
+
+	var names Names
+	complete := true
+	if ref.PodUID != "" {
+		names.Pod = r.podName(ref.PodUID)
+		complete = names.Pod != ""
+	}
+	if ref.Runtime == "docker" || ref.Runtime == "" {
+		info, err := r.dockerInspect(ref.ID)
+		if err == nil {
+			names.Container = info.container
+			if info.pod != "" {
+				names.Pod = info.pod
+			}
+		} else {
+			complete = false
+		}
+	}
+
+	c = cached{names: names}
+	if !complete {
+		c.expires = now.Add(negativeTTL)
+	}
+	r.mu.Lock()
+	r.cache[ref.ID] = c
+	r.mu.Unlock()
+	return names
+}
+
+// podName reads the pod's name from the hosts file the kubelet writes for
+// it, whose last entry maps the pod IP to the pod hostname. That is the pod
+// name unless the pod sets spec.hostname.
+func (r *Resolver) podName(uid string) string {
+	f, err := os.Open(filepath.Join(r.podsDir, uid, "etc-hosts"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var name string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			name = fields[1]
+		}
+	}
+	if strings.HasPrefix(name, "ip6-") || name == "localhost" {
+		return "" // hostNetwork pods get the node's hosts file
+	}
+	return name
+}
+
+// dockerInfo is the part of a Docker inspect answer this package uses.
+type dockerInfo struct {
+	container string
+	pod       string
+}
+
+// dockerInspect asks the Docker Engine API for a container's name and, for
+// pods run through dockershim or cri-dockerd, its Kubernetes labels.
+func (r *Resolver) dockerInspect(id string) (dockerInfo, error) {
+	resp, err := r.client.Get("http://docker/containers/" + id + "/json")
+	if err != nil {
+		return dockerInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return dockerInfo{}, fmt.Errorf("docker inspect %s: %s", id[:12], resp.Status)
+	}
+	var body struct {
+		Name   string
+		Config struct {
+			Labels map[string]string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return dockerInfo{}, fmt.Errorf("docker inspect %s: %w", id[:12], err)
+	}
+	labels := body.Config.Labels
+	info := dockerInfo{container: strings.TrimPrefix(body.Name, "/")}
+	if name := labels["io.kubernetes.container.name"]; name != "" {
+		info.container = name
+	}
+	if pod := labels["io.kubernetes.pod.name"]; pod != "" {
+		info.pod = pod
+		if ns := labels["io.kubernetes.pod.namespace"]; ns != "" {
+			info.pod = ns + "/" + pod
+		}
+	}
+	return info, nil
+}
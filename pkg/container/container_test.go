@@ -0,0 +1,97 @@
+package container
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	id     = "9b2e4c1d0f3a5b6c7d8e9f0a1b2c3d4e5f60718293a4b5c6d7e8f9a0b1c2d3e4"
+	podUID = "3f1c2a4b-5d6e-4f70-8192-a3b4c5d6e7f8"
+)
+
+func TestParse(t *testing.T) {
+	systemdUID := strings.ReplaceAll(podUID, "-", "_")
+	cases := []struct {
+		path string
+		want Ref
+		ok   bool
+	}{
+		{"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + systemdUID + ".slice/cri-containerd-" + id + ".scope",
+			Ref{ID: id, Runtime: "containerd", PodUID: podUID}, true},
+		{"/kubepods/besteffort/pod" + podUID + "/" + id, Ref{ID: id, PodUID: podUID}, true},
+		{"/system.slice/docker-" + id + ".scope", Ref{ID: id, Runtime: "docker"}, true},
+		{"/docker/" + id, Ref{ID: id, Runtime: "docker"}, true},
+		{"/kubepods.slice/kubepods-pod" + systemdUID + ".slice/crio-" + id + ".scope", Ref{ID: id, Runtime: "crio", PodUID: podUID}, true},
+		{"/system.slice/nginx.service", Ref{}, false},
+		{"/", Ref{}, false},
+	}
+	for _, tc := range cases {
+		got, ok := Parse(tc.path)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("Parse(%q) = %+v, %t; want %+v, %t", tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func writeEtcHosts(t *testing.T, podsDir, uid, contents string) {
+	t.Helper()
+	dir := filepath.Join(podsDir, uid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "etc-hosts"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookupPodFromKubelet(t *testing.T) {
+	podsDir := t.TempDir()
+	writeEtcHosts(t, podsDir, podUID, "# Kubernetes-managed hosts file.\n127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost\n\n10.244.1.7\tcheckout-7d9f\n")
+	r := newResolver(podsDir, filepath.Join(t.TempDir(), "missing.sock"))
+
+	path := "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + strings.ReplaceAll(podUID, "-", "_") + ".slice/cri-containerd-" + id + ".scope"
+	names, ok := r.Lookup([]string{"/", path})
+	if !ok || names != (Names{Pod: "checkout-7d9f"}) {
+		t.Fatalf("Lookup = %+v, %t", names, ok)
+	}
+
+	if _, ok := r.Lookup([]string{"/system.slice/nginx.service"}); ok {
+		t.Fatal("a non-container cgroup should not resolve")
+	}
+}
+
+func TestLookupDockerCachesAnswers(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		if req.URL.Path != "/containers/"+id+"/json" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte(`{"Name":"/k8s_api_checkout","Config":{"Labels":{"io.kubernetes.container.name":"api","io.kubernetes.pod.name":"checkout-7d9f","io.kubernetes.pod.namespace":"shop"}}}`))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	r := newResolver(t.TempDir(), sock)
+	for range 3 {
+		names, ok := r.Lookup([]string{"/system.slice/docker-" + id + ".scope"})
+		if !ok || names != (Names{Container: "api", Pod: "shop/checkout-7d9f"}) {
+			t.Fatalf("Lookup = %+v, %t", names, ok)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("expected one Docker API request, got %d", n)
+	}
+}
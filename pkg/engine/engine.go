@@ -0,0 +1,275 @@
+// Package engine runs the hotspot-bpf collectors as a library. An Engine
+// loads the eBPF programs once and turns each sampling window into the same
+// per-process rows the TUI renders, without the terminal, filters, or
+// exporters of cmd/hotspot.
+//
+// Loading eBPF programs requires root (or CAP_BPF and CAP_PERFMON) and, on
+// kernels before 5.11, a raised RLIMIT_MEMLOCK; New raises the limit unless
+// Options.KeepMemlock is set.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/collector/blockio"
+	"github.com/srodi/hotspot-bpf/pkg/collector/cpu"
+	"github.com/srodi/hotspot-bpf/pkg/collector/futex"
+	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
+	"github.com/srodi/hotspot-bpf/pkg/collector/network"
+	"github.com/srodi/hotspot-bpf/pkg/collector/syscalls"
+	"github.com/srodi/hotspot-bpf/pkg/config"
+	"github.com/srodi/hotspot-bpf/pkg/report"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// Options configures New.
+type Options struct {
+	CPU        cpu.Options        // CPU accounting method (zero value = cpu.MethodSched)
+	Thresholds *config.Thresholds // classification thresholds (nil = config.Default())
+	// KeepMemlock leaves RLIMIT_MEMLOCK untouched, for callers that manage
+	// the limit themselves or run on kernels that charge BPF memory to the
+	// memory cgroup instead.
+	KeepMemlock bool
+	// Net attaches the network collector, whose probes fire on every TCP
+	// send and receive. Off by default, like -net in cmd/hotspot.
+	Net bool
+	// CgroupPath has every collector count only tasks in this cgroup v2
+	// subtree, checked in the kernel, like -cgroup-path in cmd/hotspot. It
+	// replaces CPU.CgroupPath.
+	CgroupPath string
+	// MinSeverity is the least severe diagnosis passed to observers (see
+	// report.Severity); 0 passes every diagnosis but OK.
+	MinSeverity int
+	// ObserverCooldown repeats a notification for a process that keeps its
+	// diagnosis once this long has passed since the last one. With 0 a
+	// process is only reported when it takes on the diagnosis.
+	ObserverCooldown time.Duration
+}
+
+// Engine owns the CPU, memory, block I/O, syscall, and futex collectors.
+// Block I/O, syscalls, and futexes are best-effort, as in cmd/hotspot:
+// without their tracepoints the rows have no I/O, syscall, or futex columns
+// and everything else works. The network collector is opt-in (Options.Net)
+// and best-effort as well.
+type Engine struct {
+	mu          sync.Mutex // serializes Collect: each call reads and resets one window
+	windowStart time.Time  // when the window in progress began
+	cpu         *cpu.Collector
+	mem         *memory.Collector
+	blockIO     *blockio.Collector // nil when blockIOErr is set
+	blockIOErr  error
+	syscalls    *syscalls.Collector // nil when syscallsErr is set
+	syscallsErr error
+	futex       *futex.Collector // nil when futexErr is set
+	futexErr    error
+	net         *network.Collector // nil when Options.Net is false or netErr is set
+	netErr      error
+	tracker     *report.RSSTracker
+	thresholds  config.Thresholds
+	observers   *Observers
+}
+
+// New loads and attaches the collectors. The caller must Close the Engine.
+// The eBPF objects are the ones built in, or those in the directory given to
+// bpfenv.SetObjectDir beforehand.
+func New(opts Options) (*Engine, error) {
+	if !opts.KeepMemlock {
+		if err := raiseMemlock(); err != nil {
+			return nil, fmt.Errorf("raising rlimit memlock: %w", err)
+		}
+	}
+	thresholds := config.Default()
+	if opts.Thresholds != nil {
+		thresholds = *opts.Thresholds
+	}
+
+	cpuOpts := opts.CPU
+	cpuOpts.CgroupPath = opts.CgroupPath
+	cpuCollector, err := cpu.NewCollectorWithOptions(cpuOpts)
+	if err != nil {
+		return nil, fmt.Errorf("initializing CPU collector: %w", err)
+	}
+	memCollector, err := memory.NewCollectorForCgroup(opts.CgroupPath)
+	if err != nil {
+		cpuCollector.Close()
+		return nil, fmt.Errorf("initializing memory collector: %w", err)
+	}
+	blockCollector, blockErr := blockio.NewCollectorForCgroup(opts.CgroupPath)
+	if blockErr != nil {
+		blockCollector = nil
+	}
+	syscallCollector, syscallErr := syscalls.NewCollectorForCgroup(opts.CgroupPath)
+	if syscallErr != nil {
+		syscallCollector = nil
+	}
+	futexCollector, futexErr := futex.NewCollectorForCgroup(opts.CgroupPath)
+	if futexErr != nil {
+		futexCollector = nil
+	}
+	var netCollector *network.Collector
+	var netErr error
+	if opts.Net {
+		if netCollector, netErr = network.NewCollectorForCgroup(opts.CgroupPath); netErr != nil {
+			netCollector = nil
+		}
+	}
+	e := &Engine{
+		cpu:         cpuCollector,
+		mem:         memCollector,
+		blockIO:     blockCollector,
+		blockIOErr:  blockErr,
+		syscalls:    syscallCollector,
+		syscallsErr: syscallErr,
+		futex:       futexCollector,
+		futexErr:    futexErr,
+		net:         netCollector,
+		netErr:      netErr,
+		tracker:     report.NewRSSTracker(thresholds.RSSTracker),
+		thresholds:  thresholds,
+		observers:   NewObservers(opts.MinSeverity, opts.ObserverCooldown),
+	}
+	// Start the first window now rather than at load time.
+	if err := e.reset(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// Close detaches the eBPF programs and releases their maps.
+func (e *Engine) Close() error {
+	errs := []error{e.cpu.Close(), e.mem.Close()}
+	if e.blockIO != nil {
+		errs = append(errs, e.blockIO.Close())
+	}
+	if e.syscalls != nil {
+		errs = append(errs, e.syscalls.Close())
+	}
+	if e.futex != nil {
+		errs = append(errs, e.futex.Close())
+	}
+	if e.net != nil {
+		errs = append(errs, e.net.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Collect waits until interval has passed since the previous window ended
+// (or since New), then returns one row per process active in the window,
+// classified with the Engine's thresholds, and starts the next window.
+// Windows are back to back, so nothing that happens between two calls is
+// lost; if the caller took longer than interval in between, the window is
+// longer and rates are computed over its actual length.
+//
+// Rows are unsorted and unfiltered; see report.SortRows and
+// report.FilterMetrics. RSS growth is tracked across calls, so call Collect
+// back to back at a steady interval for the trend columns to fill in.
+//
+// Collect returns ctx.Err() if ctx is done before the window ends; the
+// window keeps running for the next call. A subsystem that fails on its own
+// (block I/O, say) only leaves its columns empty; an error is returned only
+// when no subsystem produced data. Observers registered with Observe are
+// notified before Collect returns.
+func (e *Engine) Collect(ctx context.Context, interval time.Duration) ([]report.ProcMetrics, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %v", interval)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if wait := time.Until(e.windowStart.Add(interval)); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	window := time.Since(e.windowStart)
+	src := report.SnapshotSources{
+		CPU:         func() ([]types.CPUStat, error) { return e.cpu.Snapshot(0) },
+		Contention:  func() ([]types.ContentionStat, error) { return e.cpu.Contention(0) },
+		PageFaults:  func() ([]types.PageFaultStat, error) { return e.mem.Snapshot(0, window) },
+		RunqLatency: func() ([]types.RunqLatencyStat, error) { return e.cpu.RunqueueLatency(0) },
+		BlockIO: func() ([]types.BlockIOStat, error) {
+			if e.blockIO == nil {
+				return nil, e.blockIOErr
+			}
+			return e.blockIO.Snapshot(0)
+		},
+		Syscalls: func() ([]types.SyscallStat, error) {
+			if e.syscalls == nil {
+				return nil, e.syscallsErr
+			}
+			return e.syscalls.Snapshot(0)
+		},
+		Futex: func() ([]types.FutexStat, error) {
+			if e.futex == nil {
+				return nil, e.futexErr
+			}
+			return e.futex.Snapshot(0)
+		},
+	}
+	if e.net != nil || e.netErr != nil {
+		src.Net = func() ([]types.NetStat, error) {
+			if e.net == nil {
+				return nil, e.netErr
+			}
+			return e.net.Snapshot(0)
+		}
+	}
+	snap := report.CollectSnapshot(src)
+	resetErr := e.reset()
+	if err := snap.Err(); err != nil {
+		return nil, err
+	}
+	if resetErr != nil {
+		return nil, resetErr
+	}
+	// Rows are per process even when Options.CPU.Threads accounts per thread.
+	snap.CPU = report.ProcessTotals(snap.CPU)
+	rows, _ := report.BuildProcMetrics(snap, report.BuildOptions{Interval: window, Tracker: e.tracker, Thresholds: e.thresholds})
+	e.observers.Notify(rows, time.Now())
+	return rows, nil
+}
+
+// reset clears the collectors and starts a new window.
+func (e *Engine) reset() error {
+	e.windowStart = time.Now()
+	if err := e.cpu.Reset(); err != nil {
+		return fmt.Errorf("resetting CPU collector: %w", err)
+	}
+	if err := e.mem.Reset(); err != nil {
+		return fmt.Errorf("resetting memory collector: %w", err)
+	}
+	if e.blockIO != nil {
+		if err := e.blockIO.Reset(); err != nil {
+			return fmt.Errorf("resetting block io collector: %w", err)
+		}
+	}
+	if e.syscalls != nil {
+		if err := e.syscalls.Reset(); err != nil {
+			return fmt.Errorf("resetting syscall collector: %w", err)
+		}
+	}
+	if e.futex != nil {
+		if err := e.futex.Reset(); err != nil {
+			return fmt.Errorf("resetting futex collector: %w", err)
+		}
+	}
+	if e.net != nil {
+		if err := e.net.Reset(); err != nil {
+			return fmt.Errorf("resetting network collector: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCollectRejectsBadArguments(t *testing.T) {
+	var e Engine // no collectors: both cases must fail before touching them
+
+	if _, err := e.Collect(context.Background(), 0); err == nil {
+		t.Fatal("expected an error for a zero interval")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.Collect(ctx, time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
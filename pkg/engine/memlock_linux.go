@@ -0,0 +1,15 @@
+//go:build linux
+// +build linux
+
+package engine
+
+import "golang.org/x/sys/unix"
+
+// raiseMemlock lifts RLIMIT_MEMLOCK so BPF maps can be created on kernels
+// that still charge them against it (before 5.11).
+func raiseMemlock() error {
+	return unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{
+		Cur: unix.RLIM_INFINITY,
+		Max: unix.RLIM_INFINITY,
+	})
+}
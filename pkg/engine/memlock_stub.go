@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package engine
+
+// raiseMemlock is a no-op: the collectors fail to load on non-Linux anyway.
+func raiseMemlock() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+// Observer is notified of diagnosed processes, for alerting integrations
+// that would otherwise parse Collect's rows themselves. See Engine.Observe.
+type Observer interface {
+	// OnDiagnosis receives a row whose diagnosis is at least as severe as
+	// Options.MinSeverity (or the minSeverity of NewObservers). It is called
+	// from Collect, before Collect returns, so it should hand slow work (a
+	// webhook, a page) to another goroutine, and must not call Collect or
+	// Observe.
+	OnDiagnosis(row report.ProcMetrics)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(row report.ProcMetrics)
+
+// OnDiagnosis calls f(row).
+func (f ObserverFunc) OnDiagnosis(row report.ProcMetrics) {
+	f(row)
+}
+
+// Observe registers o to be notified by every later Collect. Observers are
+// called in the order they were registered.
+func (e *Engine) Observe(o Observer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers.Add(o)
+}
+
+// Observers passes each window's diagnosed rows to a list of observers,
+// debounced per process and diagnosis (see Options.MinSeverity and
+// Options.ObserverCooldown). Engine keeps one; programs that run their own
+// sampling loop, like cmd/hotspot, call Notify themselves.
+//
+// Observers is not safe for concurrent use.
+type Observers struct {
+	list     []Observer
+	debounce *debouncer
+}
+
+// NewObservers returns an empty list that reports diagnoses at least as
+// severe as minSeverity (0 = every diagnosis but OK), repeating them every
+// cooldown while a process keeps one (0 = only when it takes it on).
+func NewObservers(minSeverity int, cooldown time.Duration) *Observers {
+	return &Observers{debounce: newDebouncer(minSeverity, cooldown)}
+}
+
+// Add registers o. Observers are called in the order they were added.
+func (o *Observers) Add(obs Observer) {
+	o.list = append(o.list, obs)
+}
+
+// Notify passes the rows of one window, read at now, that are due to every
+// observer.
+func (o *Observers) Notify(rows []report.ProcMetrics, now time.Time) {
+	if len(o.list) == 0 {
+		return
+	}
+	for _, row := range o.debounce.due(rows, now) {
+		for _, obs := range o.list {
+			obs.OnDiagnosis(row)
+		}
+	}
+}
+
+// debounceKey identifies one process holding one diagnosis.
+type debounceKey struct {
+	pid       uint32
+	diagnosis string
+}
+
+// debouncer decides which rows are passed to observers: a process is
+// reported when it takes on a diagnosis, and again every cooldown while it
+// keeps it (never, with a zero cooldown). A process that loses the
+// diagnosis, or misses a window, is reported afresh when it comes back.
+type debouncer struct {
+	minSeverity int
+	cooldown    time.Duration
+	fired       map[debounceKey]time.Time // when each active pair was last reported
+}
+
+func newDebouncer(minSeverity int, cooldown time.Duration) *debouncer {
+	return &debouncer{minSeverity: max(minSeverity, 1), cooldown: cooldown, fired: make(map[debounceKey]time.Time)}
+}
+
+// due returns the rows of one window to report at now, and forgets every
+// pair that did not appear in it.
+func (d *debouncer) due(rows []report.ProcMetrics, now time.Time) []report.ProcMetrics {
+	var out []report.ProcMetrics
+	active := make(map[debounceKey]time.Time, len(d.fired))
+	for _, row := range rows {
+		if report.Severity(row.Diagnosis) < d.minSeverity {
+			continue
+		}
+		key := debounceKey{row.PID, row.Diagnosis}
+		last, seen := d.fired[key]
+		if !seen || d.cooldown > 0 && now.Sub(last) >= d.cooldown {
+			out = append(out, row)
+			last = now
+		}
+		active[key] = last
+	}
+	d.fired = active
+	return out
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+func pids(rows []report.ProcMetrics) []uint32 {
+	var out []uint32
+	for _, r := range rows {
+		out = append(out, r.PID)
+	}
+	return out
+}
+
+func TestDebouncerReportsTransitionsOnly(t *testing.T) {
+	d := newDebouncer(0, 0)
+	start := time.Unix(1000, 0)
+	rows := []report.ProcMetrics{
+		{PID: 1, Diagnosis: "OOM risk – memory growth"},
+		{PID: 2, Diagnosis: "OK"},
+		{PID: 3, Diagnosis: "Mem-thrashing"},
+	}
+	if got := pids(d.due(rows, start)); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("first window reported %v, want [1 3]", got)
+	}
+	if got := d.due(rows, start.Add(time.Hour)); len(got) != 0 {
+		t.Fatalf("unchanged diagnoses reported again without a cooldown: %v", pids(got))
+	}
+
+	// PID 3 changes diagnosis and PID 1 misses a window: both are new again
+	// once they show up.
+	rows[2].Diagnosis = "Starved"
+	if got := pids(d.due(rows[1:], start.Add(2*time.Hour))); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("changed diagnosis reported %v, want [3]", got)
+	}
+	if got := pids(d.due(rows, start.Add(3*time.Hour))); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("returning process reported %v, want [1]", got)
+	}
+}
+
+func TestDebouncerCooldownAndMinSeverity(t *testing.T) {
+	d := newDebouncer(report.Severity("Mem-thrashing"), time.Minute)
+	start := time.Unix(1000, 0)
+	rows := []report.ProcMetrics{
+		{PID: 1, Diagnosis: "OOM risk – memory growth"},
+		{PID: 2, Diagnosis: "Starved"},
+	}
+	for _, step := range []struct {
+		at   time.Duration
+		want int
+	}{
+		{0, 1},                // PID 1 only: Starved is below the threshold
+		{30 * time.Second, 0}, // within the cooldown
+		{time.Minute, 1},      // cooldown elapsed since the first report
+		{90 * time.Second, 0}, // counted from the repeat, not the first report
+	} {
+		got := pids(d.due(rows, start.Add(step.at)))
+		if len(got) != step.want || step.want == 1 && got[0] != 1 {
+			t.Fatalf("at +%v reported %v, want %d row(s) of PID 1", step.at, got, step.want)
+		}
+	}
+}
+
+func TestObserveCallsEveryObserverInOrder(t *testing.T) {
+	e := Engine{observers: NewObservers(0, 0)}
+	var calls []string
+	e.Observe(ObserverFunc(func(row report.ProcMetrics) { calls = append(calls, "first:"+row.Comm) }))
+	e.Observe(ObserverFunc(func(row report.ProcMetrics) { calls = append(calls, "second:"+row.Comm) }))
+
+	rows := []report.ProcMetrics{{PID: 8, Comm: "sshd", Diagnosis: "OK"}, {PID: 9, Comm: "java", Diagnosis: "Mem-thrashing"}}
+	e.observers.Notify(rows, time.Now())
+	e.observers.Notify(rows, time.Now())
+	if len(calls) != 2 || calls[0] != "first:java" || calls[1] != "second:java" {
+		t.Fatalf("calls = %v", calls)
+	}
+}
@@ -0,0 +1,101 @@
+// Package export writes one snapshot in several formats at once, so a
+// forensic capture has a human-readable, a machine-readable, and a
+// spreadsheet-friendly view of exactly the same window. It also streams a
+// compact row set every interval for -format csv.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/srodi/hotspot-bpf/pkg/recorder"
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+// File names written by WriteDir.
+const (
+	TextFile = "snapshot.txt"
+	JSONFile = "snapshot.json"
+	CSVFile  = "snapshot.csv"
+)
+
+// csvHeader lists the CSV columns, one per ProcMetrics field worth charting.
+var csvHeader = []string{
+	"pid", "comm", "cgroup", "pod", "container",
+	"cpu_ms", "off_cpu_ms", "cpu_percent", "core_cpu_percent", "cpu_percent_max", "cpu_percent_avg", "last_core",
+	"rss_mb", "rss_min_mb", "rss_max_mb", "hugepages_mb", "faults", "faults_per_sec", "cost_per_fault_ms", "mem_efficiency",
+	"major_faults_per_sec", "major_fault_latency_ms",
+	"preempted", "preempts_others", "runq_wait_ms", "runq_max_ms",
+	"tlb_shootdowns_per_sec", "swap_ins_per_sec", "swap_outs_per_sec", "switches_per_sec", "switch_loss_percent",
+	"vol_ctx_switches", "invol_ctx_switches", "state", "d_state_intervals", "rss_slope_mb_per_min",
+	"diagnosis",
+}
+
+// WriteJSON writes s as a single indented JSON document. The schema is the
+// same as one line of a flight recorder dump.
+func WriteJSON(w io.Writer, s recorder.Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteCSV writes one row per process, preceded by a header row.
+func WriteCSV(w io.Writer, rows []report.ProcMetrics) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, row := range rows {
+		record := []string{
+			strconv.FormatUint(uint64(row.PID), 10), row.Comm, row.Cgroup, row.PodName, row.ContainerName,
+			f(row.CPUMs), f(row.OffCPUMs), f(row.CPUPercent), f(row.CoreCPUPercent), f(row.CPUPercentMax), f(row.CPUPercentAvg), strconv.FormatUint(uint64(row.CPUCore), 10),
+			f(row.RSSMB), f(row.RSSMinMB), f(row.RSSMaxMB), f(row.HugepagesMB), strconv.FormatUint(row.Faults, 10), f(row.FaultsPerSec), f(row.CPUCostPerFault), f(row.MemEfficiency),
+			f(row.MajorFaultsPerSec), f(row.MajorFaultLatencyMs),
+			strconv.FormatUint(row.Preempted, 10), strconv.FormatUint(row.PreemptsOthers, 10), f(row.RunqWaitMs), f(row.RunqMaxMs),
+			f(row.TLBShootdownsPerSec), f(row.SwapInsPerSec), f(row.SwapOutsPerSec), f(row.SwitchesPerSec), f(row.SwitchLossPercent),
+			strconv.FormatUint(row.VolCtxSwitches, 10), strconv.FormatUint(row.InvolCtxSwitches, 10), row.State, strconv.Itoa(row.DStateIntervals), f(row.RSSSlopeMBPerMin),
+			row.Diagnosis,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDir writes text, s as JSON, and s.Processes as CSV to TextFile,
+// JSONFile, and CSVFile in dir, replacing any previous capture there.
+func WriteDir(dir, text string, s recorder.Snapshot) error {
+	writers := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{TextFile, func(w io.Writer) error { _, err := io.WriteString(w, text); return err }},
+		{JSONFile, func(w io.Writer) error { return WriteJSON(w, s) }},
+		{CSVFile, func(w io.Writer) error { return WriteCSV(w, s.Processes) }},
+	}
+	for _, out := range writers {
+		if err := writeFile(filepath.Join(dir, out.name), out.write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/recorder"
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+func TestWriteDirWritesAllFormats(t *testing.T) {
+	dir := t.TempDir()
+	snap := recorder.Snapshot{
+		Time:     time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Interval: 5 * time.Second,
+		Processes: []report.ProcMetrics{
+			{PID: 10, Comm: "postgres", Cgroup: "db.service", CPUMs: 120.5, Faults: 900, Diagnosis: "Mem-thrashing"},
+			{PID: 11, Comm: "nginx, worker", CPUMs: 3, Diagnosis: "OK"},
+		},
+	}
+	if err := WriteDir(dir, "hotspot-bpf\nPID COMM\n", snap); err != nil {
+		t.Fatalf("WriteDir: %v", err)
+	}
+
+	text, err := os.ReadFile(filepath.Join(dir, TextFile))
+	if err != nil || string(text) != "hotspot-bpf\nPID COMM\n" {
+		t.Fatalf("text file = %q, %v", text, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, JSONFile))
+	if err != nil {
+		t.Fatalf("reading JSON: %v", err)
+	}
+	var decoded recorder.Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decoding JSON: %v", err)
+	}
+	if len(decoded.Processes) != 2 || decoded.Processes[0].Faults != 900 || !decoded.Time.Equal(snap.Time) {
+		t.Fatalf("JSON does not match snapshot: %+v", decoded)
+	}
+
+	f, err := os.Open(filepath.Join(dir, CSVFile))
+	if err != nil {
+		t.Fatalf("opening CSV: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing CSV: %v", err)
+	}
+	if len(records) != 3 || len(records[0]) != len(csvHeader) {
+		t.Fatalf("expected header + 2 rows of %d columns, got %v", len(csvHeader), records)
+	}
+	if records[1][0] != "10" || records[1][5] != "120.5" || records[1][len(csvHeader)-1] != "Mem-thrashing" {
+		t.Fatalf("unexpected first row: %v", records[1])
+	}
+	if records[2][1] != "nginx, worker" {
+		t.Fatalf("comm with a comma not preserved: %v", records[2])
+	}
+}
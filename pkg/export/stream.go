@@ -0,0 +1,103 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+// Column is one field of the per-interval row formats. Value returns a
+// native Go value (string, integer, float64) so encoders that keep types,
+// such as JSON, and text encoders such as CSV share one column set.
+type Column struct {
+	Name  string
+	Value func(ts time.Time, row report.ProcMetrics) any
+}
+
+// StreamColumns is the stable column set for -format csv: one row per
+// process per interval. Append new columns at the end; consumers index by
+// position as well as by name.
+var StreamColumns = []Column{
+	{"timestamp", func(ts time.Time, _ report.ProcMetrics) any { return ts.UTC().Format(time.RFC3339) }},
+	{"pid", func(_ time.Time, r report.ProcMetrics) any { return r.PID }},
+	{"comm", func(_ time.Time, r report.ProcMetrics) any { return r.Comm }},
+	{"cgroup", func(_ time.Time, r report.ProcMetrics) any { return r.Cgroup }},
+	{"cpu_ms", func(_ time.Time, r report.ProcMetrics) any { return r.CPUMs }},
+	{"cpu_pct", func(_ time.Time, r report.ProcMetrics) any { return r.CPUPercent }},
+	{"rss_mb", func(_ time.Time, r report.ProcMetrics) any { return r.RSSMB }},
+	{"faults", func(_ time.Time, r report.ProcMetrics) any { return r.Faults }},
+	{"faults_per_sec", func(_ time.Time, r report.ProcMetrics) any { return r.FaultsPerSec }},
+	{"cpu_cost_per_fault", func(_ time.Time, r report.ProcMetrics) any { return r.CPUCostPerFault }},
+	{"preempted", func(_ time.Time, r report.ProcMetrics) any { return r.Preempted }},
+	{"preempts_others", func(_ time.Time, r report.ProcMetrics) any { return r.PreemptsOthers }},
+	{"diagnosis", func(_ time.Time, r report.ProcMetrics) any { return r.Diagnosis }},
+	{"cmdline", func(_ time.Time, r report.ProcMetrics) any { return r.Cmdline }},
+	{"vol_ctx_switches", func(_ time.Time, r report.ProcMetrics) any { return r.VolCtxSwitches }},
+	{"invol_ctx_switches", func(_ time.Time, r report.ProcMetrics) any { return r.InvolCtxSwitches }},
+	{"state", func(_ time.Time, r report.ProcMetrics) any { return r.State }},
+	{"d_state_intervals", func(_ time.Time, r report.ProcMetrics) any { return r.DStateIntervals }},
+	{"rss_slope_mb_per_min", func(_ time.Time, r report.ProcMetrics) any { return r.RSSSlopeMBPerMin }},
+	{"cpu_pct_max", func(_ time.Time, r report.ProcMetrics) any { return r.CPUPercentMax }},
+	{"cpu_pct_avg", func(_ time.Time, r report.ProcMetrics) any { return r.CPUPercentAvg }},
+	{"mem_efficiency", func(_ time.Time, r report.ProcMetrics) any { return r.MemEfficiency }},
+}
+
+// CSVStream writes StreamColumns as CSV: the header once, before the first
+// rows, then rows appended every interval, so the output of a whole run is
+// one valid file. It is not safe for concurrent use.
+type CSVStream struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSVStream creates a stream writing to w.
+func NewCSVStream(w io.Writer) *CSVStream {
+	return &CSVStream{w: csv.NewWriter(w)}
+}
+
+// Write appends one row per process captured at ts and flushes, so a reader
+// tailing the file sees each interval as soon as it is written.
+func (s *CSVStream) Write(ts time.Time, rows []report.ProcMetrics) error {
+	if !s.wroteHeader {
+		header := make([]string, len(StreamColumns))
+		for i, col := range StreamColumns {
+			header[i] = col.Name
+		}
+		if err := s.w.Write(header); err != nil {
+			return err
+		}
+		s.wroteHeader = true
+	}
+	record := make([]string, len(StreamColumns))
+	for _, row := range rows {
+		for i, col := range StreamColumns {
+			record[i] = formatValue(col.Value(ts, row))
+		}
+		if err := s.w.Write(record); err != nil {
+			return err
+		}
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// formatValue renders a column value as CSV text. Floats use the shortest
+// representation that round-trips, as in WriteCSV.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
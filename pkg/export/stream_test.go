@@ -0,0 +1,47 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+func TestCSVStreamWritesHeaderOnce(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewCSVStream(&buf)
+	t0 := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := s.Write(t0, []report.ProcMetrics{
+		{PID: 10, Comm: "postgres", Cgroup: "db.service", CPUMs: 120.5, CPUPercent: 2.5, Faults: 900, Preempted: 3, Diagnosis: "Mem-thrashing"},
+	}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Write(t0.Add(5*time.Second), []report.ProcMetrics{
+		{PID: 11, Comm: "nginx, worker", Diagnosis: "OK"},
+	}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %v", records)
+	}
+	want := []string{"timestamp", "pid", "comm", "cgroup", "cpu_ms", "cpu_pct", "rss_mb", "faults", "faults_per_sec", "cpu_cost_per_fault", "preempted", "preempts_others", "diagnosis"}
+	for i, name := range want {
+		if records[0][i] != name {
+			t.Fatalf("header changed: %v", records[0])
+		}
+	}
+	first := records[1]
+	if first[0] != "2026-03-04T05:06:07Z" || first[1] != "10" || first[4] != "120.5" || first[5] != "2.5" || first[10] != "3" || first[12] != "Mem-thrashing" {
+		t.Fatalf("unexpected first row: %v", first)
+	}
+	if records[2][0] != "2026-03-04T05:06:12Z" || records[2][2] != "nginx, worker" {
+		t.Fatalf("unexpected second row: %v", records[2])
+	}
+}
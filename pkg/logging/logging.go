@@ -0,0 +1,103 @@
+// Package logging sets up hotspot's own diagnostics — failed snapshots and
+// resets, probes that did not attach, undelivered alerts — as log/slog
+// records, and keeps them off the terminal while the alternate-screen view
+// owns it.
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Output formats accepted by New, as given to -log-format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// MaxHeld is how many records a Holder keeps while held. Older records are
+// dropped first, and their number is reported when the Holder is released.
+const MaxHeld = 1000
+
+// New returns a logger writing records to w in format, FormatText
+// (key=value pairs) or FormatJSON (one object per line).
+func New(w io.Writer, format string) (*slog.Logger, error) {
+	switch format {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want %s or %s)", format, FormatText, FormatJSON)
+	}
+}
+
+// Fatalf is log.Fatalf for the default slog logger: it logs the formatted
+// message at error level and exits with status 1.
+func Fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// Holder passes writes through to an underlying writer, except between Hold
+// and Release, when it keeps them in memory instead. Handlers write each
+// record in a single call, so a held write is one whole record. It is safe
+// for concurrent use.
+type Holder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	held    bool
+	records [][]byte
+	dropped int
+}
+
+// NewHolder returns a Holder writing to w.
+func NewHolder(w io.Writer) *Holder {
+	return &Holder{w: w}
+}
+
+// Write implements io.Writer.
+func (h *Holder) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.held {
+		return h.w.Write(p)
+	}
+	if len(h.records) == MaxHeld {
+		h.records = append(h.records[:0], h.records[1:]...)
+		h.dropped++
+	}
+	h.records = append(h.records, bytes.Clone(p))
+	return len(p), nil
+}
+
+// Hold keeps every following write in memory until Release.
+func (h *Holder) Hold() {
+	h.mu.Lock()
+	h.held = true
+	h.mu.Unlock()
+}
+
+// Release writes the held records to the underlying writer, oldest first,
+// preceded by a line counting any that were dropped, and passes writes
+// through again.
+func (h *Holder) Release() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.held = false
+	var err error
+	if h.dropped > 0 {
+		_, err = fmt.Fprintf(h.w, "(%d earlier log records dropped)\n", h.dropped)
+	}
+	for _, rec := range h.records {
+		if _, werr := h.w.Write(rec); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	h.records, h.dropped = nil, 0
+	return err
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewFormats(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("reset failed", "collector", "memory", "err", errors.New("map busy"))
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("record %q is not JSON: %v", buf.String(), err)
+	}
+	if rec["level"] != "WARN" || rec["msg"] != "reset failed" || rec["collector"] != "memory" || rec["err"] != "map busy" {
+		t.Fatalf("record = %v", rec)
+	}
+
+	buf.Reset()
+	if logger, err = New(&buf, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	logger.Error("snapshot failed", "err", "boom")
+	if got := buf.String(); !strings.Contains(got, `level=ERROR msg="snapshot failed" err=boom`) {
+		t.Fatalf("text record = %q", got)
+	}
+
+	if _, err := New(&buf, "xml"); err == nil {
+		t.Fatal("New accepted format xml")
+	}
+}
+
+func TestHolder(t *testing.T) {
+	var out bytes.Buffer
+	h := NewHolder(&out)
+	fmt.Fprint(h, "a\n")
+	if out.String() != "a\n" {
+		t.Fatalf("unheld write: got %q", out.String())
+	}
+
+	h.Hold()
+	fmt.Fprint(h, "b\n")
+	fmt.Fprint(h, "c\n")
+	if out.String() != "a\n" {
+		t.Fatalf("held writes reached the writer: %q", out.String())
+	}
+	if err := h.Release(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(h, "d\n")
+	if got := out.String(); got != "a\nb\nc\nd\n" {
+		t.Fatalf("after release: got %q", got)
+	}
+}
+
+func TestHolderDropsOldest(t *testing.T) {
+	var out bytes.Buffer
+	h := NewHolder(&out)
+	h.Hold()
+	for i := range MaxHeld + 2 {
+		fmt.Fprintf(h, "%d\n", i)
+	}
+	if err := h.Release(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != MaxHeld+1 || lines[0] != "(2 earlier log records dropped)" || lines[1] != "2" {
+		t.Fatalf("got %d lines starting %q", len(lines), lines[:2])
+	}
+}
@@ -0,0 +1,287 @@
+// Package procfs reads per-process attributes (such as a process's start time)
+// and system-wide counters from /proc that the eBPF collectors do not capture.
+//
+// All readers are best-effort: a process may exit between the BPF snapshot and
+// the /proc read, so callers must treat errors as "unknown" rather than fatal.
+package procfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// root is the procfs mount point; tests point it at a fixture directory.
+var root = "/proc"
+
+// Stat holds the fields of /proc/PID/stat used by hotspot-bpf.
+type Stat struct {
+	PID       int
+	Comm      string
+	State     byte
+	PPID      int
+	UTime     uint64 // user CPU time in clock ticks
+	STime     uint64 // system CPU time in clock ticks
+	StartTime uint64 // start time in clock ticks since boot
+	Processor int    // CPU the task last ran on
+}
+
+// ReadStat parses /proc/PID/stat.
+func ReadStat(pid int) (Stat, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return Stat{}, err
+	}
+	return parseStat(data)
+}
+
+// StartTime returns the process start time in clock ticks since boot.
+// Together with the PID it uniquely identifies a process instance, which
+// lets cross-interval state survive PID reuse.
+func StartTime(pid int) (uint64, error) {
+	st, err := ReadStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	return st.StartTime, nil
+}
+
+// PIDs lists the process IDs currently present in /proc.
+func PIDs() ([]int, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if pid, err := strconv.Atoi(e.Name()); err == nil && pid > 0 {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// CgroupPath returns the process's cgroup v2 path (the "0::" line of
+// /proc/PID/cgroup), e.g. "/system.slice/nginx.service".
+func CgroupPath(pid int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry for pid %d", pid)
+}
+
+// CgroupPaths returns the process's path in every cgroup hierarchy listed in
+// /proc/PID/cgroup, v2 first, without duplicates. On a cgroup v1 or hybrid
+// host the v2 entry is often just "/", and the container shows up only in
+// the v1 controller hierarchies.
+func CgroupPaths(pid int) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		// hierarchy-ID:controller-list:path; the path may contain colons.
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 || seen[fields[2]] {
+			continue
+		}
+		seen[fields[2]] = true
+		if fields[0] == "0" {
+			paths = append([]string{fields[2]}, paths...)
+		} else {
+			paths = append(paths, fields[2])
+		}
+	}
+	return paths, nil
+}
+
+// HugetlbBytes returns the process's explicit hugetlbfs usage (the
+// "HugetlbPages" line of /proc/PID/status). These pages are not part of RSS,
+// so processes backed by explicit hugepages (databases, VMs) otherwise look
+// far smaller than they are.
+func HugetlbBytes(pid int) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(line, "HugetlbPages:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			break
+		}
+		kb, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing HugetlbPages: %w", err)
+		}
+		return kb * 1024, nil
+	}
+	// Kernels without CONFIG_HUGETLB_PAGE omit the line: nothing to count.
+	return 0, nil
+}
+
+// AllowedCPUs returns how many CPUs the process may run on (the
+// "Cpus_allowed_list" line of /proc/PID/status). The list is the process's
+// affinity mask already narrowed by its cgroup's cpuset, so a container
+// limited with cpuset.cpus and a process pinned with taskset both count
+// only the CPUs they can use.
+func AllowedCPUs(pid int) (int, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "Cpus_allowed_list:"); ok {
+			return parseCPUList(strings.TrimSpace(value))
+		}
+	}
+	return 0, fmt.Errorf("no Cpus_allowed_list for pid %d", pid)
+}
+
+// NSPid returns the process's PID in its own PID namespace: the last entry
+// of the "NSpid" line of /proc/PID/status, which lists the PID in every
+// namespace from the reader's down to the process's. For a process in a
+// container that is the PID kubectl exec and docker top show; outside one
+// it equals pid. Kernels before 4.1 have no NSpid line.
+func NSPid(pid int) (uint32, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(line, "NSpid:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			break
+		}
+		nspid, err := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("parsing NSpid: %w", err)
+		}
+		return uint32(nspid), nil
+	}
+	return 0, fmt.Errorf("no NSpid for pid %d", pid)
+}
+
+// parseCPUList counts the CPUs in a kernel CPU list such as "0-3,8,10-11".
+func parseCPUList(list string) (int, error) {
+	n := 0
+	for _, part := range strings.Split(list, ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0, fmt.Errorf("parsing cpu list %q: %w", list, err)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil {
+				return 0, fmt.Errorf("parsing cpu list %q: %w", list, err)
+			}
+		}
+		if last < first {
+			return 0, fmt.Errorf("parsing cpu list %q: range %s is reversed", list, part)
+		}
+		n += last - first + 1
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("empty cpu list")
+	}
+	return n, nil
+}
+
+// ExePath returns the full path of the process's executable (the target of
+// /proc/PID/exe). Reading it fails for kernel threads, which have no
+// executable, and for other users' processes without CAP_SYS_PTRACE.
+func ExePath(pid int) (string, error) {
+	return os.Readlink(filepath.Join(root, strconv.Itoa(pid), "exe"))
+}
+
+// Cmdline returns the process's arguments from /proc/PID/cmdline, which
+// separates them with NUL bytes. Kernel threads and zombies have an empty
+// command line and return no arguments.
+func Cmdline(pid int) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(data), "\x00"), nil
+}
+
+// ThreadComm returns the name of thread tid of process pid, from
+// /proc/PID/task/TID/comm. Threads are named separately from their process
+// (pthread_setname_np), so a worker pool's threads can each have their own.
+func ThreadComm(pid, tid int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "task", strconv.Itoa(tid), "comm"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// parseStat decodes the contents of a /proc/PID/stat file. The comm field is
+// wrapped in parentheses and may itself contain spaces or parentheses, so the
+// remaining fields are located from the LAST closing parenthesis.
+func parseStat(data []byte) (Stat, error) {
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return Stat{}, fmt.Errorf("malformed stat: missing comm")
+	}
+	var st Stat
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data[:open])))
+	if err != nil {
+		return Stat{}, fmt.Errorf("malformed stat pid: %w", err)
+	}
+	st.PID = pid
+	st.Comm = string(data[open+1 : end])
+
+	// fields[0] is field 3 (state) in proc(5) numbering.
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return Stat{}, fmt.Errorf("malformed stat: %d fields after comm", len(fields))
+	}
+	st.State = fields[0][0]
+	if st.PPID, err = strconv.Atoi(fields[1]); err != nil {
+		return Stat{}, fmt.Errorf("parsing ppid: %w", err)
+	}
+	if st.UTime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return Stat{}, fmt.Errorf("parsing utime: %w", err)
+	}
+	if st.STime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return Stat{}, fmt.Errorf("parsing stime: %w", err)
+	}
+	if st.StartTime, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return Stat{}, fmt.Errorf("parsing starttime: %w", err)
+	}
+	if len(fields) > 36 {
+		st.Processor, _ = strconv.Atoi(fields[36])
+	}
+	return st, nil
+}
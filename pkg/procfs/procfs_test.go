@@ -0,0 +1,287 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleStat = "4242 (tricky (name) x) S 1 4242 4242 0 -1 4194560 1500 0 3 0 " +
+	"120 45 0 0 20 0 4 0 987654 123456789 2048 18446744073709551615 1 1 0 0 0 0 0 " +
+	"4096 0 0 0 0 17 3 0 0 0 0 0\n"
+
+func TestParseStat(t *testing.T) {
+	st, err := parseStat([]byte(sampleStat))
+	if err != nil {
+		t.Fatalf("parseStat: %v", err)
+	}
+	if st.PID != 4242 || st.Comm != "tricky (name) x" {
+		t.Fatalf("unexpected pid/comm: %d %q", st.PID, st.Comm)
+	}
+	if st.State != 'S' || st.PPID != 1 {
+		t.Fatalf("unexpected state/ppid: %c %d", st.State, st.PPID)
+	}
+	if st.UTime != 120 || st.STime != 45 {
+		t.Fatalf("unexpected cpu times: utime=%d stime=%d", st.UTime, st.STime)
+	}
+	if st.StartTime != 987654 {
+		t.Fatalf("unexpected starttime: %d", st.StartTime)
+	}
+	if st.Processor != 3 {
+		t.Fatalf("unexpected processor: %d", st.Processor)
+	}
+}
+
+func TestParseStatMalformed(t *testing.T) {
+	for _, input := range []string{"", "12 no-parens S 1", "12 (short) S 1 2 3"} {
+		if _, err := parseStat([]byte(input)); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestStartTimeFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "4242"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "4242", "stat"), []byte(sampleStat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	start, err := StartTime(4242)
+	if err != nil {
+		t.Fatalf("StartTime: %v", err)
+	}
+	if start != 987654 {
+		t.Fatalf("expected 987654, got %d", start)
+	}
+	if _, err := StartTime(1); err == nil {
+		t.Fatal("expected error for missing pid")
+	}
+}
+
+func TestPIDsAndCgroupPathFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1", "4242", "self", "sys"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte("cpu 1 2 3 4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cgroup := "12:cpu,cpuacct:/legacy\n0::/system.slice/nginx.service\n"
+	if err := os.WriteFile(filepath.Join(dir, "4242", "cgroup"), []byte(cgroup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	pids, err := PIDs()
+	if err != nil {
+		t.Fatalf("PIDs: %v", err)
+	}
+	if len(pids) != 2 {
+		t.Fatalf("expected only numeric directories, got %v", pids)
+	}
+
+	path, err := CgroupPath(4242)
+	if err != nil || path != "/system.slice/nginx.service" {
+		t.Fatalf("CgroupPath = %q, %v", path, err)
+	}
+	if _, err := CgroupPath(1); err == nil {
+		t.Fatal("expected error when cgroup file is missing")
+	}
+	paths, err := CgroupPaths(4242)
+	if err != nil || len(paths) != 2 || paths[0] != "/system.slice/nginx.service" || paths[1] != "/legacy" {
+		t.Fatalf("CgroupPaths = %q, %v", paths, err)
+	}
+}
+
+func TestHugetlbBytesFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	status := map[string]string{
+		"10": "Name:\tpostgres\nVmRSS:\t  102400 kB\nHugetlbPages:\t 2097152 kB\n",
+		"11": "Name:\told-kernel\nVmRSS:\t  4096 kB\n",
+	}
+	for pid, contents := range status {
+		if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, pid, "status"), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	if got, err := HugetlbBytes(10); err != nil || got != 2<<30 {
+		t.Fatalf("HugetlbBytes(10) = %d, %v; want 2 GiB", got, err)
+	}
+	if got, err := HugetlbBytes(11); err != nil || got != 0 {
+		t.Fatalf("missing HugetlbPages line should mean 0, got %d, %v", got, err)
+	}
+	if _, err := HugetlbBytes(12); err == nil {
+		t.Fatal("expected error for missing pid")
+	}
+}
+
+func TestAllowedCPUsFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	status := map[string]string{
+		"10": "Name:\tpinned\nCpus_allowed:\t0000000f\nCpus_allowed_list:\t0-1\n",
+		"11": "Name:\tsplit\nCpus_allowed_list:\t0-3,8,10-11\n",
+		"12": "Name:\tancient\nVmRSS:\t  4096 kB\n",
+	}
+	for pid, contents := range status {
+		if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, pid, "status"), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	if got, err := AllowedCPUs(10); err != nil || got != 2 {
+		t.Fatalf("AllowedCPUs(10) = %d, %v; want 2", got, err)
+	}
+	if got, err := AllowedCPUs(11); err != nil || got != 7 {
+		t.Fatalf("AllowedCPUs(11) = %d, %v; want 7", got, err)
+	}
+	if _, err := AllowedCPUs(12); err == nil {
+		t.Fatal("expected error for missing Cpus_allowed_list line")
+	}
+	if _, err := AllowedCPUs(13); err == nil {
+		t.Fatal("expected error for missing pid")
+	}
+}
+
+func TestNSPidFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	status := map[string]string{
+		"4211": "Name:\tnginx\nNSpid:\t4211\t12\n",
+		"4300": "Name:\tsidecar\nNSpid:\t4300\t57\t3\n",
+		"812":  "Name:\tsshd\nNSpid:\t812\n",
+		"900":  "Name:\tancient\nVmRSS:\t  4096 kB\n",
+	}
+	for pid, contents := range status {
+		if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, pid, "status"), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	for pid, want := range map[int]uint32{4211: 12, 4300: 3, 812: 812} {
+		if got, err := NSPid(pid); err != nil || got != want {
+			t.Fatalf("NSPid(%d) = %d, %v; want %d", pid, got, err, want)
+		}
+	}
+	if _, err := NSPid(900); err == nil {
+		t.Fatal("expected error for missing NSpid line")
+	}
+	if _, err := NSPid(901); err == nil {
+		t.Fatal("expected error for missing pid")
+	}
+}
+
+func TestParseCPUListMalformed(t *testing.T) {
+	for _, input := range []string{"", "a-3", "0-b", "4-2"} {
+		if _, err := parseCPUList(input); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestExePathFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "10"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/local/bin/myapp", filepath.Join(dir, "10", "exe")); err != nil {
+		t.Fatal(err)
+	}
+	// A kernel thread's directory has an exe entry that cannot be resolved.
+	if err := os.MkdirAll(filepath.Join(dir, "2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	if got, err := ExePath(10); err != nil || got != "/usr/local/bin/myapp" {
+		t.Fatalf("ExePath(10) = %q, %v", got, err)
+	}
+	if _, err := ExePath(2); err == nil {
+		t.Fatal("expected error for a process without an executable")
+	}
+}
+
+func TestCmdlineFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	for pid, contents := range map[string]string{
+		"10": "/usr/bin/containerd-shim-runc-v2\x00-namespace\x00k8s.io\x00",
+		"2":  "", // kernel thread
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, pid, "cmdline"), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	got, err := Cmdline(10)
+	if err != nil || strings.Join(got, " ") != "/usr/bin/containerd-shim-runc-v2 -namespace k8s.io" {
+		t.Fatalf("Cmdline(10) = %q, %v", got, err)
+	}
+	if got, err := Cmdline(2); err != nil || got != nil {
+		t.Fatalf("kernel thread should have no arguments, got %q, %v", got, err)
+	}
+	if _, err := Cmdline(11); err == nil {
+		t.Fatal("expected error for missing pid")
+	}
+}
+
+func TestThreadCommFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	for tid, comm := range map[string]string{"10": "java\n", "11": "GC Thread#0\n"} {
+		if err := os.MkdirAll(filepath.Join(dir, "10", "task", tid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "10", "task", tid, "comm"), []byte(comm), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	for tid, want := range map[int]string{10: "java", 11: "GC Thread#0"} {
+		if got, err := ThreadComm(10, tid); err != nil || got != want {
+			t.Fatalf("ThreadComm(10, %d) = %q, %v; want %q", tid, got, err, want)
+		}
+	}
+	if _, err := ThreadComm(10, 12); err == nil {
+		t.Fatal("expected error for an exited thread")
+	}
+}
+
+func TestStartTimeForSelf(t *testing.T) {
+	start, err := StartTime(os.Getpid())
+	if err != nil {
+		t.Fatalf("StartTime(self): %v", err)
+	}
+	if start == 0 {
+		t.Fatal("own start time should be non-zero")
+	}
+}
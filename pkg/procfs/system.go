@@ -0,0 +1,181 @@
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CPUTimes holds the aggregate "cpu" line of /proc/stat in clock ticks.
+type CPUTimes struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+// Total returns the sum of all accounted ticks.
+func (c CPUTimes) Total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// IdleTotal returns ticks spent idle, including waiting for I/O.
+func (c CPUTimes) IdleTotal() uint64 {
+	return c.Idle + c.IOWait
+}
+
+// ReadCPUTimes parses the aggregate CPU line from /proc/stat.
+func ReadCPUTimes() (CPUTimes, error) {
+	data, err := os.ReadFile(filepath.Join(root, "stat"))
+	if err != nil {
+		return CPUTimes{}, err
+	}
+	return parseCPUTimes(data)
+}
+
+// ReadPerCPUTimes parses the per-CPU "cpuN" lines of /proc/stat, keyed by
+// CPU number. Offline CPUs have no line and are absent from the map.
+func ReadPerCPUTimes() (map[int]CPUTimes, error) {
+	data, err := os.ReadFile(filepath.Join(root, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	return parsePerCPUTimes(data)
+}
+
+// ReadSoftIRQs returns the /proc/softirqs event counts per softirq type
+// (NET_RX, TIMER, ...), summed across CPUs.
+func ReadSoftIRQs() (map[string]uint64, error) {
+	data, err := os.ReadFile(filepath.Join(root, "softirqs"))
+	if err != nil {
+		return nil, err
+	}
+	return parseSoftIRQs(data)
+}
+
+func parseCPUTimes(data []byte) (CPUTimes, error) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return CPUTimes{}, fmt.Errorf("malformed /proc/stat cpu line")
+	}
+	return parseCPUFields(fields)
+}
+
+func parsePerCPUTimes(data []byte) (map[int]CPUTimes, error) {
+	perCPU := make(map[int]CPUTimes)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		id, ok := strings.CutPrefix(fields[0], "cpu")
+		if !ok || id == "" {
+			continue // the aggregate line, or another counter
+		}
+		cpu, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		ct, err := parseCPUFields(fields)
+		if err != nil {
+			return nil, err
+		}
+		perCPU[cpu] = ct
+	}
+	if len(perCPU) == 0 {
+		return nil, fmt.Errorf("no per-CPU lines in /proc/stat")
+	}
+	return perCPU, nil
+}
+
+// parseCPUFields decodes one "cpu" or "cpuN" line split into fields.
+func parseCPUFields(fields []string) (CPUTimes, error) {
+	vals := make([]uint64, 8)
+	for i := range vals {
+		if i+1 >= len(fields) {
+			break // older kernels omit trailing columns
+		}
+		v, err := strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return CPUTimes{}, fmt.Errorf("parsing /proc/stat field %d: %w", i+1, err)
+		}
+		vals[i] = v
+	}
+	return CPUTimes{
+		User: vals[0], Nice: vals[1], System: vals[2], Idle: vals[3],
+		IOWait: vals[4], IRQ: vals[5], SoftIRQ: vals[6], Steal: vals[7],
+	}, nil
+}
+
+func parseSoftIRQs(data []byte) (map[string]uint64, error) {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("malformed /proc/softirqs")
+	}
+	counts := make(map[string]uint64)
+	for _, line := range lines[1:] { // the first line is the CPU header
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		var total uint64
+		for _, field := range strings.Fields(rest) {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing /proc/softirqs %s: %w", strings.TrimSpace(name), err)
+			}
+			total += v
+		}
+		counts[strings.TrimSpace(name)] = total
+	}
+	return counts, nil
+}
+
+// MemInfo holds the /proc/meminfo fields used for the system summary, in bytes.
+type MemInfo struct {
+	Total     uint64
+	Available uint64
+}
+
+// ReadMemInfo parses MemTotal and MemAvailable from /proc/meminfo.
+func ReadMemInfo() (MemInfo, error) {
+	f, err := os.Open(filepath.Join(root, "meminfo"))
+	if err != nil {
+		return MemInfo{}, err
+	}
+	defer f.Close()
+
+	var info MemInfo
+	found := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && found < 2 {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		var dst *uint64
+		switch fields[0] {
+		case "MemTotal:":
+			dst = &info.Total
+		case "MemAvailable:":
+			dst = &info.Available
+		default:
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return MemInfo{}, fmt.Errorf("parsing %s: %w", fields[0], err)
+		}
+		*dst = kb * 1024
+		found++
+	}
+	if err := scanner.Err(); err != nil {
+		return MemInfo{}, err
+	}
+	if info.Total == 0 {
+		return MemInfo{}, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+	return info, nil
+}
@@ -0,0 +1,74 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCPUTimes(t *testing.T) {
+	data := []byte("cpu  100 5 50 800 20 3 2 10 0 0\ncpu0 50 2 25 400 10 1 1 5 0 0\n")
+	ct, err := parseCPUTimes(data)
+	if err != nil {
+		t.Fatalf("parseCPUTimes: %v", err)
+	}
+	if ct.User != 100 || ct.Idle != 800 || ct.Steal != 10 {
+		t.Fatalf("unexpected values: %+v", ct)
+	}
+	if ct.Total() != 990 || ct.IdleTotal() != 820 {
+		t.Fatalf("unexpected totals: total=%d idle=%d", ct.Total(), ct.IdleTotal())
+	}
+
+	if _, err := parseCPUTimes([]byte("intr 1 2 3\n")); err == nil {
+		t.Fatal("expected error without cpu line")
+	}
+}
+
+func TestParsePerCPUTimes(t *testing.T) {
+	data := []byte("cpu  100 5 50 800 20 3 2 10 0 0\n" +
+		"cpu0 50 2 25 400 10 1 1 5 0 0\n" +
+		"cpu3 50 3 25 400 10 2 40 5 0 0\n" +
+		"intr 1 2 3\nctxt 999\n")
+	perCPU, err := parsePerCPUTimes(data)
+	if err != nil {
+		t.Fatalf("parsePerCPUTimes: %v", err)
+	}
+	if len(perCPU) != 2 || perCPU[3].SoftIRQ != 40 || perCPU[0].IRQ != 1 {
+		t.Fatalf("unexpected per-CPU times: %+v", perCPU)
+	}
+	if _, err := parsePerCPUTimes([]byte("cpu  1 2 3 4 5\n")); err == nil {
+		t.Fatal("expected error without per-CPU lines")
+	}
+}
+
+func TestParseSoftIRQs(t *testing.T) {
+	data := []byte("                    CPU0       CPU1\n" +
+		"          HI:          1          0\n" +
+		"       TIMER:        100        200\n" +
+		"      NET_RX:       5000         10\n")
+	counts, err := parseSoftIRQs(data)
+	if err != nil {
+		t.Fatalf("parseSoftIRQs: %v", err)
+	}
+	if counts["TIMER"] != 300 || counts["NET_RX"] != 5010 || counts["HI"] != 1 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+}
+
+func TestReadMemInfoFromFixture(t *testing.T) {
+	dir := t.TempDir()
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    8000000 kB\n"
+	if err := os.WriteFile(filepath.Join(dir, "meminfo"), []byte(meminfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root = "/proc" })
+	root = dir
+
+	info, err := ReadMemInfo()
+	if err != nil {
+		t.Fatalf("ReadMemInfo: %v", err)
+	}
+	if info.Total != 16000000*1024 || info.Available != 8000000*1024 {
+		t.Fatalf("unexpected meminfo: %+v", info)
+	}
+}
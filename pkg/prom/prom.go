@@ -0,0 +1,144 @@
+// Package prom exposes the latest snapshot as Prometheus gauges over HTTP, so
+// a fleet of hosts can be scraped instead of read from the terminal.
+package prom
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+// readHeaderTimeout bounds how long a scraper may take to send its request.
+const readHeaderTimeout = 10 * time.Second
+
+// shutdownTimeout bounds how long Close waits for in-flight scrapes.
+const shutdownTimeout = 5 * time.Second
+
+// gauge is one per-process series family and the metric it reports.
+type gauge struct {
+	vec   *prometheus.GaugeVec
+	value func(report.ProcMetrics) float64
+}
+
+// Exporter holds one gauge per exported metric, labelled by process
+// identity. Each Update replaces every series, so processes that exited or
+// fell out of the top K stop being reported. It is safe for concurrent use.
+type Exporter struct {
+	mu       sync.RWMutex // held for writing during Update so scrapes never see a half-filled registry
+	reg      *prometheus.Registry
+	strategy report.IdentityStrategy
+	gauges   []gauge
+}
+
+// NewExporter creates an exporter whose series are labelled by strategy.
+func NewExporter(strategy report.IdentityStrategy) *Exporter {
+	e := &Exporter{reg: prometheus.NewRegistry(), strategy: strategy}
+	labels := strategy.LabelNames()
+	add := func(name, help string, value func(report.ProcMetrics) float64) {
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: "hotspot", Name: name, Help: help}, labels)
+		e.reg.MustRegister(vec)
+		e.gauges = append(e.gauges, gauge{vec: vec, value: value})
+	}
+	add("cpu_percent", "CPU time as a percentage of all cores in the last window.",
+		func(r report.ProcMetrics) float64 { return r.CPUPercent })
+	add("rss_bytes", "Resident memory plus explicit hugepages.",
+		func(r report.ProcMetrics) float64 { return r.FootprintMB() * 1024 * 1024 })
+	add("faults_per_sec", "Page faults per second in the last window.",
+		func(r report.ProcMetrics) float64 { return r.FaultsPerSec })
+	add("preempted_total", "Times the process was preempted in the last window.",
+		func(r report.ProcMetrics) float64 { return float64(r.Preempted) })
+	add("preempts_others_total", "Times the process preempted another process in the last window.",
+		func(r report.ProcMetrics) float64 { return float64(r.PreemptsOthers) })
+	add("diagnosis_severity", "Severity of the process's diagnosis, 0 for OK (see -list-diagnoses).",
+		func(r report.ProcMetrics) float64 { return float64(report.Severity(r.Diagnosis)) })
+	return e
+}
+
+// Update replaces every series with one per row. Rows should already be
+// merged by the exporter's identity strategy; a later row with the same
+// labels overwrites an earlier one.
+func (e *Exporter) Update(rows []report.ProcMetrics) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, g := range e.gauges {
+		g.vec.Reset()
+		for _, row := range rows {
+			g.vec.With(prometheus.Labels(e.strategy.Labels(row))).Set(g.value(row))
+		}
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (e *Exporter) Handler() http.Handler {
+	h := promhttp.HandlerFor(e.reg, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Server serves an Exporter's /metrics endpoint.
+type Server struct {
+	srv  *http.Server
+	addr net.Addr
+	done chan struct{}
+	err  error // why Serve stopped, other than a shutdown; set before done is closed
+}
+
+// Listen binds addr and serves e at /metrics in the background until ctx is
+// cancelled or Close is called. A bind error is returned immediately.
+func Listen(ctx context.Context, addr string, e *Exporter) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e.Handler())
+	s := &Server{
+		srv:  &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout},
+		addr: ln.Addr(),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		if err := s.srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			s.err = err
+		}
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.shutdown()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.addr
+}
+
+// Close stops accepting scrapes, waits for in-flight ones to finish, and
+// returns any error that stopped the server early.
+func (s *Server) Close() error {
+	err := s.shutdown()
+	<-s.done
+	return errors.Join(err, s.err)
+}
+
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,92 @@
+package prom
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape returned %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestUpdateReplacesSeries(t *testing.T) {
+	e := NewExporter(report.IdentityPID)
+	e.Update([]report.ProcMetrics{
+		{PID: 123, Comm: "worker", Cgroup: "/kubepods", CPUPercent: 12.5, FaultsPerSec: 300, Preempted: 7, Diagnosis: "Mem-thrashing"},
+		{PID: 456, Comm: "noisy", Cgroup: "/kubepods", CPUPercent: 40},
+	})
+	body := scrape(t, e.Handler())
+	for _, want := range []string{
+		`hotspot_cpu_percent{cgroup="/kubepods",comm="worker",pid="123"} 12.5`,
+		`hotspot_faults_per_sec{cgroup="/kubepods",comm="worker",pid="123"} 300`,
+		`hotspot_preempted_total{cgroup="/kubepods",comm="worker",pid="123"} 7`,
+		`hotspot_diagnosis_severity{cgroup="/kubepods",comm="worker",pid="123"} 8`,
+		`hotspot_cpu_percent{cgroup="/kubepods",comm="noisy",pid="456"} 40`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("scrape missing %q:\n%s", want, body)
+		}
+	}
+
+	// PID 456 exited: its series must not linger.
+	e.Update([]report.ProcMetrics{{PID: 123, Comm: "worker", Cgroup: "/kubepods", CPUPercent: 3}})
+	body = scrape(t, e.Handler())
+	if strings.Contains(body, `pid="456"`) {
+		t.Fatalf("stale series for an exited process:\n%s", body)
+	}
+	if !strings.Contains(body, `hotspot_cpu_percent{cgroup="/kubepods",comm="worker",pid="123"} 3`) {
+		t.Fatalf("series not updated:\n%s", body)
+	}
+}
+
+func TestCommCgroupIdentityOmitsPID(t *testing.T) {
+	e := NewExporter(report.IdentityCommCgroup)
+	e.Update([]report.ProcMetrics{{PID: 123, Comm: "nginx", Cgroup: "/system.slice/nginx.service", CPUPercent: 5}})
+	body := scrape(t, e.Handler())
+	if !strings.Contains(body, `hotspot_cpu_percent{cgroup="/system.slice/nginx.service",comm="nginx"} 5`) || strings.Contains(body, "pid=") {
+		t.Fatalf("unexpected comm-cgroup series:\n%s", body)
+	}
+}
+
+func TestListenStopsOnContextCancel(t *testing.T) {
+	e := NewExporter(report.IdentityPID)
+	e.Update([]report.ProcMetrics{{PID: 1, Comm: "init", CPUPercent: 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := Listen(ctx, "127.0.0.1:0", e)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	resp, err := http.Get("http://" + s.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(data), `comm="init"`) {
+		t.Fatalf("unexpected scrape body:\n%s", data)
+	}
+
+	cancel()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server still running after context cancel")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close after cancel: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// LogBackups is how many rotated files a Log keeps besides the live one:
+// path.1 is the most recent, path.LogBackups the oldest.
+const LogBackups = 5
+
+// Log appends snapshots to a file as JSON Lines, the flight recorder's
+// format, so a long capture can be read back with ReadFile. When the next
+// snapshot would take the file past its size limit, the file is rotated to
+// path.1, path.1 to path.2, and so on, dropping the oldest. It is not safe
+// for concurrent use.
+type Log struct {
+	path    string
+	maxSize int64
+	f       *os.File
+	size    int64
+	buf     bytes.Buffer
+	enc     *json.Encoder // writes to buf, reused for every snapshot
+}
+
+// OpenLog opens the file at path for appending, creating it if needed. A
+// maxSize of 0 or less never rotates.
+func OpenLog(path string, maxSize int64) (*Log, error) {
+	l := &Log{path: path, maxSize: maxSize}
+	l.enc = json.NewEncoder(&l.buf)
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// Append writes s as one line and syncs the file, so a crash loses at most
+// the snapshot being written. A snapshot larger than the limit on its own is
+// still written whole, to a file of its own.
+func (l *Log) Append(s Snapshot) error {
+	l.buf.Reset()
+	if err := l.enc.Encode(s); err != nil {
+		return err
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(l.buf.Len()) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotating %s: %w", l.path, err)
+		}
+	}
+	n, err := l.f.Write(l.buf.Bytes())
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// rotate shifts the live file and its backups up by one and starts a new,
+// empty live file. The live file is reopened even if shifting failed, so
+// one failed rotation does not end the log.
+func (l *Log) rotate() error {
+	err := l.f.Close()
+	for i := LogBackups - 1; i >= 1 && err == nil; i-- {
+		if err = os.Rename(backupPath(l.path, i), backupPath(l.path, i+1)); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = os.Rename(l.path, backupPath(l.path, 1))
+	}
+	return errors.Join(err, l.open())
+}
+
+// Close closes the live file.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
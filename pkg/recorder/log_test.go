@@ -0,0 +1,71 @@
+package recorder
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogAppendsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hotspot.jsonl")
+	for _, pid := range []uint32{1, 2} {
+		l, err := OpenLog(path, 0)
+		if err != nil {
+			t.Fatalf("OpenLog: %v", err)
+		}
+		if err := l.Append(snap(pid)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if err := l.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	snaps, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if got := pids(snaps); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("reopened log should append, got %v", got)
+	}
+}
+
+func TestLogRotatesAtMaxSize(t *testing.T) {
+	line, err := json.Marshal(snap(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "hotspot.jsonl")
+	// Room for two snapshots per file, with slack for two-digit PIDs.
+	l, err := OpenLog(path, int64(2*(len(line)+1)+2))
+	if err != nil {
+		t.Fatalf("OpenLog: %v", err)
+	}
+	defer l.Close()
+	total := uint32(2*(LogBackups+1) + 1)
+	for pid := uint32(1); pid <= total; pid++ {
+		if err := l.Append(snap(pid)); err != nil {
+			t.Fatalf("Append(%d): %v", pid, err)
+		}
+	}
+
+	// The live file has the newest snapshot; each backup holds the two
+	// before the next newer file's, and the first two were dropped.
+	want := map[string][]uint32{path: {total}}
+	for i := 1; i <= LogBackups; i++ {
+		newest := total - uint32(2*i-1)
+		want[backupPath(path, i)] = []uint32{newest - 1, newest}
+	}
+	for file, pidsWant := range want {
+		snaps, err := ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", file, err)
+		}
+		if got := pids(snaps); len(got) != len(pidsWant) || got[0] != pidsWant[0] || got[len(got)-1] != pidsWant[len(pidsWant)-1] {
+			t.Fatalf("%s: got %v, want %v", file, got, pidsWant)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, LogBackups+1)); !os.IsNotExist(err) {
+		t.Fatalf("only %d backups should be kept, stat: %v", LogBackups, err)
+	}
+}
@@ -0,0 +1,167 @@
+// Package recorder implements a flight recorder: a bounded in-memory ring of
+// the most recent snapshots that can be written out when an incident is
+// noticed, capturing the lead-up to the event rather than only its peak.
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// Snapshot is one complete sampling window as seen by the classifier, before
+// display filters and top-K limits are applied.
+type Snapshot struct {
+	Time       time.Time              `json:"time"`
+	Interval   time.Duration          `json:"interval_ns"`
+	Processes  []report.ProcMetrics   `json:"processes"`
+	Contention []types.ContentionStat `json:"contention,omitempty"`
+	Errors     map[string]string      `json:"errors,omitempty"` // failed subsystem → reason; see report.SnapshotResult
+}
+
+// Ring keeps the last N snapshots. It is safe for concurrent use so a dump
+// can be requested from a signal handler goroutine.
+type Ring struct {
+	mu   sync.Mutex
+	buf  []Snapshot
+	next int  // index the next Add writes to
+	full bool // buf has wrapped at least once
+}
+
+// NewRing returns a ring holding up to size snapshots (minimum 1).
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{buf: make([]Snapshot, size)}
+}
+
+// Add stores s, overwriting the oldest snapshot once the ring is full.
+func (r *Ring) Add(s Snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = s
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshots returns the recorded snapshots, oldest first.
+func (r *Ring) Snapshots() []Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Snapshot(nil), r.buf[:r.next]...)
+	}
+	out := make([]Snapshot, 0, len(r.buf))
+	out = append(out, r.buf[r.next:]...)
+	return append(out, r.buf[:r.next]...)
+}
+
+// Len returns how many snapshots are currently held.
+func (r *Ring) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.full {
+		return len(r.buf)
+	}
+	return r.next
+}
+
+// WriteJSON writes the ring as JSON Lines, one snapshot per line, oldest first.
+func (r *Ring) WriteJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, s := range r.Snapshots() {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Dump writes the ring to a new timestamped file in dir and returns its path.
+func (r *Ring) Dump(dir string, now time.Time) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("hotspot-flight-%s.jsonl", now.Format("20060102T150405")))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	if err := r.WriteJSON(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// Read decodes every snapshot in r, oldest first. It accepts both a flight
+// recorder dump (JSON Lines) and a single indented document as written by
+// -export-dir, since both are a sequence of Snapshot values.
+func Read(r io.Reader) ([]Snapshot, error) {
+	dec := json.NewDecoder(r)
+	var out []Snapshot
+	for {
+		var s Snapshot
+		if err := dec.Decode(&s); err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("snapshot %d: %w", len(out)+1, err)
+		}
+		out = append(out, s)
+	}
+}
+
+// ReadFile reads the snapshots stored in the file at path.
+func ReadFile(path string) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Save atomically replaces the file at path with s as a single JSON line. The
+// snapshot is written to a temporary file in the same directory, synced, and
+// renamed over path, so a crash mid-write leaves the previous file intact.
+func Save(path string, s Snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if err := json.NewEncoder(tmp).Encode(s); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load returns the most recent snapshot in the file at path, as written by
+// Save or found at the end of a flight recorder dump.
+func Load(path string) (Snapshot, error) {
+	snaps, err := ReadFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(snaps) == 0 {
+		return Snapshot{}, fmt.Errorf("%s holds no snapshot", path)
+	}
+	return snaps[len(snaps)-1], nil
+}
@@ -0,0 +1,141 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+func snap(pid uint32) Snapshot {
+	return Snapshot{
+		Time:      time.Unix(int64(pid), 0).UTC(),
+		Interval:  time.Second,
+		Processes: []report.ProcMetrics{{PID: pid, Diagnosis: "OK"}},
+	}
+}
+
+func pids(snaps []Snapshot) []uint32 {
+	out := make([]uint32, 0, len(snaps))
+	for _, s := range snaps {
+		out = append(out, s.Processes[0].PID)
+	}
+	return out
+}
+
+func TestRingKeepsLastNOldestFirst(t *testing.T) {
+	r := NewRing(3)
+	if r.Len() != 0 || len(r.Snapshots()) != 0 {
+		t.Fatal("new ring should be empty")
+	}
+	r.Add(snap(1))
+	r.Add(snap(2))
+	if got := pids(r.Snapshots()); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("partial ring: got %v", got)
+	}
+	for pid := uint32(3); pid <= 5; pid++ {
+		r.Add(snap(pid))
+	}
+	got := pids(r.Snapshots())
+	if r.Len() != 3 || len(got) != 3 || got[0] != 3 || got[1] != 4 || got[2] != 5 {
+		t.Fatalf("wrapped ring: got %v (len %d), want [3 4 5]", got, r.Len())
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	r := NewRing(2)
+	r.Add(snap(7))
+	r.Add(snap(8))
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	sc := bufio.NewScanner(&buf)
+	var decoded []Snapshot
+	for sc.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			t.Fatalf("line %q is not a snapshot: %v", sc.Text(), err)
+		}
+		decoded = append(decoded, s)
+	}
+	if got := pids(decoded); len(got) != 2 || got[0] != 7 || got[1] != 8 {
+		t.Fatalf("decoded %v, want [7 8]", got)
+	}
+	if decoded[0].Interval != time.Second {
+		t.Fatalf("interval not preserved: %v", decoded[0].Interval)
+	}
+}
+
+func TestDumpCreatesTimestampedFile(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRing(1)
+	r.Add(snap(1))
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	path, err := r.Dump(dir, now)
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		t.Fatalf("reading dump %s: %v", path, err)
+	}
+	if _, err := r.Dump(dir, now); err == nil {
+		t.Fatal("expected an existing dump not to be overwritten")
+	}
+}
+
+func TestReadAcceptsLinesAndIndentedDocument(t *testing.T) {
+	r := NewRing(2)
+	r.Add(snap(7))
+	r.Add(snap(8))
+	var lines bytes.Buffer
+	if err := r.WriteJSON(&lines); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	got, err := Read(&lines)
+	if err != nil || len(got) != 2 || got[1].Processes[0].PID != 8 {
+		t.Fatalf("JSON Lines: got %v, %v", got, err)
+	}
+
+	indented, _ := json.MarshalIndent(snap(9), "", "  ")
+	got, err = Read(bytes.NewReader(indented))
+	if err != nil || len(got) != 1 || got[0].Processes[0].PID != 9 {
+		t.Fatalf("indented document: got %v, %v", got, err)
+	}
+
+	if _, err := Read(bytes.NewBufferString("{\"time\":")); err == nil {
+		t.Fatal("expected an error for a truncated capture")
+	}
+}
+
+func TestSaveReplacesAtomicallyAndLoadReadsBack(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/state.json"
+	for _, pid := range []uint32{1, 2} {
+		if err := Save(path, snap(pid)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	got, err := Load(path)
+	if err != nil || got.Processes[0].PID != 2 {
+		t.Fatalf("Load: got %+v, %v; want the last saved snapshot", got, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected an error for an empty state file")
+	}
+}
@@ -0,0 +1,119 @@
+// Package redact masks secrets in process command lines before they are
+// displayed or exported. Passwords and tokens are routinely passed as
+// arguments, and a capture shared in a ticket must not leak them.
+package redact
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Mask replaces every redacted value.
+const Mask = "***"
+
+// DefaultPatterns are the argument-name fragments whose values are always
+// masked. Matching ignores case, '-' and '_', so "apikey" also covers
+// --api-key and API_KEY.
+var DefaultPatterns = []string{
+	"password", "passwd", "pwd", "secret", "token",
+	"apikey", "accesskey", "privatekey", "credential", "authorization",
+}
+
+// Args returns a copy of args with sensitive values masked. A value is
+// sensitive when its argument name contains one of DefaultPatterns or extra:
+//
+//	--password=hunter2   → --password=***
+//	DB_TOKEN=abc         → DB_TOKEN=***
+//	--api-key abc        → --api-key ***
+//	postgres://u:pw@db   → postgres://u:***@db
+func Args(args []string, extra []string) []string {
+	var patterns []string
+	for _, list := range [][]string{DefaultPatterns, extra} {
+		for _, p := range list {
+			if p = normalize(p); p != "" {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+
+	out := make([]string, len(args))
+	maskNext := false
+	for i, arg := range args {
+		if maskNext {
+			out[i] = Mask
+			maskNext = false
+			continue
+		}
+		out[i] = arg
+		name, value, hasValue := strings.Cut(arg, "=")
+		if strings.Contains(name, "://") {
+			// A bare URL whose query string contains '='.
+			out[i] = userinfo(arg)
+			continue
+		}
+		if !sensitive(name, patterns) {
+			if hasValue {
+				out[i] = name + "=" + userinfo(value)
+			} else {
+				out[i] = userinfo(arg)
+			}
+			continue
+		}
+		switch {
+		case hasValue:
+			out[i] = name + "=" + Mask
+		case strings.HasPrefix(arg, "-"):
+			// "--password VALUE": the secret is the next argument.
+			maskNext = true
+		}
+	}
+	return out
+}
+
+func sensitive(name string, patterns []string) bool {
+	name = normalize(name)
+	if name == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if strings.Contains(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+}
+
+// userinfo masks the password of a URL-style argument such as
+// "mysql://user:pw@host/db", which carries no argument name to match.
+func userinfo(arg string) string {
+	if !strings.Contains(arg, "://") || !strings.Contains(arg, "@") {
+		return arg
+	}
+	u, err := url.Parse(arg)
+	if err != nil || u.User == nil {
+		return arg
+	}
+	if _, ok := u.User.Password(); !ok {
+		return arg
+	}
+	scheme, rest, _ := strings.Cut(arg, "://")
+	end := strings.IndexByte(rest, '/')
+	if end < 0 {
+		end = len(rest)
+	}
+	at := strings.LastIndexByte(rest[:end], '@')
+	if at < 0 {
+		return arg
+	}
+	user, _, _ := strings.Cut(rest[:at], ":")
+	return scheme + "://" + user + ":" + Mask + rest[at:]
+}
@@ -0,0 +1,198 @@
+package report
+
+import (
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// Cumulative turns collector readings that are never reset (-cumulative)
+// into per-window values, by subtracting each key's reading from the
+// previous window. The result reads like a window of reset maps, so
+// BuildProcMetrics and everything after it is unchanged.
+//
+// A reading lower than the previous one means the entry was evicted or its
+// PID reused, and the whole reading counts towards the window. Keys missing
+// from a reading are forgotten, so the readings must not be cut to a top-N.
+// Values the kernel only keeps as a running maximum or a sketch cannot be
+// split by window: RSSMinBytes, RSSMaxBytes, and FaultPages are cleared, and
+// RunqLatencyStat.MaxNs is the longest wait so far, capped at the window's
+// total wait.
+//
+// Cumulative is not safe for concurrent use.
+type Cumulative struct {
+	cpu        map[uint32]types.CPUStat
+	contention map[contentionPair]types.ContentionStat
+	pageFaults map[uint32]types.PageFaultStat
+	runq       map[uint32]types.RunqLatencyStat
+	blockIO    map[uint32]types.BlockIOStat
+	syscalls   map[uint32]types.SyscallStat
+	futex      map[uint32]types.FutexStat
+	net        map[uint32]types.NetStat
+}
+
+type contentionPair struct {
+	victim, aggressor uint32
+}
+
+// NewCumulative returns a Cumulative with no previous readings: the first
+// window is everything counted since the collectors were loaded.
+func NewCumulative() *Cumulative {
+	return &Cumulative{}
+}
+
+// Window returns r with every subsystem's readings replaced by the change
+// since the previous call, and rates recomputed over window. A failed
+// subsystem keeps its previous readings, so its next window spans both.
+func (c *Cumulative) Window(r SnapshotResult, window time.Duration) SnapshotResult {
+	seconds := window.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	if r.CPUErr == nil {
+		r.CPU, c.cpu = windowed(c.cpu, r.CPU, func(s types.CPUStat) uint32 { return s.PID }, cpuDelta)
+	}
+	if r.ContentionErr == nil {
+		r.Contention, c.contention = windowed(c.contention, r.Contention, func(s types.ContentionStat) contentionPair {
+			return contentionPair{s.VictimPID, s.AggressorPID}
+		}, contentionDelta)
+	}
+	if r.PageFaultsErr == nil {
+		r.PageFaults, c.pageFaults = windowed(c.pageFaults, r.PageFaults, func(s types.PageFaultStat) uint32 { return s.PID },
+			func(cur, prev types.PageFaultStat) (types.PageFaultStat, bool) {
+				return pageFaultDelta(cur, prev, seconds)
+			})
+	}
+	if r.RunqLatencyErr == nil {
+		r.RunqLatency, c.runq = windowed(c.runq, r.RunqLatency, func(s types.RunqLatencyStat) uint32 { return s.PID }, runqDelta)
+	}
+	if r.BlockIOErr == nil {
+		r.BlockIO, c.blockIO = windowed(c.blockIO, r.BlockIO, func(s types.BlockIOStat) uint32 { return s.PID }, blockIODelta)
+	}
+	if r.SyscallsErr == nil {
+		r.Syscalls, c.syscalls = windowed(c.syscalls, r.Syscalls, func(s types.SyscallStat) uint32 { return s.PID }, syscallDelta)
+	}
+	if r.FutexErr == nil {
+		r.Futex, c.futex = windowed(c.futex, r.Futex, func(s types.FutexStat) uint32 { return s.PID }, futexDelta)
+	}
+	if r.NetTracked && r.NetErr == nil {
+		r.Net, c.net = windowed(c.net, r.Net, func(s types.NetStat) uint32 { return s.PID }, netDelta)
+	}
+	return r
+}
+
+// windowed applies delta to each reading against the previous reading of
+// its key, keeping the results delta reports as active. It returns them with
+// the readings to compare the next window against.
+func windowed[K comparable, V any](last map[K]V, readings []V, key func(V) K, delta func(cur, prev V) (V, bool)) ([]V, map[K]V) {
+	next := make(map[K]V, len(readings))
+	var out []V
+	for _, v := range readings {
+		k := key(v)
+		next[k] = v
+		if d, ok := delta(v, last[k]); ok {
+			out = append(out, d)
+		}
+	}
+	return out, next
+}
+
+func cpuDelta(cur, prev types.CPUStat) (types.CPUStat, bool) {
+	if cur.Ns < prev.Ns || cur.Switches < prev.Switches || cur.OffCPUNs < prev.OffCPUNs ||
+		cur.VolSwitches < prev.VolSwitches || cur.InvolSwitches < prev.InvolSwitches {
+		prev = types.CPUStat{}
+	}
+	cur.Ns -= prev.Ns
+	cur.Switches -= prev.Switches
+	cur.OffCPUNs -= prev.OffCPUNs
+	cur.VolSwitches -= prev.VolSwitches
+	cur.InvolSwitches -= prev.InvolSwitches
+	return cur, cur.Ns > 0 || cur.Switches > 0 || cur.OffCPUNs > 0 || cur.VolSwitches > 0 || cur.InvolSwitches > 0
+}
+
+func contentionDelta(cur, prev types.ContentionStat) (types.ContentionStat, bool) {
+	if cur.Count < prev.Count {
+		prev = types.ContentionStat{}
+	}
+	cur.Count -= prev.Count
+	return cur, cur.Count > 0
+}
+
+func pageFaultDelta(cur, prev types.PageFaultStat, seconds float64) (types.PageFaultStat, bool) {
+	if cur.Faults < prev.Faults || cur.MajorFaults < prev.MajorFaults || cur.MajorFaultNs < prev.MajorFaultNs ||
+		cur.TLBShootdowns < prev.TLBShootdowns || cur.SwapIns < prev.SwapIns || cur.SwapOuts < prev.SwapOuts {
+		prev = types.PageFaultStat{}
+	}
+	cur.Faults -= prev.Faults
+	cur.MajorFaults -= prev.MajorFaults
+	cur.MajorFaultNs -= prev.MajorFaultNs
+	cur.TLBShootdowns -= prev.TLBShootdowns
+	cur.SwapIns -= prev.SwapIns
+	cur.SwapOuts -= prev.SwapOuts
+	cur.FaultsPerSec = float64(cur.Faults) / seconds
+	cur.TLBShootdownsPerSec = float64(cur.TLBShootdowns) / seconds
+	cur.SwapInsPerSec = float64(cur.SwapIns) / seconds
+	cur.SwapOutsPerSec = float64(cur.SwapOuts) / seconds
+	cur.RSSMinBytes, cur.RSSMaxBytes, cur.FaultPages = 0, 0, 0
+	return cur, cur.Faults > 0 || cur.TLBShootdowns > 0 || cur.SwapIns > 0 || cur.SwapOuts > 0
+}
+
+func runqDelta(cur, prev types.RunqLatencyStat) (types.RunqLatencyStat, bool) {
+	if cur.Waits < prev.Waits || cur.TotalNs < prev.TotalNs {
+		prev = types.RunqLatencyStat{}
+	}
+	cur.Waits -= prev.Waits
+	cur.TotalNs -= prev.TotalNs
+	cur.MaxNs = min(cur.MaxNs, cur.TotalNs)
+	return cur, cur.Waits > 0 || cur.TotalNs > 0
+}
+
+func blockIODelta(cur, prev types.BlockIOStat) (types.BlockIOStat, bool) {
+	if cur.Requests < prev.Requests || cur.ReadBytes < prev.ReadBytes || cur.WriteBytes < prev.WriteBytes {
+		prev = types.BlockIOStat{}
+	}
+	// The collector reports the mean over every request so far; the
+	// window's mean comes from the change in the total it was taken from.
+	curNs, prevNs := cur.AvgLatencyNs*cur.Requests, prev.AvgLatencyNs*prev.Requests
+	cur.ReadBytes -= prev.ReadBytes
+	cur.WriteBytes -= prev.WriteBytes
+	cur.Requests -= prev.Requests
+	cur.AvgLatencyNs = 0
+	if cur.Requests > 0 && curNs > prevNs {
+		cur.AvgLatencyNs = (curNs - prevNs) / cur.Requests
+	}
+	return cur, cur.Requests > 0
+}
+
+func syscallDelta(cur, prev types.SyscallStat) (types.SyscallStat, bool) {
+	if cur.Count < prev.Count || cur.TotalNs < prev.TotalNs {
+		prev = types.SyscallStat{}
+	}
+	cur.Count -= prev.Count
+	cur.TotalNs -= prev.TotalNs
+	// The top call is the top over the process's lifetime; its time in the
+	// window is only known when it was the top call before, too.
+	if cur.TopSyscall == prev.TopSyscall && cur.TopSyscallNs >= prev.TopSyscallNs {
+		cur.TopSyscallNs -= prev.TopSyscallNs
+	}
+	cur.TopSyscallNs = min(cur.TopSyscallNs, cur.TotalNs)
+	return cur, cur.Count > 0
+}
+
+func futexDelta(cur, prev types.FutexStat) (types.FutexStat, bool) {
+	if cur.WaitNs < prev.WaitNs || cur.Contended < prev.Contended {
+		prev = types.FutexStat{}
+	}
+	cur.WaitNs -= prev.WaitNs
+	cur.Contended -= prev.Contended
+	return cur, cur.WaitNs > 0 || cur.Contended > 0
+}
+
+func netDelta(cur, prev types.NetStat) (types.NetStat, bool) {
+	if cur.TxBytes < prev.TxBytes || cur.RxBytes < prev.RxBytes {
+		prev = types.NetStat{}
+	}
+	cur.TxBytes -= prev.TxBytes
+	cur.RxBytes -= prev.RxBytes
+	return cur, cur.TxBytes > 0 || cur.RxBytes > 0
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestCumulativeWindowSubtractsPreviousReading(t *testing.T) {
+	c := NewCumulative()
+	first := c.Window(SnapshotResult{
+		CPU:        []types.CPUStat{{PID: 1, Comm: "app", Ns: 2e9, VolSwitches: 10}, {PID: 2, Comm: "idle", Ns: 1e6}},
+		Contention: []types.ContentionStat{{VictimPID: 1, AggressorPID: 2, Count: 5}},
+		PageFaults: []types.PageFaultStat{{PID: 1, Faults: 100, RSSBytes: 1 << 20, RSSMinBytes: 1 << 19, RSSMaxBytes: 1 << 20}},
+	}, time.Second)
+	// The first window is everything counted so far.
+	if len(first.CPU) != 2 || first.CPU[0].Ns != 2e9 || first.PageFaults[0].FaultsPerSec != 100 {
+		t.Fatalf("first window: %+v", first)
+	}
+
+	second := c.Window(SnapshotResult{
+		CPU:        []types.CPUStat{{PID: 1, Comm: "app", Ns: 3e9, VolSwitches: 14}, {PID: 2, Comm: "idle", Ns: 1e6}},
+		Contention: []types.ContentionStat{{VictimPID: 1, AggressorPID: 2, Count: 8}},
+		PageFaults: []types.PageFaultStat{{PID: 1, Faults: 300, RSSBytes: 2 << 20, RSSMinBytes: 1 << 19, RSSMaxBytes: 2 << 20}},
+	}, 2*time.Second)
+	if len(second.CPU) != 1 || second.CPU[0].PID != 1 || second.CPU[0].Ns != 1e9 || second.CPU[0].VolSwitches != 4 {
+		t.Fatalf("idle process should drop out and the busy one count one second: %+v", second.CPU)
+	}
+	if second.Contention[0].Count != 3 {
+		t.Fatalf("contention count = %d, want 3", second.Contention[0].Count)
+	}
+	pf := second.PageFaults[0]
+	if pf.Faults != 200 || pf.FaultsPerSec != 100 || pf.RSSBytes != 2<<20 {
+		t.Fatalf("page faults: %+v", pf)
+	}
+	if pf.RSSMinBytes != 0 || pf.RSSMaxBytes != 0 {
+		t.Fatalf("lifetime RSS range should be cleared: %+v", pf)
+	}
+}
+
+func TestCumulativeWindowRestartsOnLowerReading(t *testing.T) {
+	c := NewCumulative()
+	c.Window(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "old", Ns: 5e9}}}, time.Second)
+	// PID 7 was reused: its new entry starts over below the old total.
+	got := c.Window(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "new", Ns: 1e8}}}, time.Second)
+	if len(got.CPU) != 1 || got.CPU[0].Ns != 1e8 || got.CPU[0].Comm != "new" {
+		t.Fatalf("reused PID: %+v", got.CPU)
+	}
+}
+
+func TestCumulativeWindowBlockIOAndSyscalls(t *testing.T) {
+	c := NewCumulative()
+	c.Window(SnapshotResult{
+		BlockIO:  []types.BlockIOStat{{PID: 3, ReadBytes: 4096, Requests: 10, AvgLatencyNs: 1000}},
+		Syscalls: []types.SyscallStat{{PID: 3, TotalNs: 500, Count: 5, TopSyscall: "read", TopSyscallNs: 400}},
+	}, time.Second)
+	got := c.Window(SnapshotResult{
+		BlockIO:  []types.BlockIOStat{{PID: 3, ReadBytes: 8192, Requests: 20, AvgLatencyNs: 2000}},
+		Syscalls: []types.SyscallStat{{PID: 3, TotalNs: 900, Count: 9, TopSyscall: "read", TopSyscallNs: 700}},
+	}, time.Second)
+	// 20 requests averaging 2000ns total 40000ns; the first 10 took 10000ns.
+	if io := got.BlockIO[0]; io.Requests != 10 || io.ReadBytes != 4096 || io.AvgLatencyNs != 3000 {
+		t.Fatalf("block io: %+v", io)
+	}
+	if sc := got.Syscalls[0]; sc.Count != 4 || sc.TotalNs != 400 || sc.TopSyscallNs != 300 {
+		t.Fatalf("syscalls: %+v", sc)
+	}
+}
+
+func TestCumulativeWindowKeepsReadingsOfFailedSubsystem(t *testing.T) {
+	c := NewCumulative()
+	c.Window(SnapshotResult{Futex: []types.FutexStat{{PID: 4, WaitNs: 100, Contended: 1}}}, time.Second)
+	failed := c.Window(SnapshotResult{FutexErr: errors.New("boom")}, time.Second)
+	if failed.Futex != nil || failed.FutexErr == nil {
+		t.Fatalf("failed subsystem should pass through: %+v", failed)
+	}
+	got := c.Window(SnapshotResult{Futex: []types.FutexStat{{PID: 4, WaitNs: 350, Contended: 3}}}, time.Second)
+	if len(got.Futex) != 1 || got.Futex[0].WaitNs != 250 || got.Futex[0].Contended != 2 {
+		t.Fatalf("window after a failure should span both: %+v", got.Futex)
+	}
+}
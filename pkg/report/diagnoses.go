@@ -0,0 +1,192 @@
+package report
+
+import (
+	"fmt"
+
+	"github.com/srodi/hotspot-bpf/pkg/config"
+)
+
+// DiagnosisInfo documents one diagnosis label for -list-diagnoses.
+type DiagnosisInfo struct {
+	Priority    int // evaluation order in classifyProc; labels sharing a rule share a priority
+	Label       string
+	Severity    int
+	Description string
+	Conditions  []string // every condition must hold; an entry may itself be an OR
+}
+
+// Diagnoses describes every label in the order classifyProc evaluates them,
+// with trigger conditions rendered from th. It must be kept in step with
+// classifyProc; TestDiagnosesCoverEveryLabel checks that no label is missing.
+func Diagnoses(th config.Thresholds) []DiagnosisInfo {
+	minFaults := fmt.Sprintf("at least %d faults in the window", th.Faults.MinCount)
+	thrashTiers := fmt.Sprintf("faults/sec > %g with CPU cost/fault > %g ms, or faults/sec > %g with cost/fault > %g ms, or faults/sec > %g",
+		th.MemThrashing.SevereFaultsPerSec, th.MemThrashing.SevereCostPerFault,
+		th.MemThrashing.ModerateFaultsPerSec, th.MemThrashing.ModerateCostPerFault,
+		th.MemThrashing.HighFaultsPerSec)
+	thrashCPU := fmt.Sprintf("CPU < %g%%", th.MemThrashing.MaxCPUPercent)
+
+	thrash := []string{thrashTiers, thrashCPU, minFaults}
+	growth := []string{"disabled (mem_thrashing.working_set_min_pages is 0)"}
+	if th.MemThrashing.WorkingSetMinPages > 0 {
+		thrash = append(thrash, fmt.Sprintf("fewer than %g distinct pages faulted", th.MemThrashing.WorkingSetMinPages))
+		growth = []string{"the Mem-thrashing conditions", fmt.Sprintf("at least %g distinct pages faulted", th.MemThrashing.WorkingSetMinPages)}
+	}
+
+	diskBound := []string{"disabled (disk_bound_faults.min_latency_ms is 0)"}
+	if th.DiskBound.MinLatencyMs > 0 {
+		diskBound = []string{
+			fmt.Sprintf("at least %d major faults in the window", max(th.DiskBound.MinMajorFaults, 1)),
+			fmt.Sprintf("average major-fault latency >= %g ms", th.DiskBound.MinLatencyMs),
+		}
+	}
+
+	tlb := []string{"disabled (tlb_thrashing.min_shootdowns_per_sec is 0)"}
+	if th.TLBThrashing.MinShootdownsPerSec > 0 {
+		tlb = []string{fmt.Sprintf("remote TLB shootdowns initiated/sec >= %g (x86 only)", th.TLBThrashing.MinShootdownsPerSec)}
+	}
+
+	switching := []string{"disabled (switch_thrashing.min_switches_per_sec is 0)"}
+	if th.SwitchThrashing.MinSwitchesPerSec > 0 {
+		switching = []string{
+			fmt.Sprintf("switch-outs/sec >= %g (-cpu-method sched only)", th.SwitchThrashing.MinSwitchesPerSec),
+			fmt.Sprintf("estimated CPU lost to cold caches >= %g%%, at %g µs per switch", th.SwitchThrashing.MinLostPercent, th.SwitchThrashing.CostPerSwitchUs),
+		}
+	}
+
+	dState := []string{"disabled (d_state.min_intervals is 0)"}
+	if th.DState.MinIntervals > 0 {
+		dState = []string{fmt.Sprintf("in D state (/proc/PID/stat) at the end of %d consecutive windows", th.DState.MinIntervals)}
+	}
+
+	leak := []string{"disabled (leak_suspect.min_slope_mb_per_min is 0)"}
+	if th.LeakSuspect.MinSlopeMBPerMin > 0 {
+		leak = []string{
+			fmt.Sprintf("RSS + hugepages grew >= %g MB/min, fitted over the last %d windows", th.LeakSuspect.MinSlopeMBPerMin, max(th.LeakSuspect.WindowTicks, 3)),
+			fmt.Sprintf("R² of the fit >= %g (steady growth)", th.LeakSuspect.MinFit),
+		}
+	}
+
+	lock := []string{"disabled (lock_contended.min_wait_percent is 0)"}
+	if th.LockContended.MinWaitPercent > 0 {
+		lock = []string{
+			fmt.Sprintf("blocked in futex waits >= %g%% of the window, summed across threads", th.LockContended.MinWaitPercent),
+			fmt.Sprintf("futex waits that blocked/sec >= %g", th.LockContended.MinContendedPerSec),
+		}
+	}
+
+	starvedWait := fmt.Sprintf("preempted > %d times while runnable", th.Starved.MinPreempted)
+	if th.Starved.MinRunqWaitPercent > 0 {
+		starvedWait = fmt.Sprintf("waited for a CPU >= %g%% of the window (-cpu-method sched), or preempted > %d times while runnable where run-queue latency is not measured",
+			th.Starved.MinRunqWaitPercent, th.Starved.MinPreempted)
+	}
+
+	oomSize := fmt.Sprintf("RSS + hugepages >= %g MB, or >= %g%% of its cgroup memory limit (of RAM without one)", th.OOM.RSSMB, th.OOM.RSSRatio*100)
+	if th.OOM.ProjectionSeconds > 0 {
+		oomSize += fmt.Sprintf(", or projected to reach its memory limit within %gs", th.OOM.ProjectionSeconds)
+	}
+	oomConditions := []string{
+		fmt.Sprintf("RSS + hugepages never shrank over the last %d ticks and grew by >= %g MB", th.RSSTracker.WindowTicks, th.RSSTracker.MinDeltaMB),
+		oomSize,
+		fmt.Sprintf("faults/sec >= %g", th.OOM.FaultsPerSec),
+		minFaults,
+	}
+	if th.OOM.SwapPerSec > 0 {
+		oomConditions = append(oomConditions, fmt.Sprintf("or, in place of the size and fault conditions, swap-ins + swap-outs/sec >= %g", th.OOM.SwapPerSec))
+	}
+
+	infos := []DiagnosisInfo{
+		{
+			Priority:    1,
+			Label:       "OOM risk – memory growth",
+			Description: "Memory footprint growing under sustained page-fault pressure or while swapping.",
+			Conditions:  oomConditions,
+		},
+		{
+			Priority:    2,
+			Label:       "Leak suspect",
+			Description: "Memory footprint growing steadily over many windows, however small.",
+			Conditions:  leak,
+		},
+		{
+			Priority:    3,
+			Label:       "CPU-bound",
+			Description: "Busy computing, without memory pressure or contention.",
+			Conditions: []string{
+				fmt.Sprintf("CPU > %g%% of the machine, or >= %g%% of one core", th.CPUBound.CPUPercent, th.CPUBound.CoreCPUPercent),
+				fmt.Sprintf("faults/sec < %g", th.CPUBound.MaxFaultsPerSec),
+				fmt.Sprintf("preempted <= %d times", th.CPUBound.MaxPreempted),
+			},
+		},
+		{
+			Priority:    4,
+			Label:       "Swap/disk-bound faults",
+			Description: "Major faults wait long on swap or file I/O.",
+			Conditions:  diskBound,
+		},
+		{
+			Priority:    5,
+			Label:       "Stuck in D-state",
+			Description: "In uninterruptible sleep, usually on I/O that does not complete, window after window.",
+			Conditions:  dState,
+		},
+		{
+			Priority:    6,
+			Label:       "Mem-thrashing",
+			Description: "Costly or very frequent page faults on a small hot set, with little CPU.",
+			Conditions:  thrash,
+		},
+		{
+			Priority:    6,
+			Label:       "Working-set growth",
+			Description: "The same fault pressure, spread over many new pages.",
+			Conditions:  growth,
+		},
+		{
+			Priority:    7,
+			Label:       "Lock-contended",
+			Description: "Threads queueing on contended userspace locks.",
+			Conditions:  lock,
+		},
+		{
+			Priority:    8,
+			Label:       "Starved",
+			Description: "Waiting for a CPU, getting little CPU.",
+			Conditions: []string{
+				starvedWait,
+				fmt.Sprintf("CPU < %g%%", th.Starved.MaxCPUPercent),
+			},
+		},
+		{
+			Priority:    9,
+			Label:       "Noisy neighbor",
+			Description: "Preempting others while using significant CPU.",
+			Conditions: []string{
+				fmt.Sprintf("preempted other processes > %d times", th.NoisyNeighbr.MinPreemptsOthers),
+				fmt.Sprintf("CPU > %g%%", th.NoisyNeighbr.MinCPUPercent),
+			},
+		},
+		{
+			Priority:    10,
+			Label:       "TLB-thrashing",
+			Description: "Forcing frequent TLB shootdowns onto other CPUs.",
+			Conditions:  tlb,
+		},
+		{
+			Priority:    11,
+			Label:       "Switch-thrashing",
+			Description: "Switched out so often that caches stay cold (hint).",
+			Conditions:  switching,
+		},
+		{
+			Priority:    12,
+			Label:       "OK",
+			Description: "No anomaly detected.",
+			Conditions:  []string{"no rule above matched"},
+		},
+	}
+	for i := range infos {
+		infos[i].Severity = diagnosisSeverity(infos[i].Label)
+	}
+	return infos
+}
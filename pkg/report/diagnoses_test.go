@@ -0,0 +1,47 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/srodi/hotspot-bpf/pkg/config"
+)
+
+func TestDiagnosesCoverEveryLabel(t *testing.T) {
+	infos := Diagnoses(config.Default())
+	seen := make(map[string]bool, len(infos))
+	for i, info := range infos {
+		seen[info.Label] = true
+		if info.Description == "" || len(info.Conditions) == 0 {
+			t.Fatalf("%s is missing a description or conditions", info.Label)
+		}
+		if info.Severity != diagnosisSeverity(info.Label) {
+			t.Fatalf("%s: severity %d, want %d", info.Label, info.Severity, diagnosisSeverity(info.Label))
+		}
+		if i > 0 && info.Priority < infos[i-1].Priority {
+			t.Fatalf("%s listed out of evaluation order", info.Label)
+		}
+	}
+	for _, label := range append(diagnosisLabels, "OK") {
+		if !seen[label] {
+			t.Fatalf("Diagnoses() does not document %q", label)
+		}
+	}
+}
+
+func TestDiagnosesRenderActiveThresholds(t *testing.T) {
+	th := config.Default()
+	th.Starved.MinPreempted = 321
+	th.TLBThrashing.MinShootdownsPerSec = 0
+
+	conditions := make(map[string]string)
+	for _, info := range Diagnoses(th) {
+		conditions[info.Label] = strings.Join(info.Conditions, "; ")
+	}
+	if !strings.Contains(conditions["Starved"], "preempted > 321 times") {
+		t.Fatalf("Starved conditions do not reflect the config: %q", conditions["Starved"])
+	}
+	if !strings.Contains(conditions["TLB-thrashing"], "disabled") {
+		t.Fatalf("a zero threshold should be reported as disabled: %q", conditions["TLB-thrashing"])
+	}
+}
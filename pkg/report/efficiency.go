@@ -0,0 +1,41 @@
+package report
+
+// The constants of MemoryEfficiency. They match the severe Mem-thrashing
+// gates of the default thresholds but are fixed, so scores compare across
+// hosts whatever thresholds each runs with.
+const (
+	// efficiencyFaultRate is the fault rate (faults/sec) at which faults
+	// take a quarter of the score when cheap, and up to half when costly.
+	efficiencyFaultRate = 1000
+	// efficiencyCost is the CPU cost per fault (ms) at which faults weigh
+	// half again as much as cheap ones.
+	efficiencyCost = 0.5
+	// efficiencyRSSWeight is the share of the score lost by a process whose
+	// footprint fills its memory limit (or RAM).
+	efficiencyRSSWeight = 0.3
+)
+
+// MemoryEfficiency scores how freely a process uses memory, from 100 (no
+// faults, small footprint) down towards 0 (faulting heavily and expensively
+// while filling its limit), so lower means more memory-bound. It combines
+// the fault rate (faults/sec), the CPU cost per fault (ms), and the RSS
+// ratio (footprint over limit, 0 to 1):
+//
+//	faultLoad = faults / (faults + 1000)
+//	costLoad  = cost / (cost + 0.5)
+//	score     = 100 × (1 − faultLoad × (1 + costLoad) / 2) × (1 − 0.3 × ratio)
+//
+// Fault and cost loads grow smoothly towards 1 rather than against hard
+// cutoffs, so the score has no steps for a process to hover across. The
+// cost only counts through the fault load: without faults CPUCostPerFault
+// is just the CPU rate. Negative, NaN, and infinite inputs count as 0, and
+// ratios above 1 as 1.
+func MemoryEfficiency(faultsPerSec, costPerFaultMs, rssRatio float64) float64 {
+	faults := finiteOrZero(faultsPerSec)
+	cost := finiteOrZero(costPerFaultMs)
+	ratio := min(finiteOrZero(rssRatio), 1)
+
+	faultLoad := faults / (faults + efficiencyFaultRate)
+	costLoad := cost / (cost + efficiencyCost)
+	return 100 * (1 - faultLoad*(1+costLoad)/2) * (1 - efficiencyRSSWeight*ratio)
+}
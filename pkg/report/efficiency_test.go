@@ -0,0 +1,47 @@
+package report
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMemoryEfficiency(t *testing.T) {
+	cases := []struct {
+		name                string
+		faults, cost, ratio float64
+		want                float64
+	}{
+		{"idle", 0, 0, 0, 100},
+		{"cpuBoundNoFaults", 0, 800, 0, 100}, // cost without faults is only the CPU rate
+		{"cheapFaults", 1000, 0, 0, 75},
+		{"costlyFaults", 1000, 0.5, 0, 62.5},
+		{"fullLimit", 0, 0, 1, 70},
+		{"overLimit", 0, 0, 3, 70},
+		{"thrashingAtLimit", 1000, 0.5, 1, 43.75},
+		{"badInputs", math.NaN(), math.Inf(1), -1, 100},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := MemoryEfficiency(tc.faults, tc.cost, tc.ratio); math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("MemoryEfficiency(%g, %g, %g) = %g; want %g", tc.faults, tc.cost, tc.ratio, got, tc.want)
+			}
+		})
+	}
+
+	// Bounded, and never rises with more or costlier faults or a bigger
+	// footprint.
+	prev := 100.0
+	for _, f := range []float64{1, 10, 100, 1e3, 1e4, 1e6, 1e12} {
+		got := MemoryEfficiency(f, 0.2, 0.5)
+		if got < 0 || got > 100 || got > prev {
+			t.Fatalf("MemoryEfficiency(%g, 0.2, 0.5) = %g after %g", f, got, prev)
+		}
+		prev = got
+	}
+	if MemoryEfficiency(500, 1, 0.4) >= MemoryEfficiency(500, 0.1, 0.4) {
+		t.Fatal("costlier faults should score lower")
+	}
+	if MemoryEfficiency(500, 0.1, 0.8) >= MemoryEfficiency(500, 0.1, 0.4) {
+		t.Fatal("a fuller footprint should score lower")
+	}
+}
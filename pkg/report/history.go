@@ -0,0 +1,82 @@
+package report
+
+import (
+	"math"
+
+	"github.com/srodi/hotspot-bpf/pkg/config"
+)
+
+// History keeps an exponentially weighted moving average of each process's
+// CPUPercent and FaultsPerSec across intervals, so a process that has been
+// hot for several intervals can be told from a one-interval spike. Like
+// RSSTracker's state, entries are keyed by PID+starttime, evicted after
+// IdleTicks intervals unseen, and capped at MaxTracked processes.
+//
+// History is not safe for concurrent use.
+type History struct {
+	alpha float64
+	procs *stateTable[ewmaSample]
+}
+
+// ewmaSample is one process's moving averages and the comm they were
+// recorded under.
+type ewmaSample struct {
+	comm         string
+	cpuPercent   float64
+	faultsPerSec float64
+	valid        bool
+}
+
+// NewHistory returns a History in which each interval's value has weight
+// alpha, in (0, 1]: at 1 the averages are the latest values, and at 0.3 an
+// interval's weight falls below 10% after about six more intervals. The
+// eviction bounds come from cfg as for NewRSSTracker.
+func NewHistory(alpha float64, cfg config.RSSTrackerConfig) *History {
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	maxTracked := cfg.MaxTracked
+	if maxTracked <= 0 {
+		maxTracked = defaultMaxTracked
+	}
+	idleTicks := cfg.IdleTicks
+	if idleTicks <= 0 {
+		idleTicks = defaultIdleTicks
+	}
+	return &History{alpha: alpha, procs: newStateTable[ewmaSample](maxTracked, idleTicks)}
+}
+
+// Update folds one interval's values into the process's averages and
+// returns them. The first interval of a process, or the first after it
+// exec'd into a different comm, starts the averages at its values. Values
+// that are not finite and non-negative count as zero, so one bad sample
+// cannot poison the averages for good.
+func (h *History) Update(key ProcKey, comm string, cpuPercent, faultsPerSec float64) (smoothedCPU, smoothedFaults float64) {
+	cpuPercent, faultsPerSec = finiteOrZero(cpuPercent), finiteOrZero(faultsPerSec)
+	s := h.procs.touch(key)
+	if !s.valid || s.comm != comm {
+		*s = ewmaSample{comm: comm, cpuPercent: cpuPercent, faultsPerSec: faultsPerSec, valid: true}
+		return s.cpuPercent, s.faultsPerSec
+	}
+	s.cpuPercent += h.alpha * (cpuPercent - s.cpuPercent)
+	s.faultsPerSec += h.alpha * (faultsPerSec - s.faultsPerSec)
+	return s.cpuPercent, s.faultsPerSec
+}
+
+// Advance ends the current interval and evicts processes that have not been
+// updated for the configured number of idle intervals.
+func (h *History) Advance() {
+	h.procs.advance()
+}
+
+// Len returns the number of processes currently tracked.
+func (h *History) Len() int {
+	return h.procs.len()
+}
+
+func finiteOrZero(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+		return 0
+	}
+	return v
+}
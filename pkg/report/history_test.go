@@ -0,0 +1,106 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
+	"github.com/srodi/hotspot-bpf/pkg/config"
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestHistoryMovingAverage(t *testing.T) {
+	h := NewHistory(0.5, config.RSSTrackerConfig{})
+	key := ProcKey{PID: 42, StartTime: 1000}
+
+	if cpu, faults := h.Update(key, "java", 40, 100); cpu != 40 || faults != 100 {
+		t.Fatalf("first interval = %g, %g; want the values themselves", cpu, faults)
+	}
+	h.Advance()
+	if cpu, faults := h.Update(key, "java", 0, 300); cpu != 20 || faults != 200 {
+		t.Fatalf("second interval = %g, %g; want 20, 200", cpu, faults)
+	}
+	h.Advance()
+	if cpu, _ := h.Update(key, "java", math.NaN(), 0); cpu != 10 {
+		t.Fatalf("NaN sample = %g; want it counted as 0, giving 10", cpu)
+	}
+	h.Advance()
+
+	// An exec into another program starts over.
+	if cpu, faults := h.Update(key, "sh", 5, 1); cpu != 5 || faults != 1 {
+		t.Fatalf("after exec = %g, %g; want 5, 1", cpu, faults)
+	}
+}
+
+func TestHistoryEvictsIdleProcesses(t *testing.T) {
+	h := NewHistory(0.3, config.RSSTrackerConfig{IdleTicks: 2})
+	h.Update(ProcKey{PID: 1}, "gone", 50, 0)
+	for range 3 {
+		h.Update(ProcKey{PID: 2}, "busy", 50, 0)
+		h.Advance()
+	}
+	if h.Len() != 1 {
+		t.Fatalf("tracked %d processes; want the idle one evicted", h.Len())
+	}
+	// The evicted process starts over when it comes back.
+	if cpu, _ := h.Update(ProcKey{PID: 1}, "gone", 10, 0); cpu != 10 {
+		t.Fatalf("returning process = %g; want 10", cpu)
+	}
+}
+
+func TestBuildProcMetricsSmoothing(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	tracker.SmoothWith(NewHistory(0.5, defaultTh.RSSTracker))
+	busy, _ := BuildProcMetrics(SnapshotResult{
+		CPU:        []types.CPUStat{{PID: 7, Comm: "worker", Ns: 800e6}},
+		PageFaults: []types.PageFaultStat{{PID: 7, Comm: "worker", Faults: 1000, FaultsPerSec: 1000}},
+	}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	_, index := BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "worker", Ns: 0}}}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+
+	row := index[7]
+	if want := busy[0].CPUPercent / 2; row.CPUPercent != 0 || math.Abs(row.SmoothedCPUPercent-want) > 1e-9 {
+		t.Fatalf("idle interval after a busy one: CPUPercent=%g SmoothedCPUPercent=%g; want 0 and %g", row.CPUPercent, row.SmoothedCPUPercent, want)
+	}
+	if row.SmoothedFaultsPerSec != 500 {
+		t.Fatalf("SmoothedFaultsPerSec = %g; want 500", row.SmoothedFaultsPerSec)
+	}
+
+	// Without SmoothWith the fields stay zero.
+	_, index = BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "worker", Ns: 800e6}}}, BuildOptions{Interval: time.Second, Tracker: NewRSSTracker(defaultTh.RSSTracker), Thresholds: defaultTh})
+	if index[7].SmoothedCPUPercent != 0 {
+		t.Fatalf("SmoothedCPUPercent = %g without -smooth", index[7].SmoothedCPUPercent)
+	}
+}
+
+func TestFocusPrefersSustainedOffenders(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 10, Comm: "spike", Diagnosis: "Mem-thrashing", FaultsPerSec: 9000, SmoothedFaultsPerSec: 1500},
+		{PID: 11, Comm: "steady", Diagnosis: "Mem-thrashing", FaultsPerSec: 4000, SmoothedFaultsPerSec: 3900},
+		{PID: 20, Comm: "burst", Diagnosis: "CPU-bound", CoreCPUPercent: 100, CPUPercent: 12.5, SmoothedCPUPercent: 3},
+		{PID: 21, Comm: "hog", Diagnosis: "CPU-bound", CoreCPUPercent: 90, CPUPercent: 11.2, SmoothedCPUPercent: 11},
+	}
+	for _, g := range SelectFocusGroups(rows) {
+		if first := g.Procs[0].Comm; first != "steady" && first != "hog" {
+			t.Fatalf("%s group leads with %s; want the sustained offender", g.Diagnosis, first)
+		}
+	}
+
+	// Without smoothing the interval's own values rank.
+	for i := range rows {
+		rows[i].SmoothedCPUPercent, rows[i].SmoothedFaultsPerSec = 0, 0
+	}
+	for _, g := range SelectFocusGroups(rows) {
+		if first := g.Procs[0].Comm; first != "spike" && first != "burst" {
+			t.Fatalf("%s group leads with %s; want the larger interval value", g.Diagnosis, first)
+		}
+	}
+}
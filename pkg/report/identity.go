@@ -0,0 +1,179 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IdentityStrategy selects how a process is identified in exported metric
+// series. PIDs change every time a service restarts, which breaks the
+// continuity of long-lived series; comm+cgroup survives restarts.
+type IdentityStrategy string
+
+const (
+	// IdentityPID labels each series by PID (one series per process instance).
+	IdentityPID IdentityStrategy = "pid"
+	// IdentityCommCgroup labels each series by command name and cgroup, so a
+	// restarted service keeps the same series. Processes that share both are
+	// merged into one series.
+	IdentityCommCgroup IdentityStrategy = "comm-cgroup"
+)
+
+// ParseIdentityStrategy validates a -identity flag value.
+func ParseIdentityStrategy(s string) (IdentityStrategy, error) {
+	switch strategy := IdentityStrategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case IdentityPID, IdentityCommCgroup:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown identity strategy %q (want %s or %s)", s, IdentityPID, IdentityCommCgroup)
+	}
+}
+
+// Identity returns the series key for row under the strategy.
+func (s IdentityStrategy) Identity(row ProcMetrics) string {
+	if s == IdentityCommCgroup {
+		return row.Comm + "@" + row.Cgroup
+	}
+	return strconv.FormatUint(uint64(row.PID), 10)
+}
+
+// LabelNames returns the keys of the map Labels returns, in a fixed order.
+func (s IdentityStrategy) LabelNames() []string {
+	if s == IdentityCommCgroup {
+		return []string{"comm", "cgroup"}
+	}
+	return []string{"pid", "comm", "cgroup"}
+}
+
+// Labels returns the metric labels identifying row under the strategy. The
+// pid label is omitted for comm-cgroup so restarts do not create new series.
+func (s IdentityStrategy) Labels(row ProcMetrics) map[string]string {
+	labels := map[string]string{"comm": row.Comm, "cgroup": row.Cgroup}
+	if s != IdentityCommCgroup {
+		labels["pid"] = strconv.FormatUint(uint64(row.PID), 10)
+	}
+	return labels
+}
+
+// MergeByIdentity collapses rows that share an identity into one row so each
+// exported series is unique. Counters and rates are summed, RSS is summed
+// across the group, and the most severe diagnosis wins. Merged rows keep the
+// lowest PID, with its HostPID and NSPid, as a representative. Fields that do
+// not simply sum are merged as follows:
+//
+//   - CPUCostPerFault, SwitchLossPercent, AllowedCPUs, CPUTrend, and
+//     FaultsTrend are not recomputed and keep the first row's value.
+//   - MajorFaultLatencyMs is recomputed from the summed major-fault time.
+//   - MemEfficiency is rescored from the merged fault rate and RSS ratio.
+//   - TimeToLimit keeps the soonest member projection rather than
+//     re-projecting the summed growth.
+//   - RunqMaxMs keeps the longest member wait.
+//   - IOLatencyMs is averaged over the summed requests.
+//   - TopSyscall comes from the member with the most syscall time.
+//
+// Under IdentityPID rows are returned as-is.
+func MergeByIdentity(rows []ProcMetrics, strategy IdentityStrategy) []ProcMetrics {
+	if strategy != IdentityCommCgroup {
+		return rows
+	}
+	merged := make(map[string]*ProcMetrics, len(rows))
+	order := make([]string, 0, len(rows))
+	topSyscallMs := make(map[string]float64, len(rows)) // SyscallMs of the member TopSyscall came from
+	for _, row := range rows {
+		id := strategy.Identity(row)
+		acc, ok := merged[id]
+		if !ok {
+			copy := row
+			merged[id] = &copy
+			topSyscallMs[id] = row.SyscallMs
+			order = append(order, id)
+			continue
+		}
+		if row.PID < acc.PID {
+			acc.PID, acc.HostPID, acc.NSPid = row.PID, row.HostPID, row.NSPid
+		}
+		acc.CPUNs += row.CPUNs
+		acc.CPUMs += row.CPUMs
+		acc.OffCPUMs += row.OffCPUMs
+		acc.RunqWaitMs += row.RunqWaitMs
+		acc.RunqMaxMs = max(acc.RunqMaxMs, row.RunqMaxMs)
+		acc.RunqWaitPercent += row.RunqWaitPercent
+		acc.RunqTracked = acc.RunqTracked || row.RunqTracked
+		if requests := acc.IORequests + row.IORequests; requests > 0 {
+			acc.IOLatencyMs = (acc.IOLatencyMs*float64(acc.IORequests) + row.IOLatencyMs*float64(row.IORequests)) / float64(requests)
+		}
+		acc.IOReadBytes += row.IOReadBytes
+		acc.IOWriteBytes += row.IOWriteBytes
+		acc.IORequests += row.IORequests
+		if row.SyscallMs > topSyscallMs[id] {
+			acc.TopSyscall = row.TopSyscall
+			topSyscallMs[id] = row.SyscallMs
+		}
+		acc.SyscallMs += row.SyscallMs
+		acc.Syscalls += row.Syscalls
+		acc.SyscallsPerSec += row.SyscallsPerSec
+		acc.SyscallPercent += row.SyscallPercent
+		acc.FutexWaitMs += row.FutexWaitMs
+		acc.FutexContended += row.FutexContended
+		acc.FutexContendedPerSec += row.FutexContendedPerSec
+		acc.FutexWaitPercent += row.FutexWaitPercent
+		acc.NetTxBytes += row.NetTxBytes
+		acc.NetRxBytes += row.NetRxBytes
+		acc.NetTxBytesPerSec += row.NetTxBytesPerSec
+		acc.NetRxBytesPerSec += row.NetRxBytesPerSec
+		acc.CPUPercent += row.CPUPercent
+		acc.CoreCPUPercent += row.CoreCPUPercent
+		acc.SmoothedCPUPercent += row.SmoothedCPUPercent
+		acc.CPUPercentMax += row.CPUPercentMax // an upper bound: the members' busiest sub-windows may differ
+		acc.CPUPercentAvg += row.CPUPercentAvg
+		acc.RSSMB += row.RSSMB
+		acc.RSSMinMB += row.RSSMinMB
+		acc.RSSMaxMB += row.RSSMaxMB
+		acc.HugepagesMB += row.HugepagesMB
+		acc.RSSRatio += row.RSSRatio
+		acc.Faults += row.Faults
+		acc.FaultsPerSec += row.FaultsPerSec
+		acc.SmoothedFaultsPerSec += row.SmoothedFaultsPerSec
+		acc.MajorFaults += row.MajorFaults
+		acc.MajorFaultsPerSec += row.MajorFaultsPerSec
+		acc.MajorFaultNs += row.MajorFaultNs
+		acc.MajorFaultLatencyMs = majorFaultLatencyMs(acc.MajorFaultNs, acc.MajorFaults)
+		acc.Preempted += row.Preempted
+		acc.PreemptsOthers += row.PreemptsOthers
+		acc.TLBShootdowns += row.TLBShootdowns
+		acc.TLBShootdownsPerSec += row.TLBShootdownsPerSec
+		acc.SwapIns += row.SwapIns
+		acc.SwapOuts += row.SwapOuts
+		acc.SwapInsPerSec += row.SwapInsPerSec
+		acc.SwapOutsPerSec += row.SwapOutsPerSec
+		acc.Switches += row.Switches
+		acc.SwitchesPerSec += row.SwitchesPerSec
+		acc.VolCtxSwitches += row.VolCtxSwitches
+		acc.InvolCtxSwitches += row.InvolCtxSwitches
+		if row.IOBlocked() {
+			acc.State = row.State
+		}
+		acc.DStateIntervals = max(acc.DStateIntervals, row.DStateIntervals)
+		acc.RSSGrowing = acc.RSSGrowing || row.RSSGrowing
+		acc.GrowthMBPerSec += row.GrowthMBPerSec
+		acc.RSSSlopeMBPerMin += row.RSSSlopeMBPerMin
+		acc.RSSSlopeFit = max(acc.RSSSlopeFit, row.RSSSlopeFit)
+		if row.LimitKind != "" && (acc.LimitKind == "" || row.TimeToLimit < acc.TimeToLimit) {
+			acc.TimeToLimit = row.TimeToLimit
+			acc.LimitKind = row.LimitKind
+		}
+		if diagnosisSeverity(row.Diagnosis) > diagnosisSeverity(acc.Diagnosis) {
+			acc.Diagnosis = row.Diagnosis
+		}
+	}
+	sort.Strings(order)
+	result := make([]ProcMetrics, 0, len(order))
+	for _, id := range order {
+		row := merged[id]
+		row.MemEfficiency = MemoryEfficiency(row.FaultsPerSec, row.CPUCostPerFault, row.RSSRatio)
+		result = append(result, *row)
+	}
+	return result
+}
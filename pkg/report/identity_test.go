@@ -0,0 +1,81 @@
+package report
+
+import "testing"
+
+func TestParseIdentityStrategy(t *testing.T) {
+	for _, in := range []string{"pid", "PID", " comm-cgroup "} {
+		if _, err := ParseIdentityStrategy(in); err != nil {
+			t.Fatalf("ParseIdentityStrategy(%q): %v", in, err)
+		}
+	}
+	if _, err := ParseIdentityStrategy("cmdline"); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+func TestIdentityLabels(t *testing.T) {
+	row := ProcMetrics{PID: 42, Comm: "nginx", Cgroup: "/system.slice/nginx.service"}
+
+	pidLabels := IdentityPID.Labels(row)
+	if pidLabels["pid"] != "42" || pidLabels["comm"] != "nginx" {
+		t.Fatalf("unexpected pid labels: %v", pidLabels)
+	}
+
+	stable := IdentityCommCgroup.Labels(row)
+	if _, ok := stable["pid"]; ok {
+		t.Fatalf("comm-cgroup labels must not include pid: %v", stable)
+	}
+	for _, strategy := range []IdentityStrategy{IdentityPID, IdentityCommCgroup} {
+		labels := strategy.Labels(row)
+		names := strategy.LabelNames()
+		if len(names) != len(labels) {
+			t.Fatalf("%s: LabelNames %v do not match Labels %v", strategy, names, labels)
+		}
+		for _, name := range names {
+			if _, ok := labels[name]; !ok {
+				t.Fatalf("%s: Labels %v missing %q", strategy, labels, name)
+			}
+		}
+	}
+	restarted := row
+	restarted.PID = 4242
+	if IdentityCommCgroup.Identity(row) != IdentityCommCgroup.Identity(restarted) {
+		t.Fatal("restarted process should keep the same comm-cgroup identity")
+	}
+	if IdentityPID.Identity(row) == IdentityPID.Identity(restarted) {
+		t.Fatal("pid identity should change across restarts")
+	}
+}
+
+func TestMergeByIdentity(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 20, Comm: "worker", Cgroup: "/app", CPUPercent: 10, Faults: 5, Preempted: 1, IORequests: 30, IOLatencyMs: 4, SyscallMs: 30, TopSyscall: "read", Diagnosis: "OK"},
+		{PID: 10, Comm: "worker", Cgroup: "/app", CPUPercent: 15, Faults: 7, Preempted: 2, IORequests: 10, IOLatencyMs: 8, SyscallMs: 20, TopSyscall: "futex", Diagnosis: "Starved"},
+		{PID: 30, Comm: "worker", Cgroup: "/other", CPUPercent: 1, Diagnosis: "OK"},
+	}
+
+	if got := MergeByIdentity(rows, IdentityPID); len(got) != 3 {
+		t.Fatalf("pid strategy should not merge, got %d rows", len(got))
+	}
+
+	merged := MergeByIdentity(rows, IdentityCommCgroup)
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 merged rows, got %d", len(merged))
+	}
+	app := merged[0]
+	if app.Cgroup != "/app" {
+		t.Fatalf("expected /app first (sorted by identity), got %s", app.Cgroup)
+	}
+	if app.PID != 10 || app.CPUPercent != 25 || app.Faults != 12 || app.Preempted != 3 {
+		t.Fatalf("unexpected merged row: %+v", app)
+	}
+	if app.IORequests != 40 || app.IOLatencyMs != 5 {
+		t.Fatalf("I/O latency should be averaged over all requests: %+v", app)
+	}
+	if app.SyscallMs != 50 || app.TopSyscall != "read" {
+		t.Fatalf("top syscall should come from the member with the most syscall time: %+v", app)
+	}
+	if app.Diagnosis != "Starved" {
+		t.Fatalf("expected most severe diagnosis, got %s", app.Diagnosis)
+	}
+}
@@ -0,0 +1,70 @@
+package report
+
+import "math"
+
+// leakSample is one process's most recent footprint readings, each at the
+// tracker's clock when it was taken, and the comm they were recorded under.
+type leakSample struct {
+	comm string
+	at   []float64 // seconds on RSSTracker.clock
+	mb   []float64
+}
+
+// rssSlope records the process's footprint and fits a least-squares line
+// through its last window readings. It returns the slope in MB/min and the
+// fit's R², both 0 until window readings are recorded. Readings start over
+// when the process execs into a different comm, like Trend's. A process
+// that misses windows keeps its readings, placed at the times they were
+// taken, until the tracker evicts it.
+func (t *RSSTracker) rssSlope(key ProcKey, comm string, mb float64, window int) (slopeMBPerMin, fit float64) {
+	if window < 3 {
+		window = 3
+	}
+	s := t.leaks.touch(key)
+	if s.comm != comm {
+		*s = leakSample{comm: comm}
+	}
+	s.at = append(s.at, t.clock)
+	s.mb = append(s.mb, finiteOrZero(mb))
+	if n := len(s.at); n > window {
+		s.at = append(s.at[:0], s.at[n-window:]...)
+		s.mb = append(s.mb[:0], s.mb[n-window:]...)
+	}
+	if len(s.at) < window {
+		return 0, 0
+	}
+	slope, fit := linearFit(s.at, s.mb)
+	return slope * 60, fit
+}
+
+// linearFit returns the slope of the least-squares line through the points
+// (x[i], y[i]) and its coefficient of determination R², in [0, 1]. Both are
+// 0 when x does not vary; R² is 0 as well when y does not, since a flat line
+// explains nothing.
+func linearFit(x, y []float64) (slope, r2 float64) {
+	n := float64(len(x))
+	if n < 2 {
+		return 0, 0
+	}
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var sxx, syy, sxy float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slope = sxy / sxx
+	if syy > 0 {
+		r2 = math.Min(sxy*sxy/(sxx*syy), 1)
+	}
+	return slope, r2
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestLinearFit(t *testing.T) {
+	cases := []struct {
+		name      string
+		x, y      []float64
+		slope, r2 float64
+	}{
+		{"steady", []float64{0, 5, 10, 15}, []float64{100, 101, 102, 103}, 0.2, 1},
+		{"flat", []float64{0, 5, 10}, []float64{50, 50, 50}, 0, 0},
+		{"sameTime", []float64{5, 5, 5}, []float64{1, 2, 3}, 0, 0},
+		{"single", []float64{0}, []float64{1}, 0, 0},
+		// One step up, then flat: a positive slope, but a poor fit.
+		{"burst", []float64{0, 1, 2, 3, 4, 5}, []float64{10, 10, 10, 40, 40, 40}, 7.714285714, 0.7714285714},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			slope, r2 := linearFit(tc.x, tc.y)
+			if math.Abs(slope-tc.slope) > 1e-6 || math.Abs(r2-tc.r2) > 1e-6 {
+				t.Fatalf("linearFit = %g, %g; want %g, %g", slope, r2, tc.slope, tc.r2)
+			}
+		})
+	}
+}
+
+func TestRSSTrackerSlope(t *testing.T) {
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	key := ProcKey{PID: 42, StartTime: 1000}
+	var slope, fit float64
+	for i := range 4 {
+		tracker.clock += 30
+		slope, fit = tracker.rssSlope(key, "leaky", 100+float64(i), 4)
+		if i < 3 && (slope != 0 || fit != 0) {
+			t.Fatalf("reading %d: slope %g, fit %g before the window filled", i, slope, fit)
+		}
+	}
+	if math.Abs(slope-2) > 1e-9 || math.Abs(fit-1) > 1e-9 {
+		t.Fatalf("slope %g MB/min, fit %g; want 2 MB/min (1 MB per 30s), 1", slope, fit)
+	}
+
+	// Only the last window readings count: a drop before them is forgotten.
+	tracker.clock += 30
+	if slope, _ = tracker.rssSlope(key, "leaky", 104, 4); math.Abs(slope-2) > 1e-9 {
+		t.Fatalf("sliding window slope %g; want 2", slope)
+	}
+
+	// An exec starts over.
+	tracker.clock += 30
+	if slope, fit = tracker.rssSlope(key, "sh", 200, 4); slope != 0 || fit != 0 {
+		t.Fatalf("after exec: slope %g, fit %g; want 0", slope, fit)
+	}
+}
+
+func TestRSSTrackerSlopeEvictsExitedProcesses(t *testing.T) {
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	tracker.rssSlope(ProcKey{PID: 1}, "gone", 10, 3)
+	for range defaultTh.RSSTracker.IdleTicks + 1 {
+		tracker.Advance()
+	}
+	if n := tracker.leaks.len(); n != 0 {
+		t.Fatalf("tracking %d slopes after the process disappeared; want 0", n)
+	}
+}
+
+func TestBuildProcMetricsFlagsSlowLeak(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+	})
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	// 64 MB, well under the OOM size gates, growing 1.5 MB every 5s window
+	// in one process and holding steady in the other.
+	var rss uint64 = 64 << 20
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 {
+		return map[int]uint64{42: rss, 43: 64 << 20}
+	}
+
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	stats := []types.CPUStat{{PID: 42, Comm: "leaky", Ns: 1e6}, {PID: 43, Comm: "steady", Ns: 1e6}}
+	var index map[uint32]ProcMetrics
+	for i := range defaultTh.LeakSuspect.WindowTicks {
+		_, index = BuildProcMetrics(SnapshotResult{CPU: stats}, BuildOptions{Interval: 5 * time.Second, Tracker: tracker, Thresholds: defaultTh})
+		if i < defaultTh.LeakSuspect.WindowTicks-1 && index[42].Diagnosis == "Leak suspect" {
+			t.Fatalf("window %d: flagged before the fit window filled", i)
+		}
+		rss += 3 << 19
+	}
+	got := index[42]
+	if math.Abs(got.RSSSlopeMBPerMin-18) > 1e-6 || got.Diagnosis != "Leak suspect" {
+		t.Fatalf("leaking process: slope %g MB/min, diagnosis %q; want 18, Leak suspect", got.RSSSlopeMBPerMin, got.Diagnosis)
+	}
+	if got := index[43]; got.RSSSlopeMBPerMin != 0 || got.Diagnosis != "OK" {
+		t.Fatalf("steady process: slope %g, diagnosis %q", got.RSSSlopeMBPerMin, got.Diagnosis)
+	}
+}
@@ -4,63 +4,261 @@
 // The classification engine (classifyProc) applies a priority-ordered set of
 // heuristic rules. Diagnosis precedence (highest to lowest):
 //
-//   1. OOM risk – memory growth  (RSS growing + large + high fault rate)
-//   2. CPU-bound                  (high CPU, no faults, no preemption)
-//   3. Mem-thrashing              (high fault rate + costly faults, or very high fault volume)
-//   4. Starved                    (frequently preempted, low CPU)
-//   5. Noisy neighbor             (frequently preempts others, high CPU)
-//   6. OK                         (none of the above)
+//  1. OOM risk – memory growth  (RSS growing + large or near its limit + high fault rate, or swapping)
+//  2. Leak suspect               (RSS growing steadily over many windows, at any size)
+//  3. CPU-bound                  (high CPU, no faults, no preemption)
+//  4. Swap/disk-bound faults     (major faults that wait long on swap or file I/O)
+//  5. Stuck in D-state           (in uninterruptible sleep at the end of consecutive windows)
+//  6. Mem-thrashing              (high fault rate + costly faults, or very high fault volume)
+//     Working-set growth         (same gates, but faults spread over many distinct pages)
+//  7. Lock-contended             (threads spend the window blocked in futex waits)
+//  8. Starved                    (long run-queue waits or frequently preempted, low CPU)
+//  9. Noisy neighbor             (frequently preempts others, high CPU)
+//  10. TLB-thrashing              (initiates many remote TLB shootdowns)
+//  11. Switch-thrashing           (switched out so often caches stay cold)
+//  12. OK                         (none of the above)
 //
 // A process is evaluated top-to-bottom and receives the first matching label.
 // All metrics are windowed: they reflect one sampling interval, not cumulative.
 package report
 
 import (
+	"cmp"
 	"fmt"
+	"iter"
+	"maps"
+	"math"
+	"os"
+	"regexp"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/srodi/hotspot-bpf/pkg/cgroup"
 	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
 	"github.com/srodi/hotspot-bpf/pkg/config"
+	"github.com/srodi/hotspot-bpf/pkg/container"
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+	"github.com/srodi/hotspot-bpf/pkg/redact"
 	"github.com/srodi/hotspot-bpf/pkg/types"
 )
 
-// rssBytesForPIDs allows tests to stub RSS lookups that normally hit /proc.
-var rssBytesForPIDs = memory.RSSBytesForPIDs
+// rssBytesForProcs allows tests to stub RSS lookups that normally hit /proc.
+var rssBytesForProcs = memory.RSSBytesForProcs
 
-// RSSTracker records per-PID RSS across ticks to detect growth trends.
+// procStartTime allows tests to stub process start-time lookups that normally hit /proc.
+var procStartTime = procfs.StartTime
+
+// procHugetlbBytes allows tests to stub hugetlb lookups that normally hit /proc.
+var procHugetlbBytes = procfs.HugetlbBytes
+
+// procAllowedCPUs allows tests to stub cpuset lookups that normally hit /proc.
+var procAllowedCPUs = procfs.AllowedCPUs
+
+// procExePath allows tests to stub executable lookups that normally hit /proc.
+var procExePath = procfs.ExePath
+
+// procNSPid allows tests to stub namespace PID lookups that normally hit /proc.
+var procNSPid = procfs.NSPid
+
+// procParentPID allows tests to stub parent lookups that normally hit /proc.
+var procParentPID = parentPID
+
+// selfPID is hotspot's own PID, hidden with its descendants unless
+// FilterConfig.IncludeSelf; tests point it at a fixture row.
+var selfPID = uint32(os.Getpid())
+
+// parentPID returns the PID of the process's parent (/proc/PID/stat field 4).
+func parentPID(pid int) (int, error) {
+	st, err := procfs.ReadStat(pid)
+	return st.PPID, err
+}
+
+// procState allows tests to stub scheduler-state lookups that normally hit /proc.
+var procState = readSchedState
+
+// readSchedState returns the process's scheduler state (/proc/PID/stat field 3),
+// e.g. 'R', 'S', or 'D', and its parent PID (field 4) from the same read.
+func readSchedState(pid int) (byte, int, error) {
+	st, err := procfs.ReadStat(pid)
+	return st.State, st.PPID, err
+}
+
+// maxAncestry bounds the walk up the process tree for -ppid-tree, in case a
+// parent exits and its PID is reused mid-walk.
+const maxAncestry = 64
+
+// procCmdline allows tests to stub command-line lookups that normally hit /proc.
+var procCmdline = procfs.Cmdline
+
+// procCgroupPaths allows tests to stub cgroup lookups that normally hit /proc.
+var procCgroupPaths = procfs.CgroupPaths
+
+// cgroupMemoryLimit allows tests to stub limit lookups that normally hit /sys/fs/cgroup.
+var cgroupMemoryLimit = cgroup.MemoryLimit
+
+// cgroupPathForID allows tests to stub cgroup id lookups that normally walk /sys/fs/cgroup.
+var cgroupPathForID = cgroup.PathForID
+
+// cgroupName returns a collector's cgroup path, resolving the cgroup id the
+// BPF collectors record when the collector did not read the path itself. An
+// id that no longer resolves (the cgroup is gone) shows as "n/a".
+func cgroupName(path string, id uint64) string {
+	if path != "" || id == 0 {
+		return path
+	}
+	if p := cgroupPathForID(id); p != "" {
+		return p
+	}
+	return "n/a"
+}
+
+// memoryHeadroom allows tests to stub memory-limit lookups that normally hit
+// /proc and /sys/fs/cgroup.
+var memoryHeadroom = processMemoryHeadroom
+
+// maxProjection caps time-to-limit projections. A linear extrapolation of a
+// few ticks of growth says little about where memory will be a day from now.
+const maxProjection = 24 * time.Hour
+
+// hugetlbRefreshTicks is how many ticks a process's hugetlb reading is reused
+// before /proc/PID/status is read again. Explicit hugepages are normally
+// mapped once at startup, so reading every tick would cost more than it shows.
+const hugetlbRefreshTicks = 12
+
+// cpusetRefreshTicks is how many ticks a process's allowed CPU count is
+// reused before /proc/PID/status is read again. Affinity can be changed at
+// any time (taskset, a cpuset update), but rarely is.
+const cpusetRefreshTicks = 4
+
+// cmdlineMaxLen caps Cmdline, in runes, so one JVM with a page of flags does
+// not push every other column of a table off the screen.
+const cmdlineMaxLen = 80
+
+// Fallbacks used when the rss_tracker config leaves the eviction bounds unset.
+const (
+	defaultMaxTracked = 4096
+	defaultIdleTicks  = 3
+)
+
+// RSSTracker records per-process memory footprint (RSS plus hugetlb pages)
+// across ticks to detect growth trends, and caches each process's hugetlb
+// usage, allowed CPU count, executable path, command line, cgroup paths, and
+// PID inside the process's own PID namespace.
+// It also follows each process's scheduler state, to count consecutive windows
+// spent in D state, and fits a slope through each process's footprint over a
+// longer window to spot slow leaks. State is keyed by PID+starttime and bounded
+// by config.RSSTrackerConfig: processes not seen for IdleTicks ticks are
+// evicted, and at most MaxTracked processes are kept (least recently seen
+// evicted first).
 type RSSTracker struct {
-	history map[uint32][]float64
+	history *stateTable[[]float64]
+	hugetlb *stateTable[hugetlbSample]
+	cpuset  *stateTable[cpusetSample]
+	exe     *stateTable[exeSample]
+	cmdline *stateTable[exeSample]
+	cgroups *stateTable[[]string]
+	states  *stateTable[stateSample]
+	nspids  *stateTable[nspidSample]
+	leaks   *stateTable[leakSample]
 	maxLen  int
+
+	clock float64 // seconds of windows built so far, the time axis of rssSlope
+
+	fullCmd bool // fill in Cmdline; off unless ShowCmdline was called
+
+	containers *container.Resolver // nil unless ResolveContainers was called
+	smoothing  *History            // nil unless SmoothWith was called
+	trend      *Trend              // nil unless TrendWith was called
+}
+
+// exeSample is a cached executable path or command line and the comm it was
+// read under.
+type exeSample struct {
+	path  string // "" when /proc/PID/exe (or cmdline) could not be read
+	comm  string
+	valid bool
+}
+
+// hugetlbSample is a cached hugetlb reading and how many ticks it has been reused.
+type hugetlbSample struct {
+	mb    float64
+	age   int
+	valid bool
 }
 
-// NewRSSTracker creates a tracker that keeps the last n RSS samples per PID.
-func NewRSSTracker(windowTicks int) *RSSTracker {
+// cpusetSample is a cached allowed CPU count and how many ticks it has been
+// reused.
+type cpusetSample struct {
+	cpus  int
+	age   int
+	valid bool
+}
+
+// nspidSample is a cached namespace PID. A process never changes PID
+// namespace, so it is read once per process instance.
+type nspidSample struct {
+	pid   uint32 // 0 when /proc/PID/status has no NSpid line
+	valid bool
+}
+
+// stateSample is a process's identity as last seen by a collector and how
+// many consecutive windows have ended with it in D state.
+type stateSample struct {
+	comm   string
+	cgroup string
+	dTicks int
+}
+
+// NewRSSTracker creates a tracker that keeps the last cfg.WindowTicks RSS
+// samples per process.
+func NewRSSTracker(cfg config.RSSTrackerConfig) *RSSTracker {
+	windowTicks := cfg.WindowTicks
 	if windowTicks < 2 {
 		windowTicks = 2
 	}
+	maxTracked := cfg.MaxTracked
+	if maxTracked <= 0 {
+		maxTracked = defaultMaxTracked
+	}
+	idleTicks := cfg.IdleTicks
+	if idleTicks <= 0 {
+		idleTicks = defaultIdleTicks
+	}
 	return &RSSTracker{
-		history: make(map[uint32][]float64),
+		history: newStateTable[[]float64](maxTracked, idleTicks),
+		hugetlb: newStateTable[hugetlbSample](maxTracked, idleTicks),
+		cpuset:  newStateTable[cpusetSample](maxTracked, idleTicks),
+		exe:     newStateTable[exeSample](maxTracked, idleTicks),
+		cmdline: newStateTable[exeSample](maxTracked, idleTicks),
+		cgroups: newStateTable[[]string](maxTracked, idleTicks),
+		states:  newStateTable[stateSample](maxTracked, idleTicks),
+		nspids:  newStateTable[nspidSample](maxTracked, idleTicks),
+		leaks:   newStateTable[leakSample](maxTracked, idleTicks),
 		maxLen:  windowTicks,
 	}
 }
 
-// Record stores the current RSS for a PID. Call once per tick after BuildProcMetrics.
-func (t *RSSTracker) Record(pid uint32, rssMB float64) {
-	h := t.history[pid]
-	h = append(h, rssMB)
-	if len(h) > t.maxLen {
-		h = h[len(h)-t.maxLen:]
+// Record stores the current RSS for a process. Call once per tick after BuildProcMetrics.
+func (t *RSSTracker) Record(key ProcKey, rssMB float64) {
+	h := t.history.touch(key)
+	*h = append(*h, rssMB)
+	if len(*h) > t.maxLen {
+		*h = (*h)[len(*h)-t.maxLen:]
 	}
-	t.history[pid] = h
 }
 
-// IsGrowing returns true if the PID's RSS has grown consistently over at least
+// IsGrowing returns true if the process's RSS has grown consistently over at least
 // 2 ticks with a minimum total increase of minDeltaMB.
-func (t *RSSTracker) IsGrowing(pid uint32, minDeltaMB float64) bool {
-	h := t.history[pid]
+func (t *RSSTracker) IsGrowing(key ProcKey, minDeltaMB float64) bool {
+	hp, ok := t.history.get(key)
+	if !ok {
+		return false
+	}
+	h := *hp
 	if len(h) < 2 {
 		return false
 	}
@@ -73,41 +271,460 @@ func (t *RSSTracker) IsGrowing(pid uint32, minDeltaMB float64) bool {
 	return (h[len(h)-1] - h[0]) >= minDeltaMB
 }
 
-// Prune removes PIDs that are no longer active (not in the current tick's set).
-func (t *RSSTracker) Prune(activePIDs map[uint32]bool) {
-	for pid := range t.history {
-		if !activePIDs[pid] {
-			delete(t.history, pid)
+// growthPerTick returns the process's average footprint growth per tick, in
+// MB, over the recorded window.
+func (t *RSSTracker) growthPerTick(key ProcKey) float64 {
+	hp, ok := t.history.get(key)
+	if !ok || len(*hp) < 2 {
+		return 0
+	}
+	h := *hp
+	return (h[len(h)-1] - h[0]) / float64(len(h)-1)
+}
+
+// hugepagesMB returns the process's hugetlb usage in MB, reading /proc at
+// most once every hugetlbRefreshTicks calls per process instance. A failed
+// read is treated as 0 and retried on the next call.
+func (t *RSSTracker) hugepagesMB(key ProcKey) float64 {
+	s := t.hugetlb.touch(key)
+	if s.valid && s.age < hugetlbRefreshTicks {
+		s.age++
+		return s.mb
+	}
+	bytes, err := procHugetlbBytes(int(key.PID))
+	if err != nil {
+		*s = hugetlbSample{}
+		return 0
+	}
+	*s = hugetlbSample{mb: float64(bytes) / (1024 * 1024), age: 1, valid: true}
+	return s.mb
+}
+
+// allowedCPUs returns how many CPUs the process may run on, reading /proc at
+// most once every cpusetRefreshTicks calls per process instance. It returns
+// 0 when the count cannot be read; a failed read is retried on the next call.
+func (t *RSSTracker) allowedCPUs(key ProcKey) int {
+	s := t.cpuset.touch(key)
+	if s.valid && s.age < cpusetRefreshTicks {
+		s.age++
+		return s.cpus
+	}
+	cpus, err := procAllowedCPUs(int(key.PID))
+	if err != nil {
+		*s = cpusetSample{}
+		return 0
+	}
+	*s = cpusetSample{cpus: cpus, age: 1, valid: true}
+	return cpus
+}
+
+// exePath returns the process's executable path, reading /proc only for a
+// new process instance or after an exec, which keeps the PID and start time
+// but changes comm. A failed read (kernel thread, no permission) is cached as
+// "" too; it would fail again.
+func (t *RSSTracker) exePath(key ProcKey, comm string) string {
+	s := t.exe.touch(key)
+	if s.valid && s.comm == comm {
+		return s.path
+	}
+	path, _ := procExePath(int(key.PID))
+	*s = exeSample{path: path, comm: comm, valid: true}
+	return path
+}
+
+// nsPID returns the process's PID in its own PID namespace, reading /proc
+// only for a new process instance. It returns 0 when the kernel does not
+// report it; the failure is cached, since it would fail again.
+func (t *RSSTracker) nsPID(key ProcKey) uint32 {
+	s := t.nspids.touch(key)
+	if !s.valid {
+		pid, _ := procNSPid(int(key.PID))
+		*s = nspidSample{pid: pid, valid: true}
+	}
+	return s.pid
+}
+
+// schedState reads the process's scheduler state at the end of the window
+// and returns it with the parent PID and the number of consecutive windows,
+// this one included, that ended with the process in D state. It returns "",
+// 0, and 0 when /proc cannot be read.
+func (t *RSSTracker) schedState(key ProcKey, comm, cgroup string) (string, uint32, int) {
+	s := t.states.touch(key)
+	s.comm, s.cgroup = comm, cgroup
+	state, ppid, err := procState(int(key.PID))
+	if err != nil {
+		s.dTicks = 0
+		return "", 0, 0
+	}
+	if state == 'D' {
+		s.dTicks++
+	} else {
+		s.dTicks = 0
+	}
+	return string(state), uint32(ppid), s.dTicks
+}
+
+// silentlyBlocked returns the processes the tracker still remembers that no
+// collector reported this window but that are in D state now. A task stuck
+// in uninterruptible sleep never runs, so it produces no eBPF events and
+// would otherwise drop out of every table exactly when it matters. Only
+// processes seen within the last IdleTicks windows are checked, and each
+// against its start time, so a recycled PID is not mistaken for it.
+func (t *RSSTracker) silentlyBlocked(seen map[uint32]*ProcMetrics) []ProcMetrics {
+	var blocked []ProcMetrics
+	for _, key := range t.states.keys() {
+		if _, ok := seen[key.PID]; ok {
+			continue
 		}
+		if key.StartTime != 0 {
+			if start, err := procStartTime(int(key.PID)); err != nil || start != key.StartTime {
+				continue
+			}
+		}
+		state, ppid, err := procState(int(key.PID))
+		if err != nil || state != 'D' {
+			continue
+		}
+		s, _ := t.states.get(key)
+		blocked = append(blocked, ProcMetrics{PID: key.PID, HostPID: key.PID, PPID: uint32(ppid), Comm: s.comm, Cgroup: s.cgroup})
+	}
+	return blocked
+}
+
+// ShowCmdline makes BuildProcMetrics fill in Cmdline. Off by default: it
+// costs a /proc read per new process.
+func (t *RSSTracker) ShowCmdline() {
+	t.fullCmd = true
+}
+
+// cmdlineText returns the process's command line joined with spaces, with
+// secret values masked per redactPatterns and cut to cmdlineMaxLen runes. Like
+// exePath it is read once per process instance and again after an exec. It
+// returns comm when the command line is empty (kernel threads) or unreadable.
+func (t *RSSTracker) cmdlineText(key ProcKey, comm string, redactPatterns []string) string {
+	s := t.cmdline.touch(key)
+	if !s.valid || s.comm != comm {
+		args, _ := procCmdline(int(key.PID))
+		*s = exeSample{path: strings.Join(redact.Args(args, redactPatterns), " "), comm: comm, valid: true}
+		if r := []rune(s.path); len(r) > cmdlineMaxLen {
+			s.path = string(r[:cmdlineMaxLen-1]) + "…"
+		}
+	}
+	if s.path == "" {
+		return comm
+	}
+	return s.path
+}
+
+// ResolveContainers makes BuildProcMetrics fill in ContainerName and PodName
+// using r. Off by default: a cache miss costs a /proc read and possibly a
+// container runtime API call.
+func (t *RSSTracker) ResolveContainers(r *container.Resolver) {
+	t.containers = r
+}
+
+// SmoothWith makes BuildProcMetrics fill in SmoothedCPUPercent and
+// SmoothedFaultsPerSec from h, and rank focus groups by them.
+func (t *RSSTracker) SmoothWith(h *History) {
+	t.smoothing = h
+}
+
+// TrendWith makes BuildProcMetrics fill in CPUTrend and FaultsTrend from tr.
+func (t *RSSTracker) TrendWith(tr *Trend) {
+	t.trend = tr
+}
+
+// cgroupPaths returns the process's cgroup paths, read from /proc once per
+// process instance. A failed read is cached as no paths.
+func (t *RSSTracker) cgroupPaths(key ProcKey) []string {
+	paths := t.cgroups.touch(key)
+	if *paths == nil {
+		p, _ := procCgroupPaths(int(key.PID))
+		*paths = append([]string{}, p...)
+	}
+	return *paths
+}
+
+// Advance ends the current tick and evicts processes that have not been
+// recorded for the configured number of idle ticks.
+func (t *RSSTracker) Advance() {
+	t.history.advance()
+	t.hugetlb.advance()
+	t.cpuset.advance()
+	t.exe.advance()
+	t.cmdline.advance()
+	t.cgroups.advance()
+	t.states.advance()
+	t.nspids.advance()
+	t.leaks.advance()
+	if t.smoothing != nil {
+		t.smoothing.Advance()
+	}
+	if t.trend != nil {
+		t.trend.Advance()
 	}
 }
 
+// Len returns the number of processes currently tracked.
+func (t *RSSTracker) Len() int {
+	return t.history.len()
+}
+
 // ProcMetrics condenses CPU, memory, and contention stats for a PID during one sample window.
 type ProcMetrics struct {
-	PID             uint32
-	Comm            string
-	Cgroup          string
-	CPUNs           uint64
-	CPUMs           float64
-	CPUPercent      float64
-	CPUCore         uint32  // last CPU core observed at switch-out
-	CoreCPUPercent  float64 // CPU% relative to a single core (not system-wide)
-	RSSMB           float64
-	RSSRatio        float64
-	Faults          uint64
-	FaultsPerSec    float64
-	CPUCostPerFault float64
-	Preempted       uint64
-	PreemptsOthers  uint64
-	Diagnosis       string
-	RSSGrowing      bool
+	PID                  uint32
+	TID                  uint32 // the thread a -threads row of the CPU Hotspots table is about (see ThreadRows); 0 in process rows
+	Comm                 string
+	Cgroup               string
+	ContainerName        string // container name from -resolve-containers; "" when unresolved
+	PodName              string // Kubernetes pod from -resolve-containers; "" when unresolved or not in a pod
+	HostPID              uint32 // PID in the host's PID namespace, where the collectors see it; same as PID
+	NSPid                uint32 // PID inside the process's own PID namespace (what the container sees) with -resolve-containers; 0 otherwise
+	ExePath              string // full executable path; "" when unreadable or without a tracker
+	Cmdline              string // redacted, truncated command line with -full-cmd (Comm for kernel threads); "" otherwise
+	CPUNs                uint64
+	CPUMs                float64
+	OffCPUMs             float64 // time threads spent blocked (asleep, not runnable) in the window; summed across threads
+	CPUPercent           float64 // share of the CPUs the process may run on (AllowedCPUs)
+	AllowedCPUs          int     // CPUs in the process's affinity mask and cpuset; NumCPU when unknown or without a tracker
+	CPUCore              uint32  // last CPU core observed at switch-out
+	CoreCPUPercent       float64 // CPU% relative to a single core (not system-wide)
+	SmoothedCPUPercent   float64 // moving average of CPUPercent across intervals with -smooth; 0 otherwise
+	CPUPercentMax        float64 // CPUPercent of the busiest -sample-window sub-window; CPUPercent for a single reading
+	CPUPercentAvg        float64 // CPUPercent over every sub-window, idle ones included; CPUPercent for a single reading
+	CPUTrend             string  // TrendUp, TrendDown, or TrendFlat against the previous interval with -trend; "" otherwise
+	RSSMB                float64
+	RSSMinMB             float64 // lowest RSS observed in-kernel at a fault in the window (0 = no faults or unknown)
+	RSSMaxMB             float64 // highest RSS observed in-kernel at a fault in the window
+	HugepagesMB          float64 // explicit hugetlbfs pages, not included in RSSMB
+	RSSRatio             float64 // memory footprint (RSS + hugepages) as a fraction of MemLimitMB, or of total RAM without one
+	MemLimitMB           float64 // effective cgroup memory limit when below total RAM; 0 = none or unknown
+	Faults               uint64
+	FaultsPerSec         float64
+	SmoothedFaultsPerSec float64 // moving average of FaultsPerSec across intervals with -smooth; 0 otherwise
+	FaultsTrend          string  // direction of FaultsPerSec, like CPUTrend
+	FaultPages           float64 // estimated distinct pages that faulted (0 = unknown)
+	CPUCostPerFault      float64
+	MemEfficiency        float64 // 0–100 from MemoryEfficiency; lower is more memory-bound
+	MajorFaults          uint64  // faults resolved from swap or a file (0 when untimed)
+	MajorFaultsPerSec    float64
+	MajorFaultNs         uint64  // total time spent resolving MajorFaults
+	MajorFaultLatencyMs  float64 // average time per major fault
+	Preempted            uint64
+	PreemptsOthers       uint64
+	TLBShootdowns        uint64 // remote TLB shootdowns this process initiated
+	TLBShootdownsPerSec  float64
+	SwapIns              uint64 // pages read back from swap on this process's faults
+	SwapOuts             uint64 // pages written to swap while this process was reclaiming
+	SwapInsPerSec        float64
+	SwapOutsPerSec       float64
+	RunqWaitMs           float64 // time threads spent runnable but waiting for a CPU in the window; summed across threads
+	RunqMaxMs            float64 // longest single run-queue wait
+	RunqWaitPercent      float64 // RunqWaitMs relative to the window (like CoreCPUPercent, can exceed 100)
+	RunqTracked          bool    // run-queue latency was measured; when false Starved falls back to preemptions
+	IOReadBytes          uint64  // block device reads this process issued that completed in the window
+	IOWriteBytes         uint64  // block device writes, likewise; buffered writes are charged to the flusher
+	IORequests           uint64  // completed block read and write requests
+	IOLatencyMs          float64 // average issue-to-completion time per request
+	SyscallMs            float64 // time threads spent inside system calls in the window, blocked or not; summed across threads
+	Syscalls             uint64  // system calls that returned in the window
+	SyscallsPerSec       float64
+	SyscallPercent       float64 // SyscallMs relative to the window (like CoreCPUPercent, can exceed 100)
+	TopSyscall           string  // the system call that took the most of SyscallMs
+	FutexWaitMs          float64 // time threads spent blocked in futex waits in the window; summed across threads
+	FutexContended       uint64  // futex waits that blocked
+	FutexContendedPerSec float64
+	FutexWaitPercent     float64 // FutexWaitMs relative to the window (like CoreCPUPercent, can exceed 100)
+	NetTxBytes           uint64  // TCP bytes this process sent in the window (-net only)
+	NetRxBytes           uint64  // TCP bytes this process received, likewise
+	NetTxBytesPerSec     float64 // NetTxBytes over the window
+	NetRxBytesPerSec     float64 // NetRxBytes over the window
+	Switches             uint64  // switch-outs in favour of another process
+	SwitchesPerSec       float64
+	VolCtxSwitches       uint64  // switch-outs of threads that blocked (I/O, locks, sleep), to any task
+	InvolCtxSwitches     uint64  // switch-outs of threads preempted while still runnable, to any task
+	SwitchLossPercent    float64 // estimated share of CPU time spent re-warming caches after switches
+	State                string  // scheduler state at the end of the window (R, S, D, ...), from /proc/PID/stat; "" without a tracker or when unreadable
+	PPID                 uint32  // parent PID, from the same /proc/PID/stat read as State; 0 without a tracker or when unreadable
+	DStateIntervals      int     // consecutive windows, this one included, that ended with the process in D state
+	Diagnosis            string
+	RSSGrowing           bool
+	GrowthMBPerSec       float64       // footprint growth rate over the tracker window; set only while RSSGrowing
+	RSSSlopeMBPerMin     float64       // least-squares footprint growth over the last leak_suspect.window_ticks windows; 0 until that many are recorded
+	RSSSlopeFit          float64       // R² of that fit, 0–1: how steadily the footprint grew
+	TimeToLimit          time.Duration // projected time until the footprint reaches LimitKind at GrowthMBPerSec
+	LimitKind            string        // "cgroup" (memory.max) or "RAM" (MemAvailable); "" when not projected
+}
+
+// FootprintMB returns the process's total resident memory: RSS plus explicit
+// hugepages, which the kernel accounts separately.
+func (r ProcMetrics) FootprintMB() float64 {
+	return r.RSSMB + r.HugepagesMB
+}
+
+// IOBlocked reports whether the process was in uninterruptible sleep (D
+// state) when the window ended, usually waiting for disk or network
+// filesystem I/O.
+func (r ProcMetrics) IOBlocked() bool {
+	return r.State == "D"
+}
+
+// CountIOBlocked returns how many rows were in D state when the window ended.
+func CountIOBlocked(rows []ProcMetrics) int {
+	n := 0
+	for _, row := range rows {
+		if row.IOBlocked() {
+			n++
+		}
+	}
+	return n
+}
+
+// PIDLabel returns the process's host PID followed by its PID inside its
+// container, e.g. "48213 (1)", when -resolve-containers read one that
+// differs, and otherwise just the PID.
+func (r ProcMetrics) PIDLabel() string {
+	if r.NSPid != 0 && r.NSPid != r.PID {
+		return fmt.Sprintf("%d (%d)", r.PID, r.NSPid)
+	}
+	return strconv.FormatUint(uint64(r.PID), 10)
+}
+
+// CommLabel returns the process's command line when -full-cmd read one, and
+// otherwise its comm, which the kernel truncates to 15 characters.
+func (r ProcMetrics) CommLabel() string {
+	if r.Cmdline != "" {
+		return r.Cmdline
+	}
+	return r.Comm
+}
+
+// CgroupLabel returns the process's pod and container when they were
+// resolved, e.g. "shop/checkout-7d9f/api", and otherwise its raw cgroup.
+func (r ProcMetrics) CgroupLabel() string {
+	switch {
+	case r.PodName != "" && r.ContainerName != "":
+		return r.PodName + "/" + r.ContainerName
+	case r.PodName != "":
+		return r.PodName
+	case r.ContainerName != "":
+		return r.ContainerName
+	default:
+		return r.Cgroup
+	}
 }
 
 // FilterConfig controls which processes appear in CLI tables.
 type FilterConfig struct {
-	HideKernel   *bool // nil defaults to true so kernel threads stay hidden unless explicitly shown
-	CgroupFilter string
-	Exclude      []string // command names or PIDs to hide
+	HideKernel    *bool          // nil defaults to true so kernel threads stay hidden unless explicitly shown
+	CgroupFilter  []string       // keep only processes whose cgroup contains any of these (case-insensitive)
+	CgroupExclude []string       // hide processes whose cgroup contains any of these (case-insensitive)
+	CgroupRegex   *regexp.Regexp // keep only processes whose cgroup matches; applied as well as CgroupFilter
+	CommRegex     *regexp.Regexp // keep only processes whose command name matches
+	ExeFilter     string         // keep only processes whose executable path contains this (case-sensitive)
+	Exclude       []string       // command names or PIDs to hide
+	GroupByComm   bool           // merge contention pairs of processes that share a command name
+	// Members, when non-nil, restricts output to exactly these PIDs (the
+	// current membership of a -watch-cgroup target), in addition to the
+	// filters above.
+	Members map[uint32]struct{}
+	// PIDs, when non-nil, shows exactly these processes (-pid) and
+	// overrides every other filter: a listed kernel thread or excluded
+	// command is still shown.
+	PIDs map[uint32]struct{}
+	// PPID, when non-zero, keeps only children of this process, read from
+	// /proc/PID/stat; with PPIDTree, every descendant.
+	PPID     uint32
+	PPIDTree bool
+	// IncludeSelf shows hotspot itself and its descendants (-include-self),
+	// which are hidden by default: reading maps and /proc every interval
+	// puts the tool in its own tables.
+	IncludeSelf bool
+
+	// self holds hotspot's PID and its descendants' for one filtering pass;
+	// see selfPIDs.
+	self map[uint32]struct{}
+}
+
+func (cfg FilterConfig) isMember(pid uint32) bool {
+	if cfg.Members == nil {
+		return true
+	}
+	_, ok := cfg.Members[pid]
+	return ok
+}
+
+// listed reports whether pid was given to -pid. It is false for every PID
+// when no list was given.
+func (cfg FilterConfig) listed(pid uint32) bool {
+	_, ok := cfg.PIDs[pid]
+	return ok
+}
+
+// isDescendant reports whether pid is a child of cfg.PPID (or, with
+// PPIDTree, further down its tree). It is true for every PID when PPID is 0.
+// A process whose parent cannot be read (it exited) does not match.
+func (cfg FilterConfig) isDescendant(pid uint32) bool {
+	if cfg.PPID == 0 {
+		return true
+	}
+	for range maxAncestry {
+		parent, err := procParentPID(int(pid))
+		if err != nil || parent <= 0 {
+			return false
+		}
+		if uint32(parent) == cfg.PPID {
+			return true
+		}
+		if !cfg.PPIDTree || parent == 1 {
+			return false
+		}
+		pid = uint32(parent)
+	}
+	return false
+}
+
+// withSelf returns cfg set up to hide hotspot and its descendants among
+// rows, unless IncludeSelf.
+func (cfg FilterConfig) withSelf(rows iter.Seq[ProcMetrics]) FilterConfig {
+	if !cfg.IncludeSelf {
+		cfg.self = selfPIDs(rows)
+	}
+	return cfg
+}
+
+// isSelf reports whether pid is hotspot itself or one of its descendants
+// and is to be hidden. cfg must come from withSelf.
+func (cfg FilterConfig) isSelf(pid uint32) bool {
+	_, ok := cfg.self[pid]
+	return ok
+}
+
+// selfPIDs returns hotspot's PID and those of its descendants among rows,
+// found by following each row's PPID down from selfPID, so a grandchild is
+// hidden along with the child that forked it. The set is built once per
+// pass from PPIDs the tracker already read, rather than from /proc per row;
+// a descendant whose parent has no row this window is not found.
+func selfPIDs(rows iter.Seq[ProcMetrics]) map[uint32]struct{} {
+	children := make(map[uint32][]uint32)
+	for row := range rows {
+		if row.PPID != 0 {
+			children[row.PPID] = append(children[row.PPID], row.PID)
+		}
+	}
+	self := map[uint32]struct{}{selfPID: {}}
+	queue := []uint32{selfPID}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if _, ok := self[child]; !ok {
+				self[child] = struct{}{}
+				queue = append(queue, child)
+			}
+		}
+	}
+	return self
 }
 
 func (cfg FilterConfig) hideKernelEnabled() bool {
@@ -117,18 +734,24 @@ func (cfg FilterConfig) hideKernelEnabled() bool {
 	return *cfg.HideKernel
 }
 
-// BuildProcMetrics merges raw collector stats into per-PID rows and returns both
-// a slice for table rendering and an index for quick lookups.
-// If rssTracker is non-nil, it records RSS and marks processes with growing RSS.
-// The thresholds parameter controls all classification gates.
-func BuildProcMetrics(
-	cpuStats []types.CPUStat,
-	pageFaults []types.PageFaultStat,
-	contention []types.ContentionStat,
-	interval time.Duration,
-	rssTracker *RSSTracker,
-	thresholds config.Thresholds,
-) ([]ProcMetrics, map[uint32]ProcMetrics) {
+// BuildOptions are the window-wide inputs to BuildProcMetrics.
+type BuildOptions struct {
+	Interval   time.Duration     // measured length of the window
+	Tracker    *RSSTracker       // records RSS and marks growth across windows; nil disables
+	Thresholds config.Thresholds // classification gates
+}
+
+// BuildProcMetrics merges one window of raw collector stats into per-PID rows
+// and returns both a slice for table rendering and an index for quick lookups.
+// Failed subsystems in snap have nil slices and simply contribute nothing.
+// Cgroup ids from the BPF collectors are resolved to paths here.
+// A nil snap.RunqLatency means run-queue latency was not measured, as opposed
+// to an empty one where no process waited. BlockIO adds per-process disk
+// traffic, Syscalls the time spent in system calls, Futex the time blocked on
+// userspace locks, and Net the TCP bytes sent and received.
+func BuildProcMetrics(snap SnapshotResult, opts BuildOptions) ([]ProcMetrics, map[uint32]ProcMetrics) {
+	interval, rssTracker, thresholds := opts.Interval, opts.Tracker, opts.Thresholds
+
 	// compute once
 	totalMemBytes, err := memory.TotalMemoryBytes()
 	if err != nil || totalMemBytes == 0 {
@@ -137,17 +760,22 @@ func BuildProcMetrics(
 
 	rows := make(map[uint32]*ProcMetrics)
 	ensure := func(pid uint32) *ProcMetrics {
-		if pid == 0 {
+		if isIdleTask(pid) {
 			return nil
 		}
 		if row, ok := rows[pid]; ok {
 			return row
 		}
-		row := &ProcMetrics{PID: pid}
+		row := &ProcMetrics{PID: pid, HostPID: pid, AllowedCPUs: runtime.NumCPU()}
 		rows[pid] = row
 		return row
 	}
 
+	// interval should be the measured length of the window, not the
+	// configured one: a window stretched by a slow tick would otherwise
+	// overstate every percentage.
+	// CPU% is first taken over every CPU and narrowed below, with a tracker,
+	// to the CPUs the process may run on.
 	totalCapacity := float64(interval.Nanoseconds()) * float64(runtime.NumCPU())
 	singleCoreCapacity := float64(interval.Nanoseconds())
 	intervalSeconds := interval.Seconds()
@@ -155,27 +783,36 @@ func BuildProcMetrics(
 		intervalSeconds = 1
 	}
 
-	for _, stat := range cpuStats {
+	for _, stat := range snap.CPU {
 		row := ensure(stat.PID)
 		if row == nil {
 			continue
 		}
 		row.Comm = stat.Comm
 		if row.Cgroup == "" {
-			row.Cgroup = stat.Cgroup
+			row.Cgroup = cgroupName(stat.Cgroup, stat.CgroupID)
 		}
 		row.CPUNs = stat.Ns
 		row.CPUMs = float64(stat.Ns) / 1e6
+		row.OffCPUMs = float64(stat.OffCPUNs) / 1e6
 		row.CPUCore = stat.CPUCore
-		if totalCapacity > 0 {
-			row.CPUPercent = 100 * float64(stat.Ns) / totalCapacity
-		}
+		row.Switches = stat.Switches
+		row.SwitchesPerSec = float64(stat.Switches) / intervalSeconds
+		row.SwitchLossPercent = switchLossPercent(stat.Switches, stat.Ns, thresholds.SwitchThrashing.CostPerSwitchUs)
+		row.VolCtxSwitches = stat.VolSwitches
+		row.InvolCtxSwitches = stat.InvolSwitches
+		row.CPUPercent = cpuPercent(stat.Ns, totalCapacity)
 		if singleCoreCapacity > 0 {
-			row.CoreCPUPercent = 100 * float64(stat.Ns) / singleCoreCapacity
+			row.CoreCPUPercent = min(100*float64(stat.Ns)/singleCoreCapacity, 100*float64(runtime.NumCPU()))
+		}
+		row.CPUPercentMax, row.CPUPercentAvg = row.CPUPercent, row.CPUPercent
+		if stat.PeakNsPerSec > 0 || stat.MeanNsPerSec > 0 {
+			row.CPUPercentMax = rateCPUPercent(stat.PeakNsPerSec, runtime.NumCPU())
+			row.CPUPercentAvg = rateCPUPercent(stat.MeanNsPerSec, runtime.NumCPU())
 		}
 	}
 
-	for _, pf := range pageFaults {
+	for _, pf := range snap.PageFaults {
 		row := ensure(pf.PID)
 		if row == nil {
 			continue
@@ -184,16 +821,35 @@ func BuildProcMetrics(
 			row.Comm = pf.Comm
 		}
 		if row.Cgroup == "" {
-			row.Cgroup = pf.Cgroup
+			row.Cgroup = cgroupName("", pf.CgroupID)
 		}
 		row.Faults = pf.Faults
 		row.FaultsPerSec = pf.FaultsPerSec
+		row.FaultPages = pf.FaultPages
+		row.MajorFaults = pf.MajorFaults
+		row.MajorFaultsPerSec = float64(pf.MajorFaults) / intervalSeconds
+		row.MajorFaultNs = pf.MajorFaultNs
+		row.MajorFaultLatencyMs = majorFaultLatencyMs(pf.MajorFaultNs, pf.MajorFaults)
+		row.TLBShootdowns = pf.TLBShootdowns
+		row.TLBShootdownsPerSec = pf.TLBShootdownsPerSec
+		row.SwapIns = pf.SwapIns
+		row.SwapOuts = pf.SwapOuts
+		row.SwapInsPerSec = pf.SwapInsPerSec
+		row.SwapOutsPerSec = pf.SwapOutsPerSec
 		if pf.RSSBytes > 0 {
 			row.RSSMB = float64(pf.RSSBytes) / (1024 * 1024)
 		}
+		row.RSSMinMB = float64(pf.RSSMinBytes) / (1024 * 1024)
+		row.RSSMaxMB = float64(pf.RSSMaxBytes) / (1024 * 1024)
 	}
 
-	for _, pair := range contention {
+	for _, pair := range snap.Contention {
+		// A switch to or from the idle task (PID 0) is not contention: being
+		// "preempted by idle" only means the CPU went idle. Skip the whole
+		// pair so it cannot inflate the other side's counters.
+		if isIdleTask(pair.VictimPID) || isIdleTask(pair.AggressorPID) {
+			continue
+		}
 		if victim := ensure(pair.VictimPID); victim != nil {
 			if victim.Comm == "" {
 				victim.Comm = pair.VictimComm
@@ -208,28 +864,163 @@ func BuildProcMetrics(
 		}
 	}
 
-	pidList := make([]int, 0, len(rows))
-	for pid := range rows {
-		pidList = append(pidList, int(pid))
+	for _, stat := range snap.RunqLatency {
+		row := ensure(stat.PID)
+		if row == nil {
+			continue
+		}
+		if row.Comm == "" {
+			row.Comm = stat.Comm
+		}
+		row.RunqWaitMs = float64(stat.TotalNs) / 1e6
+		row.RunqMaxMs = float64(stat.MaxNs) / 1e6
+		if singleCoreCapacity > 0 {
+			row.RunqWaitPercent = 100 * float64(stat.TotalNs) / singleCoreCapacity
+		}
 	}
-	rssMap := rssBytesForPIDs(pidList)
+	if snap.RunqLatency != nil {
+		for _, row := range rows {
+			row.RunqTracked = true
+		}
+	}
+
+	for _, stat := range snap.BlockIO {
+		row := ensure(stat.PID)
+		if row == nil {
+			continue
+		}
+		if row.Comm == "" {
+			row.Comm = stat.Comm
+		}
+		row.IOReadBytes = stat.ReadBytes
+		row.IOWriteBytes = stat.WriteBytes
+		row.IORequests = stat.Requests
+		row.IOLatencyMs = float64(stat.AvgLatencyNs) / 1e6
+	}
+
+	for _, stat := range snap.Syscalls {
+		row := ensure(stat.PID)
+		if row == nil {
+			continue
+		}
+		if row.Comm == "" {
+			row.Comm = stat.Comm
+		}
+		row.SyscallMs = float64(stat.TotalNs) / 1e6
+		row.Syscalls = stat.Count
+		row.SyscallsPerSec = float64(stat.Count) / intervalSeconds
+		if singleCoreCapacity > 0 {
+			row.SyscallPercent = 100 * float64(stat.TotalNs) / singleCoreCapacity
+		}
+		row.TopSyscall = stat.TopSyscall
+	}
+
+	for _, stat := range snap.Futex {
+		row := ensure(stat.PID)
+		if row == nil {
+			continue
+		}
+		if row.Comm == "" {
+			row.Comm = stat.Comm
+		}
+		row.FutexWaitMs = float64(stat.WaitNs) / 1e6
+		row.FutexContended = stat.Contended
+		row.FutexContendedPerSec = float64(stat.Contended) / intervalSeconds
+		if singleCoreCapacity > 0 {
+			row.FutexWaitPercent = 100 * float64(stat.WaitNs) / singleCoreCapacity
+		}
+	}
+
+	for _, stat := range snap.Net {
+		row := ensure(stat.PID)
+		if row == nil {
+			continue
+		}
+		if row.Comm == "" {
+			row.Comm = stat.Comm
+		}
+		row.NetTxBytes = stat.TxBytes
+		row.NetRxBytes = stat.RxBytes
+		row.NetTxBytesPerSec = float64(stat.TxBytes) / intervalSeconds
+		row.NetRxBytesPerSec = float64(stat.RxBytes) / intervalSeconds
+	}
+
+	// A process stuck in D state for the whole window produced no events,
+	// but the tracker still knows it from earlier windows.
+	if rssTracker != nil {
+		for _, blocked := range rssTracker.silentlyBlocked(rows) {
+			if row := ensure(blocked.PID); row != nil {
+				row.Comm, row.Cgroup = blocked.Comm, blocked.Cgroup
+			}
+		}
+	}
+
+	// The comm guards against a PID recycled since the maps were read.
+	procComms := make(map[int]string, len(rows))
+	for pid, row := range rows {
+		procComms[int(pid)] = row.Comm
+	}
+	rssMap := rssBytesForProcs(procComms)
 	for pid, rss := range rssMap {
 		if row, ok := rows[uint32(pid)]; ok && row.RSSMB == 0 {
 			row.RSSMB = float64(rss) / (1024 * 1024)
 		}
 	}
 
-	// Record RSS in tracker and mark growing processes.
+	// Record the footprint in the tracker and mark growing processes.
+	// Hugepages, executable paths, command lines, containers, scheduler states, and cgroup memory limits are
+	// only read with a tracker, which caches them per process. Limits can be
+	// changed at any time, so they are read every tick, once per cgroup.
 	if rssTracker != nil {
-		activePIDs := make(map[uint32]bool, len(rows))
+		rssTracker.clock += intervalSeconds
+		limits := make(map[string]uint64)
 		for pid, row := range rows {
-			activePIDs[pid] = true
-			rssTracker.Record(pid, row.RSSMB)
-		}
-		rssTracker.Prune(activePIDs)
-		for _, row := range rows {
-			row.RSSGrowing = rssTracker.IsGrowing(row.PID, thresholds.RSSTracker.MinDeltaMB)
+			key := ProcKey{PID: pid}
+			if start, err := procStartTime(int(pid)); err == nil {
+				key.StartTime = start
+			}
+			row.HugepagesMB = rssTracker.hugepagesMB(key)
+			// A cpuset can name CPUs that are offline, so the count never
+			// exceeds the machine's.
+			if cpus := rssTracker.allowedCPUs(key); cpus > 0 && cpus < runtime.NumCPU() {
+				row.AllowedCPUs = cpus
+				row.CPUPercent = cpuPercent(row.CPUNs, singleCoreCapacity*float64(cpus))
+				narrow := float64(runtime.NumCPU()) / float64(cpus)
+				row.CPUPercentMax = min(row.CPUPercentMax*narrow, 100)
+				row.CPUPercentAvg = min(row.CPUPercentAvg*narrow, 100)
+			}
+			row.ExePath = rssTracker.exePath(key, row.Comm)
+			row.State, row.PPID, row.DStateIntervals = rssTracker.schedState(key, row.Comm, row.Cgroup)
+			if rssTracker.fullCmd {
+				row.Cmdline = rssTracker.cmdlineText(key, row.Comm, thresholds.Redact)
+			}
+			if row.FootprintMB() > 0 || rssTracker.containers != nil {
+				paths := rssTracker.cgroupPaths(key)
+				if limit := effectiveMemoryLimit(paths, limits); limit > 0 && limit < totalMemBytes {
+					row.MemLimitMB = float64(limit) / (1024 * 1024)
+				}
+				if rssTracker.containers != nil {
+					row.NSPid = rssTracker.nsPID(key)
+					if names, ok := rssTracker.containers.Lookup(paths); ok {
+						row.ContainerName, row.PodName = names.Container, names.Pod
+					}
+				}
+			}
+			if rssTracker.smoothing != nil {
+				row.SmoothedCPUPercent, row.SmoothedFaultsPerSec = rssTracker.smoothing.Update(key, row.Comm, row.CPUPercent, row.FaultsPerSec)
+			}
+			if rssTracker.trend != nil {
+				row.CPUTrend, row.FaultsTrend = rssTracker.trend.Update(key, row.Comm, row.CPUPercent, row.FaultsPerSec)
+			}
+			rssTracker.Record(key, row.FootprintMB())
+			row.RSSSlopeMBPerMin, row.RSSSlopeFit = rssTracker.rssSlope(key, row.Comm, row.FootprintMB(), thresholds.LeakSuspect.WindowTicks)
+			row.RSSGrowing = rssTracker.IsGrowing(key, thresholds.RSSTracker.MinDeltaMB)
+			if row.RSSGrowing && thresholds.OOM.ProjectionSeconds > 0 {
+				row.GrowthMBPerSec = rssTracker.growthPerTick(key) / intervalSeconds
+				projectTimeToLimit(row)
+			}
 		}
+		rssTracker.Advance()
 	}
 
 	result := make([]ProcMetrics, 0, len(rows))
@@ -240,59 +1031,221 @@ func BuildProcMetrics(
 		if faultRate == 0 && row.Faults > 0 {
 			faultRate = float64(row.Faults) / intervalSeconds
 		}
-		row.RSSRatio = (row.RSSMB * 1024 * 1024) / float64(totalMemBytes)
+		if row.MemLimitMB > 0 {
+			row.RSSRatio = row.FootprintMB() / row.MemLimitMB
+		} else {
+			row.RSSRatio = (row.FootprintMB() * 1024 * 1024) / float64(totalMemBytes)
+		}
 		row.CPUCostPerFault = cpuMsPerSec / (faultRate + 1)
+		row.MemEfficiency = MemoryEfficiency(faultRate, row.CPUCostPerFault, row.RSSRatio)
+		sanitizeMetrics(row)
 		row.Diagnosis = classifyProc(row, thresholds)
 		copy := *row
 		result = append(result, copy)
 		index[row.PID] = copy
 	}
 
-	return result, index
+	return result, index
+}
+
+// FilterMetrics applies HideKernel/cgroup filters before ranking tables.
+func FilterMetrics(rows []ProcMetrics, cfg FilterConfig) []ProcMetrics {
+	filtered, _ := FilterMetricsWithStats(rows, cfg)
+	return filtered
+}
+
+// FilterStats counts how many rows each filter stage removed in one
+// FilterMetrics pass. Filters run in field order and a row is charged to the
+// first one that rejects it, so the removal counts sum to Total - Kept.
+type FilterStats struct {
+	Total       int // rows before filtering
+	PID         int // removed as not in the PID list (-pid), which skips every later stage
+	Kernel      int // removed as kernel threads (-hide-kernel)
+	Cgroup      int // removed by the cgroup substrings or pattern (-cgroup-filter, -cgroup-exclude, -cgroup-regex)
+	Comm        int // removed by the command name pattern (-comm-regex)
+	WatchCgroup int // removed as non-members of the watched cgroup (-watch-cgroup)
+	Exe         int // removed by the executable path substring (-exe-filter)
+	Exclude     int // removed by the exclude list (-exclude / config)
+	PPID        int // removed as not descending from the parent (-ppid)
+	Self        int // removed as hotspot itself or its children (unless -include-self)
+	Kept        int // rows that passed every filter
+}
+
+// FilterMetricsWithStats is FilterMetrics that also reports per-filter
+// rejection counts, for diagnosing an over-aggressive filter.
+func FilterMetricsWithStats(rows []ProcMetrics, cfg FilterConfig) ([]ProcMetrics, FilterStats) {
+	stats := FilterStats{Total: len(rows)}
+	filtered := make([]ProcMetrics, 0, len(rows))
+	cfg = cfg.withSelf(slices.Values(rows))
+	for _, row := range rows {
+		switch filterReason(row, cfg) {
+		case rejectPID:
+			stats.PID++
+		case rejectKernel:
+			stats.Kernel++
+		case rejectCgroup:
+			stats.Cgroup++
+		case rejectComm:
+			stats.Comm++
+		case rejectWatchCgroup:
+			stats.WatchCgroup++
+		case rejectExe:
+			stats.Exe++
+		case rejectExclude:
+			stats.Exclude++
+		case rejectPPID:
+			stats.PPID++
+		case rejectSelf:
+			stats.Self++
+		default:
+			filtered = append(filtered, row)
+		}
+	}
+	stats.Kept = len(filtered)
+	return filtered, stats
+}
+
+// CPUUsageRows returns the highest CPUNs rows up to topK.
+func CPUUsageRows(rows []ProcMetrics, topK int) []ProcMetrics {
+	return TopRows(rows, SortCPU, topK)
+}
+
+// CPUCostRows orders processes by fault rate (highest first) to spotlight memory pressure.
+// Tiebreakers: lower memory efficiency (MemEfficiency) ranks first, then
+// higher CPU cost per fault, then lower PID.
+func CPUCostRows(rows []ProcMetrics, topK int) []ProcMetrics {
+	candidates := make([]ProcMetrics, 0, len(rows))
+	for _, row := range rows {
+		if row.Faults == 0 && row.CPUMs == 0 {
+			continue
+		}
+		candidates = append(candidates, row)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if c := compareDesc(candidates[i].FaultsPerSec, candidates[j].FaultsPerSec); c != 0 {
+			return c < 0
+		}
+		if c := cmp.Compare(candidates[i].MemEfficiency, candidates[j].MemEfficiency); c != 0 {
+			return c < 0
+		}
+		if c := compareDesc(candidates[i].CPUCostPerFault, candidates[j].CPUCostPerFault); c != 0 {
+			return c < 0
+		}
+		return candidates[i].PID < candidates[j].PID
+	})
+	if topK > 0 && len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates
+}
+
+// PreemptedRows returns the most frequently preempted processes up to topK,
+// the per-process view of the Scheduler Contention table.
+func PreemptedRows(rows []ProcMetrics, topK int) []ProcMetrics {
+	candidates := make([]ProcMetrics, 0, len(rows))
+	for _, row := range rows {
+		if row.Preempted == 0 {
+			continue
+		}
+		candidates = append(candidates, row)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Preempted != candidates[j].Preempted {
+			return candidates[i].Preempted > candidates[j].Preempted
+		}
+		return candidates[i].PID < candidates[j].PID
+	})
+	if topK > 0 && len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates
+}
+
+// BlockIORows returns the processes that moved the most bytes to and from
+// block devices, up to topK. Ties break by lower PID.
+func BlockIORows(rows []ProcMetrics, topK int) []ProcMetrics {
+	candidates := make([]ProcMetrics, 0, len(rows))
+	for _, row := range rows {
+		if row.IORequests == 0 {
+			continue
+		}
+		candidates = append(candidates, row)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ti := candidates[i].IOReadBytes + candidates[i].IOWriteBytes
+		tj := candidates[j].IOReadBytes + candidates[j].IOWriteBytes
+		if ti != tj {
+			return ti > tj
+		}
+		return candidates[i].PID < candidates[j].PID
+	})
+	if topK > 0 && len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates
 }
 
-// FilterMetrics applies HideKernel/cgroup filters before ranking tables.
-func FilterMetrics(rows []ProcMetrics, cfg FilterConfig) []ProcMetrics {
-	filtered := make([]ProcMetrics, 0, len(rows))
+// NetRows returns the processes that sent and received the most TCP bytes,
+// up to topK. Ties break by lower PID.
+func NetRows(rows []ProcMetrics, topK int) []ProcMetrics {
+	candidates := make([]ProcMetrics, 0, len(rows))
 	for _, row := range rows {
-		if passesFilters(row, cfg) {
-			filtered = append(filtered, row)
+		if row.NetTxBytes == 0 && row.NetRxBytes == 0 {
+			continue
 		}
+		candidates = append(candidates, row)
 	}
-	return filtered
+	sort.Slice(candidates, func(i, j int) bool {
+		ti := candidates[i].NetTxBytes + candidates[i].NetRxBytes
+		tj := candidates[j].NetTxBytes + candidates[j].NetRxBytes
+		if ti != tj {
+			return ti > tj
+		}
+		return candidates[i].PID < candidates[j].PID
+	})
+	if topK > 0 && len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates
 }
 
-// CPUUsageRows returns the highest CPUNs rows up to topK.
-func CPUUsageRows(rows []ProcMetrics, topK int) []ProcMetrics {
+// SyscallRows returns the processes that spent the most time in system
+// calls, highest first, limited to topK (0 = no limit).
+func SyscallRows(rows []ProcMetrics, topK int) []ProcMetrics {
 	candidates := make([]ProcMetrics, 0, len(rows))
 	for _, row := range rows {
-		if row.CPUNs == 0 {
+		if row.Syscalls == 0 {
 			continue
 		}
 		candidates = append(candidates, row)
 	}
-	sort.Slice(candidates, func(i, j int) bool { return candidates[i].CPUNs > candidates[j].CPUNs })
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].SyscallMs != candidates[j].SyscallMs {
+			return candidates[i].SyscallMs > candidates[j].SyscallMs
+		}
+		return candidates[i].PID < candidates[j].PID
+	})
 	if topK > 0 && len(candidates) > topK {
 		candidates = candidates[:topK]
 	}
 	return candidates
 }
 
-// CPUCostRows orders processes by fault rate (highest first) to spotlight memory pressure.
-// Tiebreaker: higher CPU cost per fault ranks first.
-func CPUCostRows(rows []ProcMetrics, topK int) []ProcMetrics {
+// FutexRows returns the processes that spent the most time blocked in
+// futex waits, highest first, limited to topK (0 = no limit).
+func FutexRows(rows []ProcMetrics, topK int) []ProcMetrics {
 	candidates := make([]ProcMetrics, 0, len(rows))
 	for _, row := range rows {
-		if row.Faults == 0 && row.CPUMs == 0 {
+		if row.FutexContended == 0 {
 			continue
 		}
 		candidates = append(candidates, row)
 	}
 	sort.Slice(candidates, func(i, j int) bool {
-		if candidates[i].FaultsPerSec != candidates[j].FaultsPerSec {
-			return candidates[i].FaultsPerSec > candidates[j].FaultsPerSec
+		if candidates[i].FutexWaitMs != candidates[j].FutexWaitMs {
+			return candidates[i].FutexWaitMs > candidates[j].FutexWaitMs
 		}
-		return candidates[i].CPUCostPerFault > candidates[j].CPUCostPerFault
+		return candidates[i].PID < candidates[j].PID
 	})
 	if topK > 0 && len(candidates) > topK {
 		candidates = candidates[:topK]
@@ -302,40 +1255,81 @@ func CPUCostRows(rows []ProcMetrics, topK int) []ProcMetrics {
 
 // FilterContentionRows removes contention pairs hidden by filters, sorts by
 // preemption count (highest first), and limits to topK rows.
+// With cfg.GroupByComm, pairs are first merged by victim and aggressor command
+// name so worker pools (many processes, one comm) show up as a single row.
 func FilterContentionRows(entries []types.ContentionStat, cfg FilterConfig, procIndex map[uint32]ProcMetrics, topK int) []types.ContentionStat {
 	if len(entries) == 0 {
 		return nil
 	}
 	rows := make([]types.ContentionStat, 0, len(entries))
 	hideKernel := cfg.hideKernelEnabled()
+	cfg = cfg.withSelf(maps.Values(procIndex))
 	for _, entry := range entries {
+		if isIdleTask(entry.VictimPID) || isIdleTask(entry.AggressorPID) {
+			continue
+		}
 		victim, vok := procIndex[entry.VictimPID]
 		aggressor, aok := procIndex[entry.AggressorPID]
 		if !vok || !aok {
 			continue
 		}
-		if hideKernel && (isKernelThread(victim) || isKernelThread(aggressor)) {
-			continue
-		}
-		if cfg.CgroupFilter != "" {
-			vMatch := strings.Contains(strings.ToLower(victim.Cgroup), cfg.CgroupFilter)
-			aMatch := strings.Contains(strings.ToLower(aggressor.Cgroup), cfg.CgroupFilter)
-			if !vMatch && !aMatch {
+		if cfg.PIDs != nil {
+			// As for process rows, a PID list overrides the other filters.
+			if !cfg.listed(entry.VictimPID) && !cfg.listed(entry.AggressorPID) {
 				continue
 			}
-		}
-		if isExcluded(victim, cfg.Exclude) || isExcluded(aggressor, cfg.Exclude) {
+		} else if !keepContentionPair(victim, aggressor, cfg, hideKernel) {
 			continue
 		}
+		if entry.VictimComm == "" {
+			entry.VictimComm = victim.Comm
+		}
+		if entry.AggressorComm == "" {
+			entry.AggressorComm = aggressor.Comm
+		}
 		rows = append(rows, entry)
 	}
-	sort.Slice(rows, func(i, j int) bool { return rows[i].Count > rows[j].Count })
+	if cfg.GroupByComm {
+		rows = groupContentionByComm(rows)
+	}
+	sort.Slice(rows, func(i, j int) bool { return contentionLess(rows[i], rows[j]) })
 	if topK > 0 && len(rows) > topK {
 		rows = rows[:topK]
 	}
 	return rows
 }
 
+// keepContentionPair applies the process filters to a contention pair: both
+// sides must pass the kernel, cgroup exclude, exclude, and self filters, and
+// at least one side the cgroup, comm, -watch-cgroup, and -ppid filters.
+func keepContentionPair(victim, aggressor ProcMetrics, cfg FilterConfig, hideKernel bool) bool {
+	if hideKernel && (isKernelThread(victim) || isKernelThread(aggressor)) {
+		return false
+	}
+	if len(cfg.CgroupFilter) > 0 && !cgroupContainsAny(victim.Cgroup, cfg.CgroupFilter) && !cgroupContainsAny(aggressor.Cgroup, cfg.CgroupFilter) {
+		return false
+	}
+	if cgroupContainsAny(victim.Cgroup, cfg.CgroupExclude) || cgroupContainsAny(aggressor.Cgroup, cfg.CgroupExclude) {
+		return false
+	}
+	if re := cfg.CgroupRegex; re != nil && !re.MatchString(victim.Cgroup) && !re.MatchString(aggressor.Cgroup) {
+		return false
+	}
+	if re := cfg.CommRegex; re != nil && !re.MatchString(victim.Comm) && !re.MatchString(aggressor.Comm) {
+		return false
+	}
+	if !cfg.isMember(victim.PID) && !cfg.isMember(aggressor.PID) {
+		return false
+	}
+	if isExcluded(victim, cfg.Exclude) || isExcluded(aggressor, cfg.Exclude) {
+		return false
+	}
+	if !cfg.isDescendant(victim.PID) && !cfg.isDescendant(aggressor.PID) {
+		return false
+	}
+	return !cfg.isSelf(victim.PID) && !cfg.isSelf(aggressor.PID)
+}
+
 // FocusGroup represents all non-OK processes sharing the same diagnosis.
 type FocusGroup struct {
 	Diagnosis string
@@ -343,6 +1337,65 @@ type FocusGroup struct {
 	Procs     []ProcMetrics
 }
 
+// ContentionFairness scores how one-sided the preemption between two processes
+// is: min(a→b, b→a) / max(a→b, b→a), where a→b counts how often b preempted a.
+// 1.0 means the pair preempt each other equally (normal time-sharing); values
+// near 0 mean one process consistently pushes the other off the CPU.
+// The returned function looks up the score for a victim/aggressor pair from
+// entries; pairs never observed score 0.
+func ContentionFairness(entries []types.ContentionStat) func(victim, aggressor uint32) float64 {
+	counts := make(map[[2]uint32]uint64, len(entries))
+	for _, e := range entries {
+		if isIdleTask(e.VictimPID) || isIdleTask(e.AggressorPID) {
+			continue
+		}
+		counts[[2]uint32{e.VictimPID, e.AggressorPID}] += e.Count
+	}
+	return func(victim, aggressor uint32) float64 {
+		ab := counts[[2]uint32{victim, aggressor}]
+		ba := counts[[2]uint32{aggressor, victim}]
+		hi, lo := ab, ba
+		if lo > hi {
+			hi, lo = lo, hi
+		}
+		if hi == 0 {
+			return 0
+		}
+		return float64(lo) / float64(hi)
+	}
+}
+
+// groupContentionByComm sums contention counts per (victim comm, aggressor comm)
+// pair. Each merged row keeps the lowest victim and aggressor PIDs as
+// representatives.
+func groupContentionByComm(rows []types.ContentionStat) []types.ContentionStat {
+	type commPair struct{ victim, aggressor string }
+	merged := make(map[commPair]*types.ContentionStat, len(rows))
+	order := make([]commPair, 0, len(rows))
+	for _, row := range rows {
+		key := commPair{row.VictimComm, row.AggressorComm}
+		acc, ok := merged[key]
+		if !ok {
+			copy := row
+			merged[key] = &copy
+			order = append(order, key)
+			continue
+		}
+		acc.Count += row.Count
+		if row.VictimPID < acc.VictimPID {
+			acc.VictimPID = row.VictimPID
+		}
+		if row.AggressorPID < acc.AggressorPID {
+			acc.AggressorPID = row.AggressorPID
+		}
+	}
+	result := make([]types.ContentionStat, 0, len(order))
+	for _, key := range order {
+		result = append(result, *merged[key])
+	}
+	return result
+}
+
 // SelectFocusGroups returns all non-OK processes grouped by diagnosis,
 // sorted by severity (highest first). Within each group, processes are
 // sorted by the most relevant signal for that diagnosis.
@@ -350,7 +1403,7 @@ func SelectFocusGroups(rows []ProcMetrics) []FocusGroup {
 	groups := make(map[string][]ProcMetrics)
 	for _, row := range rows {
 		sev := diagnosisSeverity(row.Diagnosis)
-		if sev == 0 {
+		if sev == 0 || isIdleTask(row.PID) {
 			continue
 		}
 		groups[row.Diagnosis] = append(groups[row.Diagnosis], row)
@@ -374,43 +1427,216 @@ func SelectFocusGroups(rows []ProcMetrics) []FocusGroup {
 	return result
 }
 
+// SelectFocusPID returns the row for pid regardless of its diagnosis, for a
+// Focus section pinned to one process. It reports false when pid is not in
+// rows (it exited, was filtered out, or recorded no activity this window).
+func SelectFocusPID(rows []ProcMetrics, pid uint32) (ProcMetrics, bool) {
+	for _, row := range rows {
+		if row.PID == pid {
+			return row, true
+		}
+	}
+	return ProcMetrics{}, false
+}
+
+// FocusGroupsAtLeast drops focus groups below minSeverity. groups must be
+// sorted most severe first, as returned by SelectFocusGroups.
+func FocusGroupsAtLeast(groups []FocusGroup, minSeverity int) []FocusGroup {
+	for i, g := range groups {
+		if g.Severity < minSeverity {
+			return groups[:i]
+		}
+	}
+	return groups
+}
+
 // sortFocusProcs orders processes within a focus group by the most relevant
-// metric for that diagnosis type.
+// metric for that diagnosis type. Ties go to the lower PID. With -smooth, CPU
+// and fault rates are ranked by their moving averages, so a sustained
+// offender comes before a process with a one-interval spike.
 func sortFocusProcs(diag string, procs []ProcMetrics) {
+	metric := focusMetric(diag)
 	sort.Slice(procs, func(i, j int) bool {
-		switch diag {
-		case "OOM risk – memory growth":
-			return procs[i].RSSMB > procs[j].RSSMB
-		case "Mem-thrashing":
-			return procs[i].FaultsPerSec > procs[j].FaultsPerSec
-		case "Starved":
-			return procs[i].Preempted > procs[j].Preempted
-		case "Noisy neighbor":
-			return procs[i].PreemptsOthers > procs[j].PreemptsOthers
-		case "CPU-bound":
-			return procs[i].CoreCPUPercent > procs[j].CoreCPUPercent
-		default:
-			return procs[i].CPUPercent > procs[j].CPUPercent
+		if c := compareDesc(metric(procs[i]), metric(procs[j])); c != 0 {
+			return c < 0
 		}
+		return procs[i].PID < procs[j].PID
 	})
 }
 
+// focusMetric returns the ranking metric for a focus group's diagnosis.
+func focusMetric(diag string) func(ProcMetrics) float64 {
+	switch diag {
+	case "OOM risk – memory growth":
+		return func(r ProcMetrics) float64 { return r.FootprintMB() }
+	case "Leak suspect":
+		return func(r ProcMetrics) float64 { return r.RSSSlopeMBPerMin }
+	case "Swap/disk-bound faults":
+		return func(r ProcMetrics) float64 { return r.MajorFaultLatencyMs * r.MajorFaultsPerSec }
+	case "Mem-thrashing", "Working-set growth":
+		return func(r ProcMetrics) float64 {
+			if r.SmoothedFaultsPerSec > 0 {
+				return r.SmoothedFaultsPerSec
+			}
+			return r.FaultsPerSec
+		}
+	case "Stuck in D-state":
+		return func(r ProcMetrics) float64 { return float64(r.DStateIntervals) }
+	case "Lock-contended":
+		return func(r ProcMetrics) float64 { return r.FutexWaitMs }
+	case "Starved":
+		return func(r ProcMetrics) float64 {
+			if r.RunqTracked {
+				return r.RunqWaitMs
+			}
+			return float64(r.Preempted)
+		}
+	case "Noisy neighbor":
+		return func(r ProcMetrics) float64 { return float64(r.PreemptsOthers) }
+	case "TLB-thrashing":
+		return func(r ProcMetrics) float64 { return r.TLBShootdownsPerSec }
+	case "Switch-thrashing":
+		return func(r ProcMetrics) float64 { return r.SwitchLossPercent }
+	case "CPU-bound":
+		return func(r ProcMetrics) float64 {
+			if r.SmoothedCPUPercent > 0 {
+				return r.SmoothedCPUPercent
+			}
+			return r.CoreCPUPercent
+		}
+	default:
+		return func(r ProcMetrics) float64 {
+			if r.SmoothedCPUPercent > 0 {
+				return r.SmoothedCPUPercent
+			}
+			return r.CPUPercent
+		}
+	}
+}
+
+// contentionLess orders contention pairs by count (highest first), breaking
+// ties by victim then aggressor PID so equal pairs keep their order.
+func contentionLess(a, b types.ContentionStat) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	if a.VictimPID != b.VictimPID {
+		return a.VictimPID < b.VictimPID
+	}
+	return a.AggressorPID < b.AggressorPID
+}
+
+// sanitizeMetrics zeroes derived metrics that are NaN, ±Inf, or negative.
+// Zero or negative intervals, bogus collector rates, and overflow can all
+// produce such values; left in place they make sort order undefined and
+// render as "NaN" or "+Inf%" in the tables.
+func sanitizeMetrics(row *ProcMetrics) {
+	for _, v := range []*float64{
+		&row.CPUMs, &row.OffCPUMs, &row.CPUPercent, &row.CoreCPUPercent, &row.SmoothedCPUPercent,
+		&row.CPUPercentMax, &row.CPUPercentAvg,
+		&row.RSSMB, &row.RSSMinMB, &row.RSSMaxMB, &row.HugepagesMB, &row.RSSRatio, &row.MemLimitMB,
+		&row.FaultsPerSec, &row.SmoothedFaultsPerSec, &row.FaultPages, &row.CPUCostPerFault, &row.MemEfficiency,
+		&row.MajorFaultsPerSec, &row.MajorFaultLatencyMs,
+		&row.TLBShootdownsPerSec, &row.SwapInsPerSec, &row.SwapOutsPerSec, &row.SwitchesPerSec, &row.SwitchLossPercent,
+		&row.GrowthMBPerSec, &row.RunqWaitMs, &row.RunqMaxMs, &row.RunqWaitPercent,
+		&row.IOLatencyMs, &row.SyscallMs, &row.SyscallsPerSec, &row.SyscallPercent,
+		&row.FutexWaitMs, &row.FutexContendedPerSec, &row.FutexWaitPercent,
+		&row.NetTxBytesPerSec, &row.NetRxBytesPerSec,
+	} {
+		if math.IsNaN(*v) || math.IsInf(*v, 0) || *v < 0 {
+			*v = 0
+		}
+	}
+}
+
+// compareDesc returns -1 if a sorts before b in descending order, 1 if after,
+// and 0 if they tie. NaN sorts after every number and ties with NaN.
+func compareDesc(a, b float64) int {
+	switch {
+	case floatGreater(a, b):
+		return -1
+	case floatGreater(b, a):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// floatGreater is a descending-order comparator that is a strict weak
+// ordering even with NaN: NaN sorts after every number.
+func floatGreater(a, b float64) bool {
+	if math.IsNaN(a) {
+		return false
+	}
+	if math.IsNaN(b) {
+		return true
+	}
+	return a > b
+}
+
 // FocusSummary returns a short key-metric explanation for the Focus section.
-// Each diagnosis leads with its most critical signal.
+// Each diagnosis leads with its most critical signal; a process in D state
+// at the end of the window is also marked I/O-blocked.
 func FocusSummary(row ProcMetrics) string {
+	s := focusSummary(row)
+	if row.IOBlocked() && row.Diagnosis != "Stuck in D-state" {
+		s += ", I/O-blocked (D state)"
+	}
+	return s
+}
+
+func focusSummary(row ProcMetrics) string {
 	switch row.Diagnosis {
 	case "OOM risk – memory growth":
-		return fmt.Sprintf("RSS %.1f GB (growing), %s faults/sec",
-			row.RSSMB/1024.0, fmtFloat(row.FaultsPerSec))
+		var s string
+		if row.HugepagesMB > 0 {
+			s = fmt.Sprintf("RSS %.1f GB + %.1f GB hugepages (growing), %s faults/sec",
+				row.RSSMB/1024.0, row.HugepagesMB/1024.0, fmtFloat(row.FaultsPerSec))
+		} else {
+			s = fmt.Sprintf("RSS %.1f GB (growing), %s faults/sec",
+				row.RSSMB/1024.0, fmtFloat(row.FaultsPerSec))
+		}
+		if eta := limitETA(row); eta != "" {
+			s += fmt.Sprintf(", %s at %s MB/s", eta, fmtFloat(row.GrowthMBPerSec))
+		}
+		if swap := row.SwapInsPerSec + row.SwapOutsPerSec; swap > 0 {
+			s += fmt.Sprintf(", swapping %s pages/sec", fmtFloat(swap))
+		}
+		return s
+	case "Leak suspect":
+		return fmt.Sprintf("mem %.1f MB growing steadily at %s MB/min (fit R² %.2f), %s faults/sec",
+			row.FootprintMB(), fmtFloat(row.RSSSlopeMBPerMin), row.RSSSlopeFit, fmtFloat(row.FaultsPerSec))
+	case "Swap/disk-bound faults":
+		return fmt.Sprintf("%s major faults/sec, avg %.1f ms each, %s faults/sec total",
+			fmtFloat(row.MajorFaultsPerSec), row.MajorFaultLatencyMs, fmtFloat(row.FaultsPerSec))
+	case "Stuck in D-state":
+		return fmt.Sprintf("I/O-blocked (D state) at the end of %d consecutive windows, %.1f%% CPU",
+			row.DStateIntervals, row.CPUPercent)
 	case "Mem-thrashing":
 		return fmt.Sprintf("%s faults/sec, cost %.2f ms/fault, %.1f%% CPU",
 			fmtFloat(row.FaultsPerSec), row.CPUCostPerFault, row.CPUPercent)
+	case "Working-set growth":
+		return fmt.Sprintf("%s faults/sec over ~%s distinct pages, %.1f%% CPU",
+			fmtFloat(row.FaultsPerSec), fmtFloat(row.FaultPages), row.CPUPercent)
+	case "Lock-contended":
+		return fmt.Sprintf("blocked %.1f ms on futexes (%.0f%% of window), %s contended waits/sec, %.1f%% CPU",
+			row.FutexWaitMs, row.FutexWaitPercent, fmtFloat(row.FutexContendedPerSec), row.CPUPercent)
 	case "Starved":
+		if row.RunqTracked {
+			return fmt.Sprintf("waited %.1f ms for a CPU (longest %.1f ms), only %.1f%% CPU",
+				row.RunqWaitMs, row.RunqMaxMs, row.CPUPercent)
+		}
 		return fmt.Sprintf("preempted %dx, only %.1f%% CPU",
-			row.Preempted, row.CPUPercent)
+			preemptions(row), row.CPUPercent)
 	case "Noisy neighbor":
 		return fmt.Sprintf("preempts others %dx, %.1f%% CPU",
 			row.PreemptsOthers, row.CPUPercent)
+	case "TLB-thrashing":
+		return fmt.Sprintf("%s TLB shootdowns/sec, %s faults/sec",
+			fmtFloat(row.TLBShootdownsPerSec), fmtFloat(row.FaultsPerSec))
+	case "Switch-thrashing":
+		return fmt.Sprintf("%s switches/sec, ~%.0f%% of CPU time lost to cold caches",
+			fmtFloat(row.SwitchesPerSec), row.SwitchLossPercent)
 	case "CPU-bound":
 		return fmt.Sprintf("%.1f%% core, %.1f%% system CPU, %s faults/sec",
 			row.CoreCPUPercent, row.CPUPercent, fmtFloat(row.FaultsPerSec))
@@ -420,6 +1646,48 @@ func FocusSummary(row ProcMetrics) string {
 	}
 }
 
+// KeyMetric returns the single most relevant metric for a row's diagnosis,
+// used by the compact table where there is room for only one value.
+func KeyMetric(row ProcMetrics) string {
+	switch row.Diagnosis {
+	case "OOM risk – memory growth":
+		if eta := limitETA(row); eta != "" {
+			return eta
+		}
+		if row.HugepagesMB > 0 {
+			return fmt.Sprintf("mem %.1f MB incl. hugepages (growing)", row.FootprintMB())
+		}
+		return fmt.Sprintf("RSS %.1f MB (growing)", row.RSSMB)
+	case "Leak suspect":
+		return fmt.Sprintf("+%s MB/min", fmtFloat(row.RSSSlopeMBPerMin))
+	case "Swap/disk-bound faults":
+		return fmt.Sprintf("%.1f ms/major fault", row.MajorFaultLatencyMs)
+	case "Stuck in D-state":
+		return fmt.Sprintf("D state for %d windows", row.DStateIntervals)
+	case "Mem-thrashing":
+		return fmt.Sprintf("%s faults/sec", fmtFloat(row.FaultsPerSec))
+	case "Working-set growth":
+		return fmt.Sprintf("~%s pages faulted", fmtFloat(row.FaultPages))
+	case "Lock-contended":
+		return fmt.Sprintf("%.0f%% of window in futex waits", row.FutexWaitPercent)
+	case "Starved":
+		if row.RunqTracked {
+			return fmt.Sprintf("%.0f%% of window waiting for CPU", row.RunqWaitPercent)
+		}
+		return fmt.Sprintf("preempted %dx", preemptions(row))
+	case "Noisy neighbor":
+		return fmt.Sprintf("preempts others %dx", row.PreemptsOthers)
+	case "TLB-thrashing":
+		return fmt.Sprintf("%s shootdowns/sec", fmtFloat(row.TLBShootdownsPerSec))
+	case "Switch-thrashing":
+		return fmt.Sprintf("~%.0f%% lost to switching", row.SwitchLossPercent)
+	case "CPU-bound":
+		return fmt.Sprintf("%.1f%% core", row.CoreCPUPercent)
+	default:
+		return fmt.Sprintf("%.1f%% CPU", row.CPUPercent)
+	}
+}
+
 // fmtFloat formats a float with no decimals for >=10, one decimal otherwise.
 func fmtFloat(v float64) string {
 	if v >= 10 {
@@ -432,21 +1700,39 @@ func fmtFloat(v float64) string {
 // Rules are evaluated in priority order — the first match wins.
 // See package doc for the full precedence table.
 func classifyProc(row *ProcMetrics, th config.Thresholds) string {
-	costlyFaults := row.CPUCostPerFault > th.MemThrashing.ModerateCostPerFault && row.Faults > 0
-	veryCostlyFaults := row.CPUCostPerFault > th.MemThrashing.SevereCostPerFault && row.Faults > 0
+	// Rates from a handful of faults are noise; every fault-based rule
+	// below requires a minimum absolute count first.
+	enoughFaults := row.Faults > 0 && row.Faults >= th.Faults.MinCount
+	costlyFaults := row.CPUCostPerFault > th.MemThrashing.ModerateCostPerFault && enoughFaults
+	veryCostlyFaults := row.CPUCostPerFault > th.MemThrashing.SevereCostPerFault && enoughFaults
 
 	rssRatio := row.RSSRatio
-	bigProcess := row.RSSMB >= th.OOM.RSSMB
+	bigProcess := row.FootprintMB() >= th.OOM.RSSMB
 	highRatio := rssRatio >= th.OOM.RSSRatio
-	manyFaults := row.FaultsPerSec >= th.OOM.FaultsPerSec
+	manyFaults := row.FaultsPerSec >= th.OOM.FaultsPerSec && enoughFaults
+	nearLimit := th.OOM.ProjectionSeconds > 0 && row.LimitKind != "" &&
+		row.TimeToLimit.Seconds() <= th.OOM.ProjectionSeconds
+	swapping := th.OOM.SwapPerSec > 0 && row.SwapInsPerSec+row.SwapOutsPerSec >= th.OOM.SwapPerSec
 
 	// --- highest priority: OOM-ish behavior ---
 	// Require RSS to be actively growing across ticks to avoid false positives
-	// from large-but-stable processes (e.g., JVMs, Node.js, databases).
-	if row.RSSGrowing && (bigProcess || highRatio) && manyFaults {
+	// from large-but-stable processes (e.g., JVMs, Node.js, databases). A
+	// process projected to reach its limit soon is flagged while still small,
+	// and one that is already swapping needs neither size nor fault rate.
+	if row.RSSGrowing && ((bigProcess || highRatio || nearLimit) && manyFaults || swapping) {
 		return "OOM risk – memory growth"
 	}
 
+	// A footprint that grew steadily over many windows, however small: a
+	// slow leak that may never trip the size and fault gates above until it
+	// is too late. Checked before CPU-bound, since a leaking server is
+	// usually busy too and the leak is what needs fixing.
+	if th.LeakSuspect.MinSlopeMBPerMin > 0 &&
+		row.RSSSlopeMBPerMin >= th.LeakSuspect.MinSlopeMBPerMin &&
+		row.RSSSlopeFit >= th.LeakSuspect.MinFit {
+		return "Leak suspect"
+	}
+
 	// CPU-bound: either system-wide CPU% is high, or a single core is saturated.
 	// On multi-core machines a single-threaded busy loop may show only ~5% system
 	// CPU but ~100% on one core — that core is effectively unusable.
@@ -458,52 +1744,296 @@ func classifyProc(row *ProcMetrics, th config.Thresholds) string {
 		return "CPU-bound"
 	}
 
+	// Major faults stuck on slow storage. Checked before thrashing: the
+	// cost is in waiting for I/O, so fixing the storage (or keeping the pages
+	// resident) matters more than how many faults there are.
+	if th.DiskBound.MinLatencyMs > 0 &&
+		row.MajorFaults > 0 && row.MajorFaults >= th.DiskBound.MinMajorFaults &&
+		row.MajorFaultLatencyMs >= th.DiskBound.MinLatencyMs {
+		return "Swap/disk-bound faults"
+	}
+
+	// Uninterruptible sleep window after window: I/O that does not complete
+	// (a failing disk, an unreachable NFS server) or a kernel bug. Checked
+	// after Swap/disk-bound faults, which already names the cause when the
+	// process is paging from slow storage.
+	if th.DState.MinIntervals > 0 && row.DStateIntervals >= th.DState.MinIntervals {
+		return "Stuck in D-state"
+	}
+
 	// Memory thrashing (expensive faults)
 	if row.FaultsPerSec > th.MemThrashing.SevereFaultsPerSec &&
 		veryCostlyFaults &&
 		row.CPUPercent < th.MemThrashing.MaxCPUPercent {
-		return "Mem-thrashing"
+		return memFaultDiagnosis(row, th)
 	}
 	if row.FaultsPerSec > th.MemThrashing.ModerateFaultsPerSec &&
 		costlyFaults &&
 		row.CPUPercent < th.MemThrashing.MaxCPUPercent {
-		return "Mem-thrashing"
+		return memFaultDiagnosis(row, th)
 	}
 	// Volume tier: very high minor-fault rate (e.g., madvise+re-fault storms)
 	// where individual faults are cheap but the sustained rate is abnormal.
 	if row.FaultsPerSec > th.MemThrashing.HighFaultsPerSec &&
-		row.Faults > 0 &&
+		enoughFaults &&
 		row.CPUPercent < th.MemThrashing.MaxCPUPercent {
-		return "Mem-thrashing"
+		return memFaultDiagnosis(row, th)
 	}
 
-	// Scheduler-based diagnoses
-	if row.Preempted > th.Starved.MinPreempted && row.CPUPercent < th.Starved.MaxCPUPercent {
+	// Threads that spend the window blocked on futexes, many times a second:
+	// contended userspace locks. Checked before Starved, whose preemption
+	// fallback cannot tell a lock waiter from a process waiting for a CPU.
+	if th.LockContended.MinWaitPercent > 0 &&
+		row.FutexWaitPercent >= th.LockContended.MinWaitPercent &&
+		row.FutexContendedPerSec >= th.LockContended.MinContendedPerSec {
+		return "Lock-contended"
+	}
+
+	// Scheduler-based diagnoses. With run-queue latency measured, a process
+	// is starved only if it actually waited for a CPU: preemptions alone
+	// cannot tell a waiting process from one that yields and goes idle.
+	starved := preemptions(*row) > th.Starved.MinPreempted
+	if row.RunqTracked && th.Starved.MinRunqWaitPercent > 0 {
+		starved = row.RunqWaitPercent >= th.Starved.MinRunqWaitPercent
+	}
+	if starved && row.CPUPercent < th.Starved.MaxCPUPercent {
 		return "Starved"
 	}
 	if row.PreemptsOthers > th.NoisyNeighbr.MinPreemptsOthers && row.CPUPercent > th.NoisyNeighbr.MinCPUPercent {
 		return "Noisy neighbor"
 	}
 
+	// Lowest priority: frequent munmap/mprotect forcing TLB shootdown IPIs
+	// onto other CPUs. Only reported when nothing more specific matched.
+	if th.TLBThrashing.MinShootdownsPerSec > 0 &&
+		row.TLBShootdownsPerSec >= th.TLBThrashing.MinShootdownsPerSec {
+		return "TLB-thrashing"
+	}
+
+	// A process switched out so often that much of its CPU time goes to
+	// refilling caches and TLBs other work evicted in between. A hint rather
+	// than a fault: the fix is usually batching work or pinning, not tuning.
+	if th.SwitchThrashing.MinSwitchesPerSec > 0 &&
+		row.SwitchesPerSec >= th.SwitchThrashing.MinSwitchesPerSec &&
+		row.SwitchLossPercent >= th.SwitchThrashing.MinLostPercent {
+		return "Switch-thrashing"
+	}
+
 	return "OK"
 }
 
+// preemptions returns how often the process was preempted in the window:
+// its involuntary switch-outs where those were counted (-cpu-method sched),
+// and otherwise its switch-outs recorded as contention, which also count
+// threads that blocked on I/O or a lock and only then gave up the CPU.
+func preemptions(row ProcMetrics) uint64 {
+	if row.VolCtxSwitches+row.InvolCtxSwitches > 0 {
+		return row.InvolCtxSwitches
+	}
+	return row.Preempted
+}
+
+// projectTimeToLimit sets row.TimeToLimit and row.LimitKind by extrapolating
+// row.GrowthMBPerSec linearly against the memory the process can still
+// allocate. Rows that are not growing, whose headroom cannot be read, or whose
+// projection exceeds maxProjection are left unprojected.
+func projectTimeToLimit(row *ProcMetrics) {
+	if row.GrowthMBPerSec <= 0 || math.IsNaN(row.GrowthMBPerSec) || math.IsInf(row.GrowthMBPerSec, 0) {
+		return
+	}
+	headroom, kind, err := memoryHeadroom(int(row.PID))
+	if err != nil {
+		return
+	}
+	seconds := float64(headroom) / (1024 * 1024) / row.GrowthMBPerSec
+	if seconds > maxProjection.Seconds() {
+		return
+	}
+	row.TimeToLimit = time.Duration(seconds * float64(time.Second))
+	row.LimitKind = kind
+}
+
+// processMemoryHeadroom returns how many more bytes pid can be charged before
+// it reaches a limit: memory.max on its cgroup or an ancestor ("cgroup"),
+// otherwise the system's MemAvailable ("RAM").
+func processMemoryHeadroom(pid int) (uint64, string, error) {
+	if path, err := procfs.CgroupPath(pid); err == nil {
+		if headroom, limited, err := cgroup.MemoryHeadroom(path); err == nil && limited {
+			return headroom, "cgroup", nil
+		}
+	}
+	info, err := procfs.ReadMemInfo()
+	if err != nil {
+		return 0, "", err
+	}
+	return info.Available, "RAM", nil
+}
+
+// effectiveMemoryLimit returns the tightest memory limit over a process's
+// cgroup paths (one per hierarchy), or 0 when none is limited. Answers are
+// memoized in cache, keyed by path, for the duration of one tick.
+func effectiveMemoryLimit(paths []string, cache map[string]uint64) uint64 {
+	var tightest uint64
+	for _, path := range paths {
+		limit, ok := cache[path]
+		if !ok {
+			if l, limited, err := cgroupMemoryLimit(path); err == nil && limited {
+				limit = l
+			}
+			cache[path] = limit
+		}
+		if limit > 0 && (tightest == 0 || limit < tightest) {
+			tightest = limit
+		}
+	}
+	return tightest
+}
+
+// limitETA describes a projected time-to-limit for the focus summary, e.g.
+// "cgroup limit in ~4m". It returns "" for an unprojected row.
+func limitETA(row ProcMetrics) string {
+	if row.LimitKind == "" {
+		return ""
+	}
+	d := row.TimeToLimit
+	var eta string
+	switch {
+	case d < time.Minute:
+		eta = fmt.Sprintf("~%ds", int(d.Round(time.Second)/time.Second))
+	case d < time.Hour:
+		eta = fmt.Sprintf("~%dm", int(d.Round(time.Minute)/time.Minute))
+	default:
+		d = d.Round(time.Minute)
+		eta = fmt.Sprintf("~%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return fmt.Sprintf("%s limit in %s", row.LimitKind, eta)
+}
+
+// cpuPercent returns cpuNs as a percentage of capacity, the CPU time the
+// window holds across the CPUs considered. CPU time read a moment after the
+// window closed can still push a busy process slightly past it, so the
+// result is capped at 100%.
+func cpuPercent(cpuNs uint64, capacity float64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return math.Min(100*float64(cpuNs)/capacity, 100)
+}
+
+// rateCPUPercent is cpuPercent for a rate of CPU time, in nanoseconds per
+// second, on cpus CPUs.
+func rateCPUPercent(nsPerSec float64, cpus int) float64 {
+	if cpus <= 0 {
+		return 0
+	}
+	return math.Min(100*nsPerSec/(1e9*float64(cpus)), 100)
+}
+
+// majorFaultLatencyMs returns the average time per major fault in ms.
+func majorFaultLatencyMs(totalNs, faults uint64) float64 {
+	if faults == 0 {
+		return 0
+	}
+	return float64(totalNs) / float64(faults) / 1e6
+}
+
+// switchLossPercent estimates the share of a process's CPU time spent
+// re-warming caches after being switched out, assuming each switch costs
+// costUs microseconds of refill. The estimate is capped at 100%.
+func switchLossPercent(switches, cpuNs uint64, costUs float64) float64 {
+	if switches == 0 || cpuNs == 0 || costUs <= 0 {
+		return 0
+	}
+	lost := float64(switches) * costUs * 1e3
+	return 100 * math.Min(lost/float64(cpuNs), 1)
+}
+
+// memFaultDiagnosis splits a fault-heavy process by fault-address locality.
+// Faults concentrated on a few pages mean the same small set is faulted over
+// and over (true thrashing); faults spread over many distinct pages mean the
+// process is touching new memory (working-set growth). Without an address
+// sample (FaultPages == 0) the process stays "Mem-thrashing".
+func memFaultDiagnosis(row *ProcMetrics, th config.Thresholds) string {
+	if th.MemThrashing.WorkingSetMinPages > 0 && row.FaultPages >= th.MemThrashing.WorkingSetMinPages {
+		return "Working-set growth"
+	}
+	return "Mem-thrashing"
+}
+
 func passesFilters(row ProcMetrics, cfg FilterConfig) bool {
+	return filterReason(row, cfg) == rejectNone
+}
+
+// filterRejection names the filter stage that removed a row.
+type filterRejection int
+
+const (
+	rejectNone filterRejection = iota
+	rejectPID
+	rejectKernel
+	rejectCgroup
+	rejectComm
+	rejectWatchCgroup
+	rejectExe
+	rejectExclude
+	rejectPPID
+	rejectSelf
+)
+
+// filterReason returns the first filter stage that rejects row, or rejectNone.
+func filterReason(row ProcMetrics, cfg FilterConfig) filterRejection {
+	if cfg.PIDs != nil {
+		if cfg.listed(row.PID) {
+			return rejectNone
+		}
+		return rejectPID
+	}
 	if cfg.hideKernelEnabled() && isKernelThread(row) {
-		return false
+		return rejectKernel
 	}
-	if cfg.CgroupFilter != "" {
-		cg := strings.ToLower(row.Cgroup)
-		if !strings.Contains(cg, cfg.CgroupFilter) {
-			return false
-		}
+	if len(cfg.CgroupFilter) > 0 && !cgroupContainsAny(row.Cgroup, cfg.CgroupFilter) {
+		return rejectCgroup
 	}
-	for _, ex := range cfg.Exclude {
-		if ex == fmt.Sprintf("%d", row.PID) || strings.EqualFold(ex, row.Comm) {
-			return false
+	if cgroupContainsAny(row.Cgroup, cfg.CgroupExclude) {
+		return rejectCgroup
+	}
+	if cfg.CgroupRegex != nil && !cfg.CgroupRegex.MatchString(row.Cgroup) {
+		return rejectCgroup
+	}
+	if cfg.CommRegex != nil && !cfg.CommRegex.MatchString(row.Comm) {
+		return rejectComm
+	}
+	if !cfg.isMember(row.PID) {
+		return rejectWatchCgroup
+	}
+	// Paths are case-sensitive, unlike comm and cgroup names. A process
+	// whose executable could not be read never matches.
+	if cfg.ExeFilter != "" && !strings.Contains(row.ExePath, cfg.ExeFilter) {
+		return rejectExe
+	}
+	if isExcluded(row, cfg.Exclude) {
+		return rejectExclude
+	}
+	// After the cheap checks, since it may read /proc for every ancestor.
+	if !cfg.isDescendant(row.PID) {
+		return rejectPPID
+	}
+	if cfg.isSelf(row.PID) {
+		return rejectSelf
+	}
+	return rejectNone
+}
+
+// cgroupContainsAny reports whether cgroup contains any of subs, ignoring
+// case. It is false when subs is empty.
+func cgroupContainsAny(cgroup string, subs []string) bool {
+	cg := strings.ToLower(cgroup)
+	for _, sub := range subs {
+		if strings.Contains(cg, strings.ToLower(sub)) {
+			return true
 		}
 	}
-	return true
+	return false
 }
 
 func isExcluded(row ProcMetrics, exclude []string) bool {
@@ -515,6 +2045,12 @@ func isExcluded(row ProcMetrics, exclude []string) bool {
 	return false
 }
 
+// isIdleTask reports whether pid is the per-CPU idle task (swapper, shown as
+// "idle"). It never represents real work and is excluded everywhere.
+func isIdleTask(pid uint32) bool {
+	return pid == 0
+}
+
 func isKernelThread(row ProcMetrics) bool {
 	if row.PID == 0 {
 		return true
@@ -529,18 +2065,100 @@ func isKernelThread(row ProcMetrics) bool {
 	return false
 }
 
-// diagnosisSeverity maps a diagnosis label to a numeric priority (0–5).
+// Severity returns the numeric priority of a diagnosis label, as used to
+// order the Focus section: higher is more urgent, 0 for "OK" or unknown.
+func Severity(label string) int {
+	return diagnosisSeverity(label)
+}
+
+// diagnosisLabels lists every non-OK diagnosis, most severe first.
+var diagnosisLabels = []string{
+	"OOM risk – memory growth",
+	"Swap/disk-bound faults",
+	"Stuck in D-state",
+	"Leak suspect",
+	"Mem-thrashing",
+	"Working-set growth",
+	"Starved",
+	"Lock-contended",
+	"Noisy neighbor",
+	"TLB-thrashing",
+	"Switch-thrashing",
+	"CPU-bound",
+}
+
+// ParseDiagnosis resolves user input to a canonical non-OK diagnosis label.
+// Matching is case-insensitive, and a unique prefix is enough (e.g. "oom"
+// for "OOM risk – memory growth", which is awkward to type).
+func ParseDiagnosis(s string) (string, error) {
+	want := strings.ToLower(strings.TrimSpace(s))
+	if want == "" {
+		return "", fmt.Errorf("empty diagnosis")
+	}
+	var matches []string
+	for _, label := range diagnosisLabels {
+		lower := strings.ToLower(label)
+		if lower == want {
+			return label, nil
+		}
+		if strings.HasPrefix(lower, want) {
+			matches = append(matches, label)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return "", fmt.Errorf("unknown diagnosis %q", s)
+	default:
+		return "", fmt.Errorf("ambiguous diagnosis %q matches %s", s, strings.Join(matches, ", "))
+	}
+}
+
+// ParseSeverity resolves a severity threshold given either as a number
+// (1 = least severe non-OK diagnosis) or as a diagnosis label accepted by
+// ParseDiagnosis, which stands for that diagnosis's severity.
+func ParseSeverity(s string) (int, error) {
+	maxSeverity := diagnosisSeverity(diagnosisLabels[0])
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		if n < 0 || n > maxSeverity {
+			return 0, fmt.Errorf("severity %d out of range 0-%d", n, maxSeverity)
+		}
+		return n, nil
+	}
+	label, err := ParseDiagnosis(s)
+	if err != nil {
+		return 0, err
+	}
+	return diagnosisSeverity(label), nil
+}
+
+// diagnosisSeverity maps a diagnosis label to a numeric priority (0–12).
 // Used by SelectFocusGroups to pick the most critical processes for the
 // Focus section. Higher severity wins.
 func diagnosisSeverity(label string) int {
 	switch label {
 	case "OOM risk – memory growth":
-		return 5
+		return 12
+	case "Swap/disk-bound faults":
+		return 11
+	case "Stuck in D-state":
+		return 10
+	case "Leak suspect":
+		return 9
 	case "Mem-thrashing":
-		return 4
+		return 8
+	case "Working-set growth":
+		return 7
 	case "Starved":
-		return 3
+		return 6
+	case "Lock-contended":
+		return 5
 	case "Noisy neighbor":
+		return 4
+	case "TLB-thrashing":
+		return 3
+	case "Switch-thrashing":
 		return 2
 	case "CPU-bound":
 		return 1
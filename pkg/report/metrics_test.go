@@ -1,14 +1,20 @@
 package report
 
 import (
+	"errors"
 	"math"
+	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/srodi/hotspot-bpf/pkg/cgroup"
 	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
 	"github.com/srodi/hotspot-bpf/pkg/config"
+	"github.com/srodi/hotspot-bpf/pkg/container"
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
 	"github.com/srodi/hotspot-bpf/pkg/types"
 )
 
@@ -16,8 +22,8 @@ import (
 var defaultTh = config.Default()
 
 func TestBuildProcMetricsMergesStats(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
-	rssBytesForPIDs = func(pids []int) map[int]uint64 {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 {
 		return map[int]uint64{
 			123: 200 << 20,
 			456: 64 << 20,
@@ -26,11 +32,11 @@ func TestBuildProcMetricsMergesStats(t *testing.T) {
 
 	interval := time.Second
 	cpuNs := uint64((50 * time.Millisecond).Nanoseconds())
-	cpuStats := []types.CPUStat{{PID: 123, Comm: "worker", Cgroup: "/kubepods", Ns: cpuNs}}
-	pageFaults := []types.PageFaultStat{{PID: 123, Comm: "worker", Cgroup: "/kubepods", Faults: 25, FaultsPerSec: 25}}
+	cpuStats := []types.CPUStat{{PID: 123, Comm: "worker", Cgroup: "/kubepods", Ns: cpuNs, OffCPUNs: uint64(400 * time.Millisecond)}}
+	pageFaults := []types.PageFaultStat{{PID: 123, Comm: "worker", Faults: 25, FaultsPerSec: 25}}
 	contention := []types.ContentionStat{{VictimPID: 123, VictimComm: "worker", AggressorPID: 456, AggressorComm: "noisy", Count: 150}}
 
-	rows, index := BuildProcMetrics(cpuStats, pageFaults, contention, interval, nil, defaultTh)
+	rows, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, PageFaults: pageFaults, Contention: contention}, BuildOptions{Interval: interval, Thresholds: defaultTh})
 	if len(rows) != 2 {
 		t.Fatalf("expected 2 rows, got %d", len(rows))
 	}
@@ -44,6 +50,9 @@ func TestBuildProcMetricsMergesStats(t *testing.T) {
 	if math.Abs(victim.CPUMs-float64(cpuNs)/1e6) > 1e-6 {
 		t.Fatalf("unexpected CPUMs: %.3f", victim.CPUMs)
 	}
+	if victim.OffCPUMs != 400 {
+		t.Fatalf("unexpected OffCPUMs: %.3f", victim.OffCPUMs)
+	}
 	expectedPercent := 100 * float64(cpuNs) / (float64(interval.Nanoseconds()) * float64(runtime.NumCPU()))
 	if math.Abs(victim.CPUPercent-expectedPercent) > 1e-6 {
 		t.Fatalf("unexpected CPU%%: got %.4f want %.4f", victim.CPUPercent, expectedPercent)
@@ -80,15 +89,46 @@ func TestBuildProcMetricsMergesStats(t *testing.T) {
 	}
 }
 
+func TestBuildProcMetricsUsesMeasuredWindow(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	cpuNs := uint64((300 * time.Millisecond).Nanoseconds())
+	cpuStats := []types.CPUStat{{PID: 7, Comm: "worker", Cgroup: "/scope", Ns: cpuNs}}
+
+	_, onTime := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	_, late := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: 1200 * time.Millisecond, Thresholds: defaultTh})
+	if late[7].CPUPercent >= onTime[7].CPUPercent {
+		t.Fatalf("CPU%% over a 1.2s window should be below 1s: got %.4f vs %.4f", late[7].CPUPercent, onTime[7].CPUPercent)
+	}
+	if late[7].CoreCPUPercent >= onTime[7].CoreCPUPercent {
+		t.Fatalf("Core%% over a 1.2s window should be below 1s: got %.4f vs %.4f", late[7].CoreCPUPercent, onTime[7].CoreCPUPercent)
+	}
+	if want := onTime[7].CPUPercent / 1.2; math.Abs(late[7].CPUPercent-want) > 1e-6 {
+		t.Fatalf("unexpected CPU%% over 1.2s: got %.4f want %.4f", late[7].CPUPercent, want)
+	}
+
+	// More CPU time than the window could hold is clamped rather than shown
+	// as over 100%.
+	overNs := uint64(2*runtime.NumCPU()) * uint64(time.Second.Nanoseconds())
+	_, over := BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "worker", Ns: overNs}}}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	if over[7].CPUPercent != 100 {
+		t.Fatalf("expected CPU%% clamped to 100, got %.4f", over[7].CPUPercent)
+	}
+	if limit := 100 * float64(runtime.NumCPU()); over[7].CoreCPUPercent != limit {
+		t.Fatalf("expected Core%% clamped to %.0f, got %.4f", limit, over[7].CoreCPUPercent)
+	}
+}
+
 func TestBuildProcMetricsDefaultsInterval(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
-	rssBytesForPIDs = func(pids []int) map[int]uint64 { return nil }
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
 
 	cpuNs := uint64((2 * time.Millisecond).Nanoseconds())
 	cpuStats := []types.CPUStat{{PID: 99, Comm: "tiny", Cgroup: "/scope", Ns: cpuNs}}
-	pageFaults := []types.PageFaultStat{{PID: 99, Comm: "tiny", Cgroup: "/scope", Faults: 10}}
+	pageFaults := []types.PageFaultStat{{PID: 99, Comm: "tiny", Faults: 10}}
 
-	_, index := BuildProcMetrics(cpuStats, pageFaults, nil, 0, nil, defaultTh)
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, PageFaults: pageFaults}, BuildOptions{Interval: 0, Thresholds: defaultTh})
 	row := index[99]
 	if row.CPUMs != float64(cpuNs)/1e6 {
 		t.Fatalf("unexpected CPUMs: %.3f", row.CPUMs)
@@ -102,6 +142,252 @@ func TestBuildProcMetricsDefaultsInterval(t *testing.T) {
 	}
 }
 
+func TestFilterMetricsWithStats(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, Comm: "kworker/0:1", Cgroup: "/kernel"},
+		{PID: 2, Comm: "ksoftirqd/0", Cgroup: "/kubepods"},
+		{PID: 42, Comm: "api", Cgroup: "/kubepods/burst"},
+		{PID: 43, Comm: "db", Cgroup: "/docker/db"},
+		{PID: 44, Comm: "sidecar", Cgroup: "/kubepods/burst"},
+		{PID: 45, Comm: "cron", Cgroup: "/kubepods/burst", ExePath: "/usr/local/bin/cron"},
+		{PID: 46, Comm: "api", Cgroup: "/kubepods/burst", ExePath: "/opt/old/bin/api"},
+	}
+	for i := range rows[2:5] {
+		rows[2+i].ExePath = "/usr/local/bin/" + rows[2+i].Comm
+	}
+	cfg := FilterConfig{
+		CgroupFilter: []string{"kube"},
+		Members:      map[uint32]struct{}{42: {}, 45: {}, 46: {}},
+		ExeFilter:    "/usr/local/",
+		Exclude:      []string{"cron"},
+	}
+	kept, stats := FilterMetricsWithStats(rows, cfg)
+	if len(kept) != 1 || kept[0].PID != 42 {
+		t.Fatalf("expected only PID 42, got %+v", kept)
+	}
+	want := FilterStats{Total: 7, Kernel: 2, Cgroup: 1, WatchCgroup: 1, Exe: 1, Exclude: 1, Kept: 1}
+	if stats != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestFilterMetricsRegex(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 10, Comm: "nginx", Cgroup: "/kubepods/burstable/pod1/nginx"},
+		{PID: 11, Comm: "nginx", Cgroup: "/kubepods/besteffort/pod2/nginx"},
+		{PID: 12, Comm: "envoy", Cgroup: "/kubepods/burstable/pod1/envoy"},
+		{PID: 13, Comm: "php-fpm", Cgroup: "/kubepods/burstable/pod3/nginx"},
+	}
+	cfg := FilterConfig{
+		CgroupRegex: regexp.MustCompile(`kubepods/burstable/.*nginx`),
+		CommRegex:   regexp.MustCompile(`^nginx$`),
+	}
+	kept, stats := FilterMetricsWithStats(rows, cfg)
+	if len(kept) != 1 || kept[0].PID != 10 {
+		t.Fatalf("expected only PID 10, got %+v", kept)
+	}
+	if stats.Cgroup != 2 || stats.Comm != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// With both, the substring and the pattern must match.
+	cfg.CgroupFilter = []string{"pod3"}
+	cfg.CommRegex = nil
+	if kept := FilterMetrics(rows, cfg); len(kept) != 1 || kept[0].PID != 13 {
+		t.Fatalf("expected only PID 13, got %+v", kept)
+	}
+
+	procIndex := map[uint32]ProcMetrics{10: rows[0], 12: rows[2], 11: rows[1]}
+	entries := []types.ContentionStat{
+		{VictimPID: 12, AggressorPID: 10, Count: 3},
+		{VictimPID: 12, AggressorPID: 11, Count: 2},
+	}
+	pairs := FilterContentionRows(entries, FilterConfig{CommRegex: regexp.MustCompile(`^nginx$`), CgroupRegex: regexp.MustCompile(`burstable`)}, procIndex, 0)
+	if len(pairs) != 2 {
+		t.Fatalf("either side matching should keep a pair, got %+v", pairs)
+	}
+	pairs = FilterContentionRows(entries, FilterConfig{CommRegex: regexp.MustCompile(`^php`)}, procIndex, 0)
+	if len(pairs) != 0 {
+		t.Fatalf("no side matches, got %+v", pairs)
+	}
+}
+
+func TestFilterMetricsPIDListAndParent(t *testing.T) {
+	t.Cleanup(func() { procParentPID = parentPID })
+	// 1 ─┬─ 100 (supervisor) ─┬─ 101 (worker) ── 102 (helper)
+	//    │                    └─ 103 (worker)
+	//    └─ 200 (other)
+	parents := map[int]int{100: 1, 101: 100, 102: 101, 103: 100, 200: 1, 2: 0}
+	procParentPID = func(pid int) (int, error) {
+		if ppid, ok := parents[pid]; ok {
+			return ppid, nil
+		}
+		return 0, errors.New("no such process")
+	}
+	rows := []ProcMetrics{
+		{PID: 2, Comm: "kthreadd"},
+		{PID: 100, Comm: "supervisor"},
+		{PID: 101, Comm: "worker"},
+		{PID: 102, Comm: "helper"},
+		{PID: 103, Comm: "worker"},
+		{PID: 200, Comm: "other"},
+		{PID: 300, Comm: "exited"},
+	}
+	pids := func(rows []ProcMetrics) []uint32 {
+		var out []uint32
+		for _, r := range rows {
+			out = append(out, r.PID)
+		}
+		return out
+	}
+
+	// The PID list overrides the kernel filter and the exclude list.
+	listed, stats := FilterMetricsWithStats(rows, FilterConfig{
+		PIDs:    map[uint32]struct{}{2: {}, 101: {}},
+		Exclude: []string{"worker"},
+	})
+	if got := pids(listed); !slices.Equal(got, []uint32{2, 101}) {
+		t.Fatalf("PID list kept %v", got)
+	}
+	if stats.PID != 5 || stats.Kept != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	children, stats := FilterMetricsWithStats(rows, FilterConfig{PPID: 100})
+	if got := pids(children); !slices.Equal(got, []uint32{101, 103}) {
+		t.Fatalf("-ppid kept %v", got)
+	}
+	if stats.Kernel != 1 || stats.PPID != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	tree := FilterMetrics(rows, FilterConfig{PPID: 100, PPIDTree: true})
+	if got := pids(tree); !slices.Equal(got, []uint32{101, 102, 103}) {
+		t.Fatalf("-ppid-tree kept %v", got)
+	}
+}
+
+func TestFilterMetricsHidesSelf(t *testing.T) {
+	oldSelf := selfPID
+	t.Cleanup(func() { procParentPID, selfPID = parentPID, oldSelf })
+	selfPID = 500
+	procParentPID = func(pid int) (int, error) {
+		t.Fatalf("parent of %d read from /proc; want the rows' PPIDs", pid)
+		return 0, nil
+	}
+	rows := []ProcMetrics{
+		{PID: 502, PPID: 1, Comm: "app"},
+		{PID: 504, PPID: 501, Comm: "grandchild"}, // listed before its parent
+		{PID: 500, PPID: 1, Comm: "hotspot"},
+		{PID: 501, PPID: 500, Comm: "child"},
+		{PID: 503, PPID: 502, Comm: "db"},
+	}
+	kept, stats := FilterMetricsWithStats(rows, FilterConfig{})
+	if len(kept) != 2 || kept[0].PID != 502 || kept[1].PID != 503 || stats.Self != 3 {
+		t.Fatalf("kept %+v, stats %+v; want hotspot, its child, and its grandchild hidden", kept, stats)
+	}
+	if kept := FilterMetrics(rows, FilterConfig{IncludeSelf: true}); len(kept) != 5 {
+		t.Fatalf("-include-self kept %d rows; want 5", len(kept))
+	}
+	if kept := FilterMetrics(rows, FilterConfig{PIDs: map[uint32]struct{}{500: {}}}); len(kept) != 1 || kept[0].PID != 500 {
+		t.Fatalf("a listed PID should be shown, got %+v", kept)
+	}
+
+	index := make(map[uint32]ProcMetrics, len(rows))
+	for _, r := range rows {
+		index[r.PID] = r
+	}
+	entries := []types.ContentionStat{
+		{VictimPID: 502, AggressorPID: 500, Count: 9},
+		{VictimPID: 501, AggressorPID: 503, Count: 7},
+		{VictimPID: 503, AggressorPID: 502, Count: 5},
+		{VictimPID: 504, AggressorPID: 502, Count: 3},
+	}
+	if pairs := FilterContentionRows(entries, FilterConfig{}, index, 0); len(pairs) != 1 || pairs[0].VictimPID != 503 {
+		t.Fatalf("pairs = %+v; want only 503<-502", pairs)
+	}
+	if pairs := FilterContentionRows(entries, FilterConfig{IncludeSelf: true}, index, 0); len(pairs) != 4 {
+		t.Fatalf("-include-self kept %d pairs; want 4", len(pairs))
+	}
+}
+
+func TestFilterMetricsMatchesPodUID(t *testing.T) {
+	// Container cgroup paths under the systemd and cgroupfs drivers, with
+	// the pod UID in the pod cgroup.
+	rows := []ProcMetrics{
+		{PID: 42, Comm: "api", Cgroup: "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod3f1c2a9e_8d4b_4c7e_9a61_0b5e7d2c4f18.slice/cri-containerd-9b2e4f6a8c0d1e3f5a7b9c1d3e5f7a9b1c3d5e7f9a1b3c5d7e9f1a3b5c7d9e1f.scope"},
+		{PID: 43, Comm: "worker", Cgroup: "/kubepods/burstable/pod3f1c2a9e-8d4b-4c7e-9a61-0b5e7d2c4f18/9b2e4f6a8c0d1e3f5a7b9c1d3e5f7a9b1c3d5e7f9a1b3c5d7e9f1a3b5c7d9e1f"},
+		{PID: 44, Comm: "db", Cgroup: "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod77aa0c1d_2e3f_4a5b_8c9d_e0f1a2b3c4d5.slice/cri-containerd-0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9.scope"},
+	}
+
+	for _, filter := range []string{"pod3f1c2a9e", "0b5e7d2c4f18"} {
+		got := FilterMetrics(rows, FilterConfig{HideKernel: boolPtr(false), CgroupFilter: []string{filter}})
+		if len(got) != 2 || got[0].PID != 42 || got[1].PID != 43 {
+			t.Fatalf("-cgroup-filter %s: expected both containers of the pod, got %+v", filter, got)
+		}
+	}
+	full := FilterMetrics(rows, FilterConfig{HideKernel: boolPtr(false), CgroupFilter: []string{"3f1c2a9e_8d4b_4c7e_9a61_0b5e7d2c4f18"}})
+	if len(full) != 1 || full[0].PID != 42 {
+		t.Fatalf("expected the full systemd-driver pod UID to match, got %+v", full)
+	}
+}
+
+func TestFilterMetricsCgroupIncludeExclude(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 2, Comm: "kworker/u8:2", Cgroup: "/ns-a/kthreads"},
+		{PID: 10, Comm: "api", Cgroup: "/kubepods/NS-A/api"},
+		{PID: 11, Comm: "web", Cgroup: "/kubepods/ns-b/web"},
+		{PID: 12, Comm: "batch", Cgroup: "/kubepods/ns-c/batch"},
+		{PID: 13, Comm: "sshd", Cgroup: "/system.slice/sshd.service"},
+		{PID: 14, Comm: "api-canary", Cgroup: "/kubepods/ns-a/canary"},
+	}
+	for _, tc := range []struct {
+		name             string
+		include, exclude []string
+		showKernel       bool
+		want             []uint32
+		removed          int
+	}{
+		{"anyIncludeMatches", []string{"ns-a", "NS-B"}, nil, false, []uint32{10, 11, 14}, 2},
+		{"includeWithKernel", []string{"ns-a", "ns-b"}, nil, true, []uint32{2, 10, 11, 14}, 2},
+		{"excludeOnly", nil, []string{"/system.slice"}, false, []uint32{10, 11, 12, 14}, 1},
+		{"excludeWins", []string{"ns-a"}, []string{"canary"}, false, []uint32{10}, 4},
+		{"excludeKernelCgroup", []string{"ns-a"}, []string{"kthreads"}, true, []uint32{10, 14}, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := FilterConfig{HideKernel: boolPtr(!tc.showKernel), CgroupFilter: tc.include, CgroupExclude: tc.exclude}
+			kept, stats := FilterMetricsWithStats(rows, cfg)
+			var got []uint32
+			for _, r := range kept {
+				got = append(got, r.PID)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("kept %v; want %v", got, tc.want)
+			}
+			if stats.Cgroup != tc.removed {
+				t.Fatalf("cgroup filters removed %d; want %d (stats %+v)", stats.Cgroup, tc.removed, stats)
+			}
+		})
+	}
+
+	// Either side of a contention pair may match an include, but neither
+	// may match an exclude.
+	index := make(map[uint32]ProcMetrics, len(rows))
+	for _, r := range rows {
+		index[r.PID] = r
+	}
+	entries := []types.ContentionStat{
+		{VictimPID: 10, AggressorPID: 12, Count: 4},
+		{VictimPID: 11, AggressorPID: 13, Count: 3},
+		{VictimPID: 12, AggressorPID: 13, Count: 2},
+	}
+	cfg := FilterConfig{CgroupFilter: []string{"ns-a", "ns-b"}, CgroupExclude: []string{"system.slice"}}
+	pairs := FilterContentionRows(entries, cfg, index, 0)
+	if len(pairs) != 1 || pairs[0].VictimPID != 10 {
+		t.Fatalf("pairs = %+v; want only 10<-12", pairs)
+	}
+}
+
 func TestFilterMetricsRespectsKernelAndCgroup(t *testing.T) {
 	rows := []ProcMetrics{
 		{PID: 1, Comm: "kworker/0:1", Cgroup: "/kernel"},
@@ -113,7 +399,7 @@ func TestFilterMetricsRespectsKernelAndCgroup(t *testing.T) {
 	if len(visible) != 2 {
 		t.Fatalf("expected 2 user rows, got %d", len(visible))
 	}
-	cfg := FilterConfig{HideKernel: boolPtr(false), CgroupFilter: "kube"}
+	cfg := FilterConfig{HideKernel: boolPtr(false), CgroupFilter: []string{"kube"}}
 	scoped := FilterMetrics(rows, cfg)
 	if len(scoped) != 1 || scoped[0].PID != 42 {
 		t.Fatalf("expected only kube cgroup row, got %+v", scoped)
@@ -157,6 +443,157 @@ func TestCPUUsageRows(t *testing.T) {
 	}
 }
 
+func TestBlockIORows(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, CPUNs: 50},
+		{PID: 2, IOReadBytes: 100, IORequests: 2},
+		{PID: 3, IOWriteBytes: 300, IORequests: 1},
+		{PID: 4, IOReadBytes: 50, IOWriteBytes: 50, IORequests: 4},
+	}
+	top := BlockIORows(rows, 2)
+	if len(top) != 2 || top[0].PID != 3 || top[1].PID != 2 {
+		t.Fatalf("unexpected order: %+v", top)
+	}
+	if all := BlockIORows(rows, 0); len(all) != 3 {
+		t.Fatalf("processes without I/O should be skipped, got %d rows", len(all))
+	}
+}
+
+func TestSyscallRows(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, CPUNs: 50},
+		{PID: 2, SyscallMs: 20, Syscalls: 100},
+		{PID: 3, SyscallMs: 80, Syscalls: 4},
+		{PID: 4, SyscallMs: 20, Syscalls: 9},
+	}
+	top := SyscallRows(rows, 2)
+	if len(top) != 2 || top[0].PID != 3 || top[1].PID != 2 {
+		t.Fatalf("unexpected order: %+v", top)
+	}
+	if all := SyscallRows(rows, 0); len(all) != 3 {
+		t.Fatalf("processes without syscalls should be skipped, got %d rows", len(all))
+	}
+}
+
+func TestBuildProcMetricsNonFiniteGuards(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	assertFinite := func(t *testing.T, row ProcMetrics) {
+		t.Helper()
+		for name, v := range map[string]float64{
+			"CPUMs": row.CPUMs, "CPUPercent": row.CPUPercent, "CoreCPUPercent": row.CoreCPUPercent,
+			"RSSMB": row.RSSMB, "RSSRatio": row.RSSRatio, "FaultsPerSec": row.FaultsPerSec,
+			"FaultPages": row.FaultPages, "CPUCostPerFault": row.CPUCostPerFault,
+		} {
+			if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+				t.Fatalf("%s = %v for pid %d, want finite and >= 0", name, v, row.PID)
+			}
+		}
+	}
+
+	cases := []struct {
+		name       string
+		interval   time.Duration
+		cpuStats   []types.CPUStat
+		pageFaults []types.PageFaultStat
+	}{
+		{"zeroInterval", 0,
+			[]types.CPUStat{{PID: 1, Comm: "a", Ns: 1e9}},
+			[]types.PageFaultStat{{PID: 1, Comm: "a", Faults: 10}}},
+		{"negativeInterval", -time.Second,
+			[]types.CPUStat{{PID: 1, Comm: "a", Ns: 1e9}},
+			[]types.PageFaultStat{{PID: 1, Comm: "a", Faults: 10, FaultsPerSec: -10}}},
+		{"nanAndInfRates", time.Second,
+			[]types.CPUStat{{PID: 1, Comm: "a", Ns: 1e6}},
+			[]types.PageFaultStat{
+				{PID: 1, Comm: "a", Faults: 1, FaultsPerSec: math.NaN()},
+				{PID: 2, Comm: "b", Faults: 1, FaultsPerSec: math.Inf(1), FaultPages: math.Inf(1)},
+				{PID: 3, Comm: "c", Faults: 1, FaultsPerSec: -1},
+			}},
+		{"hugeValues", time.Nanosecond,
+			[]types.CPUStat{{PID: 1, Comm: "a", Ns: math.MaxUint64}},
+			[]types.PageFaultStat{{PID: 1, Comm: "a", Faults: math.MaxUint64, RSSBytes: math.MaxUint64}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, _ := BuildProcMetrics(SnapshotResult{CPU: tc.cpuStats, PageFaults: tc.pageFaults}, BuildOptions{Interval: tc.interval, Thresholds: defaultTh})
+			if len(rows) == 0 {
+				t.Fatal("expected rows")
+			}
+			for _, row := range rows {
+				assertFinite(t, row)
+			}
+		})
+	}
+}
+
+func TestSortingWithNaNIsDeterministic(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, Faults: 1, FaultsPerSec: math.NaN()},
+		{PID: 2, Faults: 1, FaultsPerSec: 50},
+		{PID: 3, Faults: 1, FaultsPerSec: math.NaN()},
+		{PID: 4, Faults: 1, FaultsPerSec: 100},
+	}
+	sorted := CPUCostRows(rows, 0)
+	if sorted[0].PID != 4 || sorted[1].PID != 2 {
+		t.Fatalf("finite rates should sort first in descending order, got %+v", sorted)
+	}
+	for _, row := range sorted[2:] {
+		if !math.IsNaN(row.FaultsPerSec) {
+			t.Fatalf("NaN rows should sort last, got %+v", sorted)
+		}
+	}
+}
+
+func TestSortingTiesBreakByPID(t *testing.T) {
+	// Rows with identical metrics, deliberately out of PID order. Every
+	// ordering must come out by ascending PID regardless of input order.
+	base := []ProcMetrics{
+		{PID: 30, CPUNs: 1e6, Faults: 10, FaultsPerSec: 5, CPUCostPerFault: 0.2, Preempted: 200, Diagnosis: "Starved"},
+		{PID: 10, CPUNs: 1e6, Faults: 10, FaultsPerSec: 5, CPUCostPerFault: 0.2, Preempted: 200, Diagnosis: "Starved"},
+		{PID: 20, CPUNs: 1e6, Faults: 10, FaultsPerSec: 5, CPUCostPerFault: 0.2, Preempted: 200, Diagnosis: "Starved"},
+	}
+	procIndex := map[uint32]ProcMetrics{}
+	for _, row := range base {
+		procIndex[row.PID] = row
+	}
+	assertPIDs := func(name string, got []uint32) {
+		t.Helper()
+		if len(got) != 3 || got[0] != 10 || got[1] != 20 || got[2] != 30 {
+			t.Fatalf("%s: expected PIDs [10 20 30], got %v", name, got)
+		}
+	}
+	pids := func(rows []ProcMetrics) []uint32 {
+		out := make([]uint32, 0, len(rows))
+		for _, row := range rows {
+			out = append(out, row.PID)
+		}
+		return out
+	}
+
+	for rotation := 0; rotation < len(base); rotation++ {
+		rows := append(append([]ProcMetrics{}, base[rotation:]...), base[:rotation]...)
+		assertPIDs("CPUUsageRows", pids(CPUUsageRows(rows, 0)))
+		assertPIDs("CPUCostRows", pids(CPUCostRows(rows, 0)))
+		groups := SelectFocusGroups(rows)
+		if len(groups) != 1 {
+			t.Fatalf("expected one focus group, got %d", len(groups))
+		}
+		assertPIDs("SelectFocusGroups", pids(groups[0].Procs))
+
+		entries := make([]types.ContentionStat, 0, len(rows))
+		for _, row := range rows {
+			entries = append(entries, types.ContentionStat{VictimPID: row.PID, AggressorPID: 10, Count: 7})
+		}
+		var victims []uint32
+		for _, pair := range FilterContentionRows(entries, FilterConfig{}, procIndex, 0) {
+			victims = append(victims, pair.VictimPID)
+		}
+		assertPIDs("FilterContentionRows", victims)
+	}
+}
+
 func TestCPUCostRowsSortingAndLimit(t *testing.T) {
 	rows := []ProcMetrics{
 		{PID: 1, Faults: 10, CPUMs: 10, CPUCostPerFault: 5, FaultsPerSec: 1},
@@ -173,6 +610,34 @@ func TestCPUCostRowsSortingAndLimit(t *testing.T) {
 	}
 }
 
+func TestCPUCostRowsRanksLessEfficientFirstOnEqualFaultRates(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, Faults: 50, FaultsPerSec: 10, CPUCostPerFault: 0.9, MemEfficiency: 95},
+		{PID: 2, Faults: 50, FaultsPerSec: 10, CPUCostPerFault: 0.1, MemEfficiency: 70}, // near its memory limit
+		{PID: 3, Faults: 90, FaultsPerSec: 20, CPUCostPerFault: 0.1, MemEfficiency: 98},
+	}
+	got := CPUCostRows(rows, 0)
+	if got[0].PID != 3 || got[1].PID != 2 || got[2].PID != 1 {
+		t.Fatalf("order %d %d %d; want fault rate first, then the lower efficiency score", got[0].PID, got[1].PID, got[2].PID)
+	}
+}
+
+func TestPreemptedRowsSortingAndLimit(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, Preempted: 40},
+		{PID: 2, Preempted: 90},
+		{PID: 3, Preempted: 40},
+		{PID: 4, PreemptsOthers: 500},
+	}
+	top := PreemptedRows(rows, 2)
+	if len(top) != 2 || top[0].PID != 2 || top[1].PID != 1 {
+		t.Fatalf("unexpected rows: %+v", top)
+	}
+	if all := PreemptedRows(rows, 0); len(all) != 3 {
+		t.Fatalf("expected every preempted row without a limit, got %+v", all)
+	}
+}
+
 func TestFilterContentionRows(t *testing.T) {
 	procIndex := map[uint32]ProcMetrics{
 		101: {PID: 101, Comm: "svc", Cgroup: "/kubepods/stateful"},
@@ -190,11 +655,81 @@ func TestFilterContentionRows(t *testing.T) {
 		t.Fatalf("expected only user processes, got %+v", filtered)
 	}
 
-	cfg := FilterConfig{HideKernel: boolPtr(false), CgroupFilter: "state"}
+	cfg := FilterConfig{HideKernel: boolPtr(false), CgroupFilter: []string{"state"}}
 	limited := FilterContentionRows(entries, cfg, procIndex, 1)
 	if len(limited) != 1 || limited[0].VictimPID != 101 {
 		t.Fatalf("expected stateful rows after topK, got %+v", limited)
 	}
+
+	// A pair is kept when either side is a member of the watched cgroup.
+	watched := FilterContentionRows(entries, FilterConfig{HideKernel: boolPtr(false), Members: map[uint32]struct{}{303: {}}}, procIndex, 0)
+	if len(watched) != 1 || watched[0].AggressorPID != 303 {
+		t.Fatalf("expected only the pair involving member 303, got %+v", watched)
+	}
+
+	// A listed PID shows its pairs even with a kernel thread on the other side.
+	listed := FilterContentionRows(entries, FilterConfig{PIDs: map[uint32]struct{}{303: {}}}, procIndex, 0)
+	if len(listed) != 1 || listed[0].AggressorPID != 303 {
+		t.Fatalf("expected only the pair involving listed 303, got %+v", listed)
+	}
+}
+
+func TestContentionFairness(t *testing.T) {
+	fairness := ContentionFairness([]types.ContentionStat{
+		{VictimPID: 1, AggressorPID: 2, Count: 100},
+		{VictimPID: 2, AggressorPID: 1, Count: 25},
+		{VictimPID: 3, AggressorPID: 4, Count: 50},
+		{VictimPID: 5, AggressorPID: 6, Count: 10},
+		{VictimPID: 6, AggressorPID: 5, Count: 10},
+	})
+	cases := []struct {
+		victim, aggressor uint32
+		want              float64
+	}{
+		{1, 2, 0.25},
+		{2, 1, 0.25}, // symmetric in its arguments
+		{3, 4, 0},    // one-sided
+		{5, 6, 1},    // perfectly fair
+		{7, 8, 0},    // unknown pair
+	}
+	for _, tc := range cases {
+		if got := fairness(tc.victim, tc.aggressor); math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("fairness(%d, %d) = %f, want %f", tc.victim, tc.aggressor, got, tc.want)
+		}
+	}
+}
+
+func TestFilterContentionRowsGroupByComm(t *testing.T) {
+	procIndex := map[uint32]ProcMetrics{
+		11: {PID: 11, Comm: "php-fpm"},
+		12: {PID: 12, Comm: "php-fpm"},
+		13: {PID: 13, Comm: "php-fpm"},
+		20: {PID: 20, Comm: "db"},
+		30: {PID: 30, Comm: "cron"},
+	}
+	entries := []types.ContentionStat{
+		{VictimPID: 12, AggressorPID: 20, Count: 100},
+		{VictimPID: 11, AggressorPID: 20, Count: 150},
+		{VictimPID: 13, AggressorPID: 20, Count: 150},
+		{VictimPID: 30, AggressorPID: 20, Count: 300},
+	}
+
+	ungrouped := FilterContentionRows(entries, FilterConfig{}, procIndex, 1)
+	if ungrouped[0].VictimComm != "cron" {
+		t.Fatalf("without grouping the single largest pair should win, got %+v", ungrouped[0])
+	}
+
+	grouped := FilterContentionRows(entries, FilterConfig{GroupByComm: true}, procIndex, 0)
+	if len(grouped) != 2 {
+		t.Fatalf("expected 2 comm pairs, got %+v", grouped)
+	}
+	top := grouped[0]
+	if top.VictimComm != "php-fpm" || top.AggressorComm != "db" || top.Count != 400 {
+		t.Fatalf("expected php-fpm preempted by db 400x first, got %+v", top)
+	}
+	if top.VictimPID != 11 {
+		t.Fatalf("expected lowest victim PID as representative, got %d", top.VictimPID)
+	}
 }
 
 func TestSelectFocusGroups(t *testing.T) {
@@ -209,7 +744,7 @@ func TestSelectFocusGroups(t *testing.T) {
 		if len(groups) != 2 {
 			t.Fatalf("expected 2 groups, got %d", len(groups))
 		}
-		// Mem-thrashing (severity 4) should come before Starved (severity 3)
+		// Mem-thrashing (severity 5) should come before Starved (severity 3)
 		if groups[0].Diagnosis != "Mem-thrashing" {
 			t.Fatalf("expected Mem-thrashing first, got %s", groups[0].Diagnosis)
 		}
@@ -262,11 +797,21 @@ func TestFocusSummary(t *testing.T) {
 		row      ProcMetrics
 		expected string
 	}{
+		{"diskBound", ProcMetrics{Diagnosis: "Swap/disk-bound faults", MajorFaultsPerSec: 40, MajorFaultLatencyMs: 12.5, FaultsPerSec: 300}, "avg 12.5 ms each"},
+		{"stuck", ProcMetrics{Diagnosis: "Stuck in D-state", State: "D", DStateIntervals: 5}, "at the end of 5 consecutive windows"},
+		{"leak", ProcMetrics{Diagnosis: "Leak suspect", RSSMB: 120, RSSSlopeMBPerMin: 3, RSSSlopeFit: 0.97}, "growing steadily at 3.0 MB/min (fit R² 0.97)"},
+		{"ioBlocked", ProcMetrics{Diagnosis: "Starved", State: "D", Preempted: 200, CPUPercent: 2}, "only 2.0% CPU, I/O-blocked (D state)"},
 		{"thrash", ProcMetrics{Diagnosis: "Mem-thrashing", FaultsPerSec: 1200, CPUCostPerFault: 0.3, CPUPercent: 4, Preempted: 3}, "faults/sec"},
+		{"workingSet", ProcMetrics{Diagnosis: "Working-set growth", FaultsPerSec: 1200, FaultPages: 640, CPUPercent: 4}, "distinct pages"},
 		{"starved", ProcMetrics{Diagnosis: "Starved", Preempted: 200, CPUPercent: 2}, "preempted"},
 		{"neighbor", ProcMetrics{Diagnosis: "Noisy neighbor", PreemptsOthers: 50, CPUPercent: 40}, "preempts others"},
+		{"tlb", ProcMetrics{Diagnosis: "TLB-thrashing", TLBShootdownsPerSec: 800, FaultsPerSec: 20}, "TLB shootdowns/sec"},
+		{"switch", ProcMetrics{Diagnosis: "Switch-thrashing", SwitchesPerSec: 5000, SwitchLossPercent: 40}, "switches/sec"},
+		{"lock", ProcMetrics{Diagnosis: "Lock-contended", FutexWaitMs: 1750, FutexWaitPercent: 350, FutexContendedPerSec: 5000}, "blocked 1750.0 ms on futexes"},
 		{"cpu", ProcMetrics{Diagnosis: "CPU-bound", CoreCPUPercent: 100, CPUPercent: 5, FaultsPerSec: 0}, "core"},
 		{"oom", ProcMetrics{Diagnosis: "OOM risk – memory growth", RSSMB: 2048, FaultsPerSec: 500}, "RSS"},
+		{"oomProjected", ProcMetrics{Diagnosis: "OOM risk – memory growth", RSSMB: 300, FaultsPerSec: 500, GrowthMBPerSec: 2, TimeToLimit: 250 * time.Second, LimitKind: "cgroup"}, "cgroup limit in ~4m at 2.0 MB/s"},
+		{"oomSwapping", ProcMetrics{Diagnosis: "OOM risk – memory growth", RSSMB: 120, FaultsPerSec: 20, SwapInsPerSec: 30, SwapOutsPerSec: 40}, "swapping 70 pages/sec"},
 		{"default", ProcMetrics{Diagnosis: "OK", CPUPercent: 3, FaultsPerSec: 1}, "faults/sec"},
 	}
 
@@ -280,6 +825,37 @@ func TestFocusSummary(t *testing.T) {
 	}
 }
 
+func TestKeyMetric(t *testing.T) {
+	cases := []struct {
+		name     string
+		row      ProcMetrics
+		expected string
+	}{
+		{"diskBound", ProcMetrics{Diagnosis: "Swap/disk-bound faults", MajorFaultLatencyMs: 12.54}, "12.5 ms/major fault"},
+		{"stuck", ProcMetrics{Diagnosis: "Stuck in D-state", DStateIntervals: 4}, "D state for 4 windows"},
+		{"leak", ProcMetrics{Diagnosis: "Leak suspect", RSSSlopeMBPerMin: 2.54}, "+2.5 MB/min"},
+		{"thrash", ProcMetrics{Diagnosis: "Mem-thrashing", FaultsPerSec: 1200, CPUPercent: 4}, "1200 faults/sec"},
+		{"workingSet", ProcMetrics{Diagnosis: "Working-set growth", FaultsPerSec: 1200, FaultPages: 640}, "~640 pages faulted"},
+		{"starved", ProcMetrics{Diagnosis: "Starved", Preempted: 200, CPUPercent: 2}, "preempted 200x"},
+		{"neighbor", ProcMetrics{Diagnosis: "Noisy neighbor", PreemptsOthers: 50, CPUPercent: 40}, "preempts others 50x"},
+		{"tlb", ProcMetrics{Diagnosis: "TLB-thrashing", TLBShootdownsPerSec: 800}, "800 shootdowns/sec"},
+		{"switch", ProcMetrics{Diagnosis: "Switch-thrashing", SwitchLossPercent: 42.4}, "~42% lost to switching"},
+		{"lock", ProcMetrics{Diagnosis: "Lock-contended", FutexWaitPercent: 350}, "350% of window in futex waits"},
+		{"cpu", ProcMetrics{Diagnosis: "CPU-bound", CoreCPUPercent: 99.5, CPUPercent: 5}, "99.5% core"},
+		{"oom", ProcMetrics{Diagnosis: "OOM risk – memory growth", RSSMB: 2048, FaultsPerSec: 500}, "RSS 2048.0 MB (growing)"},
+		{"oomProjected", ProcMetrics{Diagnosis: "OOM risk – memory growth", RSSMB: 300, TimeToLimit: 65 * time.Minute, LimitKind: "RAM"}, "RAM limit in ~1h05m"},
+		{"default", ProcMetrics{Diagnosis: "OK", CPUPercent: 3}, "3.0% CPU"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := KeyMetric(tc.row); got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestClassifyProc(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -288,11 +864,36 @@ func TestClassifyProc(t *testing.T) {
 	}{
 		{"cpuBound", ProcMetrics{CPUPercent: 60, FaultsPerSec: 0.5}, "CPU-bound"},
 		{"thrashByCost", ProcMetrics{CPUPercent: 10, FaultsPerSec: 800, CPUCostPerFault: 0.2, Faults: 200}, "Mem-thrashing"},
-		{"thrashByVeryCostly", ProcMetrics{CPUPercent: 5, FaultsPerSec: 1500, CPUCostPerFault: 0.8, Faults: 20}, "Mem-thrashing"},
+		{"thrashByVeryCostly", ProcMetrics{CPUPercent: 5, FaultsPerSec: 1500, CPUCostPerFault: 0.8, Faults: 7500}, "Mem-thrashing"},
 		{"thrashByVolume", ProcMetrics{CPUPercent: 5, FaultsPerSec: 15000, CPUCostPerFault: 0.003, Faults: 75000}, "Mem-thrashing"},
+		{"thrashSmallHotSet", ProcMetrics{CPUPercent: 5, FaultsPerSec: 15000, CPUCostPerFault: 0.003, Faults: 75000, FaultPages: 12}, "Mem-thrashing"},
+		{"workingSetGrowth", ProcMetrics{CPUPercent: 5, FaultsPerSec: 15000, CPUCostPerFault: 0.003, Faults: 75000, FaultPages: 900}, "Working-set growth"},
+		{"workingSetGrowthCostly", ProcMetrics{CPUPercent: 10, FaultsPerSec: 800, CPUCostPerFault: 0.2, Faults: 200, FaultPages: 128}, "Working-set growth"},
 		{"highFaultsHighCPUNotThrash", ProcMetrics{CPUPercent: 25, FaultsPerSec: 15000, Faults: 75000}, "OK"},
+		{"diskBound", ProcMetrics{CPUPercent: 2, MajorFaults: 40, MajorFaultLatencyMs: 8}, "Swap/disk-bound faults"},
+		{"diskBoundBeatsThrash", ProcMetrics{CPUPercent: 10, FaultsPerSec: 800, CPUCostPerFault: 0.2, Faults: 200, MajorFaults: 200, MajorFaultLatencyMs: 8}, "Swap/disk-bound faults"},
+		{"stuckInD", ProcMetrics{State: "D", DStateIntervals: 3}, "Stuck in D-state"},
+		{"briefDStateOK", ProcMetrics{State: "D", DStateIntervals: 2}, "OK"},
+		{"diskBoundBeatsStuck", ProcMetrics{State: "D", DStateIntervals: 3, MajorFaults: 40, MajorFaultLatencyMs: 8}, "Swap/disk-bound faults"},
+		{"stuckBeatsStarved", ProcMetrics{CPUPercent: 1, Preempted: 200, State: "D", DStateIntervals: 3}, "Stuck in D-state"},
+		{"leakSuspect", ProcMetrics{RSSMB: 80, RSSSlopeMBPerMin: 2, RSSSlopeFit: 0.95}, "Leak suspect"},
+		{"leakBeatsCPUBound", ProcMetrics{CPUPercent: 60, RSSSlopeMBPerMin: 2, RSSSlopeFit: 0.95}, "Leak suspect"},
+		{"noisyGrowthOK", ProcMetrics{RSSMB: 80, RSSSlopeMBPerMin: 2, RSSSlopeFit: 0.5}, "OK"},
+		{"slowGrowthOK", ProcMetrics{RSSMB: 80, RSSSlopeMBPerMin: 0.5, RSSSlopeFit: 0.99}, "OK"},
+		{"fastMajorFaultsThrash", ProcMetrics{CPUPercent: 10, FaultsPerSec: 800, CPUCostPerFault: 0.2, Faults: 200, MajorFaults: 200, MajorFaultLatencyMs: 0.1}, "Mem-thrashing"},
+		{"fewSlowMajorFaults", ProcMetrics{CPUPercent: 2, MajorFaults: 3, MajorFaultLatencyMs: 50}, "OK"},
 		{"starved", ProcMetrics{CPUPercent: 5, Preempted: 200}, "Starved"},
 		{"neighbor", ProcMetrics{CPUPercent: 35, PreemptsOthers: 120}, "Noisy neighbor"},
+		{"tlbThrashing", ProcMetrics{CPUPercent: 15, TLBShootdownsPerSec: 900}, "TLB-thrashing"},
+		{"tlbBelowThreshold", ProcMetrics{CPUPercent: 15, TLBShootdownsPerSec: 100}, "OK"},
+		{"starvedBeatsTLB", ProcMetrics{CPUPercent: 5, Preempted: 200, TLBShootdownsPerSec: 900}, "Starved"},
+		{"lockContended", ProcMetrics{CPUPercent: 20, FutexWaitPercent: 350, FutexContendedPerSec: 5000}, "Lock-contended"},
+		{"lockBeatsStarved", ProcMetrics{CPUPercent: 5, Preempted: 200, FutexWaitPercent: 350, FutexContendedPerSec: 5000}, "Lock-contended"},
+		{"idlePoolNotContended", ProcMetrics{CPUPercent: 1, FutexWaitPercent: 3200, FutexContendedPerSec: 20}, "OK"},
+		{"switchThrashing", ProcMetrics{CPUPercent: 3, SwitchesPerSec: 5000, SwitchLossPercent: 40}, "Switch-thrashing"},
+		{"switchLowLoss", ProcMetrics{CPUPercent: 3, SwitchesPerSec: 5000, SwitchLossPercent: 5}, "OK"},
+		{"switchFewSwitches", ProcMetrics{CPUPercent: 3, SwitchesPerSec: 200, SwitchLossPercent: 90}, "OK"},
+		{"tlbBeatsSwitch", ProcMetrics{CPUPercent: 3, TLBShootdownsPerSec: 900, SwitchesPerSec: 5000, SwitchLossPercent: 40}, "TLB-thrashing"},
 		{"ok", ProcMetrics{CPUPercent: 5}, "OK"},
 	}
 	for _, tc := range cases {
@@ -318,19 +919,29 @@ func TestClassifyProcTableDrivenScenarios(t *testing.T) {
 		},
 		{
 			name: "oomRisk",
-			row:  ProcMetrics{RSSMB: 2048, FaultsPerSec: 500, RSSRatio: 0.4, RSSGrowing: true},
+			row:  ProcMetrics{RSSMB: 2048, FaultsPerSec: 500, Faults: 2500, RSSRatio: 0.4, RSSGrowing: true},
 			want: "OOM risk – memory growth",
 		},
 		{
 			name: "oomRiskAtBoundary",
-			row:  ProcMetrics{RSSMB: 500, FaultsPerSec: 200, RSSGrowing: true},
+			row:  ProcMetrics{RSSMB: 500, FaultsPerSec: 200, Faults: 1000, RSSGrowing: true},
 			want: "OOM risk – memory growth",
 		},
 		{
 			name: "oomRiskByRatio",
-			row:  ProcMetrics{RSSMB: 400, FaultsPerSec: 300, RSSRatio: 0.10, RSSGrowing: true},
+			row:  ProcMetrics{RSSMB: 400, FaultsPerSec: 300, Faults: 1500, RSSRatio: 0.10, RSSGrowing: true},
+			want: "OOM risk – memory growth",
+		},
+		{
+			name: "oomRiskBySwapping",
+			row:  ProcMetrics{RSSMB: 120, FaultsPerSec: 20, Faults: 100, RSSRatio: 0.01, SwapInsPerSec: 30, SwapOutsPerSec: 40, RSSGrowing: true},
 			want: "OOM risk – memory growth",
 		},
+		{
+			name: "swappingButStableNotOOM",
+			row:  ProcMetrics{RSSMB: 120, FaultsPerSec: 20, Faults: 100, RSSRatio: 0.01, SwapInsPerSec: 30, SwapOutsPerSec: 40},
+			want: "OK",
+		},
 		{
 			name: "belowOomThresholds",
 			row:  ProcMetrics{RSSMB: 400, FaultsPerSec: 300, RSSRatio: 0.05},
@@ -378,12 +989,52 @@ func TestClassifyProcTableDrivenScenarios(t *testing.T) {
 	}
 }
 
+func TestParseDiagnosis(t *testing.T) {
+	cases := map[string]string{
+		"Starved":        "Starved",
+		"mem-thrashing":  "Mem-thrashing",
+		"oom":            "OOM risk – memory growth",
+		"  noisy ":       "Noisy neighbor",
+		"tlb-thrashing":  "TLB-thrashing",
+		"switch":         "Switch-thrashing",
+		"lock":           "Lock-contended",
+		"swap":           "Swap/disk-bound faults",
+		"stuck":          "Stuck in D-state",
+		"leak":           "Leak suspect",
+		"working-set gr": "Working-set growth",
+	}
+	for in, want := range cases {
+		got, err := ParseDiagnosis(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseDiagnosis(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "OK", "bogus", "sw"} {
+		if _, err := ParseDiagnosis(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+	// Every listed label must carry a severity, in descending order.
+	for i := 1; i < len(diagnosisLabels); i++ {
+		if Severity(diagnosisLabels[i-1]) <= Severity(diagnosisLabels[i]) {
+			t.Fatalf("diagnosisLabels not in severity order at %q", diagnosisLabels[i])
+		}
+	}
+}
+
 func TestDiagnosisSeverity(t *testing.T) {
 	labels := map[string]int{
-		"OOM risk – memory growth": 5,
-		"Mem-thrashing":            4,
-		"Starved":                  3,
-		"Noisy neighbor":           2,
+		"OOM risk – memory growth": 12,
+		"Swap/disk-bound faults":   11,
+		"Stuck in D-state":         10,
+		"Leak suspect":             9,
+		"Mem-thrashing":            8,
+		"Working-set growth":       7,
+		"Starved":                  6,
+		"Lock-contended":           5,
+		"Noisy neighbor":           4,
+		"TLB-thrashing":            3,
+		"Switch-thrashing":         2,
 		"CPU-bound":                1,
 		"OK":                       0,
 	}
@@ -419,20 +1070,28 @@ func TestPassesFilters(t *testing.T) {
 	if passesFilters(ProcMetrics{PID: 1, Comm: "kworker"}, FilterConfig{}) {
 		t.Fatalf("kernel thread should be hidden by default")
 	}
-	cfg := FilterConfig{HideKernel: boolPtr(false), CgroupFilter: "kube"}
+	cfg := FilterConfig{HideKernel: boolPtr(false), CgroupFilter: []string{"kube"}}
 	if !passesFilters(row, cfg) {
 		t.Fatalf("expected kube row to pass cgroup filter")
 	}
-	cfg.CgroupFilter = "db"
+	cfg.CgroupFilter = []string{"db"}
 	if passesFilters(row, cfg) {
 		t.Fatalf("unexpected cgroup match")
 	}
+	cfg = FilterConfig{Members: map[uint32]struct{}{11: {}}}
+	if passesFilters(row, cfg) {
+		t.Fatalf("non-member should be filtered")
+	}
+	cfg.Members[10] = struct{}{}
+	if !passesFilters(row, cfg) {
+		t.Fatalf("member should pass")
+	}
 }
 
 func TestBuildProcMetricsBPFRSSPreferred(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
 	// /proc returns 100MB for pid 42
-	rssBytesForPIDs = func(pids []int) map[int]uint64 {
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 {
 		return map[int]uint64{42: 100 << 20}
 	}
 
@@ -441,7 +1100,7 @@ func TestBuildProcMetricsBPFRSSPreferred(t *testing.T) {
 	pageFaults := []types.PageFaultStat{
 		{PID: 42, Comm: "leaker", Faults: 5000, FaultsPerSec: 1000, RSSBytes: 512 << 20},
 	}
-	_, index := BuildProcMetrics(nil, pageFaults, nil, interval, nil, defaultTh)
+	_, index := BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: interval, Thresholds: defaultTh})
 	row, ok := index[42]
 	if !ok {
 		t.Fatal("missing row for pid 42")
@@ -451,10 +1110,25 @@ func TestBuildProcMetricsBPFRSSPreferred(t *testing.T) {
 	}
 }
 
+func TestBuildProcMetricsFaultTimeRSSRange(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	// RSS climbed to 900MB mid-window and was back at 300MB by the last fault.
+	pageFaults := []types.PageFaultStat{
+		{PID: 42, Comm: "batch", Faults: 5000, FaultsPerSec: 1000, RSSBytes: 300 << 20, RSSMinBytes: 250 << 20, RSSMaxBytes: 900 << 20},
+	}
+	_, index := BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	row := index[42]
+	if row.RSSMB != 300 || row.RSSMinMB != 250 || row.RSSMaxMB != 900 {
+		t.Fatalf("RSS = %.1f (min %.1f, max %.1f); want 300 (min 250, max 900)", row.RSSMB, row.RSSMinMB, row.RSSMaxMB)
+	}
+}
+
 func TestBuildProcMetricsFallbackToProcRSS(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
 	// /proc returns 256MB
-	rssBytesForPIDs = func(pids []int) map[int]uint64 {
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 {
 		return map[int]uint64{99: 256 << 20}
 	}
 
@@ -463,7 +1137,7 @@ func TestBuildProcMetricsFallbackToProcRSS(t *testing.T) {
 	pageFaults := []types.PageFaultStat{
 		{PID: 99, Comm: "app", Faults: 10, FaultsPerSec: 10, RSSBytes: 0},
 	}
-	_, index := BuildProcMetrics(nil, pageFaults, nil, interval, nil, defaultTh)
+	_, index := BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: interval, Thresholds: defaultTh})
 	row := index[99]
 	if math.Abs(row.RSSMB-256) > 1e-3 {
 		t.Fatalf("expected /proc fallback RSS (256MB), got %.3f MB", row.RSSMB)
@@ -471,55 +1145,176 @@ func TestBuildProcMetricsFallbackToProcRSS(t *testing.T) {
 }
 
 func TestRSSTracker(t *testing.T) {
-	tracker := NewRSSTracker(3)
+	tracker := NewRSSTracker(config.RSSTrackerConfig{WindowTicks: 3, IdleTicks: 1})
+	p1 := ProcKey{PID: 1, StartTime: 100}
+	p2 := ProcKey{PID: 2, StartTime: 200}
 
 	// First tick: not enough data
-	tracker.Record(1, 100)
-	if tracker.IsGrowing(1, 10) {
+	tracker.Record(p1, 100)
+	if tracker.IsGrowing(p1, 10) {
 		t.Fatal("should not be growing with only 1 sample")
 	}
 
 	// Second tick: growing
-	tracker.Record(1, 120)
-	if !tracker.IsGrowing(1, 10) {
+	tracker.Record(p1, 120)
+	if !tracker.IsGrowing(p1, 10) {
 		t.Fatal("should be growing: 100 -> 120 (delta 20 >= 10)")
 	}
 
 	// Third tick: still growing
-	tracker.Record(1, 150)
-	if !tracker.IsGrowing(1, 10) {
+	tracker.Record(p1, 150)
+	if !tracker.IsGrowing(p1, 10) {
 		t.Fatal("should be growing: 100 -> 120 -> 150")
 	}
 
 	// Fourth tick: drops (not monotonic)
-	tracker.Record(1, 140) // window is now [120, 150, 140]
-	if tracker.IsGrowing(1, 10) {
+	tracker.Record(p1, 140) // window is now [120, 150, 140]
+	if tracker.IsGrowing(p1, 10) {
 		t.Fatal("should not be growing: 150 -> 140 is a drop")
 	}
 
 	// Stable process: no growth
-	tracker.Record(2, 500)
-	tracker.Record(2, 500)
-	tracker.Record(2, 500)
-	if tracker.IsGrowing(2, 10) {
+	tracker.Record(p2, 500)
+	tracker.Record(p2, 500)
+	tracker.Record(p2, 500)
+	if tracker.IsGrowing(p2, 10) {
 		t.Fatal("stable RSS should not be flagged as growing")
 	}
 
-	// Prune removes inactive PIDs
-	tracker.Prune(map[uint32]bool{1: true})
-	if tracker.IsGrowing(2, 10) {
-		t.Fatal("pruned PID should have no history")
+	// A recycled PID with a new start time does not inherit history
+	reused := ProcKey{PID: 1, StartTime: 999}
+	tracker.Record(reused, 500)
+	if tracker.IsGrowing(reused, 10) {
+		t.Fatal("recycled PID should start with empty history")
 	}
-	if len(tracker.history) != 1 {
-		t.Fatalf("expected 1 PID after prune, got %d", len(tracker.history))
+
+	// Advance evicts processes not seen in the last idle_ticks ticks
+	tracker.Advance()
+	tracker.Record(p1, 160)
+	tracker.Advance()
+	if tracker.IsGrowing(p2, 10) {
+		t.Fatal("evicted process should have no history")
+	}
+	if tracker.Len() != 1 {
+		t.Fatalf("expected 1 process after eviction, got %d", tracker.Len())
+	}
+}
+
+func TestRSSTrackerBoundedUnderChurn(t *testing.T) {
+	const maxTracked = 64
+	tracker := NewRSSTracker(config.RSSTrackerConfig{WindowTicks: 3, MaxTracked: maxTracked, IdleTicks: 5})
+
+	// A long-lived process that is seen every tick must survive the churn.
+	steady := ProcKey{PID: 1, StartTime: 1}
+	pid := uint32(2)
+	for tick := 0; tick < 500; tick++ {
+		tracker.Record(steady, float64(100+10*tick))
+		// 40 short-lived processes per tick: more than fit in the table
+		// across two ticks, so both LRU and idle eviction are exercised.
+		for i := 0; i < 40; i++ {
+			tracker.Record(ProcKey{PID: pid, StartTime: uint64(tick)}, 10)
+			pid++
+		}
+		if tracker.Len() > maxTracked {
+			t.Fatalf("tick %d: tracked %d processes, limit %d", tick, tracker.Len(), maxTracked)
+		}
+		tracker.Advance()
+	}
+	if !tracker.IsGrowing(steady, 10) {
+		t.Fatal("steady process history should survive churn")
+	}
+
+	// Once churn stops, idle eviction drains everything not seen recently.
+	for tick := 0; tick < 5; tick++ {
+		tracker.Record(steady, 6000)
+		tracker.Advance()
+	}
+	if tracker.Len() != 1 {
+		t.Fatalf("expected only the steady process after idle eviction, got %d", tracker.Len())
+	}
+}
+
+func TestBuildProcMetricsTrackerUsesStartTime(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	start := uint64(1000)
+	procStartTime = func(pid int) (uint64, error) { return start, nil }
+
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	for _, rssBytes := range []uint64{200 << 20, 400 << 20} {
+		pageFaults := []types.PageFaultStat{{PID: 42, Comm: "leaker", Faults: 5000, FaultsPerSec: 1000, RSSBytes: rssBytes}}
+		_, _ = BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+
+	// PID 42 exits and the PID is reused by a new process: its RSS must not
+	// be compared against the previous owner's history.
+	start = 2000
+	pageFaults := []types.PageFaultStat{{PID: 42, Comm: "fresh", Faults: 5000, FaultsPerSec: 1000, RSSBytes: 800 << 20}}
+	_, index := BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if index[42].RSSGrowing {
+		t.Fatal("recycled PID should not inherit RSS growth from previous process")
+	}
+}
+
+func TestBuildProcMetricsCountsDStateIntervals(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procState = readSchedState
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	states := map[int]byte{42: 'D', 43: 'S'}
+	procState = func(pid int) (byte, int, error) {
+		if st, ok := states[pid]; ok {
+			return st, 1, nil
+		}
+		return 0, 0, errors.New("no such process")
+	}
+
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	cpuStats := []types.CPUStat{
+		{PID: 42, Comm: "writer", Cgroup: "/nfs.slice", Ns: 1e6},
+		{PID: 43, Comm: "reader", Ns: 1e6},
+	}
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if got := index[42]; got.State != "D" || got.PPID != 1 || !got.IOBlocked() || got.DStateIntervals != 1 || got.Diagnosis == "Stuck in D-state" {
+		t.Fatalf("one window in D should only mark I/O-blocked: %+v", got)
+	}
+	if got := index[43]; got.State != "S" || got.IOBlocked() || got.DStateIntervals != 0 {
+		t.Fatalf("sleeping process marked blocked: %+v", got)
+	}
+
+	// Stuck, the process no longer runs, so no collector reports it; the
+	// tracker still checks it and keeps its row.
+	var rows []ProcMetrics
+	for range 2 {
+		rows, index = BuildProcMetrics(SnapshotResult{}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	got, ok := index[42]
+	if !ok || got.Comm != "writer" || got.Cgroup != "/nfs.slice" || got.DStateIntervals != 3 || got.Diagnosis != "Stuck in D-state" {
+		t.Fatalf("silent process in D state: got %+v (present %v)", got, ok)
+	}
+	if _, ok := index[43]; ok || CountIOBlocked(rows) != 1 {
+		t.Fatalf("only the blocked process should be kept: %+v", rows)
+	}
+
+	// Once the I/O completes the count starts over.
+	states[42] = 'R'
+	_, index = BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 42, Comm: "writer", Ns: 1e6}}}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if got := index[42]; got.State != "R" || got.DStateIntervals != 0 || got.Diagnosis == "Stuck in D-state" {
+		t.Fatalf("recovered process: %+v", got)
 	}
 }
 
 func TestBuildProcMetricsWithTracker(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
-	rssBytesForPIDs = func(pids []int) map[int]uint64 { return nil }
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
 
-	tracker := NewRSSTracker(3)
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
 	interval := time.Second
 
 	// Simulate 3 ticks of growing RSS for a leaking process
@@ -527,14 +1322,14 @@ func TestBuildProcMetricsWithTracker(t *testing.T) {
 		pageFaults := []types.PageFaultStat{
 			{PID: 42, Comm: "leaker", Faults: 5000, FaultsPerSec: 1000, RSSBytes: rssBytes},
 		}
-		_, _ = BuildProcMetrics(nil, pageFaults, nil, interval, tracker, defaultTh)
+		_, _ = BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: interval, Tracker: tracker, Thresholds: defaultTh})
 	}
 
 	// On the 3rd tick, the process should be classified as OOM risk
 	pageFaults := []types.PageFaultStat{
 		{PID: 42, Comm: "leaker", Faults: 5000, FaultsPerSec: 1000, RSSBytes: 800 << 20},
 	}
-	_, index := BuildProcMetrics(nil, pageFaults, nil, interval, tracker, defaultTh)
+	_, index := BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: interval, Tracker: tracker, Thresholds: defaultTh})
 	row := index[42]
 	if row.Diagnosis != "OOM risk – memory growth" {
 		t.Fatalf("expected OOM risk with growing RSS, got %s (RSSMB=%.1f, RSSGrowing=%v)",
@@ -542,9 +1337,99 @@ func TestBuildProcMetricsWithTracker(t *testing.T) {
 	}
 }
 
+func TestBuildProcMetricsProjectsTimeToLimit(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		memoryHeadroom = processMemoryHeadroom
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	// 300 MB used, 600 MB left under the container's memory.max.
+	memoryHeadroom = func(pid int) (uint64, string, error) { return 600 << 20, "cgroup", nil }
+
+	// 100 MB -> 300 MB over 3 ticks of 2s: 50 MB/s, so 12s to the limit.
+	// The process is far below oom.rss_mb but is flagged ahead of the kill.
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	var index map[uint32]ProcMetrics
+	for _, rssBytes := range []uint64{100 << 20, 200 << 20, 300 << 20} {
+		pageFaults := []types.PageFaultStat{{PID: 42, Comm: "leaker", Faults: 5000, FaultsPerSec: 1000, RSSBytes: rssBytes}}
+		_, index = BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: 2 * time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	row := index[42]
+	if row.GrowthMBPerSec != 50 || row.LimitKind != "cgroup" || row.TimeToLimit != 12*time.Second {
+		t.Fatalf("projection = %.1f MB/s, %q in %v; want 50 MB/s, cgroup in 12s",
+			row.GrowthMBPerSec, row.LimitKind, row.TimeToLimit)
+	}
+	if row.Diagnosis != "OOM risk – memory growth" {
+		t.Fatalf("expected OOM risk for a process near its limit, got %s", row.Diagnosis)
+	}
+
+	// With projection disabled the same process is too small to flag.
+	th := config.Default()
+	th.OOM.ProjectionSeconds = 0
+	tracker = NewRSSTracker(th.RSSTracker)
+	for _, rssBytes := range []uint64{100 << 20, 200 << 20, 300 << 20} {
+		pageFaults := []types.PageFaultStat{{PID: 42, Comm: "leaker", Faults: 5000, FaultsPerSec: 1000, RSSBytes: rssBytes}}
+		_, index = BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: 2 * time.Second, Tracker: tracker, Thresholds: th})
+	}
+	if row := index[42]; row.LimitKind != "" || row.Diagnosis == "OOM risk – memory growth" {
+		t.Fatalf("projection disabled: got %q limit, diagnosis %s", row.LimitKind, row.Diagnosis)
+	}
+}
+
+func TestClassifyProcMinFaultCount(t *testing.T) {
+	// 2 faults in a short window extrapolate to a high rate, but are too few
+	// to mean anything.
+	cases := []struct {
+		name string
+		row  ProcMetrics
+	}{
+		{"thrashing", ProcMetrics{CPUPercent: 5, FaultsPerSec: 1500, CPUCostPerFault: 0.8, Faults: 2}},
+		{"volume", ProcMetrics{CPUPercent: 5, FaultsPerSec: 15000, CPUCostPerFault: 0.003, Faults: 2}},
+		{"oom", ProcMetrics{RSSMB: 2048, RSSRatio: 0.4, FaultsPerSec: 500, Faults: 2, RSSGrowing: true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if label := classifyProc(&tc.row, defaultTh); label != "OK" {
+				t.Fatalf("expected OK below min fault count, got %s", label)
+			}
+			lowered := defaultTh
+			lowered.Faults.MinCount = 0
+			if label := classifyProc(&tc.row, lowered); label == "OK" {
+				t.Fatal("expected a fault-based diagnosis with the count gate disabled")
+			}
+		})
+	}
+}
+
+func TestClassifyProcStarvedCountsInvoluntarySwitches(t *testing.T) {
+	// Run-queue latency not measured: 200 switch-outs to other processes
+	// were recorded as contention, but most were the process blocking on
+	// I/O rather than being preempted.
+	blocking := ProcMetrics{CPUPercent: 2, Preempted: 200, VolCtxSwitches: 190, InvolCtxSwitches: 10}
+	if label := classifyProc(&blocking, defaultTh); label == "Starved" {
+		t.Fatal("a process that mostly blocks voluntarily is not starved")
+	}
+
+	preempted := ProcMetrics{CPUPercent: 2, Preempted: 200, VolCtxSwitches: 20, InvolCtxSwitches: 180}
+	if label := classifyProc(&preempted, defaultTh); label != "Starved" {
+		t.Fatalf("expected Starved for a process preempted while runnable, got %s", label)
+	}
+	if got := KeyMetric(ProcMetrics{Diagnosis: "Starved", Preempted: 200, VolCtxSwitches: 20, InvolCtxSwitches: 180}); got != "preempted 180x" {
+		t.Fatalf("KeyMetric = %q; want the involuntary switch count", got)
+	}
+
+	// Rows without the split (an older dump) keep using the contention count.
+	legacy := ProcMetrics{CPUPercent: 2, Preempted: 200}
+	if label := classifyProc(&legacy, defaultTh); label != "Starved" {
+		t.Fatalf("expected Starved from the contention count, got %s", label)
+	}
+}
+
 func TestClassifyProcWithCustomThresholds(t *testing.T) {
 	// With default thresholds, 200MB + 300 faults/sec + growing = OK (below 500MB)
-	row := ProcMetrics{RSSMB: 200, FaultsPerSec: 300, RSSGrowing: true, Faults: 1}
+	row := ProcMetrics{RSSMB: 200, FaultsPerSec: 300, RSSGrowing: true, Faults: 1500}
 	if label := classifyProc(&row, defaultTh); label != "OK" {
 		t.Fatalf("expected OK with defaults (200MB < 500MB), got %s", label)
 	}
@@ -576,8 +1461,8 @@ func boolPtr(v bool) *bool { return &v }
 // Previously, CPU was keyed by TID and memory by TGID, causing multi-threaded
 // processes to have disjoint rows.
 func TestBuildProcMetricsMultiThreadedMerge(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
-	rssBytesForPIDs = func(pids []int) map[int]uint64 { return nil }
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
 
 	interval := time.Second
 	tgid := uint32(1000) // shared process ID
@@ -588,10 +1473,10 @@ func TestBuildProcMetricsMultiThreadedMerge(t *testing.T) {
 		{PID: tgid, Comm: "java", Cgroup: "/kubepods", Ns: uint64(200 * time.Millisecond)},
 	}
 	pageFaults := []types.PageFaultStat{
-		{PID: tgid, Comm: "java", Cgroup: "/kubepods", Faults: 500, FaultsPerSec: 100, RSSBytes: 1 << 30},
+		{PID: tgid, Comm: "java", Faults: 500, FaultsPerSec: 100, RSSBytes: 1 << 30},
 	}
 
-	rows, index := BuildProcMetrics(cpuStats, pageFaults, nil, interval, nil, defaultTh)
+	rows, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, PageFaults: pageFaults}, BuildOptions{Interval: interval, Thresholds: defaultTh})
 
 	// Must produce exactly one row for the TGID.
 	if len(rows) != 1 {
@@ -625,8 +1510,8 @@ func TestBuildProcMetricsMultiThreadedMerge(t *testing.T) {
 // TestBuildProcMetricsContentionMerge verifies that contention data (also TGID-keyed
 // after the fix) merges correctly with CPU and memory data.
 func TestBuildProcMetricsContentionMerge(t *testing.T) {
-	t.Cleanup(func() { rssBytesForPIDs = memory.RSSBytesForPIDs })
-	rssBytesForPIDs = func(pids []int) map[int]uint64 { return nil }
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
 
 	interval := time.Second
 	victimTGID := uint32(2000)
@@ -643,7 +1528,7 @@ func TestBuildProcMetricsContentionMerge(t *testing.T) {
 		{VictimPID: victimTGID, VictimComm: "victim-app", AggressorPID: aggressorTGID, AggressorComm: "aggressor-app", Count: 200},
 	}
 
-	_, index := BuildProcMetrics(cpuStats, pageFaults, contention, interval, nil, defaultTh)
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, PageFaults: pageFaults, Contention: contention}, BuildOptions{Interval: interval, Thresholds: defaultTh})
 
 	victim := index[victimTGID]
 	if victim.Preempted != 200 {
@@ -661,3 +1546,621 @@ func TestBuildProcMetricsContentionMerge(t *testing.T) {
 		t.Fatalf("aggressor preempts-others not merged: got %d", aggressor.PreemptsOthers)
 	}
 }
+
+func TestIdleTaskExcludedFromContention(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	cpuStats := []types.CPUStat{
+		{PID: 2000, Comm: "victim-app", Cgroup: "/pods", Ns: uint64(10 * time.Millisecond)},
+	}
+	contention := []types.ContentionStat{
+		{VictimPID: 2000, VictimComm: "victim-app", AggressorPID: 0, AggressorComm: "idle", Count: 5000},
+		{VictimPID: 0, VictimComm: "idle", AggressorPID: 2000, AggressorComm: "victim-app", Count: 5000},
+	}
+
+	rows, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, Contention: contention}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	if _, ok := index[0]; ok {
+		t.Fatal("idle task should not get a row")
+	}
+	victim := index[2000]
+	if victim.Preempted != 0 || victim.PreemptsOthers != 0 {
+		t.Fatalf("switches involving idle counted as contention: %+v", victim)
+	}
+	if victim.Diagnosis == "Starved" || victim.Diagnosis == "Noisy neighbor" {
+		t.Fatalf("idle switches should not drive a diagnosis, got %s", victim.Diagnosis)
+	}
+
+	// Even if an idle row slips into the index, it must not surface.
+	index[0] = ProcMetrics{PID: 0, Comm: "idle", Diagnosis: "Noisy neighbor", PreemptsOthers: 5000}
+	if got := FilterContentionRows(contention, FilterConfig{HideKernel: boolPtr(false)}, index, 0); len(got) != 0 {
+		t.Fatalf("expected idle pairs to be filtered, got %+v", got)
+	}
+	if got := ContentionFairness(contention)(2000, 0); got != 0 {
+		t.Fatalf("expected no fairness score for idle pair, got %f", got)
+	}
+	for _, group := range SelectFocusGroups(append(rows, index[0])) {
+		for _, proc := range group.Procs {
+			if proc.PID == 0 {
+				t.Fatalf("idle task selected for focus in %s", group.Diagnosis)
+			}
+		}
+	}
+}
+
+func TestBuildProcMetricsRunqueueLatencyDrivesStarved(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	// Both processes are preempted often and get little CPU. Only "waiter"
+	// then sat on the run queue; "sleeper" went idle on its own.
+	cpuStats := []types.CPUStat{
+		{PID: 10, Comm: "waiter", Ns: uint64(10 * time.Millisecond)},
+		{PID: 11, Comm: "sleeper", Ns: uint64(10 * time.Millisecond)},
+	}
+	contention := []types.ContentionStat{
+		{VictimPID: 10, VictimComm: "waiter", AggressorPID: 20, AggressorComm: "hog", Count: 500},
+		{VictimPID: 11, VictimComm: "sleeper", AggressorPID: 20, AggressorComm: "hog", Count: 500},
+	}
+	runq := []types.RunqLatencyStat{
+		{PID: 10, Comm: "waiter", Waits: 40, Waiting: 1, TotalNs: uint64(600 * time.Millisecond), MaxNs: uint64(200 * time.Millisecond)},
+		{PID: 11, Comm: "sleeper", Waits: 500, TotalNs: uint64(5 * time.Millisecond), MaxNs: uint64(time.Millisecond)},
+	}
+
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, Contention: contention, RunqLatency: runq}, BuildOptions{Interval: 2 * time.Second, Thresholds: defaultTh})
+	waiter, sleeper := index[10], index[11]
+	if !waiter.RunqTracked || waiter.RunqWaitMs != 600 || waiter.RunqMaxMs != 200 || waiter.RunqWaitPercent != 30 {
+		t.Fatalf("unexpected run-queue metrics: %+v", waiter)
+	}
+	if waiter.Diagnosis != "Starved" {
+		t.Fatalf("waiter: expected Starved, got %s", waiter.Diagnosis)
+	}
+	if sleeper.Diagnosis == "Starved" {
+		t.Fatal("sleeper barely waited for a CPU and should not be Starved")
+	}
+	if got := FocusSummary(waiter); !strings.Contains(got, "waited 600.0 ms for a CPU (longest 200.0 ms)") {
+		t.Fatalf("unexpected focus summary: %q", got)
+	}
+
+	// A process that never waited has no entry but is still tracked.
+	if hog := index[20]; !hog.RunqTracked || hog.RunqWaitMs != 0 {
+		t.Fatalf("aggressor should be tracked with no wait: %+v", hog)
+	}
+
+	// Disabling the threshold falls back to the preemption count.
+	th := config.Default()
+	th.Starved.MinRunqWaitPercent = 0
+	_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats, Contention: contention, RunqLatency: runq}, BuildOptions{Interval: 2 * time.Second, Thresholds: th})
+	if index[11].Diagnosis != "Starved" {
+		t.Fatalf("sleeper: expected Starved from preemptions, got %s", index[11].Diagnosis)
+	}
+}
+
+func TestBuildProcMetricsBlockIO(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	cpuStats := []types.CPUStat{{PID: 10, Comm: "postgres", Ns: uint64(5 * time.Millisecond)}}
+	blockIO := []types.BlockIOStat{
+		{PID: 10, Comm: "postgres", ReadBytes: 8 << 20, WriteBytes: 1 << 20, Requests: 300, AvgLatencyNs: uint64(2500 * time.Microsecond)},
+		// Writeback is issued by flusher threads that may not appear in CPU stats.
+		{PID: 40, Comm: "kworker/u8:2", WriteBytes: 64 << 20, Requests: 90, AvgLatencyNs: uint64(time.Millisecond)},
+	}
+
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, BlockIO: blockIO}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	pg := index[10]
+	if pg.IOReadBytes != 8<<20 || pg.IOWriteBytes != 1<<20 || pg.IORequests != 300 || pg.IOLatencyMs != 2.5 {
+		t.Fatalf("unexpected block I/O metrics: %+v", pg)
+	}
+	if flusher, ok := index[40]; !ok || flusher.Comm != "kworker/u8:2" || flusher.IOWriteBytes != 64<<20 {
+		t.Fatalf("I/O-only process missing or incomplete: %+v", flusher)
+	}
+}
+
+func TestBuildProcMetricsSyscalls(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	cpuStats := []types.CPUStat{{PID: 10, Comm: "nginx", Ns: uint64(5 * time.Millisecond)}}
+	syscalls := []types.SyscallStat{
+		{PID: 10, Comm: "nginx", TotalNs: uint64(500 * time.Millisecond), Count: 4000, TopSyscall: "epoll_wait"},
+		// A process blocked in a syscall all window may never be scheduled.
+		{PID: 20, Comm: "sleeper", TotalNs: uint64(2 * time.Second), Count: 1, TopSyscall: "nanosleep"},
+	}
+
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, Syscalls: syscalls}, BuildOptions{Interval: 2 * time.Second, Thresholds: defaultTh})
+	nginx := index[10]
+	if nginx.SyscallMs != 500 || nginx.Syscalls != 4000 || nginx.SyscallsPerSec != 2000 || nginx.SyscallPercent != 25 || nginx.TopSyscall != "epoll_wait" {
+		t.Fatalf("unexpected syscall metrics: %+v", nginx)
+	}
+	if sleeper, ok := index[20]; !ok || sleeper.Comm != "sleeper" || sleeper.SyscallPercent != 100 {
+		t.Fatalf("syscall-only process missing or incomplete: %+v", sleeper)
+	}
+}
+
+func TestBuildProcMetricsFutex(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	cpuStats := []types.CPUStat{{PID: 10, Comm: "cache", Ns: uint64(100 * time.Millisecond)}}
+	futex := []types.FutexStat{
+		// Eight threads blocked on one lock for most of a 2s window.
+		{PID: 10, Comm: "cache", WaitNs: uint64(7 * time.Second), Contended: 20000},
+		// A parked pool: long waits, few of them; never scheduled in the window.
+		{PID: 20, Comm: "pool", WaitNs: uint64(16 * time.Second), Contended: 8},
+	}
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, Futex: futex}, BuildOptions{Interval: 2 * time.Second, Thresholds: defaultTh})
+
+	cache := index[10]
+	if cache.FutexWaitMs != 7000 || cache.FutexContended != 20000 || cache.FutexContendedPerSec != 10000 || cache.FutexWaitPercent != 350 {
+		t.Fatalf("unexpected futex metrics: %+v", cache)
+	}
+	if cache.Diagnosis != "Lock-contended" {
+		t.Fatalf("expected Lock-contended, got %s", cache.Diagnosis)
+	}
+	pool, ok := index[20]
+	if !ok || pool.Comm != "pool" || pool.FutexWaitPercent != 800 {
+		t.Fatalf("futex-only process missing or incomplete: %+v", pool)
+	}
+	if pool.Diagnosis != "OK" {
+		t.Fatalf("an idle pool should not be Lock-contended, got %s", pool.Diagnosis)
+	}
+	if rows := FutexRows([]ProcMetrics{cache, pool, index[10]}, 2); len(rows) != 2 || rows[0].PID != 20 || rows[1].PID != 10 {
+		t.Fatalf("FutexRows should order by wait time: %+v", rows)
+	}
+}
+
+func TestBuildProcMetricsNet(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	cpuStats := []types.CPUStat{{PID: 10, Comm: "nginx", Ns: uint64(50 * time.Millisecond)}}
+	net := []types.NetStat{
+		{PID: 10, Comm: "nginx", TxBytes: 8 << 20, RxBytes: 2 << 20},
+		// A process that only received in the window and was never scheduled.
+		{PID: 20, Comm: "scp", RxBytes: 16 << 20},
+		{PID: 30, Comm: "idle"},
+	}
+	rows, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, Net: net}, BuildOptions{Interval: 2 * time.Second, Thresholds: defaultTh})
+
+	nginx := index[10]
+	if nginx.NetTxBytes != 8<<20 || nginx.NetRxBytes != 2<<20 || nginx.NetTxBytesPerSec != 4<<20 || nginx.NetRxBytesPerSec != 1<<20 {
+		t.Fatalf("unexpected network metrics: %+v", nginx)
+	}
+	if scp, ok := index[20]; !ok || scp.Comm != "scp" || scp.NetRxBytes != 16<<20 {
+		t.Fatalf("network-only process missing or incomplete: %+v", scp)
+	}
+	if got := NetRows(rows, 0); len(got) != 2 || got[0].PID != 20 || got[1].PID != 10 {
+		t.Fatalf("NetRows should skip idle processes and order by bytes moved: %+v", got)
+	}
+}
+
+func TestBuildProcMetricsSwitchLoss(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	// 20k switches at the default 5µs each is 100ms of refill against 250ms
+	// of CPU: an estimated 40% lost.
+	cpuStats := []types.CPUStat{
+		{PID: 10, Comm: "proxy", Ns: uint64(250 * time.Millisecond), Switches: 20000},
+		{PID: 11, Comm: "tiny", Ns: uint64(time.Millisecond), Switches: 5000},
+	}
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: 2 * time.Second, Thresholds: defaultTh})
+
+	proxy := index[10]
+	if proxy.SwitchesPerSec != 10000 {
+		t.Fatalf("switches/sec: got %.1f, want 10000", proxy.SwitchesPerSec)
+	}
+	if math.Abs(proxy.SwitchLossPercent-40) > 0.01 {
+		t.Fatalf("switch loss: got %.2f%%, want 40%%", proxy.SwitchLossPercent)
+	}
+	if proxy.Diagnosis != "Switch-thrashing" {
+		t.Fatalf("expected Switch-thrashing, got %s", proxy.Diagnosis)
+	}
+	if got := index[11].SwitchLossPercent; got != 100 {
+		t.Fatalf("loss should be capped at 100%%, got %.1f", got)
+	}
+}
+
+func TestBuildProcMetricsMajorFaultLatency(t *testing.T) {
+	t.Cleanup(func() { rssBytesForProcs = memory.RSSBytesForProcs })
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+
+	// 40 major faults totalling 400ms is 10ms each: far slower than local
+	// storage. The same count at 0.1ms each is a healthy page-in rate.
+	pageFaults := []types.PageFaultStat{
+		{PID: 20, Comm: "nfs-reader", Faults: 60, FaultsPerSec: 30, MajorFaults: 40, MajorFaultNs: uint64(400 * time.Millisecond)},
+		{PID: 21, Comm: "nvme-reader", Faults: 60, FaultsPerSec: 30, MajorFaults: 40, MajorFaultNs: uint64(4 * time.Millisecond)},
+	}
+	_, index := BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: 2 * time.Second, Thresholds: defaultTh})
+
+	slow := index[20]
+	if slow.MajorFaultsPerSec != 20 || math.Abs(slow.MajorFaultLatencyMs-10) > 1e-9 {
+		t.Fatalf("got %.1f major faults/sec at %.3f ms, want 20 at 10 ms", slow.MajorFaultsPerSec, slow.MajorFaultLatencyMs)
+	}
+	if slow.Diagnosis != "Swap/disk-bound faults" {
+		t.Fatalf("expected Swap/disk-bound faults, got %s", slow.Diagnosis)
+	}
+	if fast := index[21]; fast.Diagnosis != "OK" {
+		t.Fatalf("fast major faults should stay OK, got %s (%.3f ms)", fast.Diagnosis, fast.MajorFaultLatencyMs)
+	}
+
+	merged := MergeByIdentity([]ProcMetrics{
+		{PID: 1, Comm: "w", MajorFaults: 10, MajorFaultNs: uint64(100 * time.Millisecond), MajorFaultLatencyMs: 10},
+		{PID: 2, Comm: "w", MajorFaults: 30, MajorFaultNs: uint64(60 * time.Millisecond), MajorFaultLatencyMs: 2},
+	}, IdentityCommCgroup)
+	if len(merged) != 1 || merged[0].MajorFaultLatencyMs != 4 {
+		t.Fatalf("merged latency should be recomputed from totals (4 ms), got %+v", merged)
+	}
+}
+
+func TestSelectFocusPID(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 10, Diagnosis: "Starved"},
+		{PID: 20, Diagnosis: "OK", CPUPercent: 2},
+	}
+	row, ok := SelectFocusPID(rows, 20)
+	if !ok || row.PID != 20 || row.Diagnosis != "OK" {
+		t.Fatalf("expected the OK row for pid 20, got %+v (found=%t)", row, ok)
+	}
+	if _, ok := SelectFocusPID(rows, 30); ok {
+		t.Fatal("expected pid 30 not to be found")
+	}
+}
+
+func TestBuildProcMetricsHugepages(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procHugetlbBytes = procfs.HugetlbBytes
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	reads := 0
+	procHugetlbBytes = func(pid int) (uint64, error) {
+		reads++
+		return 4 << 30, nil
+	}
+
+	// A database with a small RSS but 4 GiB of explicit hugepages, growing.
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	var index map[uint32]ProcMetrics
+	for _, rssBytes := range []uint64{100 << 20, 150 << 20, 200 << 20} {
+		pageFaults := []types.PageFaultStat{{PID: 7, Comm: "postgres", Faults: 5000, FaultsPerSec: 1000, RSSBytes: rssBytes}}
+		_, index = BuildProcMetrics(SnapshotResult{PageFaults: pageFaults}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+
+	row := index[7]
+	if row.HugepagesMB != 4096 || row.FootprintMB() != 4096+200 {
+		t.Fatalf("expected 4096 MB of hugepages on top of RSS, got %+v", row)
+	}
+	if row.Diagnosis != "OOM risk – memory growth" {
+		t.Fatalf("hugepages should count toward the OOM size gate, got %s", row.Diagnosis)
+	}
+	if reads != 1 {
+		t.Fatalf("hugetlb usage should be cached per process, read %d times", reads)
+	}
+}
+
+func TestBuildProcMetricsAllowedCPUs(t *testing.T) {
+	if runtime.NumCPU() < 2 {
+		t.Skip("needs a machine with more CPUs than the pinned process")
+	}
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procAllowedCPUs = procfs.AllowedCPUs
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	reads := 0
+	procAllowedCPUs = func(pid int) (int, error) {
+		reads++
+		switch pid {
+		case 7:
+			return 1, nil
+		case 8:
+			return 4 * runtime.NumCPU(), nil // offline CPUs in the cpuset
+		}
+		return 0, errors.New("no such process")
+	}
+
+	// Each process keeps one CPU busy for the whole window.
+	cpuNs := uint64(time.Second.Nanoseconds())
+	cpuStats := []types.CPUStat{{PID: 7, Comm: "pinned", Ns: cpuNs}, {PID: 8, Comm: "wide", Ns: cpuNs}, {PID: 9, Comm: "gone", Ns: cpuNs}}
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	var index map[uint32]ProcMetrics
+	for range 2 {
+		_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+
+	if pinned := index[7]; pinned.AllowedCPUs != 1 || pinned.CPUPercent != 100 {
+		t.Fatalf("a process saturating its only CPU should show 100%%, got %d CPUs, %.2f%%", pinned.AllowedCPUs, pinned.CPUPercent)
+	}
+	machine := 100 / float64(runtime.NumCPU())
+	for _, pid := range []uint32{8, 9} {
+		if row := index[pid]; row.AllowedCPUs != runtime.NumCPU() || math.Abs(row.CPUPercent-machine) > 1e-6 {
+			t.Fatalf("pid %d should fall back to the whole machine, got %d CPUs, %.2f%%", pid, row.AllowedCPUs, row.CPUPercent)
+		}
+	}
+	// PIDs 7 and 8 are read once and cached; PID 9's failed read is retried.
+	if reads != 4 {
+		t.Fatalf("allowed CPUs should be cached per process, read %d times", reads)
+	}
+
+	_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	if row := index[7]; row.AllowedCPUs != runtime.NumCPU() || math.Abs(row.CPUPercent-machine) > 1e-6 {
+		t.Fatalf("without a tracker CPU%% should span the machine, got %d CPUs, %.2f%%", row.AllowedCPUs, row.CPUPercent)
+	}
+}
+
+func TestBuildProcMetricsExePathCached(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procExePath = procfs.ExePath
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	reads := 0
+	procExePath = func(pid int) (string, error) {
+		reads++
+		if pid == 2 {
+			return "", errors.New("permission denied")
+		}
+		return "/usr/local/bin/myapp", nil
+	}
+
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	var index map[uint32]ProcMetrics
+	for _, comm := range []string{"sh", "sh", "myapp"} {
+		cpuStats := []types.CPUStat{{PID: 7, Comm: comm, Ns: 1e6}, {PID: 2, Comm: "kthreadd", Ns: 1e6}}
+		_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	if index[7].ExePath != "/usr/local/bin/myapp" || index[2].ExePath != "" {
+		t.Fatalf("unexpected exe paths: %q, %q", index[7].ExePath, index[2].ExePath)
+	}
+	// One read per process, plus one for PID 7 after its exec changed comm.
+	if reads != 3 {
+		t.Fatalf("exe path should be cached per process instance, read %d times", reads)
+	}
+}
+
+func TestBuildProcMetricsCmdline(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procCmdline = procfs.Cmdline
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	reads := 0
+	procCmdline = func(pid int) ([]string, error) {
+		reads++
+		switch pid {
+		case 7:
+			return []string{"/usr/bin/containerd-shim-runc-v2", "-namespace", "k8s.io", "--token=abc123"}, nil
+		case 8:
+			return []string{"java", strings.Repeat("-Xflag ", 20)}, nil
+		default:
+			return nil, nil // kernel thread
+		}
+	}
+	cpuStats := []types.CPUStat{
+		{PID: 7, Comm: "containerd-shim", Ns: 1e6},
+		{PID: 8, Comm: "java", Ns: 1e6},
+		{PID: 2, Comm: "kthreadd", Ns: 1e6},
+	}
+
+	// Without ShowCmdline, nothing is read.
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if reads != 0 || index[7].Cmdline != "" || index[7].CommLabel() != "containerd-shim" {
+		t.Fatalf("cmdline read without -full-cmd: %d reads, %+v", reads, index[7])
+	}
+
+	tracker.ShowCmdline()
+	for range 2 {
+		_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	if got := index[7].CommLabel(); got != "/usr/bin/containerd-shim-runc-v2 -namespace k8s.io --token=***" {
+		t.Fatalf("unexpected command line: %q", got)
+	}
+	if got := []rune(index[8].Cmdline); len(got) != cmdlineMaxLen || got[len(got)-1] != '…' {
+		t.Fatalf("long command line not truncated: %q", index[8].Cmdline)
+	}
+	if index[2].Cmdline != "kthreadd" {
+		t.Fatalf("kernel thread should fall back to comm, got %q", index[2].Cmdline)
+	}
+	if reads != 3 {
+		t.Fatalf("command line should be cached per process instance, read %d times", reads)
+	}
+}
+
+func TestBuildProcMetricsResolvesCgroupIDs(t *testing.T) {
+	t.Cleanup(func() { cgroupPathForID = cgroup.PathForID })
+	cgroupPathForID = func(id uint64) string {
+		if id == 7429 {
+			return "/system.slice/nginx.service"
+		}
+		return ""
+	}
+
+	cpuStats := []types.CPUStat{
+		{PID: 7, Comm: "nginx", CgroupID: 7429, Ns: 1e6},
+		{PID: 8, Comm: "gone", CgroupID: 9001, Ns: 1e6},
+		{PID: 9, Comm: "polled", Cgroup: "/user.slice", Ns: 1e6}, // read from /proc, not BPF
+	}
+	pageFaults := []types.PageFaultStat{{PID: 10, Comm: "faulter", CgroupID: 7429, Faults: 5}}
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats, PageFaults: pageFaults}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	want := map[uint32]string{7: "/system.slice/nginx.service", 8: "n/a", 9: "/user.slice", 10: "/system.slice/nginx.service"}
+	for pid, cg := range want {
+		if got := index[pid].Cgroup; got != cg {
+			t.Fatalf("pid %d: Cgroup = %q; want %q", pid, got, cg)
+		}
+	}
+}
+
+func TestBuildProcMetricsContainerLookupCached(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procCgroupPaths = procfs.CgroupPaths
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	reads := 0
+	procCgroupPaths = func(pid int) ([]string, error) {
+		reads++
+		return []string{"/system.slice/nginx.service"}, nil
+	}
+
+	cpuStats := []types.CPUStat{{PID: 7, Comm: "nginx", Cgroup: "nginx.service", Ns: 1e6}}
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if reads != 0 {
+		t.Fatal("cgroup paths read without -resolve-containers")
+	}
+
+	tracker.ResolveContainers(container.NewResolver())
+	for range 3 {
+		_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	if reads != 1 {
+		t.Fatalf("cgroup paths should be read once per process instance, read %d times", reads)
+	}
+	// Not a container: the raw cgroup is shown.
+	if got := index[7].CgroupLabel(); got != "nginx.service" {
+		t.Fatalf("CgroupLabel() = %q", got)
+	}
+
+	row := ProcMetrics{Cgroup: "cri-containerd-9b2e.scope", PodName: "shop/checkout-7d9f", ContainerName: "api"}
+	if got := row.CgroupLabel(); got != "shop/checkout-7d9f/api" {
+		t.Fatalf("CgroupLabel() = %q", got)
+	}
+}
+
+func TestBuildProcMetricsReadsNSPidWithContainers(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procCgroupPaths = procfs.CgroupPaths
+		procNSPid = procfs.NSPid
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	procCgroupPaths = func(pid int) ([]string, error) { return []string{"/"}, nil }
+	reads := 0
+	procNSPid = func(pid int) (uint32, error) {
+		reads++
+		switch pid {
+		case 4211:
+			return 1, nil
+		case 812:
+			return 812, nil
+		}
+		return 0, errors.New("no NSpid")
+	}
+
+	cpuStats := []types.CPUStat{{PID: 4211, Comm: "nginx", Ns: 1e6}, {PID: 812, Comm: "sshd", Ns: 1e6}, {PID: 900, Comm: "old", Ns: 1e6}}
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if reads != 0 || index[4211].NSPid != 0 || index[4211].HostPID != 4211 {
+		t.Fatalf("NSpid read without -resolve-containers: %+v", index[4211])
+	}
+
+	tracker.ResolveContainers(container.NewResolver())
+	for range 3 {
+		_, index = BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	if reads != 3 {
+		t.Fatalf("NSpid should be read once per process instance, read %d times", reads)
+	}
+	for pid, want := range map[uint32]string{4211: "4211 (1)", 812: "812", 900: "900"} {
+		if got := index[pid].PIDLabel(); got != want {
+			t.Fatalf("PID %d: PIDLabel() = %q, want %q", pid, got, want)
+		}
+	}
+}
+
+func TestBuildProcMetricsRSSRatioUsesCgroupLimit(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+		procHugetlbBytes = procfs.HugetlbBytes
+		procExePath = procfs.ExePath
+		procCgroupPaths = procfs.CgroupPaths
+		cgroupMemoryLimit = cgroup.MemoryLimit
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 {
+		return map[int]uint64{10: 512 << 20, 11: 256 << 20, 20: 512 << 20}
+	}
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+	procHugetlbBytes = func(pid int) (uint64, error) { return 0, nil }
+	procExePath = func(pid int) (string, error) { return "", nil }
+	procCgroupPaths = func(pid int) ([]string, error) {
+		if pid == 20 {
+			return []string{"/system.slice/cron.service"}, nil
+		}
+		return []string{"/kubepods/pod1/c1"}, nil
+	}
+	limitReads := 0
+	cgroupMemoryLimit = func(path string) (uint64, bool, error) {
+		limitReads++
+		if path == "/kubepods/pod1/c1" {
+			return 1 << 30, true, nil
+		}
+		return 0, false, nil
+	}
+
+	cpuStats := []types.CPUStat{{PID: 10, Ns: 1e6}, {PID: 11, Ns: 1e6}, {PID: 20, Ns: 1e6}}
+	_, index := BuildProcMetrics(SnapshotResult{CPU: cpuStats}, BuildOptions{Interval: time.Second, Tracker: NewRSSTracker(defaultTh.RSSTracker), Thresholds: defaultTh})
+	if got := index[10]; got.MemLimitMB != 1024 || got.RSSRatio != 0.5 {
+		t.Fatalf("limited process: MemLimitMB=%g RSSRatio=%g, want 1024 and 0.5", got.MemLimitMB, got.RSSRatio)
+	}
+	if got := index[11]; got.RSSRatio != 0.25 {
+		t.Fatalf("second process in the pod: RSSRatio=%g, want 0.25", got.RSSRatio)
+	}
+	total, err := memory.TotalMemoryBytes()
+	if err != nil {
+		t.Skipf("no /proc/meminfo: %v", err)
+	}
+	if got := index[20]; got.MemLimitMB != 0 || math.Abs(got.RSSRatio-float64(512<<20)/float64(total)) > 1e-9 {
+		t.Fatalf("unlimited process should be measured against RAM: %+v", got)
+	}
+	if limitReads != 2 {
+		t.Fatalf("limits should be read once per cgroup per tick, read %d times", limitReads)
+	}
+}
+
+func TestParseSeverityAndGate(t *testing.T) {
+	cases := map[string]int{
+		"0":       0,
+		"5":       5,
+		"starved": diagnosisSeverity("Starved"),
+		"oom":     diagnosisSeverity("OOM risk – memory growth"),
+	}
+	for in, want := range cases {
+		if got, err := ParseSeverity(in); err != nil || got != want {
+			t.Fatalf("ParseSeverity(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"-1", "99", "bogus"} {
+		if _, err := ParseSeverity(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+
+	groups := SelectFocusGroups([]ProcMetrics{
+		{PID: 1, Diagnosis: "CPU-bound"},
+		{PID: 2, Diagnosis: "Starved"},
+		{PID: 3, Diagnosis: "Mem-thrashing"},
+	})
+	gated := FocusGroupsAtLeast(groups, diagnosisSeverity("Starved"))
+	if len(gated) != 2 || gated[0].Diagnosis != "Mem-thrashing" || gated[1].Diagnosis != "Starved" {
+		t.Fatalf("expected Mem-thrashing and Starved to pass, got %+v", gated)
+	}
+	if all := FocusGroupsAtLeast(groups, 0); len(all) != 3 {
+		t.Fatalf("threshold 0 should keep every group, got %d", len(all))
+	}
+}
@@ -0,0 +1,30 @@
+package report
+
+import "github.com/srodi/hotspot-bpf/pkg/config"
+
+// LabelChange records a process whose diagnosis differs under new thresholds.
+type LabelChange struct {
+	PID  uint32
+	Comm string
+	From string
+	To   string
+}
+
+// Reclassify re-applies the classifier with th to rows already built by
+// BuildProcMetrics, e.g. from a flight-recorder capture, and returns the rows
+// whose label changed, in input order. rows are not modified.
+//
+// SwitchLossPercent is recomputed because it depends on th. RSSGrowing and
+// the RSS slope are taken as recorded: the RSS history they were derived
+// from is not captured, so a different leak_suspect.window_ticks has no
+// effect.
+func Reclassify(rows []ProcMetrics, th config.Thresholds) []LabelChange {
+	var changes []LabelChange
+	for _, row := range rows {
+		row.SwitchLossPercent = switchLossPercent(row.Switches, row.CPUNs, th.SwitchThrashing.CostPerSwitchUs)
+		if label := classifyProc(&row, th); label != row.Diagnosis {
+			changes = append(changes, LabelChange{PID: row.PID, Comm: row.Comm, From: row.Diagnosis, To: label})
+		}
+	}
+	return changes
+}
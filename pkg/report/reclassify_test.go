@@ -0,0 +1,38 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/srodi/hotspot-bpf/pkg/config"
+)
+
+func TestReclassifyReportsOnlyChangedLabels(t *testing.T) {
+	th := config.Default()
+	rows := []ProcMetrics{
+		{PID: 1, Comm: "idle", Diagnosis: "OK"},
+		{PID: 2, Comm: "batch", Preempted: 150, CPUPercent: 1, Diagnosis: "OK"},
+		{PID: 3, Comm: "spin", CPUPercent: 95, Diagnosis: "CPU-bound"},
+	}
+	if got := Reclassify(rows, th); len(got) != 1 || got[0].PID != 2 || got[0].To != "Starved" {
+		t.Fatalf("default thresholds: unexpected changes %+v", got)
+	}
+
+	th.Starved.MinPreempted = 200
+	if got := Reclassify(rows, th); len(got) != 0 {
+		t.Fatalf("raised starved threshold: expected no changes, got %+v", got)
+	}
+	if rows[1].Diagnosis != "OK" {
+		t.Fatal("Reclassify must not modify its input")
+	}
+}
+
+func TestReclassifyRecomputesSwitchLoss(t *testing.T) {
+	th := config.Default()
+	// 2000 switches over 100ms of CPU: 10% lost at 5µs per switch, 40% at 20µs.
+	row := ProcMetrics{PID: 7, Comm: "rpc", CPUNs: 1e8, Switches: 2000, SwitchesPerSec: 2000, SwitchLossPercent: 10, Diagnosis: "OK"}
+	th.SwitchThrashing.CostPerSwitchUs = 20
+	got := Reclassify([]ProcMetrics{row}, th)
+	if len(got) != 1 || got[0].From != "OK" || got[0].To != "Switch-thrashing" {
+		t.Fatalf("expected OK → Switch-thrashing with a higher switch cost, got %+v", got)
+	}
+}
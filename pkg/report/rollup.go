@@ -0,0 +1,56 @@
+package report
+
+// Rollup sums the processes a top-N table leaves out, for the "others" row
+// of -show-others: whether the top rows dominate or the load is spread
+// across a long tail.
+type Rollup struct {
+	Procs int         // processes summed; 0 when the table shows them all
+	Row   ProcMetrics // their summed metrics; identity fields, State, and Diagnosis are empty
+}
+
+// SplitTop cuts rows, already in table order (e.g. TopRows or CPUCostRows
+// with no limit), to topK (0 for no limit) and sums the rest.
+func SplitTop(rows []ProcMetrics, topK int) ([]ProcMetrics, Rollup) {
+	if topK <= 0 || len(rows) <= topK {
+		return rows, Rollup{}
+	}
+	return rows[:topK], RollupRows(rows[topK:])
+}
+
+// RollupRows sums the additive metrics of the CPU Hotspots and Memory
+// Pressure tables across rows. Averages are recomputed from the sums rather
+// than added: major-fault latency from the total time and count, and CPU
+// cost per fault as the summed CPU rate over the summed fault rate.
+func RollupRows(rows []ProcMetrics) Rollup {
+	var sum ProcMetrics
+	var cpuMsPerSec float64 // inverts CPUCostPerFault = cpuMsPerSec / (FaultsPerSec + 1)
+	for _, r := range rows {
+		sum.CPUNs += r.CPUNs
+		sum.CPUMs += r.CPUMs
+		sum.OffCPUMs += r.OffCPUMs
+		sum.CPUPercent += r.CPUPercent
+		sum.CoreCPUPercent += r.CoreCPUPercent
+		sum.CPUPercentAvg += r.CPUPercentAvg
+		sum.RunqWaitMs += r.RunqWaitMs
+		sum.RunqWaitPercent += r.RunqWaitPercent
+		sum.RunqTracked = sum.RunqTracked || r.RunqTracked
+		sum.VolCtxSwitches += r.VolCtxSwitches
+		sum.InvolCtxSwitches += r.InvolCtxSwitches
+		sum.Preempted += r.Preempted
+		sum.RSSMB += r.RSSMB
+		sum.HugepagesMB += r.HugepagesMB
+		sum.RSSSlopeMBPerMin += r.RSSSlopeMBPerMin
+		sum.Faults += r.Faults
+		sum.FaultsPerSec += r.FaultsPerSec
+		sum.MajorFaults += r.MajorFaults
+		sum.MajorFaultNs += r.MajorFaultNs
+		sum.MajorFaultsPerSec += r.MajorFaultsPerSec
+		sum.TLBShootdownsPerSec += r.TLBShootdownsPerSec
+		sum.SwapInsPerSec += r.SwapInsPerSec
+		sum.SwapOutsPerSec += r.SwapOutsPerSec
+		cpuMsPerSec += r.CPUCostPerFault * (r.FaultsPerSec + 1)
+	}
+	sum.MajorFaultLatencyMs = majorFaultLatencyMs(sum.MajorFaultNs, sum.MajorFaults)
+	sum.CPUCostPerFault = cpuMsPerSec / (sum.FaultsPerSec + 1)
+	return Rollup{Procs: len(rows), Row: sum}
+}
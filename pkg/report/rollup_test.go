@@ -0,0 +1,46 @@
+package report
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSplitTop(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 1, CPUNs: 5e9, CPUPercent: 50, Faults: 10},
+		{PID: 2, CPUNs: 2e9, CPUPercent: 20, Faults: 4, RSSMB: 100},
+		{PID: 3, CPUNs: 1e9, CPUPercent: 10, Faults: 6, RSSMB: 50, RunqTracked: true},
+	}
+	top, others := SplitTop(rows, 1)
+	if len(top) != 1 || top[0].PID != 1 {
+		t.Fatalf("top = %+v", top)
+	}
+	if others.Procs != 2 || others.Row.CPUNs != 3e9 || others.Row.CPUPercent != 30 ||
+		others.Row.Faults != 10 || others.Row.RSSMB != 150 || !others.Row.RunqTracked {
+		t.Fatalf("others = %+v", others)
+	}
+	if others.Row.PID != 0 || others.Row.Comm != "" || others.Row.Diagnosis != "" {
+		t.Fatalf("rollup should carry no identity: %+v", others.Row)
+	}
+
+	for _, topK := range []int{0, 3, 10} {
+		if top, others := SplitTop(rows, topK); len(top) != 3 || others.Procs != 0 {
+			t.Fatalf("topK %d: every row fits, got %d rows and %+v", topK, len(top), others)
+		}
+	}
+}
+
+func TestRollupRowsRecomputesAverages(t *testing.T) {
+	// 10 major faults at 2ms and 30 at 6ms average 5ms, not 8ms.
+	// Cost per fault: (1*(9+1) + 4*(19+1)) / (28+1) = 90/29.
+	got := RollupRows([]ProcMetrics{
+		{MajorFaults: 10, MajorFaultNs: 20e6, MajorFaultLatencyMs: 2, FaultsPerSec: 9, CPUCostPerFault: 1},
+		{MajorFaults: 30, MajorFaultNs: 180e6, MajorFaultLatencyMs: 6, FaultsPerSec: 19, CPUCostPerFault: 4},
+	})
+	if got.Procs != 2 || got.Row.MajorFaultLatencyMs != 5 {
+		t.Fatalf("major fault latency = %v, want 5", got.Row.MajorFaultLatencyMs)
+	}
+	if want := 90.0 / 29; math.Abs(got.Row.CPUCostPerFault-want) > 1e-9 {
+		t.Fatalf("cost per fault = %v, want %v", got.Row.CPUCostPerFault, want)
+	}
+}
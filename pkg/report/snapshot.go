@@ -0,0 +1,198 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// Subsystem names used by SnapshotResult in status text and error maps.
+const (
+	SubsystemCPU        = "cpu"
+	SubsystemContention = "contention"
+	SubsystemMemory     = "memory"
+	SubsystemRunqueue   = "runqueue"
+	SubsystemBlockIO    = "blockio"
+	SubsystemSyscalls   = "syscalls"
+	SubsystemFutex      = "futex"
+	SubsystemNet        = "net"
+)
+
+// SnapshotResult holds the raw collector output for one window. Each
+// subsystem succeeds or fails on its own: a failed subsystem has a nil slice
+// and a non-nil error, and the others stay usable, so a window with working
+// CPU accounting but no page-fault data is still rendered.
+type SnapshotResult struct {
+	CPU            []types.CPUStat
+	CPUErr         error
+	Contention     []types.ContentionStat
+	ContentionErr  error
+	PageFaults     []types.PageFaultStat
+	PageFaultsErr  error
+	RunqLatency    []types.RunqLatencyStat
+	RunqLatencyErr error
+	BlockIO        []types.BlockIOStat
+	BlockIOErr     error
+	Syscalls       []types.SyscallStat
+	SyscallsErr    error
+	Futex          []types.FutexStat
+	FutexErr       error
+	Net            []types.NetStat
+	NetErr         error
+	NetTracked     bool // a Net source was given; otherwise net is left out of the status
+}
+
+// SnapshotSources are the collector reads behind one window.
+type SnapshotSources struct {
+	CPU         func() ([]types.CPUStat, error)
+	Contention  func() ([]types.ContentionStat, error)
+	PageFaults  func() ([]types.PageFaultStat, error)
+	RunqLatency func() ([]types.RunqLatencyStat, error)
+	BlockIO     func() ([]types.BlockIOStat, error)
+	Syscalls    func() ([]types.SyscallStat, error)
+	Futex       func() ([]types.FutexStat, error)
+	Net         func() ([]types.NetStat, error) // nil when network accounting is off (-net)
+}
+
+// CollectSnapshot runs every source concurrently, so collection takes as long
+// as the slowest source rather than the sum of all of them. The sources must
+// be safe to call concurrently with each other. A failed source leaves a nil
+// slice and its error in the result; the others are unaffected.
+func CollectSnapshot(src SnapshotSources) SnapshotResult {
+	var r SnapshotResult
+	var wg sync.WaitGroup
+	wg.Add(7)
+	go func() {
+		defer wg.Done()
+		if r.CPU, r.CPUErr = src.CPU(); r.CPUErr != nil {
+			r.CPU = nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if r.Contention, r.ContentionErr = src.Contention(); r.ContentionErr != nil {
+			r.Contention = nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if r.PageFaults, r.PageFaultsErr = src.PageFaults(); r.PageFaultsErr != nil {
+			r.PageFaults = nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if r.RunqLatency, r.RunqLatencyErr = src.RunqLatency(); r.RunqLatencyErr != nil {
+			r.RunqLatency = nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if r.BlockIO, r.BlockIOErr = src.BlockIO(); r.BlockIOErr != nil {
+			r.BlockIO = nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if r.Syscalls, r.SyscallsErr = src.Syscalls(); r.SyscallsErr != nil {
+			r.Syscalls = nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if r.Futex, r.FutexErr = src.Futex(); r.FutexErr != nil {
+			r.Futex = nil
+		}
+	}()
+	if src.Net != nil {
+		r.NetTracked = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r.Net, r.NetErr = src.Net(); r.NetErr != nil {
+				r.Net = nil
+			}
+		}()
+	}
+	wg.Wait()
+	return r
+}
+
+// subsystems lists each subsystem with its error, in display order. Net is
+// only listed when it was tracked.
+func (r SnapshotResult) subsystems() []struct {
+	name string
+	err  error
+} {
+	subsystems := []struct {
+		name string
+		err  error
+	}{
+		{SubsystemCPU, r.CPUErr},
+		{SubsystemContention, r.ContentionErr},
+		{SubsystemMemory, r.PageFaultsErr},
+		{SubsystemRunqueue, r.RunqLatencyErr},
+		{SubsystemBlockIO, r.BlockIOErr},
+		{SubsystemSyscalls, r.SyscallsErr},
+		{SubsystemFutex, r.FutexErr},
+	}
+	if r.NetTracked {
+		subsystems = append(subsystems, struct {
+			name string
+			err  error
+		}{SubsystemNet, r.NetErr})
+	}
+	return subsystems
+}
+
+// OK reports whether every subsystem succeeded.
+func (r SnapshotResult) OK() bool {
+	return r.CPUErr == nil && r.ContentionErr == nil && r.PageFaultsErr == nil && r.RunqLatencyErr == nil && r.BlockIOErr == nil && r.SyscallsErr == nil && r.FutexErr == nil && r.NetErr == nil
+}
+
+// Err returns nil if at least one subsystem produced data, otherwise an
+// error joining every subsystem's failure: the window has nothing to show.
+func (r SnapshotResult) Err() error {
+	var errs []error
+	for _, s := range r.subsystems() {
+		if s.err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.name, s.err))
+	}
+	return errors.Join(errs...)
+}
+
+// Errors maps each failed subsystem to its error message, for structured
+// output. It returns nil when every subsystem succeeded.
+func (r SnapshotResult) Errors() map[string]string {
+	var errs map[string]string
+	for _, s := range r.subsystems() {
+		if s.err == nil {
+			continue
+		}
+		if errs == nil {
+			errs = make(map[string]string)
+		}
+		errs[s.name] = s.err.Error()
+	}
+	return errs
+}
+
+// Status summarizes every subsystem on one line, e.g.
+// "cpu ok, contention ok, memory unavailable: kprobe not attached, runqueue ok, blockio ok,
+// syscalls ok, futex ok", followed by net when it is tracked.
+func (r SnapshotResult) Status() string {
+	parts := make([]string, 0, 8)
+	for _, s := range r.subsystems() {
+		if s.err == nil {
+			parts = append(parts, s.name+" ok")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s unavailable: %v", s.name, s.err))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestSnapshotResultPartialFailure(t *testing.T) {
+	r := SnapshotResult{
+		CPU:           []types.CPUStat{{PID: 1}},
+		ContentionErr: errors.New("perf mode records no switches"),
+		PageFaultsErr: errors.New("kprobe not attached"),
+	}
+	if r.OK() {
+		t.Fatal("expected OK to be false with failed subsystems")
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("CPU data is usable, Err should be nil: %v", err)
+	}
+	want := "cpu ok, contention unavailable: perf mode records no switches, memory unavailable: kprobe not attached, runqueue ok, blockio ok, syscalls ok, futex ok"
+	if got := r.Status(); got != want {
+		t.Fatalf("Status() = %q, want %q", got, want)
+	}
+	errs := r.Errors()
+	if len(errs) != 2 || errs[SubsystemMemory] != "kprobe not attached" {
+		t.Fatalf("unexpected Errors(): %v", errs)
+	}
+
+	// Net only shows up once it is tracked.
+	r.NetTracked, r.NetErr = true, errors.New("tcp_sendmsg not found")
+	if got := r.Status(); got != want+", net unavailable: tcp_sendmsg not found" {
+		t.Fatalf("Status() with net = %q", got)
+	}
+}
+
+func TestSnapshotResultAllFailedOrOK(t *testing.T) {
+	boom := errors.New("boom")
+	failed := SnapshotResult{CPUErr: boom, ContentionErr: boom, PageFaultsErr: boom, RunqLatencyErr: boom, BlockIOErr: boom, SyscallsErr: boom, FutexErr: boom}
+	if err := failed.Err(); !errors.Is(err, boom) {
+		t.Fatalf("expected joined error when nothing succeeded, got %v", err)
+	}
+
+	var ok SnapshotResult
+	if !ok.OK() || ok.Err() != nil || ok.Errors() != nil {
+		t.Fatalf("an all-successful result should report no errors: %+v", ok)
+	}
+}
+
+func TestCollectSnapshotRunsSourcesConcurrently(t *testing.T) {
+	// Each source blocks until all of them have started. Run serially, the
+	// first source would wait forever; the timeout turns that into a failure.
+	var started sync.WaitGroup
+	started.Add(8)
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+	rendezvous := func() error {
+		started.Done()
+		select {
+		case <-allStarted:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("sources did not run concurrently")
+		}
+	}
+
+	r := CollectSnapshot(SnapshotSources{
+		CPU: func() ([]types.CPUStat, error) {
+			return []types.CPUStat{{PID: 1}}, rendezvous()
+		},
+		Contention: func() ([]types.ContentionStat, error) {
+			return []types.ContentionStat{{VictimPID: 1}}, rendezvous()
+		},
+		PageFaults: func() ([]types.PageFaultStat, error) {
+			if err := rendezvous(); err != nil {
+				return nil, err
+			}
+			return []types.PageFaultStat{{PID: 1}}, errors.New("kprobe not attached")
+		},
+		RunqLatency: func() ([]types.RunqLatencyStat, error) {
+			return []types.RunqLatencyStat{{PID: 1}}, rendezvous()
+		},
+		BlockIO: func() ([]types.BlockIOStat, error) {
+			return []types.BlockIOStat{{PID: 1}}, rendezvous()
+		},
+		Syscalls: func() ([]types.SyscallStat, error) {
+			return []types.SyscallStat{{PID: 1}}, rendezvous()
+		},
+		Futex: func() ([]types.FutexStat, error) {
+			return []types.FutexStat{{PID: 1}}, rendezvous()
+		},
+		Net: func() ([]types.NetStat, error) {
+			return []types.NetStat{{PID: 1}}, rendezvous()
+		},
+	})
+	if r.CPUErr != nil || r.ContentionErr != nil || r.RunqLatencyErr != nil || r.BlockIOErr != nil || r.SyscallsErr != nil || r.FutexErr != nil || r.NetErr != nil {
+		t.Fatalf("unexpected errors: %s", r.Status())
+	}
+	if len(r.CPU) != 1 || len(r.Contention) != 1 || len(r.RunqLatency) != 1 || len(r.BlockIO) != 1 || len(r.Syscalls) != 1 || len(r.Futex) != 1 || len(r.Net) != 1 || !r.NetTracked {
+		t.Fatalf("results not merged: %+v", r)
+	}
+	// Per-source error handling is preserved: a failure drops only its data.
+	if r.PageFaultsErr == nil || r.PageFaults != nil {
+		t.Fatalf("expected memory failure with nil data, got %+v / %v", r.PageFaults, r.PageFaultsErr)
+	}
+}
+
+func BenchmarkCollectSnapshot(b *testing.B) {
+	// Seven 1ms sources: concurrent collection takes ~1ms per op, serial ~7ms.
+	slow := func() { time.Sleep(time.Millisecond) }
+	src := SnapshotSources{
+		CPU:         func() ([]types.CPUStat, error) { slow(); return nil, nil },
+		Contention:  func() ([]types.ContentionStat, error) { slow(); return nil, nil },
+		PageFaults:  func() ([]types.PageFaultStat, error) { slow(); return nil, nil },
+		RunqLatency: func() ([]types.RunqLatencyStat, error) { slow(); return nil, nil },
+		BlockIO:     func() ([]types.BlockIOStat, error) { slow(); return nil, nil },
+		Syscalls:    func() ([]types.SyscallStat, error) { slow(); return nil, nil },
+		Futex:       func() ([]types.FutexStat, error) { slow(); return nil, nil },
+	}
+	for b.Loop() {
+		CollectSnapshot(src)
+	}
+}
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortKey selects the metric the main process table is ordered by.
+type SortKey string
+
+const (
+	SortCPU       SortKey = "cpu"       // on-CPU time in the window (default)
+	SortFaults    SortKey = "faults"    // page faults in the window
+	SortRSS       SortKey = "rss"       // resident memory including hugepages
+	SortCost      SortKey = "cost"      // CPU time per page fault
+	SortPreempted SortKey = "preempted" // times the process was preempted
+)
+
+// ParseSortKey validates a -sort flag value.
+func ParseSortKey(s string) (SortKey, error) {
+	switch key := SortKey(strings.ToLower(strings.TrimSpace(s))); key {
+	case SortCPU, SortFaults, SortRSS, SortCost, SortPreempted:
+		return key, nil
+	default:
+		return "", fmt.Errorf("unknown sort key %q (want %s, %s, %s, %s, or %s)",
+			s, SortCPU, SortFaults, SortRSS, SortCost, SortPreempted)
+	}
+}
+
+// Label describes the key for table headers, e.g. "Top 10 processes by RSS".
+func (k SortKey) Label() string {
+	switch k {
+	case SortFaults:
+		return "page faults"
+	case SortRSS:
+		return "RSS"
+	case SortCost:
+		return "CPU cost per fault"
+	case SortPreempted:
+		return "preemptions"
+	default:
+		return "CPU time"
+	}
+}
+
+// compare orders a before b (negative) when a has the higher value for k.
+func (k SortKey) compare(a, b ProcMetrics) int {
+	switch k {
+	case SortFaults:
+		return cmp.Compare(b.Faults, a.Faults)
+	case SortRSS:
+		return compareDesc(a.FootprintMB(), b.FootprintMB())
+	case SortCost:
+		return compareDesc(a.CPUCostPerFault, b.CPUCostPerFault)
+	case SortPreempted:
+		return cmp.Compare(b.Preempted, a.Preempted)
+	default:
+		return cmp.Compare(b.CPUNs, a.CPUNs)
+	}
+}
+
+// zero reports whether row has nothing to show under k, which keeps idle
+// processes out of a table ordered by that key.
+func (k SortKey) zero(row ProcMetrics) bool {
+	switch k {
+	case SortFaults:
+		return row.Faults == 0
+	case SortRSS:
+		return row.FootprintMB() == 0
+	case SortCost:
+		return row.CPUCostPerFault == 0
+	case SortPreempted:
+		return row.Preempted == 0
+	default:
+		return row.CPUNs == 0
+	}
+}
+
+// SortRows orders rows in place by key, highest first. Ties break by lower
+// PID so the order is stable from one interval to the next.
+func SortRows(rows []ProcMetrics, key SortKey) {
+	sort.Slice(rows, func(i, j int) bool {
+		if c := key.compare(rows[i], rows[j]); c != 0 {
+			return c < 0
+		}
+		return rows[i].PID < rows[j].PID
+	})
+}
+
+// TopRows returns the rows with a non-zero value for key, ordered by
+// SortRows and limited to topK (0 for no limit). rows is not modified.
+func TopRows(rows []ProcMetrics, key SortKey, topK int) []ProcMetrics {
+	candidates := make([]ProcMetrics, 0, len(rows))
+	for _, row := range rows {
+		if key.zero(row) {
+			continue
+		}
+		candidates = append(candidates, row)
+	}
+	SortRows(candidates, key)
+	if topK > 0 && len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	return candidates
+}
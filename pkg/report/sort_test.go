@@ -0,0 +1,50 @@
+package report
+
+import "testing"
+
+func TestParseSortKey(t *testing.T) {
+	for _, s := range []string{"cpu", "faults", "RSS", " cost ", "preempted"} {
+		if _, err := ParseSortKey(s); err != nil {
+			t.Errorf("ParseSortKey(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseSortKey("memory"); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestTopRowsByKey(t *testing.T) {
+	rows := []ProcMetrics{
+		{PID: 4, CPUNs: 50, RSSMB: 100, Faults: 7, Preempted: 2},
+		{PID: 3, CPUNs: 90, RSSMB: 0, Faults: 7, CPUCostPerFault: 1.5},
+		{PID: 1, CPUNs: 10, RSSMB: 400, HugepagesMB: 200, Faults: 0, Preempted: 2},
+		{PID: 2, CPUNs: 0, RSSMB: 500, Faults: 1, CPUCostPerFault: 0.5},
+	}
+	cases := []struct {
+		key  SortKey
+		want []uint32
+	}{
+		{SortCPU, []uint32{3, 4, 1}},
+		{SortRSS, []uint32{1, 2, 4}},
+		{SortFaults, []uint32{3, 4, 2}}, // equal faults break by PID
+		{SortCost, []uint32{3, 2}},
+		{SortPreempted, []uint32{1, 4}},
+	}
+	for _, tc := range cases {
+		got := TopRows(rows, tc.key, 0)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d rows, want %v", tc.key, len(got), tc.want)
+		}
+		for i, pid := range tc.want {
+			if got[i].PID != pid {
+				t.Fatalf("%s: got PID %d at %d, want order %v", tc.key, got[i].PID, i, tc.want)
+			}
+		}
+	}
+	if got := TopRows(rows, SortRSS, 1); len(got) != 1 || got[0].PID != 1 {
+		t.Fatalf("topK not applied: %+v", got)
+	}
+	if rows[0].PID != 4 {
+		t.Fatal("TopRows must not reorder its input")
+	}
+}
@@ -0,0 +1,105 @@
+package report
+
+import "container/list"
+
+// ProcKey identifies one process instance across ticks. PIDs are recycled on
+// busy hosts, so the start time (clock ticks since boot, from /proc/PID/stat)
+// keeps a new process from inheriting the history of an exited one. A zero
+// StartTime means the start time could not be read; the key then degrades to
+// PID-only identity.
+type ProcKey struct {
+	PID       uint32
+	StartTime uint64
+}
+
+// stateTable holds per-process state that must survive across ticks. It is
+// bounded two ways so long-running sessions do not leak memory as PIDs churn:
+//   - TTL:  entries not touched for idleTicks consecutive ticks are evicted by advance
+//   - LRU:  when more than maxEntries are tracked, the least recently touched entry is evicted
+//
+// stateTable is not safe for concurrent use.
+type stateTable[V any] struct {
+	entries    map[ProcKey]*list.Element
+	lru        *list.List // front = most recently touched
+	maxEntries int
+	idleTicks  uint64
+	tick       uint64
+}
+
+type stateEntry[V any] struct {
+	key      ProcKey
+	value    V
+	lastSeen uint64
+}
+
+func newStateTable[V any](maxEntries, idleTicks int) *stateTable[V] {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	if idleTicks < 1 {
+		idleTicks = 1
+	}
+	return &stateTable[V]{
+		entries:    make(map[ProcKey]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		idleTicks:  uint64(idleTicks),
+	}
+}
+
+// touch returns a pointer to the state for key, creating a zero value if
+// needed, and marks it as seen in the current tick.
+func (s *stateTable[V]) touch(key ProcKey) *V {
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*stateEntry[V])
+		e.lastSeen = s.tick
+		s.lru.MoveToFront(el)
+		return &e.value
+	}
+	e := &stateEntry[V]{key: key, lastSeen: s.tick}
+	s.entries[key] = s.lru.PushFront(e)
+	for len(s.entries) > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+	return &e.value
+}
+
+// get returns the state for key without refreshing its recency.
+func (s *stateTable[V]) get(key ProcKey) (*V, bool) {
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return &el.Value.(*stateEntry[V]).value, true
+}
+
+// advance ends the current tick and evicts entries that have gone unseen for
+// idleTicks complete ticks. Because the LRU list is ordered by recency, eviction
+// stops at the first entry that is still fresh.
+func (s *stateTable[V]) advance() {
+	s.tick++
+	for el := s.lru.Back(); el != nil; el = s.lru.Back() {
+		if s.tick-el.Value.(*stateEntry[V]).lastSeen <= s.idleTicks {
+			break
+		}
+		s.remove(el)
+	}
+}
+
+func (s *stateTable[V]) remove(el *list.Element) {
+	delete(s.entries, el.Value.(*stateEntry[V]).key)
+	s.lru.Remove(el)
+}
+
+// keys returns every tracked key, most recently touched first.
+func (s *stateTable[V]) keys() []ProcKey {
+	keys := make([]ProcKey, 0, len(s.entries))
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*stateEntry[V]).key)
+	}
+	return keys
+}
+
+func (s *stateTable[V]) len() int {
+	return len(s.entries)
+}
@@ -0,0 +1,365 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// AggregateMode is how SubWindows combines the sub-windows of one display
+// interval (-sample-aggregate).
+type AggregateMode string
+
+const (
+	AggregateSum AggregateMode = "sum" // totals over the whole interval
+	AggregateAvg AggregateMode = "avg" // the mean sub-window (default)
+	AggregateMax AggregateMode = "max" // each metric's highest sub-window
+)
+
+// ParseAggregateMode validates a -sample-aggregate flag value.
+func ParseAggregateMode(s string) (AggregateMode, error) {
+	switch mode := AggregateMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case AggregateSum, AggregateAvg, AggregateMax:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown aggregation %q (want %s, %s, or %s)", s, AggregateSum, AggregateAvg, AggregateMax)
+	}
+}
+
+// SubWindows combines the readings of several short sampling windows
+// (-sample-window) into one window per display interval, so the screen can
+// refresh slowly while each measurement stays short. The result reads like a
+// single window of reset maps, so BuildProcMetrics and everything after it
+// is unchanged.
+//
+// With AggregateSum the counters are totals over every sub-window and the
+// window is their combined length. With AggregateAvg they are divided by the
+// number of sub-windows and the window is the mean sub-window length, so
+// rates match AggregateSum while counts describe a typical sub-window. With
+// AggregateMax each counter and rate is the highest any one sub-window saw,
+// taken field by field, over the mean sub-window length: a one-sub-window
+// burst shows at full height instead of being diluted.
+//
+// Values that are not counts are combined by meaning rather than by mode:
+// comm, cgroup, last core, RSS, and threads still waiting come from the
+// latest sub-window, the longest run-queue wait and highest RSS are maxima,
+// and the lowest RSS is a minimum. Block I/O latency is averaged over the
+// requests, except under AggregateMax. A sub-window whose read of a
+// subsystem failed counts as empty for it; the subsystem's error is kept
+// only when every sub-window failed. Readings must not be cut to a top-N,
+// or a process's sub-windows would be missing at random.
+//
+// Whatever the mode, each CPU stat also carries the CPU time per second of
+// its busiest sub-window and of all of them together (PeakNsPerSec and
+// MeanNsPerSec), so a process that spikes and idles can be told from one
+// that runs steadily at the same total. The mean counts sub-windows the
+// process did not run in as idle.
+//
+// SubWindows is not safe for concurrent use.
+type SubWindows struct {
+	mode    AggregateMode
+	n       int           // sub-windows added since the last Result
+	elapsed time.Duration // their combined length
+
+	cpu        merged[uint32, types.CPUStat]
+	contention merged[contentionPair, types.ContentionStat]
+	pageFaults merged[uint32, types.PageFaultStat]
+	runq       merged[uint32, types.RunqLatencyStat]
+	blockIO    merged[uint32, types.BlockIOStat]
+	syscalls   merged[uint32, types.SyscallStat]
+	futex      merged[uint32, types.FutexStat]
+	net        merged[uint32, types.NetStat]
+	netTracked bool
+
+	cpuSpread  map[uint32]cpuSpread
+	cpuElapsed time.Duration // length of the sub-windows whose CPU read succeeded
+}
+
+// cpuSpread is one process's CPU time across sub-windows.
+type cpuSpread struct {
+	peakNsPerSec float64 // the busiest sub-window's
+	ns           uint64  // total
+}
+
+// NewSubWindows returns an empty SubWindows combining by mode.
+func NewSubWindows(mode AggregateMode) *SubWindows {
+	return &SubWindows{mode: mode}
+}
+
+// Len returns the number of sub-windows added since the last Result.
+func (s *SubWindows) Len() int {
+	return s.n
+}
+
+// Add records one sub-window's readings, taken over window.
+func (s *SubWindows) Add(r SnapshotResult, window time.Duration) {
+	s.n++
+	s.elapsed += window
+	if r.CPUErr == nil {
+		s.addCPUSpread(r.CPU, window)
+	}
+	s.cpu.add(r.CPU, r.CPUErr, func(v types.CPUStat) uint32 { return v.PID }, s.mergeCPU)
+	s.contention.add(r.Contention, r.ContentionErr, func(v types.ContentionStat) contentionPair {
+		return contentionPair{v.VictimPID, v.AggressorPID}
+	}, s.mergeContention)
+	s.pageFaults.add(r.PageFaults, r.PageFaultsErr, func(v types.PageFaultStat) uint32 { return v.PID }, s.mergePageFaults)
+	s.runq.add(r.RunqLatency, r.RunqLatencyErr, func(v types.RunqLatencyStat) uint32 { return v.PID }, s.mergeRunq)
+	s.blockIO.add(r.BlockIO, r.BlockIOErr, func(v types.BlockIOStat) uint32 { return v.PID }, s.mergeBlockIO)
+	s.syscalls.add(r.Syscalls, r.SyscallsErr, func(v types.SyscallStat) uint32 { return v.PID }, s.mergeSyscalls)
+	s.futex.add(r.Futex, r.FutexErr, func(v types.FutexStat) uint32 { return v.PID }, s.mergeFutex)
+	if r.NetTracked {
+		s.netTracked = true
+		s.net.add(r.Net, r.NetErr, func(v types.NetStat) uint32 { return v.PID }, s.mergeNet)
+	}
+}
+
+// Result returns the combined readings with the window to compute rates
+// over, and starts over for the next display interval.
+func (s *SubWindows) Result() (SnapshotResult, time.Duration) {
+	window := s.elapsed
+	if s.mode != AggregateSum && s.n > 0 {
+		window /= time.Duration(s.n)
+	}
+	seconds := window.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	var r SnapshotResult
+	r.CPU, r.CPUErr = s.cpu.result(func(v types.CPUStat, n uint64) types.CPUStat {
+		spread := s.cpuSpread[v.PID]
+		v.PeakNsPerSec = spread.peakNsPerSec
+		if s.cpuElapsed > 0 {
+			v.MeanNsPerSec = float64(spread.ns) / s.cpuElapsed.Seconds()
+		}
+		if s.mode == AggregateAvg {
+			v.Ns /= n
+			v.Switches /= n
+			v.OffCPUNs /= n
+			v.VolSwitches /= n
+			v.InvolSwitches /= n
+		}
+		return v
+	})
+	r.Contention, r.ContentionErr = s.contention.result(func(v types.ContentionStat, n uint64) types.ContentionStat {
+		if s.mode == AggregateAvg {
+			v.Count /= n
+		}
+		return v
+	})
+	r.PageFaults, r.PageFaultsErr = s.pageFaults.result(func(v types.PageFaultStat, n uint64) types.PageFaultStat {
+		if s.mode == AggregateMax {
+			return v
+		}
+		if s.mode == AggregateAvg {
+			v.Faults /= n
+			v.MajorFaults /= n
+			v.MajorFaultNs /= n
+			v.TLBShootdowns /= n
+			v.SwapIns /= n
+			v.SwapOuts /= n
+			v.FaultPages /= float64(n)
+		}
+		v.FaultsPerSec = float64(v.Faults) / seconds
+		v.TLBShootdownsPerSec = float64(v.TLBShootdowns) / seconds
+		v.SwapInsPerSec = float64(v.SwapIns) / seconds
+		v.SwapOutsPerSec = float64(v.SwapOuts) / seconds
+		return v
+	})
+	r.RunqLatency, r.RunqLatencyErr = s.runq.result(func(v types.RunqLatencyStat, n uint64) types.RunqLatencyStat {
+		if s.mode == AggregateAvg {
+			v.Waits /= n
+			v.TotalNs /= n
+		}
+		return v
+	})
+	r.BlockIO, r.BlockIOErr = s.blockIO.result(func(v types.BlockIOStat, n uint64) types.BlockIOStat {
+		if s.mode == AggregateAvg {
+			v.ReadBytes /= n
+			v.WriteBytes /= n
+			v.Requests /= n
+		}
+		return v
+	})
+	r.Syscalls, r.SyscallsErr = s.syscalls.result(func(v types.SyscallStat, n uint64) types.SyscallStat {
+		if s.mode == AggregateAvg {
+			v.TotalNs /= n
+			v.Count /= n
+			v.TopSyscallNs /= n
+		}
+		return v
+	})
+	r.Futex, r.FutexErr = s.futex.result(func(v types.FutexStat, n uint64) types.FutexStat {
+		if s.mode == AggregateAvg {
+			v.WaitNs /= n
+			v.Contended /= n
+		}
+		return v
+	})
+	if s.netTracked {
+		r.NetTracked = true
+		r.Net, r.NetErr = s.net.result(func(v types.NetStat, n uint64) types.NetStat {
+			if s.mode == AggregateAvg {
+				v.TxBytes /= n
+				v.RxBytes /= n
+			}
+			return v
+		})
+	}
+	*s = SubWindows{mode: s.mode}
+	return r, window
+}
+
+// addCPUSpread records each process's CPU time in one sub-window of length
+// window.
+func (s *SubWindows) addCPUSpread(stats []types.CPUStat, window time.Duration) {
+	if s.cpuSpread == nil {
+		s.cpuSpread = make(map[uint32]cpuSpread, len(stats))
+	}
+	s.cpuElapsed += window
+	seconds := window.Seconds()
+	for _, v := range stats {
+		spread := s.cpuSpread[v.PID]
+		spread.ns += v.Ns
+		if seconds > 0 {
+			spread.peakNsPerSec = max(spread.peakNsPerSec, float64(v.Ns)/seconds)
+		}
+		s.cpuSpread[v.PID] = spread
+	}
+}
+
+// merged holds one subsystem's readings combined across sub-windows, in the
+// order their keys were first seen.
+type merged[K comparable, V any] struct {
+	order []K
+	vals  map[K]V
+	reads uint64 // sub-windows read successfully
+	err   error  // the latest failed read
+}
+
+// add folds one sub-window's readings in with merge, which is given the
+// combined value so far and the new reading.
+func (m *merged[K, V]) add(readings []V, err error, key func(V) K, merge func(acc, v V) V) {
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.reads++
+	if m.vals == nil {
+		m.vals = make(map[K]V, len(readings))
+	}
+	for _, v := range readings {
+		k := key(v)
+		acc, ok := m.vals[k]
+		if !ok {
+			m.order = append(m.order, k)
+			m.vals[k] = v
+			continue
+		}
+		m.vals[k] = merge(acc, v)
+	}
+}
+
+// result passes each combined value through finish with the number of
+// sub-windows read. The error is only returned when none was.
+func (m *merged[K, V]) result(finish func(v V, n uint64) V) ([]V, error) {
+	if m.reads == 0 {
+		return nil, m.err
+	}
+	out := make([]V, 0, len(m.order))
+	for _, k := range m.order {
+		out = append(out, finish(m.vals[k], m.reads))
+	}
+	return out, nil
+}
+
+// combine adds two counters, or keeps the larger under AggregateMax.
+func combine[T uint64 | float64](mode AggregateMode, acc, v T) T {
+	if mode == AggregateMax {
+		return max(acc, v)
+	}
+	return acc + v
+}
+
+// The merge functions return the new reading with its counters combined
+// with acc, so identity fields and point-in-time values are the latest.
+
+func (s *SubWindows) mergeCPU(acc, v types.CPUStat) types.CPUStat {
+	v.Ns = combine(s.mode, acc.Ns, v.Ns)
+	v.Switches = combine(s.mode, acc.Switches, v.Switches)
+	v.OffCPUNs = combine(s.mode, acc.OffCPUNs, v.OffCPUNs)
+	v.VolSwitches = combine(s.mode, acc.VolSwitches, v.VolSwitches)
+	v.InvolSwitches = combine(s.mode, acc.InvolSwitches, v.InvolSwitches)
+	return v
+}
+
+func (s *SubWindows) mergeContention(acc, v types.ContentionStat) types.ContentionStat {
+	v.Count = combine(s.mode, acc.Count, v.Count)
+	return v
+}
+
+func (s *SubWindows) mergePageFaults(acc, v types.PageFaultStat) types.PageFaultStat {
+	v.Faults = combine(s.mode, acc.Faults, v.Faults)
+	v.MajorFaults = combine(s.mode, acc.MajorFaults, v.MajorFaults)
+	v.MajorFaultNs = combine(s.mode, acc.MajorFaultNs, v.MajorFaultNs)
+	v.TLBShootdowns = combine(s.mode, acc.TLBShootdowns, v.TLBShootdowns)
+	v.SwapIns = combine(s.mode, acc.SwapIns, v.SwapIns)
+	v.SwapOuts = combine(s.mode, acc.SwapOuts, v.SwapOuts)
+	v.FaultPages = combine(s.mode, acc.FaultPages, v.FaultPages)
+	// Only AggregateMax keeps these; the others recompute them in Result.
+	v.FaultsPerSec = max(acc.FaultsPerSec, v.FaultsPerSec)
+	v.TLBShootdownsPerSec = max(acc.TLBShootdownsPerSec, v.TLBShootdownsPerSec)
+	v.SwapInsPerSec = max(acc.SwapInsPerSec, v.SwapInsPerSec)
+	v.SwapOutsPerSec = max(acc.SwapOutsPerSec, v.SwapOutsPerSec)
+	if acc.RSSMinBytes > 0 && (v.RSSMinBytes == 0 || acc.RSSMinBytes < v.RSSMinBytes) {
+		v.RSSMinBytes = acc.RSSMinBytes
+	}
+	v.RSSMaxBytes = max(acc.RSSMaxBytes, v.RSSMaxBytes)
+	return v
+}
+
+func (s *SubWindows) mergeRunq(acc, v types.RunqLatencyStat) types.RunqLatencyStat {
+	v.Waits = combine(s.mode, acc.Waits, v.Waits)
+	v.TotalNs = combine(s.mode, acc.TotalNs, v.TotalNs)
+	v.MaxNs = max(acc.MaxNs, v.MaxNs)
+	return v
+}
+
+func (s *SubWindows) mergeBlockIO(acc, v types.BlockIOStat) types.BlockIOStat {
+	if s.mode == AggregateMax {
+		v.AvgLatencyNs = max(acc.AvgLatencyNs, v.AvgLatencyNs)
+	} else if requests := acc.Requests + v.Requests; requests > 0 {
+		v.AvgLatencyNs = (acc.AvgLatencyNs*acc.Requests + v.AvgLatencyNs*v.Requests) / requests
+	}
+	v.ReadBytes = combine(s.mode, acc.ReadBytes, v.ReadBytes)
+	v.WriteBytes = combine(s.mode, acc.WriteBytes, v.WriteBytes)
+	v.Requests = combine(s.mode, acc.Requests, v.Requests)
+	return v
+}
+
+func (s *SubWindows) mergeSyscalls(acc, v types.SyscallStat) types.SyscallStat {
+	// The top call of each sub-window may differ: the same call's times
+	// are combined, otherwise the one with more time is kept.
+	switch {
+	case acc.TopSyscall == v.TopSyscall:
+		v.TopSyscallNs = combine(s.mode, acc.TopSyscallNs, v.TopSyscallNs)
+	case acc.TopSyscallNs > v.TopSyscallNs:
+		v.TopSyscall, v.TopSyscallNs = acc.TopSyscall, acc.TopSyscallNs
+	}
+	v.TotalNs = combine(s.mode, acc.TotalNs, v.TotalNs)
+	v.Count = combine(s.mode, acc.Count, v.Count)
+	return v
+}
+
+func (s *SubWindows) mergeFutex(acc, v types.FutexStat) types.FutexStat {
+	v.WaitNs = combine(s.mode, acc.WaitNs, v.WaitNs)
+	v.Contended = combine(s.mode, acc.Contended, v.Contended)
+	return v
+}
+
+func (s *SubWindows) mergeNet(acc, v types.NetStat) types.NetStat {
+	v.TxBytes = combine(s.mode, acc.TxBytes, v.TxBytes)
+	v.RxBytes = combine(s.mode, acc.RxBytes, v.RxBytes)
+	return v
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// addBurst adds two one-second sub-windows: PID 1 runs throughout, PID 2 spikes
+// in the second one only.
+func addBurst(s *SubWindows) {
+	s.Add(SnapshotResult{
+		CPU:        []types.CPUStat{{PID: 1, Comm: "app", Ns: 2e8, CPUCore: 0}},
+		PageFaults: []types.PageFaultStat{{PID: 1, Faults: 10, FaultsPerSec: 10, RSSBytes: 1 << 20, RSSMinBytes: 1 << 19, RSSMaxBytes: 1 << 20}},
+	}, time.Second)
+	s.Add(SnapshotResult{
+		CPU:        []types.CPUStat{{PID: 1, Comm: "app", Ns: 4e8, CPUCore: 3}, {PID: 2, Comm: "cron", Ns: 9e8}},
+		PageFaults: []types.PageFaultStat{{PID: 1, Faults: 30, FaultsPerSec: 30, RSSBytes: 2 << 20, RSSMinBytes: 1 << 20, RSSMaxBytes: 2 << 20}},
+	}, time.Second)
+}
+
+func TestSubWindowsModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode         AggregateMode
+		window       time.Duration
+		app, cron    uint64
+		faults       uint64
+		faultsPerSec float64
+	}{
+		{AggregateSum, 2 * time.Second, 6e8, 9e8, 40, 20},
+		{AggregateAvg, time.Second, 3e8, 4.5e8, 20, 20},
+		{AggregateMax, time.Second, 4e8, 9e8, 30, 30},
+	} {
+		s := NewSubWindows(tc.mode)
+		addBurst(s)
+		r, window := s.Result()
+		if window != tc.window {
+			t.Fatalf("%s: window = %v, want %v", tc.mode, window, tc.window)
+		}
+		if len(r.CPU) != 2 || r.CPU[0].Ns != tc.app || r.CPU[1].Ns != tc.cron {
+			t.Fatalf("%s: cpu = %+v", tc.mode, r.CPU)
+		}
+		pf := r.PageFaults[0]
+		if pf.Faults != tc.faults || pf.FaultsPerSec != tc.faultsPerSec {
+			t.Fatalf("%s: faults = %d (%g/s), want %d (%g/s)", tc.mode, pf.Faults, pf.FaultsPerSec, tc.faults, tc.faultsPerSec)
+		}
+		// Point-in-time values are the latest whatever the mode.
+		if r.CPU[0].CPUCore != 3 || pf.RSSBytes != 2<<20 || pf.RSSMinBytes != 1<<19 || pf.RSSMaxBytes != 2<<20 {
+			t.Fatalf("%s: point-in-time values: %+v %+v", tc.mode, r.CPU[0], pf)
+		}
+		if s.Len() != 0 {
+			t.Fatalf("%s: Result should start over, %d sub-windows left", tc.mode, s.Len())
+		}
+	}
+}
+
+func TestSubWindowsCPUSpread(t *testing.T) {
+	cpus := float64(runtime.NumCPU())
+	for _, mode := range []AggregateMode{AggregateSum, AggregateAvg, AggregateMax} {
+		s := NewSubWindows(mode)
+		addBurst(s)
+		r, window := s.Result()
+		app, cron := r.CPU[0], r.CPU[1]
+		if app.PeakNsPerSec != 4e8 || app.MeanNsPerSec != 3e8 {
+			t.Fatalf("%s: app peak %g, mean %g ns/s; want 4e8, 3e8", mode, app.PeakNsPerSec, app.MeanNsPerSec)
+		}
+		// cron was idle in the first sub-window, which the mean counts.
+		if cron.PeakNsPerSec != 9e8 || cron.MeanNsPerSec != 4.5e8 {
+			t.Fatalf("%s: cron peak %g, mean %g ns/s; want 9e8, 4.5e8", mode, cron.PeakNsPerSec, cron.MeanNsPerSec)
+		}
+
+		rows, _ := BuildProcMetrics(SnapshotResult{CPU: r.CPU}, BuildOptions{Interval: window, Thresholds: defaultTh})
+		for _, row := range rows {
+			if row.PID != 2 {
+				continue
+			}
+			if math.Abs(row.CPUPercentMax-90/cpus) > 1e-9 || math.Abs(row.CPUPercentAvg-45/cpus) > 1e-9 {
+				t.Fatalf("%s: cron max %g%%, avg %g%%; want %g, %g", mode, row.CPUPercentMax, row.CPUPercentAvg, 90/cpus, 45/cpus)
+			}
+		}
+	}
+
+	// A single reading has nothing to spread over.
+	rows, _ := BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 1, Comm: "app", Ns: 5e8}}}, BuildOptions{Interval: time.Second, Thresholds: defaultTh})
+	if r := rows[0]; r.CPUPercentMax != r.CPUPercent || r.CPUPercentAvg != r.CPUPercent {
+		t.Fatalf("single reading: max %g, avg %g; want CPU%% %g", r.CPUPercentMax, r.CPUPercentAvg, r.CPUPercent)
+	}
+}
+
+func TestSubWindowsBlockIOAndSyscalls(t *testing.T) {
+	s := NewSubWindows(AggregateSum)
+	s.Add(SnapshotResult{
+		BlockIO:  []types.BlockIOStat{{PID: 3, Requests: 10, AvgLatencyNs: 1000}},
+		Syscalls: []types.SyscallStat{{PID: 3, TotalNs: 500, Count: 5, TopSyscall: "read", TopSyscallNs: 400}},
+	}, time.Second)
+	s.Add(SnapshotResult{
+		BlockIO:  []types.BlockIOStat{{PID: 3, Requests: 30, AvgLatencyNs: 3000}},
+		Syscalls: []types.SyscallStat{{PID: 3, TotalNs: 900, Count: 9, TopSyscall: "futex", TopSyscallNs: 300}},
+	}, time.Second)
+	r, _ := s.Result()
+	// (10*1000 + 30*3000) / 40
+	if b := r.BlockIO[0]; b.Requests != 40 || b.AvgLatencyNs != 2500 {
+		t.Fatalf("block io: %+v", b)
+	}
+	if sc := r.Syscalls[0]; sc.TotalNs != 1400 || sc.TopSyscall != "read" || sc.TopSyscallNs != 400 {
+		t.Fatalf("syscalls: %+v", sc)
+	}
+}
+
+func TestSubWindowsKeepsErrorOnlyWhenEveryReadFailed(t *testing.T) {
+	s := NewSubWindows(AggregateAvg)
+	failed := errors.New("map read failed")
+	s.Add(SnapshotResult{CPU: []types.CPUStat{{PID: 1, Ns: 4e8}}, FutexErr: failed}, time.Second)
+	s.Add(SnapshotResult{CPUErr: failed, FutexErr: failed}, time.Second)
+	r, _ := s.Result()
+	if r.CPUErr != nil || len(r.CPU) != 1 || r.CPU[0].Ns != 4e8 {
+		t.Fatalf("cpu should average over the sub-window it was read in: %+v, %v", r.CPU, r.CPUErr)
+	}
+	if !errors.Is(r.FutexErr, failed) || r.Futex != nil {
+		t.Fatalf("futex failed every read: %+v, %v", r.Futex, r.FutexErr)
+	}
+	if r.NetTracked {
+		t.Fatal("net was never tracked")
+	}
+}
+
+func TestParseAggregateMode(t *testing.T) {
+	for in, want := range map[string]AggregateMode{"sum": AggregateSum, " AVG ": AggregateAvg, "max": AggregateMax} {
+		if got, err := ParseAggregateMode(in); err != nil || got != want {
+			t.Fatalf("ParseAggregateMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseAggregateMode("median"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
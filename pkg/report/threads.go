@@ -0,0 +1,116 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+// ProcessTotals folds per-thread CPU stats, as the CPU collector returns
+// them with -threads (TID set), into one stat per process, the form
+// BuildProcMetrics and everything after it expect. CPU and off-CPU time and
+// switch counts are summed. The comm is the main thread's (TID == PID) when
+// it ran, and otherwise the busiest thread's, much as the per-process maps
+// record whichever thread they catch first; the last core and cgroup are the
+// busiest thread's too. Stats without a TID pass through unchanged, so
+// stats is returned as is when none has one.
+func ProcessTotals(stats []types.CPUStat) []types.CPUStat {
+	perThread := false
+	for _, s := range stats {
+		if s.TID != 0 {
+			perThread = true
+			break
+		}
+	}
+	if !perThread {
+		return stats
+	}
+
+	mainComm := make(map[uint32]string)
+	for _, s := range stats {
+		if s.TID == s.PID && s.Comm != "" {
+			mainComm[s.PID] = s.Comm
+		}
+	}
+	var out []types.CPUStat
+	byPID := make(map[uint32]int)
+	busiest := make(map[uint32]uint64) // Ns of the thread the row's comm and core came from
+	for _, s := range stats {
+		i, ok := byPID[s.PID]
+		if !ok {
+			byPID[s.PID] = len(out)
+			busiest[s.PID] = s.Ns
+			s.TID = 0
+			out = append(out, s)
+			continue
+		}
+		total := &out[i]
+		total.Ns += s.Ns
+		total.OffCPUNs += s.OffCPUNs
+		total.Switches += s.Switches
+		total.VolSwitches += s.VolSwitches
+		total.InvolSwitches += s.InvolSwitches
+		if s.Ns > busiest[s.PID] {
+			busiest[s.PID] = s.Ns
+			total.Comm, total.CPUCore, total.Cgroup, total.CgroupID = s.Comm, s.CPUCore, s.Cgroup, s.CgroupID
+		}
+	}
+	for i := range out {
+		if comm, ok := mainComm[out[i].PID]; ok {
+			out[i].Comm = comm
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Ns > out[j].Ns })
+	return out
+}
+
+// ThreadRows returns a row for each per-thread stat whose process is in
+// procs, for the CPU Hotspots table with -threads. A thread row starts as a
+// copy of its process's row, so it keeps the process-wide metrics (memory,
+// I/O, run-queue latency) and diagnosis, and takes the thread's TID, name,
+// CPU and off-CPU time, switch counts, and last core. CPU% is of the CPUs
+// the process may run on, like the process's, so a thread's share reads
+// against its process's; Core% shows a thread that keeps one core busy.
+// The moving averages and trends of -smooth and -trend are per process and
+// are cleared. procs is normally the filtered rows, so filters apply to
+// threads through their process.
+func ThreadRows(threads []types.CPUStat, procs []ProcMetrics, interval time.Duration) []ProcMetrics {
+	index := make(map[uint32]ProcMetrics, len(procs))
+	for _, p := range procs {
+		index[p.PID] = p
+	}
+	singleCoreCapacity := float64(interval.Nanoseconds())
+	intervalSeconds := interval.Seconds()
+	if intervalSeconds <= 0 {
+		intervalSeconds = 1
+	}
+
+	rows := make([]ProcMetrics, 0, len(threads))
+	for _, t := range threads {
+		row, ok := index[t.PID]
+		if !ok || t.TID == 0 {
+			continue
+		}
+		row.TID = t.TID
+		if t.Comm != "" {
+			row.Comm = t.Comm
+		}
+		row.Cmdline = "" // the process's, which would hide the thread's name
+		row.CPUNs = t.Ns
+		row.CPUMs = float64(t.Ns) / 1e6
+		row.OffCPUMs = float64(t.OffCPUNs) / 1e6
+		row.CPUCore = t.CPUCore
+		row.Switches = t.Switches
+		row.SwitchesPerSec = float64(t.Switches) / intervalSeconds
+		row.VolCtxSwitches = t.VolSwitches
+		row.InvolCtxSwitches = t.InvolSwitches
+		row.CPUPercent = cpuPercent(t.Ns, singleCoreCapacity*float64(max(row.AllowedCPUs, 1)))
+		row.CoreCPUPercent = cpuPercent(t.Ns, singleCoreCapacity)
+		row.CPUPercentMax, row.CPUPercentAvg = row.CPUPercent, row.CPUPercent
+		row.SmoothedCPUPercent, row.CPUTrend = 0, ""
+		sanitizeMetrics(&row)
+		rows = append(rows, row)
+	}
+	return rows
+}
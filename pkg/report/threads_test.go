@@ -0,0 +1,77 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestProcessTotals(t *testing.T) {
+	threads := []types.CPUStat{
+		{PID: 100, TID: 104, Comm: "worker-3", Ns: 900, CPUCore: 3, Switches: 1, VolSwitches: 2, OffCPUNs: 10},
+		{PID: 100, TID: 100, Comm: "java", Ns: 50, CPUCore: 0, InvolSwitches: 4, OffCPUNs: 5},
+		{PID: 200, TID: 201, Comm: "tokio-rt-1", Ns: 300, CPUCore: 1},
+		{PID: 200, TID: 202, Comm: "tokio-rt-2", Ns: 700, CPUCore: 2},
+	}
+	got := ProcessTotals(threads)
+	if len(got) != 2 {
+		t.Fatalf("got %d processes; want 2: %+v", len(got), got)
+	}
+	java, tokio := got[1], got[0] // ordered by CPU time, 1000 then 950
+	if java.PID != 100 || java.TID != 0 || java.Ns != 950 || java.OffCPUNs != 15 {
+		t.Fatalf("java = %+v", java)
+	}
+	if java.Comm != "java" || java.CPUCore != 3 {
+		t.Fatalf("java comm %q, core %d; want the main thread's name and the busiest thread's core", java.Comm, java.CPUCore)
+	}
+	if java.Switches != 1 || java.VolSwitches != 2 || java.InvolSwitches != 4 {
+		t.Fatalf("java switches %d/%d/%d; want summed", java.Switches, java.VolSwitches, java.InvolSwitches)
+	}
+	// The main thread never ran, so the busiest thread names the process.
+	if tokio.PID != 200 || tokio.Ns != 1000 || tokio.Comm != "tokio-rt-2" || tokio.CPUCore != 2 {
+		t.Fatalf("tokio = %+v", tokio)
+	}
+
+	procs := []types.CPUStat{{PID: 1, Comm: "init", Ns: 5}}
+	if got := ProcessTotals(procs); len(got) != 1 || &got[0] != &procs[0] {
+		t.Fatal("per-process stats were not passed through")
+	}
+}
+
+func TestThreadRows(t *testing.T) {
+	procs := []ProcMetrics{{
+		PID: 100, Comm: "java", Cmdline: "java -jar app.jar", AllowedCPUs: 4, CPUPercent: 30,
+		RSSMB: 512, Diagnosis: "CPU-bound", CPUTrend: TrendUp, SmoothedCPUPercent: 25,
+	}}
+	threads := []types.CPUStat{
+		{PID: 100, TID: 104, Comm: "GC Thread#0", Ns: uint64(time.Second), CPUCore: 3, VolSwitches: 7, OffCPUNs: 2e6},
+		{PID: 100, TID: 100, Comm: "java", Ns: uint64(100 * time.Millisecond)},
+		{PID: 300, TID: 301, Comm: "filtered", Ns: uint64(time.Second)}, // its process is not in procs
+	}
+
+	rows := ThreadRows(threads, procs, time.Second)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows; want 2", len(rows))
+	}
+	gc := rows[0]
+	if gc.PID != 100 || gc.TID != 104 || gc.Comm != "GC Thread#0" || gc.CommLabel() != "GC Thread#0" {
+		t.Fatalf("thread row identifies PID %d TID %d %q (label %q)", gc.PID, gc.TID, gc.Comm, gc.CommLabel())
+	}
+	if math.Abs(gc.CPUPercent-25) > 1e-9 || math.Abs(gc.CoreCPUPercent-100) > 1e-9 {
+		t.Fatalf("CPU%% %g, Core%% %g; want 25 (one of 4 allowed CPUs) and 100", gc.CPUPercent, gc.CoreCPUPercent)
+	}
+	if gc.CPUMs != 1000 || gc.OffCPUMs != 2 || gc.CPUCore != 3 || gc.VolCtxSwitches != 7 {
+		t.Fatalf("thread metrics = %+v", gc)
+	}
+	if gc.RSSMB != 512 || gc.Diagnosis != "CPU-bound" {
+		t.Fatalf("RSS %g, diagnosis %q; want the process's", gc.RSSMB, gc.Diagnosis)
+	}
+	if gc.CPUTrend != "" || gc.SmoothedCPUPercent != 0 {
+		t.Fatalf("per-process trend %q and average %g carried over", gc.CPUTrend, gc.SmoothedCPUPercent)
+	}
+	if rows[1].TID != 100 || math.Abs(rows[1].CoreCPUPercent-10) > 1e-9 {
+		t.Fatalf("main thread row = %+v", rows[1])
+	}
+}
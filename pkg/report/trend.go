@@ -0,0 +1,89 @@
+package report
+
+import (
+	"github.com/srodi/hotspot-bpf/pkg/config"
+)
+
+// Trend arrows, comparing a value with the same process's value in the
+// previous interval.
+const (
+	TrendUp   = "↑"
+	TrendDown = "↓"
+	TrendFlat = "→"
+)
+
+// A value must move by more than trendTolerance of its previous value, and
+// by more than the metric's floor, to count as rising or falling. The floors
+// keep an idle process's 0.1% → 0.3% CPU from reading as a ramp.
+const (
+	trendTolerance   = 0.10
+	trendCPUFloor    = 1.0  // percentage points
+	trendFaultsFloor = 10.0 // faults/sec
+)
+
+// Trend compares each process's CPUPercent and FaultsPerSec with its values
+// in the previous interval, so a ramping leak can be told from a steady
+// state. Each interval's values are already deltas, as the maps are reset;
+// Trend only adds the direction. Entries are keyed by PID+starttime like
+// History, and a process that misses an interval is forgotten, so it has no
+// direction when it comes back.
+//
+// Trend is not safe for concurrent use.
+type Trend struct {
+	procs *stateTable[trendSample]
+}
+
+// trendSample is one process's values in the previous interval and the comm
+// they were recorded under.
+type trendSample struct {
+	comm         string
+	cpuPercent   float64
+	faultsPerSec float64
+	valid        bool
+}
+
+// NewTrend returns a Trend tracking at most cfg.MaxTracked processes.
+func NewTrend(cfg config.RSSTrackerConfig) *Trend {
+	maxTracked := cfg.MaxTracked
+	if maxTracked <= 0 {
+		maxTracked = defaultMaxTracked
+	}
+	return &Trend{procs: newStateTable[trendSample](maxTracked, 1)}
+}
+
+// Update records one interval's values and returns the direction of each
+// against the previous interval. Both are "" on a process's first interval,
+// or the first after it exec'd into a different comm.
+func (t *Trend) Update(key ProcKey, comm string, cpuPercent, faultsPerSec float64) (cpuTrend, faultsTrend string) {
+	cpuPercent, faultsPerSec = finiteOrZero(cpuPercent), finiteOrZero(faultsPerSec)
+	s := t.procs.touch(key)
+	if s.valid && s.comm == comm {
+		cpuTrend = trendDirection(s.cpuPercent, cpuPercent, trendCPUFloor)
+		faultsTrend = trendDirection(s.faultsPerSec, faultsPerSec, trendFaultsFloor)
+	}
+	*s = trendSample{comm: comm, cpuPercent: cpuPercent, faultsPerSec: faultsPerSec, valid: true}
+	return cpuTrend, faultsTrend
+}
+
+// Advance ends the current interval and forgets processes that were not
+// updated in it.
+func (t *Trend) Advance() {
+	t.procs.advance()
+}
+
+// Len returns the number of processes currently tracked.
+func (t *Trend) Len() int {
+	return t.procs.len()
+}
+
+func trendDirection(prev, cur, floor float64) string {
+	margin := max(prev*trendTolerance, floor)
+	switch {
+	case cur > prev+margin:
+		return TrendUp
+	case cur < prev-margin:
+		return TrendDown
+	default:
+		return TrendFlat
+	}
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/collector/memory"
+	"github.com/srodi/hotspot-bpf/pkg/config"
+	"github.com/srodi/hotspot-bpf/pkg/procfs"
+	"github.com/srodi/hotspot-bpf/pkg/types"
+)
+
+func TestTrendDirections(t *testing.T) {
+	tr := NewTrend(config.RSSTrackerConfig{})
+	key := ProcKey{PID: 42, StartTime: 1000}
+
+	if cpu, faults := tr.Update(key, "java", 20, 100); cpu != "" || faults != "" {
+		t.Fatalf("first interval = %q, %q; want no direction", cpu, faults)
+	}
+	steps := []struct {
+		cpuPercent, faultsPerSec float64
+		wantCPU, wantFaults      string
+	}{
+		{30, 105, TrendUp, TrendFlat},   // +10 points; +5 faults/sec is under the floor
+		{30.5, 300, TrendFlat, TrendUp}, // +0.5 points is under the floor
+		{10, 150, TrendDown, TrendDown},
+		{10.8, 160, TrendFlat, TrendFlat}, // within 10% and the floors
+	}
+	for i, s := range steps {
+		tr.Advance()
+		cpu, faults := tr.Update(key, "java", s.cpuPercent, s.faultsPerSec)
+		if cpu != s.wantCPU || faults != s.wantFaults {
+			t.Fatalf("step %d = %q, %q; want %q, %q", i, cpu, faults, s.wantCPU, s.wantFaults)
+		}
+	}
+
+	// An exec into another program starts over.
+	tr.Advance()
+	if cpu, _ := tr.Update(key, "sh", 90, 0); cpu != "" {
+		t.Fatalf("after exec = %q; want no direction", cpu)
+	}
+}
+
+func TestTrendForgetsMissingProcesses(t *testing.T) {
+	tr := NewTrend(config.RSSTrackerConfig{})
+	tr.Update(ProcKey{PID: 1}, "gone", 50, 0)
+	tr.Update(ProcKey{PID: 2}, "busy", 50, 0)
+	tr.Advance()
+	tr.Update(ProcKey{PID: 2}, "busy", 50, 0)
+	tr.Advance()
+	if tr.Len() != 1 {
+		t.Fatalf("tracked %d processes; want the one that missed an interval forgotten", tr.Len())
+	}
+	// Compared with nothing rather than with two intervals ago.
+	if cpu, _ := tr.Update(ProcKey{PID: 1}, "gone", 90, 0); cpu != "" {
+		t.Fatalf("returning process = %q; want no direction", cpu)
+	}
+}
+
+func TestBuildProcMetricsTrend(t *testing.T) {
+	t.Cleanup(func() {
+		rssBytesForProcs = memory.RSSBytesForProcs
+		procStartTime = procfs.StartTime
+	})
+	rssBytesForProcs = func(procs map[int]string) map[int]uint64 { return nil }
+	procStartTime = func(pid int) (uint64, error) { return 1000, nil }
+
+	tracker := NewRSSTracker(defaultTh.RSSTracker)
+	tracker.TrendWith(NewTrend(defaultTh.RSSTracker))
+	for _, ns := range []uint64{100e6, 400e6} {
+		BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "worker", Ns: ns}}}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	}
+	_, index := BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "worker", Ns: 400e6}}}, BuildOptions{Interval: time.Second, Tracker: tracker, Thresholds: defaultTh})
+	if got := index[7]; got.CPUTrend != TrendFlat || got.FaultsTrend != TrendFlat {
+		t.Fatalf("steady interval: CPUTrend=%q FaultsTrend=%q; want →", got.CPUTrend, got.FaultsTrend)
+	}
+
+	// Without a Trend, rows carry no arrows.
+	_, index = BuildProcMetrics(SnapshotResult{CPU: []types.CPUStat{{PID: 7, Comm: "worker", Ns: 800e6}}}, BuildOptions{Interval: time.Second, Tracker: NewRSSTracker(defaultTh.RSSTracker), Thresholds: defaultTh})
+	if index[7].CPUTrend != "" {
+		t.Fatalf("CPUTrend without a Trend = %q; want empty", index[7].CPUTrend)
+	}
+}
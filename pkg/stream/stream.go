@@ -0,0 +1,164 @@
+// Package stream publishes snapshots as JSON Lines to local clients over a
+// Unix domain socket, so a separate frontend can consume hotspot's data
+// without scraping the terminal output.
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// clientBuffer is how many snapshots may queue for one client. A client that
+// falls further behind is disconnected rather than slowing the sampler.
+const clientBuffer = 16
+
+// writeTimeout bounds a single write to a client.
+const writeTimeout = 5 * time.Second
+
+// Server accepts any number of clients on a Unix socket and sends every
+// published value to each of them as one line of JSON. It is safe for
+// concurrent use.
+type Server struct {
+	path string
+	ln   net.Listener
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type client struct {
+	conn  net.Conn
+	lines chan []byte
+}
+
+// Listen creates the socket at path, readable and writable by the owner
+// only, and starts accepting clients. A stale socket left by a previous run
+// is replaced; any other existing file is an error.
+func Listen(path string) (*Server, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	s := &Server{path: path, ln: ln, clients: make(map[*client]struct{})}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+		c := &client{conn: conn, lines: make(chan []byte, clientBuffer)}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.clients[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serve(c)
+	}
+}
+
+// serve writes queued lines to c until its queue is closed or a write fails.
+func (s *Server) serve(c *client) {
+	defer s.wg.Done()
+	defer c.conn.Close()
+	for line := range c.lines {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := c.conn.Write(line); err != nil {
+			s.drop(c)
+			return
+		}
+	}
+}
+
+// drop forgets c and closes its queue. It is a no-op for a dropped client.
+func (s *Server) drop(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.lines)
+	}
+}
+
+// Publish encodes v once and queues it for every connected client. A client
+// whose queue is full is disconnected.
+func (s *Server) Publish(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	var slow []*client
+	for c := range s.clients {
+		select {
+		case c.lines <- line:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	s.mu.Unlock()
+	for _, c := range slow {
+		s.drop(c)
+	}
+	return nil
+}
+
+// Clients returns the number of connected clients.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Close stops accepting clients, sends each client what is already queued
+// for it, disconnects them, and removes the socket file.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	err := s.ln.Close()
+
+	s.mu.Lock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c.lines)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
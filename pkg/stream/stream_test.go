@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitForClients(t *testing.T, s *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Clients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d clients, have %d", n, s.Clients())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPublishReachesEveryClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "h.sock")
+	s, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	var readers []*bufio.Reader
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		readers = append(readers, bufio.NewReader(conn))
+	}
+	waitForClients(t, s, 2)
+
+	for tick := 1; tick <= 2; tick++ {
+		if err := s.Publish(map[string]int{"tick": tick}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	for i, r := range readers {
+		for tick := 1; tick <= 2; tick++ {
+			line, err := r.ReadBytes('\n')
+			if err != nil {
+				t.Fatalf("client %d: %v", i, err)
+			}
+			var got map[string]int
+			if err := json.Unmarshal(line, &got); err != nil || got["tick"] != tick {
+				t.Fatalf("client %d: line %q, want tick %d", i, line, tick)
+			}
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file should be removed on Close, stat err = %v", err)
+	}
+}
+
+func TestListenReplacesStaleSocketOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "h.sock")
+	// A socket file left behind by a process that died without cleaning up.
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	s, err := Listen(path)
+	if err != nil {
+		t.Fatalf("stale socket should be replaced: %v", err)
+	}
+	s.Close()
+
+	regular := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(regular, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(regular); err == nil {
+		t.Fatal("expected an error for an existing regular file")
+	}
+	if data, _ := os.ReadFile(regular); string(data) != "keep me" {
+		t.Fatal("a regular file must not be removed")
+	}
+}
@@ -3,16 +3,57 @@
 // These types are intentionally simple and carry no business logic.
 package types
 
+import "time"
+
 // DefaultTopK controls how many top processes we display per resource category.
 const DefaultTopK = 5
 
 // CPUStat holds information about how much CPU time a PID consumed during a window.
 type CPUStat struct {
+	PID      uint32
+	TID      uint32 // thread, when the collector accounts per thread (-threads); 0 for a whole process
+	Comm     string
+	Cgroup   string // cgroup path, when the collector read it (MethodProc)
+	CgroupID uint64 // cgroup v2 id, when it did not; see report.BuildProcMetrics
+	Ns       uint64
+	CPUCore  uint32 // last CPU core observed at switch-out
+	Switches uint64 // switch-outs in favour of another process (0 when not tracked)
+	OffCPUNs uint64 // time threads spent blocked (asleep, not runnable); 0 when not tracked
+
+	// Switch-outs of the process's threads to any task, split by whether
+	// the thread blocked (voluntary) or was preempted while runnable
+	// (involuntary). Both are 0 when not tracked.
+	VolSwitches   uint64
+	InvolSwitches uint64
+
+	// CPU time per second of window in the busiest sub-window and across
+	// all of them, set when report.SubWindows combines several readings
+	// (-sample-window); both 0 for a single reading.
+	PeakNsPerSec float64
+	MeanNsPerSec float64
+}
+
+// RunqLatencyStat summarizes how long one process's threads waited on the
+// run queue (runnable but not running) within a window.
+type RunqLatencyStat struct {
 	PID     uint32
 	Comm    string
-	Cgroup  string
-	Ns      uint64
-	CPUCore uint32 // last CPU core observed at switch-out
+	Waits   uint64 // waits that ended with a thread being switched in
+	Waiting uint64 // threads still waiting when the window was read
+	TotalNs uint64 // summed across threads, including Waiting threads so far
+	MaxNs   uint64 // longest single wait
+}
+
+// SpikeEvent reports one thread that ran for at least the spike threshold
+// without being switched out. Spikes are streamed as they happen rather than
+// summed per window.
+type SpikeEvent struct {
+	PID     uint32
+	TID     uint32
+	Comm    string    // comm of the thread
+	CPU     uint32    // core it ran on
+	SliceNs uint64    // length of the run
+	At      time.Time // when the run ended
 }
 
 // ContentionStat captures how often one PID preempted another within a window.
@@ -28,8 +69,101 @@ type ContentionStat struct {
 type PageFaultStat struct {
 	PID          uint32
 	Comm         string
-	Cgroup       string
+	CgroupID     uint64 // cgroup v2 id; see report.BuildProcMetrics
 	Faults       uint64
 	FaultsPerSec float64
 	RSSBytes     uint64
+	RSSMinBytes  uint64  // lowest RSS sampled in-kernel at a fault in the window (0 = unknown)
+	RSSMaxBytes  uint64  // highest RSS sampled in-kernel at a fault in the window
+	FaultPages   float64 // estimated distinct pages that faulted (0 = unknown)
+	MajorFaults  uint64  // faults that waited for swap or file I/O (0 when untimed)
+	MajorFaultNs uint64  // total time spent resolving MajorFaults
+	// Remote TLB shootdowns this process initiated (x86 only; 0 when untracked).
+	TLBShootdowns       uint64
+	TLBShootdownsPerSec float64
+	// Pages this process read back from swap, and pages written out to swap
+	// while it was reclaiming (0 when untracked).
+	SwapIns        uint64
+	SwapOuts       uint64
+	SwapInsPerSec  float64
+	SwapOutsPerSec float64
+}
+
+// BlockIOStat summarizes the block device reads and writes one process
+// issued that completed within a window.
+type BlockIOStat struct {
+	PID          uint32
+	Comm         string
+	ReadBytes    uint64
+	WriteBytes   uint64
+	Requests     uint64 // completed read and write requests
+	AvgLatencyNs uint64 // mean issue-to-completion time per request
+}
+
+// SyscallStat summarizes the system calls one process returned from within
+// a window. Time spent blocked inside a call (futex, epoll_wait) counts too.
+type SyscallStat struct {
+	PID          uint32
+	Comm         string
+	TotalNs      uint64 // time inside system calls, summed across threads
+	Count        uint64 // calls that returned in the window
+	TopSyscall   string // the call with the most time, by name ("syscall N" if unknown)
+	TopSyscallNs uint64 // time in TopSyscall
+}
+
+// FutexStat summarizes the futex waits one process blocked in within a
+// window: lock contention, or threads parked on a condition variable.
+type FutexStat struct {
+	PID       uint32
+	Comm      string
+	WaitNs    uint64 // time blocked in futex waits, summed across threads
+	Contended uint64 // waits that blocked rather than finding the futex already released
+}
+
+// NetStat counts the TCP bytes one process sent and received within a
+// window, charged to the process that made the send or receive call.
+type NetStat struct {
+	PID     uint32
+	Comm    string
+	TxBytes uint64 // bytes queued for sending
+	RxBytes uint64 // bytes copied to userspace
+}
+
+// StackFrame is one function in a sampled call stack.
+type StackFrame struct {
+	Symbol string // function name, or module+offset / address when it could not be resolved
+	Kernel bool
+}
+
+// StackCount is one distinct call stack of a profiled process and how many
+// samples in the window landed on it. Frames run from the innermost (where
+// the CPU was) outward, kernel frames before the user frames below them.
+type StackCount struct {
+	Frames  []StackFrame
+	Samples uint64
+}
+
+// SystemCPU splits all CPU time since the previous sample by what the CPUs
+// were doing, as percentages of the ticks of every CPU. Interrupt time is in
+// SystemStat's IRQPercent and SoftIRQPercent, so the shares here do not sum
+// to 100 on their own.
+type SystemCPU struct {
+	UserPercent   float64 // user and nice, including guest time
+	SystemPercent float64 // kernel, excluding interrupts
+	IdlePercent   float64
+	IOWaitPercent float64 // idle with I/O outstanding
+	StealPercent  float64 // runnable while the hypervisor ran another guest
+}
+
+// SystemStat summarizes whole-machine utilization for one window.
+type SystemStat struct {
+	CPUBusyPercent    float64 // non-idle share of all CPU time since the previous sample
+	CPU               SystemCPU
+	MemTotalBytes     uint64
+	MemAvailableBytes uint64
+	IRQPercent        float64 // hardirq share of all CPU time since the previous sample
+	SoftIRQPercent    float64 // softirq share of all CPU time since the previous sample
+	PeakIRQCPU        int     // CPU with the highest irq+softirq share
+	PeakIRQPercent    float64 // irq+softirq share of PeakIRQCPU's time
+	TopSoftIRQ        string  // softirq type with the most events since the previous sample
 }
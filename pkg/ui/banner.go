@@ -21,8 +21,15 @@ const (
 	hotspotFlame = "\033[38;5;208m"
 )
 
-// Banner renders a colored hotspot wordmark.
-func Banner() string {
+// Banner renders the hotspot wordmark, colored unless color is false, in
+// which case it contains no escape sequences.
+func Banner(color bool) string {
+	paint := func(codes ...string) string {
+		if !color {
+			return ""
+		}
+		return strings.Join(codes, "")
+	}
 	var b strings.Builder
 
 	hotspotLetters := [][]string{
@@ -37,17 +44,17 @@ func Banner() string {
 	hotspotGradient := []string{hotspotFlame, honeyOrange, beeYellow, mint, cobalt, deepIndigo, fuchsia}
 	hotspotRows := make([]string, len(hotspotLetters[0]))
 	for i, letter := range hotspotLetters {
-		color := hotspotGradient[i%len(hotspotGradient)]
+		letterColor := paint(hotspotGradient[i%len(hotspotGradient)])
 		for row := 0; row < len(letter); row++ {
-			hotspotRows[row] += color + letter[row] + "  "
+			hotspotRows[row] += letterColor + letter[row] + "  "
 		}
 	}
 	for _, line := range hotspotRows {
-		b.WriteString(bold + line + reset + "\n")
+		b.WriteString(paint(bold) + line + paint(reset) + "\n")
 	}
 
 	b.WriteString("\n")
-	b.WriteString(bold + hotspotFlame + "hotspot" + reset + "  •  eBPF performance lens\n\n")
+	b.WriteString(paint(bold, hotspotFlame) + "hotspot" + paint(reset) + "  •  eBPF performance lens\n\n")
 
 	return b.String()
 }
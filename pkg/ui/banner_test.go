@@ -8,11 +8,11 @@ import (
 
 // TestBannerPreview prints the banner so `go test ./pkg/ui -run TestBannerPreview` shows it.
 func TestBannerPreview(t *testing.T) {
-	fmt.Println(Banner())
+	fmt.Println(Banner(true))
 }
 
 func TestBannerIncludesWordmark(t *testing.T) {
-	banner := Banner()
+	banner := Banner(true)
 	if !strings.Contains(banner, "hotspot") {
 		t.Fatalf("banner missing hotspot wordmark: %q", banner)
 	}
@@ -26,7 +26,7 @@ func TestBannerIncludesWordmark(t *testing.T) {
 }
 
 func TestBannerUsesGradientColors(t *testing.T) {
-	banner := Banner()
+	banner := Banner(true)
 	colors := []string{bold, hotspotFlame, honeyOrange, beeYellow, mint, cobalt, deepIndigo, fuchsia}
 	for _, color := range colors {
 		if !strings.Contains(banner, color) {
@@ -34,3 +34,13 @@ func TestBannerUsesGradientColors(t *testing.T) {
 		}
 	}
 }
+
+func TestBannerWithoutColor(t *testing.T) {
+	banner := Banner(false)
+	if strings.Contains(banner, "\033") {
+		t.Fatalf("plain banner contains escape sequences: %q", banner)
+	}
+	if !strings.Contains(banner, "hotspot  •  eBPF performance lens") {
+		t.Fatalf("plain banner missing wordmark: %q", banner)
+	}
+}
@@ -6,6 +6,7 @@ package ui
 import (
 	"fmt"
 	"os"
+	"regexp"
 
 	"golang.org/x/term"
 )
@@ -17,9 +18,11 @@ const (
 	Dim     = "\033[2m"
 
 	Red     = "\033[38;5;196m"
+	Green   = "\033[38;5;114m"
 	Orange  = "\033[38;5;208m"
 	Yellow  = "\033[38;5;220m"
 	Cyan    = "\033[38;5;81m"
+	Magenta = "\033[38;5;171m"
 	Blue    = "\033[38;5;33m"
 	Gray    = "\033[38;5;245m"
 	White   = "\033[38;5;255m"
@@ -62,30 +65,48 @@ func C(color, text string) string {
 	return color + text + Reset
 }
 
-// DiagColor returns the ANSI color code for a diagnosis label.
-func DiagColor(label string) string {
+// ansiEscape matches the CSI sequences emitted by this package and the
+// frame renderer (colors, cursor movement, line clearing).
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;?]*[A-Za-z]")
+
+// StripANSI removes ANSI escape sequences, for writing rendered output to
+// files regardless of whether color is enabled.
+func StripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// SeverityColor returns the ANSI color code for a diagnosis severity on the
+// scale of report.Severity: green for OK (0), yellow up to Switch- and
+// TLB-thrashing (1-3), orange for Noisy neighbor and Lock-contended (4-5),
+// and red from Starved up (6 and above). The codes are all the same length,
+// so colored cells of one column stay equally wide even to writers that
+// count bytes rather than cells.
+func SeverityColor(severity int) string {
 	if !colorEnabled {
 		return ""
 	}
-	switch label {
-	case "OOM risk – memory growth":
-		return Bold + Red
-	case "Mem-thrashing":
-		return Bold + Orange
-	case "Starved":
+	switch {
+	case severity >= severityRed:
+		return Red
+	case severity >= severityOrange:
+		return Orange
+	case severity > 0:
 		return Yellow
-	case "Noisy neighbor":
-		return Cyan
-	case "CPU-bound":
-		return Blue
 	default:
-		return Dim
+		return Green
 	}
 }
 
-// DiagLabel returns a diagnosis string wrapped in its severity color.
-func DiagLabel(label string) string {
-	return C(DiagColor(label), label)
+// Severity thresholds of SeverityColor: Starved and Noisy neighbor.
+const (
+	severityRed    = 6
+	severityOrange = 4
+)
+
+// ColorizeDiagnosis returns a diagnosis label wrapped in the color of its
+// severity (see SeverityColor), or the plain label when color is disabled.
+func ColorizeDiagnosis(label string, severity int) string {
+	return C(SeverityColor(severity), label)
 }
 
 // SectionHeader formats a section title with a box-drawing underline.
@@ -100,8 +121,10 @@ func SectionHeader(title string) string {
 	return fmt.Sprintf("\n%s%s%s\n%s%s%s\n", Bold, White, title, Dim, line, Reset)
 }
 
-func FocusGroupHeader(diagnosis string, count int) string {
-	diagColor := DiagColor(diagnosis)
+// FocusGroupHeader formats the heading of a focus group, its diagnosis
+// colored by severity as in the tables.
+func FocusGroupHeader(diagnosis string, severity, count int) string {
+	diagColor := SeverityColor(severity)
 	if !colorEnabled {
 		return fmt.Sprintf("\n  [%s] (%d)\n", diagnosis, count)
 	}
@@ -111,8 +134,8 @@ func FocusGroupHeader(diagnosis string, count int) string {
 }
 
 // FocusEntry formats a single process line within a focus group.
-func FocusEntry(comm string, pid uint32, summary string, diagnosis string) string {
-	diagColor := DiagColor(diagnosis)
+func FocusEntry(comm string, pid uint32, summary string, severity int) string {
+	diagColor := SeverityColor(severity)
 	if !colorEnabled {
 		return fmt.Sprintf("    %-16s pid %-8d %s\n", comm, pid, summary)
 	}
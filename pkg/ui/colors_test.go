@@ -5,63 +5,47 @@ import (
 	"testing"
 )
 
-func TestDiagColorMapping(t *testing.T) {
+func TestColorizeDiagnosis(t *testing.T) {
 	SetColorEnabled(true)
 	defer SetColorEnabled(true)
 
+	// Severities as report.Severity assigns them.
 	cases := []struct {
-		label     string
-		wantEmpty bool
+		label    string
+		severity int
+		color    string
 	}{
-		{"OOM risk – memory growth", false},
-		{"Mem-thrashing", false},
-		{"Starved", false},
-		{"Noisy neighbor", false},
-		{"CPU-bound", false},
-		{"OK", false}, // dim, still a color
+		{"OK", 0, Green},
+		{"CPU-bound", 1, Yellow},
+		{"TLB-thrashing", 3, Yellow},
+		{"Noisy neighbor", 4, Orange},
+		{"Starved", 6, Red},
+		{"Mem-thrashing", 8, Red},
+		{"OOM risk – memory growth", 12, Red},
 	}
 	for _, tc := range cases {
-		color := DiagColor(tc.label)
-		if tc.wantEmpty && color != "" {
-			t.Errorf("DiagColor(%q) = %q, want empty", tc.label, color)
-		}
-		if !tc.wantEmpty && color == "" {
-			t.Errorf("DiagColor(%q) = empty, want color code", tc.label)
+		if got, want := ColorizeDiagnosis(tc.label, tc.severity), tc.color+tc.label+Reset; got != want {
+			t.Errorf("ColorizeDiagnosis(%q, %d) = %q, want %q", tc.label, tc.severity, got, want)
 		}
 	}
-}
 
-func TestDiagColorDisabled(t *testing.T) {
 	SetColorEnabled(false)
-	defer SetColorEnabled(true)
-
-	for _, label := range []string{"OOM risk – memory growth", "Starved", "OK"} {
-		if color := DiagColor(label); color != "" {
-			t.Errorf("DiagColor(%q) with color disabled = %q, want empty", label, color)
-		}
+	if got := ColorizeDiagnosis("Starved", 6); got != "Starved" {
+		t.Errorf("ColorizeDiagnosis with color disabled = %q, want plain text", got)
 	}
 }
 
-func TestDiagLabel(t *testing.T) {
+func TestSeverityColorsAreEquallyLong(t *testing.T) {
 	SetColorEnabled(true)
 	defer SetColorEnabled(true)
 
-	label := DiagLabel("OOM risk – memory growth")
-	if !strings.Contains(label, "OOM risk") {
-		t.Errorf("DiagLabel missing text: %q", label)
-	}
-	if !strings.Contains(label, Reset) {
-		t.Errorf("DiagLabel missing reset code: %q", label)
-	}
-}
-
-func TestDiagLabelNoColor(t *testing.T) {
-	SetColorEnabled(false)
-	defer SetColorEnabled(true)
-
-	label := DiagLabel("Starved")
-	if label != "Starved" {
-		t.Errorf("DiagLabel with color disabled = %q, want plain text", label)
+	// Writers that count escape bytes as text only line up a column of
+	// differently colored cells when every color code has the same length.
+	want := len(SeverityColor(0))
+	for sev := 1; sev <= 12; sev++ {
+		if got := len(SeverityColor(sev)); got != want {
+			t.Errorf("SeverityColor(%d) is %d bytes, want %d", sev, got, want)
+		}
 	}
 }
 
@@ -100,3 +84,13 @@ func TestSectionHeader(t *testing.T) {
 		t.Error("SectionHeader missing underline")
 	}
 }
+
+func TestStripANSI(t *testing.T) {
+	SetColorEnabled(true)
+	defer SetColorEnabled(true)
+
+	in := C(Bold+Red, "OOM") + " ok\033[K\n"
+	if got := StripANSI(in); got != "OOM ok\n" {
+		t.Errorf("StripANSI(%q) = %q, want %q", in, got, "OOM ok\n")
+	}
+}
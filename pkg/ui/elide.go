@@ -0,0 +1,41 @@
+package ui
+
+// Ellipsis marks where Elide and ElideStart cut a string.
+const Ellipsis = "…"
+
+// Elide shortens s to at most n terminal cells, replacing its end with an
+// ellipsis. It suits names whose start identifies them, such as a command.
+func Elide(s string, n int) string {
+	if Width(s) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	r := []rune(s)
+	end, w := 0, 0
+	for end < len(r) && w+RuneWidth(r[end]) <= n-1 {
+		w += RuneWidth(r[end])
+		end++
+	}
+	return string(r[:end]) + Ellipsis
+}
+
+// ElideStart shortens s to at most n terminal cells, replacing its start
+// with an ellipsis. It suits paths, whose last elements identify them: a
+// cgroup keeps its leaf.
+func ElideStart(s string, n int) string {
+	if Width(s) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	r := []rune(s)
+	start, w := len(r), 0
+	for start > 0 && w+RuneWidth(r[start-1]) <= n-1 {
+		w += RuneWidth(r[start-1])
+		start--
+	}
+	return Ellipsis + string(r[start:])
+}
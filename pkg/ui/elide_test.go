@@ -0,0 +1,82 @@
+package ui
+
+import "testing"
+
+func TestElide(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"postgres", 8, "postgres"},
+		{"postgres", 20, "postgres"},
+		{"postgres: checkpointer", 10, "postgres:…"},
+		{"postgres", 1, "…"},
+		{"postgres", 0, ""},
+		{"naïve-wörker", 6, "naïve…"},
+		{"数据库服务", 5, "数据…"},
+		{"数据库服务", 4, "数…"},
+	}
+	for _, tc := range cases {
+		if got := Elide(tc.in, tc.n); got != tc.want {
+			t.Errorf("Elide(%q, %d) = %q, want %q", tc.in, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestElideStart(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"/system.slice/nginx.service", 40, "/system.slice/nginx.service"},
+		{"/system.slice/nginx.service", 14, "…nginx.service"},
+		{"/system.slice/nginx.service", 1, "…"},
+		{"/system.slice/nginx.service", 0, ""},
+	}
+	for _, tc := range cases {
+		if got := ElideStart(tc.in, tc.n); got != tc.want {
+			t.Errorf("ElideStart(%q, %d) = %q, want %q", tc.in, tc.n, got, tc.want)
+		}
+		if got := Width(ElideStart(tc.in, tc.n)); got > tc.n {
+			t.Errorf("ElideStart(%q, %d) is %d cells wide", tc.in, tc.n, got)
+		}
+	}
+}
+
+func TestWidthIgnoresColor(t *testing.T) {
+	SetColorEnabled(true)
+	defer SetColorEnabled(true)
+
+	if got := Width(C(Red, "OOM risk")); got != 8 {
+		t.Errorf("Width = %d, want 8", got)
+	}
+	if got := Width("…/app"); got != 5 {
+		t.Errorf("Width = %d, want 5", got)
+	}
+}
+
+func TestWidthCountsWideRunesTwice(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int
+	}{
+		{"postgres", 8},
+		{"数据库", 6},
+		{"ｗｏｒｋｅｒ", 12}, // fullwidth forms
+		{"e\u0301", 1}, // a combining accent takes no cell
+		{"한국어-app", 10},
+	}
+	for _, tc := range cases {
+		if got := Width(tc.in); got != tc.want {
+			t.Errorf("Width(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+	if got := PadRight("数据", 6); got != "数据  " {
+		t.Errorf("PadRight = %q, want two spaces of padding", got)
+	}
+	if got := PadRight(C(Red, "OK"), 2); got != C(Red, "OK") {
+		t.Errorf("PadRight padded a cell already wide enough: %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// Table lays out tab-separated lines as aligned columns, like text/tabwriter
+// with padding characters of ' ', but measures cells in terminal cells (see
+// Width) rather than runes, so colored cells and wide characters line up.
+// Each cell but the last of a line is padded to the widest cell of its
+// column plus gap spaces; the last is written as is. Lines are kept until
+// Flush.
+type Table struct {
+	w   io.Writer
+	gap int
+	buf bytes.Buffer
+}
+
+// NewTable returns a Table writing to w with gap spaces between columns.
+func NewTable(w io.Writer, gap int) *Table {
+	return &Table{w: w, gap: gap}
+}
+
+// Write implements io.Writer.
+func (t *Table) Write(p []byte) (int, error) {
+	return t.buf.Write(p)
+}
+
+// Flush writes the lines written so far as a table. A last line without a
+// newline is written without one.
+func (t *Table) Flush() error {
+	text := t.buf.String()
+	t.buf.Reset()
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	trailing := lines[len(lines)-1] == ""
+	if trailing {
+		lines = lines[:len(lines)-1]
+	}
+
+	rows := make([][]string, len(lines))
+	var widths []int
+	for i, line := range lines {
+		rows[i] = strings.Split(line, "\t")
+		for j, cell := range rows[i][:len(rows[i])-1] {
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], Width(cell))
+		}
+	}
+
+	var out bytes.Buffer
+	for i, cells := range rows {
+		last := len(cells) - 1
+		for j, cell := range cells[:last] {
+			out.WriteString(PadRight(cell, widths[j]+t.gap))
+		}
+		out.WriteString(cells[last])
+		if i < len(rows)-1 || trailing {
+			out.WriteByte('\n')
+		}
+	}
+	_, err := t.w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// columnStarts returns the terminal cell at which each of the fields after
+// the first starts in a line of out, fields being separated by two or more
+// spaces.
+func columnStarts(t *testing.T, line string) []int {
+	t.Helper()
+	var starts []int
+	plain := StripANSI(line)
+	for i, cell := 0, 0; i < len(plain); {
+		if strings.HasPrefix(plain[i:], "  ") {
+			for i < len(plain) && plain[i] == ' ' {
+				i++
+				cell++
+			}
+			if i < len(plain) {
+				starts = append(starts, cell)
+			}
+			continue
+		}
+		r := []rune(plain[i:])[0]
+		i += len(string(r))
+		cell += RuneWidth(r)
+	}
+	return starts
+}
+
+func TestTableAlignsColoredAndWideCells(t *testing.T) {
+	SetColorEnabled(true)
+	defer SetColorEnabled(true)
+
+	var buf bytes.Buffer
+	tw := NewTable(&buf, 2)
+	fmt.Fprintln(tw, "PID\tCOMM\tDiag\tCPU")
+	fmt.Fprintf(tw, "1\t%s\t%s\t%s\n", "nginx", C(Red, "Starved"), "12.0")
+	fmt.Fprintf(tw, "22\t%s\t%s\t%s\n", "数据库服务", C(Green, "OK"), "3.5")
+	fmt.Fprintf(tw, "333\t%s\t%s\t%s\n", "naïve", "CPU-bound", "7.25")
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines; want 4:\n%s", len(lines), buf.String())
+	}
+	want := columnStarts(t, lines[0])
+	if len(want) != 3 {
+		t.Fatalf("header columns start at %v; want 3 columns", want)
+	}
+	for _, line := range lines[1:] {
+		if got := columnStarts(t, line); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("columns of %q start at cells %v; want %v", StripANSI(line), got, want)
+		}
+	}
+	if !strings.Contains(lines[1], C(Red, "Starved")) {
+		t.Errorf("colored cell lost its escapes: %q", lines[1])
+	}
+}
+
+func TestTableLastCell(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewTable(&buf, 2)
+	fmt.Fprint(tw, "a\tlong last cell\nbbb\tx")
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// The last cell of a line is not padded, and a line without a newline
+	// stays without one.
+	if got, want := buf.String(), "a    long last cell\nbbb  x"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := tw.Flush(); err != nil || buf.Len() != 0 {
+		t.Errorf("second Flush wrote %q, err %v", buf.String(), err)
+	}
+}
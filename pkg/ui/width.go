@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// wideRanges are the runes a terminal draws two cells wide: the East Asian
+// Wide and Fullwidth blocks (CJK ideographs, kana, Hangul, fullwidth forms)
+// and the emoji blocks.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi radicals, CJK symbols and punctuation
+	{0x3041, 0x33FF},   // kana, Bopomofo, Hangul compatibility Jamo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x1F300, 0x1F64F}, // pictographs and emoticons
+	{0x1F900, 0x1F9FF}, // supplemental pictographs
+	{0x20000, 0x2FFFD}, // CJK extensions B-F
+	{0x30000, 0x3FFFD}, // CJK extension G
+}
+
+// RuneWidth returns the number of terminal cells r occupies: 0 for control
+// characters and combining marks, 2 for wide East Asian characters and
+// emoji, and 1 otherwise.
+func RuneWidth(r rune) int {
+	if r < 0x20 || r == 0x7F || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	if r < wideRanges[0][0] {
+		return 1
+	}
+	for _, rg := range wideRanges {
+		if r >= rg[0] && r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Width returns the number of terminal cells s occupies, ignoring ANSI
+// escape sequences and counting wide characters twice (see RuneWidth).
+func Width(s string) int {
+	n := 0
+	for _, r := range StripANSI(s) {
+		n += RuneWidth(r)
+	}
+	return n
+}
+
+// PadRight pads s with spaces to n terminal cells. A string already n cells
+// wide or wider is returned unchanged.
+func PadRight(s string, n int) string {
+	if w := Width(s); w < n {
+		return s + strings.Repeat(" ", n-w)
+	}
+	return s
+}
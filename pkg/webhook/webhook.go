@@ -0,0 +1,208 @@
+// Package webhook POSTs a JSON alert to an HTTP endpoint when a process
+// takes on a severe diagnosis, turning hotspot into a lightweight watchdog
+// for hosts without a monitoring stack. A Notifier is an engine.Observer.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+// queueSize is how many alerts may wait for delivery. Alerts beyond it are
+// dropped and logged rather than slowing the sampler.
+const queueSize = 64
+
+// Delivery is retried on network errors, 429, and 5xx responses, waiting
+// initialBackoff before the first retry and doubling it each time.
+const (
+	maxAttempts    = 4
+	initialBackoff = time.Second
+	requestTimeout = 10 * time.Second
+)
+
+// closeTimeout bounds how long Close waits for queued alerts, so shutting
+// down against an unreachable endpoint does not hang; whatever is still
+// queued or in flight then is abandoned.
+const closeTimeout = requestTimeout
+
+// Payload is the JSON body of one alert.
+type Payload struct {
+	Time      time.Time `json:"time"`
+	Host      string    `json:"host"`
+	PID       uint32    `json:"pid"`
+	Comm      string    `json:"comm"`
+	Cgroup    string    `json:"cgroup,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Diagnosis string    `json:"diagnosis"`
+	Severity  int       `json:"severity"`
+	Summary   string    `json:"summary"` // report.FocusSummary, e.g. "RSS grew 12.0 MB/s, 840 faults/sec"
+}
+
+// Notifier delivers alerts in the background, one at a time and in order.
+// It sends every alert it is given; engine.Observers decides how often a
+// process and diagnosis is alerted on. It is safe for concurrent use.
+type Notifier struct {
+	url          string
+	client       *http.Client
+	host         string
+	backoff      time.Duration // wait before the first retry; tests shorten it
+	closeTimeout time.Duration // tests shorten it too
+
+	mu     sync.Mutex
+	queue  chan Payload
+	closed bool
+	done   chan struct{} // closed by Close: retries give up
+	ctx    context.Context
+	cancel context.CancelFunc // called when Close stops waiting: requests are abandoned
+	wg     sync.WaitGroup
+}
+
+// New returns a Notifier posting to url and starts its delivery goroutine.
+// The caller must Close it.
+func New(url string) *Notifier {
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		url:          url,
+		client:       &http.Client{Timeout: requestTimeout},
+		host:         host,
+		backoff:      initialBackoff,
+		closeTimeout: closeTimeout,
+		queue:        make(chan Payload, queueSize),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	n.wg.Add(1)
+	go n.deliver()
+	return n
+}
+
+// OnDiagnosis queues an alert for row.
+func (n *Notifier) OnDiagnosis(row report.ProcMetrics) {
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	p := Payload{
+		Time:      now,
+		Host:      n.host,
+		PID:       row.PID,
+		Comm:      row.Comm,
+		Cgroup:    row.Cgroup,
+		Pod:       row.PodName,
+		Container: row.ContainerName,
+		Diagnosis: row.Diagnosis,
+		Severity:  report.Severity(row.Diagnosis),
+		Summary:   report.FocusSummary(row),
+	}
+	select {
+	case n.queue <- p:
+	default:
+		slog.Warn("webhook queue full, dropping alert", "pid", row.PID, "diagnosis", row.Diagnosis)
+	}
+}
+
+// Close stops taking alerts and waits for the queued ones to be sent. Each
+// gets one more attempt but no further retries, and all of them together
+// get closeTimeout, after which the rest are dropped.
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.queue)
+	close(n.done)
+	n.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(n.closeTimeout):
+		n.cancel()
+		<-finished
+	}
+	n.cancel()
+	return nil
+}
+
+func (n *Notifier) deliver() {
+	defer n.wg.Done()
+	dropped := 0
+	for p := range n.queue {
+		if n.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		if err := n.send(p); err != nil {
+			slog.Warn("webhook alert not delivered", "pid", p.PID, "diagnosis", p.Diagnosis, "err", err)
+		}
+	}
+	if dropped > 0 {
+		slog.Warn("webhook alerts dropped at shutdown", "count", dropped)
+	}
+}
+
+// send posts p, retrying with exponential backoff until it is accepted, the
+// endpoint rejects it outright (a 4xx other than 429), or the attempts run
+// out.
+func (n *Notifier) send(p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	wait := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil || !retry || attempt == maxAttempts {
+			return err
+		}
+		select {
+		case <-n.done:
+			return fmt.Errorf("%w (shutting down, not retried)", err)
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// post makes one attempt, reporting whether a failure is worth retrying.
+func (n *Notifier) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return n.ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+}
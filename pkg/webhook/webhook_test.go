@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/srodi/hotspot-bpf/pkg/engine"
+	"github.com/srodi/hotspot-bpf/pkg/report"
+)
+
+var _ engine.Observer = (*Notifier)(nil)
+
+// recorder is an endpoint that answers with the given statuses in turn (200
+// once they run out) and keeps every payload it was sent.
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	payloads []Payload
+	attempts int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+	var p Payload
+	if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, p)
+}
+
+func newTestNotifier(t *testing.T, rec http.Handler) *Notifier {
+	t.Helper()
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	n := New(srv.URL)
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestNotifierPostsPayload(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	n.OnDiagnosis(report.ProcMetrics{
+		PID: 42, Comm: "java", Cgroup: "/kubepods/pod1", PodName: "api-7f9", ContainerName: "api",
+		Diagnosis: "Starved", CPUPercent: 3, Preempted: 120,
+	})
+	n.Close()
+
+	if len(rec.payloads) != 1 {
+		t.Fatalf("got %d payloads, want 1", len(rec.payloads))
+	}
+	p := rec.payloads[0]
+	if p.PID != 42 || p.Comm != "java" || p.Pod != "api-7f9" || p.Container != "api" || p.Cgroup != "/kubepods/pod1" {
+		t.Fatalf("identity fields: %+v", p)
+	}
+	if p.Diagnosis != "Starved" || p.Severity != report.Severity("Starved") || p.Summary == "" {
+		t.Fatalf("diagnosis fields: %+v", p)
+	}
+}
+
+func TestNotifierSendsEveryAlert(t *testing.T) {
+	// Repeats are engine.Observers' to suppress, not the Notifier's.
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	starved := report.ProcMetrics{PID: 1, Comm: "a", Diagnosis: "Starved"}
+	n.OnDiagnosis(starved)
+	n.OnDiagnosis(starved)
+	n.OnDiagnosis(report.ProcMetrics{PID: 2, Comm: "b", Diagnosis: "Starved"})
+	n.Close()
+
+	if len(rec.payloads) != 3 {
+		t.Fatalf("got %d payloads, want 3: %+v", len(rec.payloads), rec.payloads)
+	}
+}
+
+func TestNotifierCloseIsBounded(t *testing.T) {
+	// An endpoint that does not answer until the test ends.
+	release := make(chan struct{})
+	hang := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	})
+	n := newTestNotifier(t, hang)
+	t.Cleanup(func() { close(release) }) // before the server is closed
+	n.closeTimeout = 50 * time.Millisecond
+	for pid := range uint32(10) {
+		n.OnDiagnosis(report.ProcMetrics{PID: pid, Diagnosis: "Starved"})
+	}
+	start := time.Now()
+	n.Close()
+	if took := time.Since(start); took > 5*time.Second {
+		t.Fatalf("Close took %v against a hung endpoint", took)
+	}
+}
+
+func TestNotifierRetriesServerErrors(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	n := newTestNotifier(t, rec)
+	n.OnDiagnosis(report.ProcMetrics{PID: 1, Diagnosis: "Starved"})
+	// Wait for delivery before closing, which would cut the retries short.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec.mu.Lock()
+		delivered := len(rec.payloads)
+		rec.mu.Unlock()
+		if delivered == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("alert was not delivered after retries")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	n.Close()
+	if rec.attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", rec.attempts)
+	}
+}
+
+func TestNotifierDoesNotRetryClientErrors(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusBadRequest}}
+	n := newTestNotifier(t, rec)
+	n.OnDiagnosis(report.ProcMetrics{PID: 1, Diagnosis: "Starved"})
+	n.Close()
+	if rec.attempts != 1 || len(rec.payloads) != 0 {
+		t.Fatalf("attempts = %d, payloads = %d; want 1 and 0", rec.attempts, len(rec.payloads))
+	}
+}